@@ -1382,6 +1382,14 @@ func (c *Config) getParserConfig(name string, tbl *ast.Table) (*parsers.Config,
 		}
 	}
 
+	//for syslog parser
+	c.getFieldString(tbl, "syslog_format", &pc.SyslogFormat)
+	c.getFieldString(tbl, "syslog_framing", &pc.SyslogFraming)
+	c.getFieldString(tbl, "syslog_trailer", &pc.SyslogTrailer)
+	c.getFieldBool(tbl, "syslog_best_effort", &pc.SyslogBestEffort)
+	c.getFieldString(tbl, "syslog_sdparam_separator", &pc.SyslogSeparator)
+	c.getFieldString(tbl, "syslog_timezone", &pc.SyslogTimezone)
+
 	pc.MetricName = name
 
 	if c.hasErrs() {
@@ -1406,6 +1414,7 @@ func (c *Config) buildSerializer(name string, tbl *ast.Table) (serializers.Seria
 	c.getFieldString(tbl, "prefix", &sc.Prefix)
 	c.getFieldString(tbl, "template", &sc.Template)
 	c.getFieldStringSlice(tbl, "templates", &sc.Templates)
+	c.getFieldString(tbl, "template_batch", &sc.TemplateBatch)
 	c.getFieldString(tbl, "carbon2_format", &sc.Carbon2Format)
 	c.getFieldString(tbl, "carbon2_sanitize_replace_char", &sc.Carbon2SanitizeReplaceChar)
 	c.getFieldInt(tbl, "influx_max_line_bytes", &sc.InfluxMaxLineBytes)
@@ -1427,6 +1436,12 @@ func (c *Config) buildSerializer(name string, tbl *ast.Table) (serializers.Seria
 	c.getFieldBool(tbl, "prometheus_sort_metrics", &sc.PrometheusSortMetrics)
 	c.getFieldBool(tbl, "prometheus_string_as_label", &sc.PrometheusStringAsLabel)
 
+	c.getFieldString(tbl, "csv_header", &sc.CSVHeader)
+	c.getFieldStringSlice(tbl, "csv_columns", &sc.CSVColumns)
+	c.getFieldString(tbl, "csv_timestamp_format", &sc.CSVTimestampFormat)
+	c.getFieldString(tbl, "csv_separator", &sc.CSVSeparator)
+	c.getFieldString(tbl, "csv_layout", &sc.CSVLayout)
+
 	if c.hasErrs() {
 		return nil, c.firstErr()
 	}
@@ -1471,8 +1486,9 @@ func (c *Config) missingTomlField(typ reflect.Type, key string) error {
 	switch key {
 	case "alias", "carbon2_format", "carbon2_sanitize_replace_char", "collectd_auth_file",
 		"collectd_parse_multivalue", "collectd_security_level", "collectd_typesdb", "collection_jitter",
-		"csv_column_names", "csv_column_types", "csv_comment", "csv_delimiter", "csv_header_row_count",
-		"csv_measurement_column", "csv_skip_columns", "csv_skip_rows", "csv_tag_columns",
+		"csv_column_names", "csv_column_types", "csv_columns", "csv_comment", "csv_delimiter",
+		"csv_header", "csv_header_row_count", "csv_layout", "csv_measurement_column", "csv_separator",
+		"csv_skip_columns", "csv_skip_rows", "csv_tag_columns",
 		"csv_timestamp_column", "csv_timestamp_format", "csv_timezone", "csv_trim_space", "csv_skip_values",
 		"data_format", "data_type", "delay", "drop", "drop_original", "dropwizard_metric_registry_path",
 		"dropwizard_tag_paths", "dropwizard_tags_path", "dropwizard_time_format", "dropwizard_time_path",
@@ -1485,8 +1501,10 @@ func (c *Config) missingTomlField(typ reflect.Type, key string) error {
 		"metric_batch_size", "metric_buffer_limit", "name_override", "name_prefix",
 		"name_suffix", "namedrop", "namepass", "order", "pass", "period", "precision",
 		"prefix", "prometheus_export_timestamp", "prometheus_sort_metrics", "prometheus_string_as_label",
-		"separator", "splunkmetric_hec_routing", "splunkmetric_multimetric", "tag_keys",
-		"tagdrop", "tagexclude", "taginclude", "tagpass", "tags", "template", "templates",
+		"separator", "splunkmetric_hec_routing", "splunkmetric_multimetric",
+		"syslog_best_effort", "syslog_format", "syslog_framing", "syslog_sdparam_separator",
+		"syslog_timezone", "syslog_trailer", "tag_keys",
+		"tagdrop", "tagexclude", "taginclude", "tagpass", "tags", "template", "template_batch", "templates",
 		"wavefront_source_override", "wavefront_use_strict", "xml":
 
 		// ignore fields that are common to all plugins.
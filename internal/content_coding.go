@@ -6,14 +6,61 @@ import (
 	"compress/gzip"
 	"errors"
 	"io"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
 )
 
+// DefaultMaxDecompressionSize is the default limit for the size of decoded
+// data, protecting against decompression bombs.
+const DefaultMaxDecompressionSize = 500 * 1024 * 1024
+
+// ErrDecompressionSizeExceeded is returned when the decoded data is larger
+// than the allowed size.
+var ErrDecompressionSizeExceeded = errors.New("size of decoded data exceeds allowed size")
+
+// DecodingOption configures a ContentDecoder.
+type DecodingOption func(*decoderConfig)
+
+type decoderConfig struct {
+	maxDecompressionSize int64
+}
+
+// WithMaxDecompressionSize limits the size of the decoded data, decoding
+// larger payloads fails with an error.
+func WithMaxDecompressionSize(n int64) DecodingOption {
+	return func(cfg *decoderConfig) {
+		cfg.maxDecompressionSize = n
+	}
+}
+
+func newDecoderConfig(options []DecodingOption) decoderConfig {
+	cfg := decoderConfig{maxDecompressionSize: DefaultMaxDecompressionSize}
+	for _, option := range options {
+		option(&cfg)
+	}
+	return cfg
+}
+
 // NewStreamContentDecoder returns a reader that will decode the stream
 // according to the encoding type.
 func NewStreamContentDecoder(encoding string, r io.Reader) (io.Reader, error) {
 	switch encoding {
 	case "gzip":
 		return NewGzipReader(r)
+	case "zstd":
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	case "x-snappy-framed":
+		return snappy.NewReader(r), nil
+	case "lz4":
+		return lz4.NewReader(r), nil
+	case "snappy":
+		return nil, errors.New("snappy block encoding is not supported for streams, use x-snappy-framed")
 	case "identity", "":
 		return r, nil
 	default:
@@ -73,6 +120,14 @@ func NewContentEncoder(encoding string) (ContentEncoder, error) {
 	switch encoding {
 	case "gzip":
 		return NewGzipEncoder()
+	case "zstd":
+		return NewZstdEncoder()
+	case "snappy":
+		return NewSnappyEncoder(), nil
+	case "x-snappy-framed":
+		return NewSnappyFramedEncoder(), nil
+	case "lz4":
+		return NewLZ4Encoder(), nil
 	case "identity", "":
 		return NewIdentityEncoder(), nil
 	default:
@@ -81,10 +136,18 @@ func NewContentEncoder(encoding string) (ContentEncoder, error) {
 }
 
 // NewContentDecoder returns a ContentDecoder for the encoding type.
-func NewContentDecoder(encoding string) (ContentDecoder, error) {
+func NewContentDecoder(encoding string, options ...DecodingOption) (ContentDecoder, error) {
 	switch encoding {
 	case "gzip":
-		return NewGzipDecoder()
+		return NewGzipDecoder(options...)
+	case "zstd":
+		return NewZstdDecoder(options...)
+	case "snappy":
+		return NewSnappyDecoder(options...), nil
+	case "x-snappy-framed":
+		return NewSnappyFramedDecoder(options...), nil
+	case "lz4":
+		return NewLZ4Decoder(options...), nil
 	case "identity", "":
 		return NewIdentityDecoder(), nil
 	default:
@@ -126,6 +189,97 @@ func (e *GzipEncoder) Encode(data []byte) ([]byte, error) {
 	return e.buf.Bytes(), nil
 }
 
+// ZstdEncoder compresses the buffer using zstd at the default level.
+type ZstdEncoder struct {
+	encoder *zstd.Encoder
+	buf     []byte
+}
+
+func NewZstdEncoder() (*ZstdEncoder, error) {
+	e, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ZstdEncoder{encoder: e}, nil
+}
+
+func (e *ZstdEncoder) Encode(data []byte) ([]byte, error) {
+	e.buf = e.encoder.EncodeAll(data, e.buf[:0])
+	return e.buf, nil
+}
+
+// SnappyEncoder compresses the buffer using the snappy block format.
+type SnappyEncoder struct {
+	buf []byte
+}
+
+func NewSnappyEncoder() *SnappyEncoder {
+	return &SnappyEncoder{}
+}
+
+func (e *SnappyEncoder) Encode(data []byte) ([]byte, error) {
+	e.buf = snappy.Encode(e.buf[:cap(e.buf)], data)
+	return e.buf, nil
+}
+
+// SnappyFramedEncoder compresses the buffer using the snappy framing format.
+type SnappyFramedEncoder struct {
+	writer *snappy.Writer
+	buf    *bytes.Buffer
+}
+
+func NewSnappyFramedEncoder() *SnappyFramedEncoder {
+	var buf bytes.Buffer
+	return &SnappyFramedEncoder{
+		writer: snappy.NewBufferedWriter(&buf),
+		buf:    &buf,
+	}
+}
+
+func (e *SnappyFramedEncoder) Encode(data []byte) ([]byte, error) {
+	e.buf.Reset()
+	e.writer.Reset(e.buf)
+
+	_, err := e.writer.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	err = e.writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// LZ4Encoder compresses the buffer using the lz4 frame format.
+type LZ4Encoder struct {
+	writer *lz4.Writer
+	buf    *bytes.Buffer
+}
+
+func NewLZ4Encoder() *LZ4Encoder {
+	var buf bytes.Buffer
+	return &LZ4Encoder{
+		writer: lz4.NewWriter(&buf),
+		buf:    &buf,
+	}
+}
+
+func (e *LZ4Encoder) Encode(data []byte) ([]byte, error) {
+	e.buf.Reset()
+	e.writer.Reset(e.buf)
+
+	_, err := e.writer.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	err = e.writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
 // IdentityEncoder is a null encoder that applies no transformation.
 type IdentityEncoder struct{}
 
@@ -142,25 +296,43 @@ type ContentDecoder interface {
 	Decode([]byte) ([]byte, error)
 }
 
+// readLimited reads r into buf failing if more than max bytes are available.
+func readLimited(buf *bytes.Buffer, r io.Reader, max int64) error {
+	n, err := buf.ReadFrom(io.LimitReader(r, max+1))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if n > max {
+		return ErrDecompressionSizeExceeded
+	}
+	return nil
+}
+
 // GzipDecoder decompresses buffers with gzip compression.
 type GzipDecoder struct {
-	reader *gzip.Reader
-	buf    *bytes.Buffer
+	reader  *gzip.Reader
+	buf     *bytes.Buffer
+	maxSize int64
 }
 
-func NewGzipDecoder() (*GzipDecoder, error) {
+func NewGzipDecoder(options ...DecodingOption) (*GzipDecoder, error) {
+	cfg := newDecoderConfig(options)
 	return &GzipDecoder{
-		reader: new(gzip.Reader),
-		buf:    new(bytes.Buffer),
+		reader:  new(gzip.Reader),
+		buf:     new(bytes.Buffer),
+		maxSize: cfg.maxDecompressionSize,
 	}, nil
 }
 
 func (d *GzipDecoder) Decode(data []byte) ([]byte, error) {
-	d.reader.Reset(bytes.NewBuffer(data))
+	err := d.reader.Reset(bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
 	d.buf.Reset()
 
-	_, err := d.buf.ReadFrom(d.reader)
-	if err != nil && err != io.EOF {
+	err = readLimited(d.buf, d.reader, d.maxSize)
+	if err != nil {
 		return nil, err
 	}
 	err = d.reader.Close()
@@ -170,6 +342,117 @@ func (d *GzipDecoder) Decode(data []byte) ([]byte, error) {
 	return d.buf.Bytes(), nil
 }
 
+// ZstdDecoder decompresses buffers with zstd compression.
+type ZstdDecoder struct {
+	decoder *zstd.Decoder
+	buf     []byte
+}
+
+func NewZstdDecoder(options ...DecodingOption) (*ZstdDecoder, error) {
+	cfg := newDecoderConfig(options)
+	d, err := zstd.NewReader(nil,
+		zstd.WithDecoderConcurrency(1),
+		zstd.WithDecoderMaxMemory(uint64(cfg.maxDecompressionSize)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ZstdDecoder{decoder: d}, nil
+}
+
+func (d *ZstdDecoder) Decode(data []byte) ([]byte, error) {
+	buf, err := d.decoder.DecodeAll(data, d.buf[:0])
+	if err == zstd.ErrDecoderSizeExceeded || err == zstd.ErrWindowSizeExceeded {
+		return nil, ErrDecompressionSizeExceeded
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.buf = buf
+	return d.buf, nil
+}
+
+// SnappyDecoder decompresses buffers in the snappy block format.
+type SnappyDecoder struct {
+	buf     []byte
+	maxSize int64
+}
+
+func NewSnappyDecoder(options ...DecodingOption) *SnappyDecoder {
+	cfg := newDecoderConfig(options)
+	return &SnappyDecoder{maxSize: cfg.maxDecompressionSize}
+}
+
+func (d *SnappyDecoder) Decode(data []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if int64(n) > d.maxSize {
+		return nil, ErrDecompressionSizeExceeded
+	}
+	buf, err := snappy.Decode(d.buf[:cap(d.buf)], data)
+	if err != nil {
+		return nil, err
+	}
+	d.buf = buf
+	return d.buf, nil
+}
+
+// SnappyFramedDecoder decompresses buffers in the snappy framing format.
+type SnappyFramedDecoder struct {
+	reader  *snappy.Reader
+	buf     *bytes.Buffer
+	maxSize int64
+}
+
+func NewSnappyFramedDecoder(options ...DecodingOption) *SnappyFramedDecoder {
+	cfg := newDecoderConfig(options)
+	return &SnappyFramedDecoder{
+		reader:  snappy.NewReader(nil),
+		buf:     new(bytes.Buffer),
+		maxSize: cfg.maxDecompressionSize,
+	}
+}
+
+func (d *SnappyFramedDecoder) Decode(data []byte) ([]byte, error) {
+	d.reader.Reset(bytes.NewReader(data))
+	d.buf.Reset()
+
+	err := readLimited(d.buf, d.reader, d.maxSize)
+	if err != nil {
+		return nil, err
+	}
+	return d.buf.Bytes(), nil
+}
+
+// LZ4Decoder decompresses buffers in the lz4 frame format.
+type LZ4Decoder struct {
+	reader  *lz4.Reader
+	buf     *bytes.Buffer
+	maxSize int64
+}
+
+func NewLZ4Decoder(options ...DecodingOption) *LZ4Decoder {
+	cfg := newDecoderConfig(options)
+	return &LZ4Decoder{
+		reader:  lz4.NewReader(nil),
+		buf:     new(bytes.Buffer),
+		maxSize: cfg.maxDecompressionSize,
+	}
+}
+
+func (d *LZ4Decoder) Decode(data []byte) ([]byte, error) {
+	d.reader.Reset(bytes.NewReader(data))
+	d.buf.Reset()
+
+	err := readLimited(d.buf, d.reader, d.maxSize)
+	if err != nil {
+		return nil, err
+	}
+	return d.buf.Bytes(), nil
+}
+
 // IdentityDecoder is a null decoder that returns the input.
 type IdentityDecoder struct{}
 
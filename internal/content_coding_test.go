@@ -92,3 +92,70 @@ func TestStreamGzipDecode(t *testing.T) {
 
 	require.Equal(t, []byte("howdy"), b[:n])
 }
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, encoding := range []string{"gzip", "zstd", "snappy", "x-snappy-framed", "lz4", "identity"} {
+		t.Run(encoding, func(t *testing.T) {
+			enc, err := NewContentEncoder(encoding)
+			require.NoError(t, err)
+			dec, err := NewContentDecoder(encoding)
+			require.NoError(t, err)
+
+			for _, payload := range []string{"howdy", "doody"} {
+				encoded, err := enc.Encode([]byte(payload))
+				require.NoError(t, err)
+
+				actual, err := dec.Decode(encoded)
+				require.NoError(t, err)
+				require.Equal(t, payload, string(actual))
+			}
+		})
+	}
+}
+
+func TestDecodeMaxDecompressionSize(t *testing.T) {
+	data := bytes.Repeat([]byte("howdy"), 1000)
+	for _, encoding := range []string{"gzip", "zstd", "snappy", "x-snappy-framed", "lz4"} {
+		t.Run(encoding, func(t *testing.T) {
+			enc, err := NewContentEncoder(encoding)
+			require.NoError(t, err)
+			encoded, err := enc.Encode(data)
+			require.NoError(t, err)
+
+			dec, err := NewContentDecoder(encoding, WithMaxDecompressionSize(int64(len(data))))
+			require.NoError(t, err)
+			actual, err := dec.Decode(encoded)
+			require.NoError(t, err)
+			require.Equal(t, data, actual)
+
+			dec, err = NewContentDecoder(encoding, WithMaxDecompressionSize(int64(len(data)-1)))
+			require.NoError(t, err)
+			_, err = dec.Decode(encoded)
+			require.Equal(t, ErrDecompressionSizeExceeded, err)
+		})
+	}
+}
+
+func TestStreamDecode(t *testing.T) {
+	for _, encoding := range []string{"zstd", "x-snappy-framed", "lz4"} {
+		t.Run(encoding, func(t *testing.T) {
+			var buf bytes.Buffer
+			for _, payload := range []string{"howdy\n", "doody\n"} {
+				enc, err := NewContentEncoder(encoding)
+				require.NoError(t, err)
+				encoded, err := enc.Encode([]byte(payload))
+				require.NoError(t, err)
+				buf.Write(encoded)
+			}
+
+			dec, err := NewStreamContentDecoder(encoding, &buf)
+			require.NoError(t, err)
+			data, err := ioutil.ReadAll(dec)
+			require.NoError(t, err)
+			require.Equal(t, "howdy\ndoody\n", string(data))
+		})
+	}
+
+	_, err := NewStreamContentDecoder("snappy", &bytes.Buffer{})
+	require.Error(t, err)
+}
@@ -88,6 +88,14 @@ func (w *FileWriter) Write(p []byte) (n int, err error) {
 	return n, nil
 }
 
+// Size returns the number of bytes in the current file, it is 0 after the
+// file was rotated.
+func (w *FileWriter) Size() int64 {
+	w.Lock()
+	defer w.Unlock()
+	return w.bytesWritten
+}
+
 // Close closes the current file.  Writer is unusable after this
 // is called.
 func (w *FileWriter) Close() (err error) {
@@ -85,7 +85,7 @@ func parseURL(uri string) (string, error) {
 	return strings.Join(kvs, " "), nil
 }
 
-// Service common functionality shared between the postgresql and postgresql_extensible
+// Service common functionality shared between the postgresql inputs and output
 // packages.
 type Service struct {
 	Address       string
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -69,8 +70,11 @@ var sampleConfig = `
   ## HTTP entity-body to send with POST/PUT requests.
   # body = ""
 
-  ## HTTP Content-Encoding for write request body, can be set to "gzip" to
-  ## compress body or "identity" to apply no encoding.
+  ## HTTP Content-Encoding for write request body, can be set to "gzip",
+  ## "zstd", "snappy", "x-snappy-framed" or "lz4" to compress body or
+  ## "identity" to apply no encoding.  Responses are decoded according to
+  ## their Content-Encoding header, responses of other encodings are parsed
+  ## as is.
   # content_encoding = "identity"
 
   ## HTTP Proxy support
@@ -107,6 +111,10 @@ func (*HTTP) Description() string {
 }
 
 func (h *HTTP) Init() error {
+	if _, err := internal.NewContentEncoder(h.ContentEncoding); err != nil {
+		return err
+	}
+
 	tlsCfg, err := h.ClientConfig.TLSConfig()
 	if err != nil {
 		return err
@@ -189,8 +197,8 @@ func (h *HTTP) gatherURL(
 		request.Header.Set("Authorization", bearer)
 	}
 
-	if h.ContentEncoding == "gzip" {
-		request.Header.Set("Content-Encoding", "gzip")
+	if h.ContentEncoding != "" && h.ContentEncoding != "identity" {
+		request.Header.Set("Content-Encoding", h.ContentEncoding)
 	}
 
 	for k, v := range h.Headers {
@@ -231,6 +239,16 @@ func (h *HTTP) gatherURL(
 		return err
 	}
 
+	// The transport only removes the gzip encoding if it requested it, any
+	// other encoding is left to us.  Bodies of unknown encodings are passed
+	// to the parser as is.
+	if decoder, err := internal.NewContentDecoder(resp.Header.Get("Content-Encoding")); err == nil {
+		b, err = decoder.Decode(b)
+		if err != nil {
+			return err
+		}
+	}
+
 	metrics, err := h.parser.Parse(b)
 	if err != nil {
 		return err
@@ -248,14 +266,26 @@ func (h *HTTP) gatherURL(
 
 func makeRequestBodyReader(contentEncoding, body string) (io.ReadCloser, error) {
 	var reader io.Reader = strings.NewReader(body)
-	if contentEncoding == "gzip" {
+	switch contentEncoding {
+	case "gzip":
 		rc, err := internal.CompressWithGzip(reader)
 		if err != nil {
 			return nil, err
 		}
 		return rc, nil
+	case "identity", "":
+		return ioutil.NopCloser(reader), nil
+	}
+
+	encoder, err := internal.NewContentEncoder(contentEncoding)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encoder.Encode([]byte(body))
+	if err != nil {
+		return nil, err
 	}
-	return ioutil.NopCloser(reader), nil
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
 }
 
 func init() {
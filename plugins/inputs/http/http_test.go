@@ -7,7 +7,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	plugin "github.com/influxdata/telegraf/plugins/inputs/http"
 	"github.com/influxdata/telegraf/plugins/parsers"
 	"github.com/influxdata/telegraf/testutil"
@@ -233,6 +236,27 @@ func TestBodyAndContentEncoding(t *testing.T) {
 				w.WriteHeader(http.StatusOK)
 			},
 		},
+		{
+			name: "zstd encoding",
+			plugin: &plugin.HTTP{
+				URLs:            []string{url},
+				Method:          "POST",
+				Body:            "test",
+				ContentEncoding: "zstd",
+			},
+			queryHandlerFunc: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, r.Header.Get("Content-Encoding"), "zstd")
+
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				dec, err := internal.NewContentDecoder("zstd")
+				require.NoError(t, err)
+				body, err = dec.Decode(body)
+				require.NoError(t, err)
+				require.Equal(t, []byte("test"), body)
+				w.WriteHeader(http.StatusOK)
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -252,3 +276,43 @@ func TestBodyAndContentEncoding(t *testing.T) {
 		})
 	}
 }
+
+func TestResponseContentEncoding(t *testing.T) {
+	for _, encoding := range []string{"zstd", "snappy", "x-snappy-framed", "lz4", "x-unknown"} {
+		t.Run(encoding, func(t *testing.T) {
+			// Unknown encodings are passed through undecoded.
+			payload := []byte("cpu value=42 1600000000000000000\n")
+			if enc, err := internal.NewContentEncoder(encoding); err == nil {
+				payload, err = enc.Encode(payload)
+				require.NoError(t, err)
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", encoding)
+				_, _ = w.Write(payload)
+			}))
+			defer ts.Close()
+
+			plugin := &plugin.HTTP{
+				URLs: []string{ts.URL},
+			}
+			parser, err := parsers.NewParser(&parsers.Config{DataFormat: "influx"})
+			require.NoError(t, err)
+			plugin.SetParser(parser)
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, acc.GatherError(plugin.Gather))
+
+			expected := []telegraf.Metric{
+				testutil.MustMetric(
+					"cpu",
+					map[string]string{"url": ts.URL},
+					map[string]interface{}{"value": 42.0},
+					time.Unix(1600000000, 0),
+				),
+			}
+			testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package http_listener_v2
 
 import (
-	"compress/gzip"
 	"crypto/subtle"
 	"crypto/tls"
 	"io/ioutil"
@@ -73,7 +72,8 @@ const sampleConfig = `
   ## maximum duration before timing out write of the response
   # write_timeout = "10s"
 
-  ## Maximum allowed http request body size in bytes.
+  ## Maximum allowed http request body size in bytes, the limit applies to
+  ## the request body before and after removing its Content-Encoding.
   ## 0 means to use the default of 524,288,00 bytes (500 mebibytes)
   # max_body_size = "500MB"
 
@@ -247,27 +247,32 @@ func (h *HTTPListenerV2) serveWrite(res http.ResponseWriter, req *http.Request)
 }
 
 func (h *HTTPListenerV2) collectBody(res http.ResponseWriter, req *http.Request) ([]byte, bool) {
-	body := req.Body
-
-	// Handle gzip request bodies
-	if req.Header.Get("Content-Encoding") == "gzip" {
-		var err error
-		body, err = gzip.NewReader(req.Body)
-		if err != nil {
-			h.Log.Debug(err.Error())
-			badRequest(res)
-			return nil, false
-		}
-		defer body.Close()
+	decoder, err := internal.NewContentDecoder(req.Header.Get("Content-Encoding"),
+		internal.WithMaxDecompressionSize(h.MaxBodySize.Size))
+	if err != nil {
+		h.Log.Debug(err.Error())
+		badRequest(res)
+		return nil, false
 	}
 
-	body = http.MaxBytesReader(res, body, h.MaxBodySize.Size)
+	body := http.MaxBytesReader(res, req.Body, h.MaxBodySize.Size)
 	bytes, err := ioutil.ReadAll(body)
 	if err != nil {
 		tooLarge(res)
 		return nil, false
 	}
 
+	bytes, err = decoder.Decode(bytes)
+	if err == internal.ErrDecompressionSizeExceeded {
+		tooLarge(res)
+		return nil, false
+	}
+	if err != nil {
+		h.Log.Debug(err.Error())
+		badRequest(res)
+		return nil, false
+	}
+
 	return bytes, true
 }
 
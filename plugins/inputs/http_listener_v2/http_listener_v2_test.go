@@ -327,6 +327,64 @@ func TestWriteHTTPGzippedData(t *testing.T) {
 	}
 }
 
+// test that all supported content encodings are decoded
+func TestWriteHTTPEncodedData(t *testing.T) {
+	for _, encoding := range []string{"zstd", "snappy", "x-snappy-framed", "lz4"} {
+		t.Run(encoding, func(t *testing.T) {
+			listener := newTestHTTPListenerV2()
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			enc, err := internal.NewContentEncoder(encoding)
+			require.NoError(t, err)
+			data, err := enc.Encode([]byte(testMsg))
+			require.NoError(t, err)
+
+			req, err := http.NewRequest("POST", createURL(listener, "http", "/write", ""), bytes.NewBuffer(data))
+			require.NoError(t, err)
+			req.Header.Set("Content-Encoding", encoding)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, 204, resp.StatusCode)
+
+			acc.Wait(1)
+			acc.AssertContainsTaggedFields(t, "cpu_load_short",
+				map[string]interface{}{"value": float64(12)},
+				map[string]string{"host": "server01"},
+			)
+		})
+	}
+}
+
+// test that the body size limit applies to the decoded body
+func TestWriteHTTPDecodedBodyTooLarge(t *testing.T) {
+	listener := newTestHTTPListenerV2()
+	listener.MaxBodySize = internal.Size{Size: 4096}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	enc, err := internal.NewContentEncoder("zstd")
+	require.NoError(t, err)
+	data, err := enc.Encode([]byte(hugeMetric))
+	require.NoError(t, err)
+	require.True(t, len(data) < 4096)
+
+	req, err := http.NewRequest("POST", createURL(listener, "http", "/write", ""), bytes.NewBuffer(data))
+	require.NoError(t, err)
+	req.Header.Set("Content-Encoding", "zstd")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 413, resp.StatusCode)
+}
+
 // writes 25,000 metrics to the listener with 10 different writers
 func TestWriteHTTPHighTraffic(t *testing.T) {
 	if runtime.GOOS == "darwin" {
@@ -0,0 +1,414 @@
+package journald
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/parsers"
+)
+
+const sampleConfig = `
+  ## Units to read the entries of, all entries are read if empty.
+  # units = ["nginx.service", "sshd.service"]
+
+  ## Read the entries of this priority or a more important one, one of
+  ## "emerg", "alert", "crit", "err", "warning", "notice", "info" or "debug".
+  # priority = "debug"
+
+  ## Additional matches of journal fields as "FIELD=VALUE", see journalctl(1).
+  ## Matches of different fields must all match, matches of the same field
+  ## any of them.
+  # matches = ["_TRANSPORT=kernel"]
+
+  ## Directory of the journal files to read instead of the system journal.
+  # directory = "/var/log/journal"
+
+  ## File the position of the last entry read is stored in, to continue with
+  ## the next entry when Telegraf is restarted.  Without a cursor file or
+  ## stored position reading starts at the end of the journal.
+  # cursor_file = "/var/lib/telegraf/journald.cursor"
+
+  ## Read the entries already in the journal if there is no stored position.
+  # from_beginning = false
+
+  ## Fields of the entries added as tags and as fields; globs are supported.
+  ## The names are lowercased and leading underscores removed, for example
+  ## "_SYSTEMD_UNIT" becomes "systemd_unit".
+  # tag_fields = ["_SYSTEMD_UNIT", "SYSLOG_IDENTIFIER", "PRIORITY"]
+  # fields = ["MESSAGE"]
+
+  ## Pass the MESSAGE of the entries to the parser of data_format instead of
+  ## adding the entries as metrics.  The tag_fields are added to the parsed
+  ## metrics.
+  # parse_message = false
+
+  ## Data format of the messages if parse_message is enabled.
+  ## Each data format has its own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_INPUT.md
+  # data_format = "influx"
+
+  ## Delay before journalctl is restarted after an unexpected termination.
+  # restart_delay = "10s"
+`
+
+var priorities = map[string]bool{
+	"emerg": true, "alert": true, "crit": true, "err": true,
+	"warning": true, "notice": true, "info": true, "debug": true,
+}
+
+type Journald struct {
+	Units         []string        `toml:"units"`
+	Priority      string          `toml:"priority"`
+	Matches       []string        `toml:"matches"`
+	Directory     string          `toml:"directory"`
+	CursorFile    string          `toml:"cursor_file"`
+	FromBeginning bool            `toml:"from_beginning"`
+	TagFields     []string        `toml:"tag_fields"`
+	Fields        []string        `toml:"fields"`
+	ParseMessage  bool            `toml:"parse_message"`
+	RestartDelay  config.Duration `toml:"restart_delay"`
+	Log           telegraf.Logger `toml:"-"`
+
+	// journalctl is the command run to follow the journal.
+	journalctl string
+
+	tagFilter   filter.Filter
+	fieldFilter filter.Filter
+	parser      parsers.Parser
+	acc         telegraf.Accumulator
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu           sync.Mutex
+	cursor       string
+	storedCursor string
+}
+
+func (j *Journald) SampleConfig() string {
+	return sampleConfig
+}
+
+func (j *Journald) Description() string {
+	return "Read entries from the systemd journal"
+}
+
+func (j *Journald) SetParser(parser parsers.Parser) {
+	j.parser = parser
+}
+
+func (j *Journald) Init() error {
+	if j.Priority != "" && !priorities[j.Priority] {
+		return fmt.Errorf("invalid priority %q", j.Priority)
+	}
+	for _, match := range j.Matches {
+		if !strings.Contains(match, "=") {
+			return fmt.Errorf("invalid match %q, expected FIELD=VALUE", match)
+		}
+	}
+	if j.ParseMessage && j.parser == nil {
+		return errors.New("parse_message requires a data_format")
+	}
+
+	var err error
+	if j.tagFilter, err = filter.Compile(j.TagFields); err != nil {
+		return fmt.Errorf("invalid tag_fields: %v", err)
+	}
+	if j.fieldFilter, err = filter.Compile(j.Fields); err != nil {
+		return fmt.Errorf("invalid fields: %v", err)
+	}
+
+	if j.CursorFile != "" {
+		data, err := ioutil.ReadFile(j.CursorFile)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("reading cursor file failed: %v", err)
+		}
+		j.cursor = strings.TrimSpace(string(data))
+		j.storedCursor = j.cursor
+	}
+	return nil
+}
+
+func (j *Journald) Start(acc telegraf.Accumulator) error {
+	j.acc = acc
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		j.follow(ctx)
+	}()
+	return nil
+}
+
+func (j *Journald) Stop() {
+	if j.cancel != nil {
+		j.cancel()
+	}
+	j.wg.Wait()
+
+	if err := j.storeCursor(); err != nil {
+		j.Log.Errorf("Storing cursor failed: %v", err)
+	}
+}
+
+// Gather stores the position of the last entry read, the entries themselves
+// are added as they are read.
+func (j *Journald) Gather(acc telegraf.Accumulator) error {
+	return j.storeCursor()
+}
+
+// args returns the arguments of journalctl, following the journal after the
+// last entry read.
+func (j *Journald) args() []string {
+	args := []string{"--follow", "--output=json", "--no-pager"}
+	if j.Directory != "" {
+		args = append(args, "--directory="+j.Directory)
+	}
+	if j.Priority != "" {
+		args = append(args, "--priority="+j.Priority)
+	}
+	for _, unit := range j.Units {
+		args = append(args, "--unit="+unit)
+	}
+
+	j.mu.Lock()
+	cursor := j.cursor
+	j.mu.Unlock()
+	switch {
+	case cursor != "":
+		args = append(args, "--after-cursor="+cursor, "--no-tail")
+	case j.FromBeginning:
+		args = append(args, "--no-tail")
+	default:
+		args = append(args, "--lines=0")
+	}
+	return append(args, j.Matches...)
+}
+
+// follow runs journalctl until the plugin is stopped, restarting it after
+// the restart delay if it exits.
+func (j *Journald) follow(ctx context.Context) {
+	for {
+		err := j.run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		j.Log.Errorf("journalctl exited: %v, restarting in %s", err, time.Duration(j.RestartDelay))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(j.RestartDelay)):
+		}
+	}
+}
+
+func (j *Journald) run(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, j.journalctl, j.args()...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			j.Log.Errorf("stderr: %q", scanner.Text())
+		}
+	}()
+
+	j.read(stdout)
+	<-done
+	return cmd.Wait()
+}
+
+// read adds the entries printed by journalctl, one JSON object per line.
+func (j *Journald) read(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		entry, err := parseEntry(scanner.Bytes())
+		if err != nil {
+			j.acc.AddError(err)
+			continue
+		}
+		j.addEntry(entry)
+
+		if cursor := entry["__CURSOR"]; cursor != "" {
+			j.mu.Lock()
+			j.cursor = cursor
+			j.mu.Unlock()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		j.acc.AddError(fmt.Errorf("reading journal failed: %v", err))
+	}
+}
+
+func (j *Journald) addEntry(entry map[string]string) {
+	tm := time.Now()
+	if usec, err := strconv.ParseInt(entry["__REALTIME_TIMESTAMP"], 10, 64); err == nil {
+		tm = time.Unix(0, usec*int64(time.Microsecond))
+	}
+
+	tags := make(map[string]string)
+	fields := make(map[string]interface{})
+	for name, value := range entry {
+		// Fields starting with a double underscore are addresses and
+		// timestamps of the entry.
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		if j.tagFilter != nil && j.tagFilter.Match(name) {
+			tags[fieldName(name)] = value
+		}
+		if !j.ParseMessage && j.fieldFilter != nil && j.fieldFilter.Match(name) {
+			fields[fieldName(name)] = value
+		}
+	}
+
+	if !j.ParseMessage {
+		if len(fields) > 0 {
+			j.acc.AddFields("journald", fields, tags, tm)
+		}
+		return
+	}
+
+	metrics, err := j.parser.Parse([]byte(entry["MESSAGE"]))
+	if err != nil {
+		j.acc.AddError(fmt.Errorf("parsing message failed: %v", err))
+		return
+	}
+	for _, m := range metrics {
+		for k, v := range tags {
+			m.AddTag(k, v)
+		}
+		j.acc.AddMetric(m)
+	}
+}
+
+// storeCursor writes the cursor of the last entry read to the cursor file.
+func (j *Journald) storeCursor() error {
+	if j.CursorFile == "" {
+		return nil
+	}
+
+	j.mu.Lock()
+	cursor := j.cursor
+	j.mu.Unlock()
+	if cursor == "" || cursor == j.storedCursor {
+		return nil
+	}
+
+	// Replace the file atomically to not lose the position if Telegraf is
+	// interrupted while writing.
+	tmp, err := ioutil.TempFile(filepath.Dir(j.CursorFile), filepath.Base(j.CursorFile))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(cursor + "\n"); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), j.CursorFile); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	j.storedCursor = cursor
+	return nil
+}
+
+// parseEntry parses an entry of the JSON output of journalctl.  Values are
+// strings, arrays of bytes for binary values or arrays of those for fields
+// with multiple values, of which the first one is used.
+func parseEntry(data []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid journal entry: %v", err)
+	}
+
+	entry := make(map[string]string, len(raw))
+	for name, value := range raw {
+		if v, ok := parseValue(value); ok {
+			entry[name] = v
+		}
+	}
+	return entry, nil
+}
+
+func parseValue(value json.RawMessage) (string, bool) {
+	// Values too large to be printed are null.
+	if string(value) == "null" {
+		return "", false
+	}
+
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s, true
+	}
+
+	var b []byte
+	var raw []json.RawMessage
+	if err := json.Unmarshal(value, &raw); err != nil || len(raw) == 0 {
+		return "", false
+	}
+	for _, element := range raw {
+		var n int
+		if err := json.Unmarshal(element, &n); err != nil {
+			// An array of values, use the first one.
+			return parseValue(raw[0])
+		}
+		b = append(b, byte(n))
+	}
+	return string(b), true
+}
+
+// fieldName returns the name of a journal field in metrics.
+func fieldName(name string) string {
+	return strings.ToLower(strings.TrimLeft(name, "_"))
+}
+
+func init() {
+	inputs.Add("journald", func() telegraf.Input {
+		return &Journald{
+			TagFields:    []string{"_SYSTEMD_UNIT", "SYSLOG_IDENTIFIER", "PRIORITY"},
+			Fields:       []string{"MESSAGE"},
+			RestartDelay: config.Duration(10 * time.Second),
+			journalctl:   "journalctl",
+		}
+	})
+}
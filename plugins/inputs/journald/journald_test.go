@@ -0,0 +1,156 @@
+// +build !windows
+
+package journald
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+// fakeJournalctl creates a script printing the entries of testdata and
+// recording its arguments in the returned file.
+func fakeJournalctl(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	entries, err := filepath.Abs("testdata/entries.json")
+	require.NoError(t, err)
+
+	argsFile := filepath.Join(dir, "args")
+	script := filepath.Join(dir, "journalctl")
+	content := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\ncat %s\n", argsFile, entries)
+	require.NoError(t, ioutil.WriteFile(script, []byte(content), 0755))
+	return script, argsFile
+}
+
+func newJournald(t *testing.T, journalctl string) *Journald {
+	return &Journald{
+		TagFields:    []string{"_SYSTEMD_UNIT", "SYSLOG_IDENTIFIER", "PRIORITY"},
+		Fields:       []string{"MESSAGE", "_PID"},
+		RestartDelay: config.Duration(time.Hour),
+		Log:          testutil.Logger{},
+		journalctl:   journalctl,
+	}
+}
+
+func TestJournald(t *testing.T) {
+	journalctl, argsFile := fakeJournalctl(t)
+	cursorFile := filepath.Join(t.TempDir(), "journald.cursor")
+
+	j := newJournald(t, journalctl)
+	j.Units = []string{"nginx.service", "sshd.service"}
+	j.Priority = "warning"
+	j.Matches = []string{"_TRANSPORT=journal"}
+	j.CursorFile = cursorFile
+	require.NoError(t, j.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, j.Start(&acc))
+	acc.Wait(3)
+	j.Stop()
+
+	args, err := ioutil.ReadFile(argsFile)
+	require.NoError(t, err)
+	require.Equal(t, "--follow --output=json --no-pager --priority=warning --unit=nginx.service --unit=sshd.service --lines=0 _TRANSPORT=journal",
+		strings.TrimSpace(string(args)))
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("journald",
+			map[string]string{"systemd_unit": "nginx.service", "syslog_identifier": "nginx", "priority": "6"},
+			map[string]interface{}{"message": "request_time,path=/ value=0.12", "pid": "812"},
+			time.Unix(1622548800, 0)),
+		testutil.MustMetric("journald",
+			map[string]string{"systemd_unit": "nginx.service", "syslog_identifier": "nginx", "priority": "3"},
+			map[string]interface{}{"message": "request_time value=1.5", "pid": "812"},
+			time.Unix(1622548801, 0)),
+		testutil.MustMetric("journald",
+			map[string]string{"systemd_unit": "sshd.service", "syslog_identifier": "sshd", "priority": "4"},
+			map[string]interface{}{"message": "disk value=3i", "pid": "900"},
+			time.Unix(1622548802, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+
+	// The cursor of the last entry is stored and used after a restart.
+	cursor, err := ioutil.ReadFile(cursorFile)
+	require.NoError(t, err)
+	require.Equal(t, "s=6e2b4d5a;i=1a2d;b=9f1c;m=3c1021;t=5c3b9e1a4d4a0;x=3\n", string(cursor))
+
+	j = newJournald(t, journalctl)
+	j.CursorFile = cursorFile
+	require.NoError(t, j.Init())
+	require.Contains(t, j.args(), "--after-cursor=s=6e2b4d5a;i=1a2d;b=9f1c;m=3c1021;t=5c3b9e1a4d4a0;x=3")
+	require.Contains(t, j.args(), "--no-tail")
+}
+
+func TestJournaldParseMessage(t *testing.T) {
+	journalctl, _ := fakeJournalctl(t)
+
+	j := newJournald(t, journalctl)
+	j.TagFields = []string{"_SYSTEMD_UNIT"}
+	j.ParseMessage = true
+	j.SetParser(influx.NewParser(influx.NewMetricHandler()))
+	require.NoError(t, j.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, j.Start(&acc))
+	acc.Wait(3)
+	j.Stop()
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("request_time",
+			map[string]string{"path": "/", "systemd_unit": "nginx.service"},
+			map[string]interface{}{"value": 0.12},
+			time.Unix(0, 0)),
+		testutil.MustMetric("request_time",
+			map[string]string{"systemd_unit": "nginx.service"},
+			map[string]interface{}{"value": 1.5},
+			time.Unix(0, 0)),
+		testutil.MustMetric("disk",
+			map[string]string{"systemd_unit": "sshd.service"},
+			map[string]interface{}{"value": int64(3)},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+func TestJournaldArgs(t *testing.T) {
+	j := newJournald(t, "journalctl")
+	j.Directory = "/var/log/journal"
+	j.FromBeginning = true
+	require.NoError(t, j.Init())
+	require.Equal(t, []string{"--follow", "--output=json", "--no-pager", "--directory=/var/log/journal", "--no-tail"}, j.args())
+}
+
+func TestJournaldInit(t *testing.T) {
+	tests := []struct {
+		name string
+		j    *Journald
+	}{
+		{"invalid priority", &Journald{Priority: "verbose"}},
+		{"invalid match", &Journald{Matches: []string{"_TRANSPORT"}}},
+		{"no parser", &Journald{ParseMessage: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, tt.j.Init())
+		})
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	entry, err := parseEntry([]byte(`{"A":"text","B":[104,105],"C":["x","y"],"D":[[104,105],[104,111]],"E":null,"F":[]}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"A": "text", "B": "hi", "C": "x", "D": "hi"}, entry)
+
+	_, err = parseEntry([]byte(`{"A":`))
+	require.Error(t, err)
+}
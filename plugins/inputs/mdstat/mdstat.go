@@ -0,0 +1,204 @@
+package mdstat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+type Mdstat struct {
+	FileName string          `toml:"file_name"`
+	Log      telegraf.Logger `toml:"-"`
+
+	mdstatPath string
+}
+
+const sampleConfig = `
+  ## Path of the mdstat file, defaults to $HOST_PROC/mdstat or /proc/mdstat.
+  # file_name = "/proc/mdstat"
+`
+
+var (
+	// disksRE matches the number of disks of an array and the ones in sync,
+	// "[3/2]".
+	disksRE = regexp.MustCompile(`\[(\d+)/(\d+)\]`)
+
+	// progressRE matches the progress of a resync, recovery, check or
+	// reshape:
+	// [==>......]  recovery = 12.6% (246944640/1953382400) finish=152.3min speed=186700K/sec
+	progressRE = regexp.MustCompile(`(\w+)\s*=\s*([\d.]+)%\s*\((\d+)/(\d+)\)\s*finish\s*=\s*([\d.]+)min\s*speed\s*=\s*(\d+)K/sec`)
+
+	// pendingRE matches a sync waiting to start, "resync=DELAYED".
+	pendingRE = regexp.MustCompile(`(\w+)\s*=\s*(DELAYED|PENDING)`)
+)
+
+func (m *Mdstat) SampleConfig() string {
+	return sampleConfig
+}
+
+func (m *Mdstat) Description() string {
+	return "Read the state of Linux software RAID arrays from /proc/mdstat"
+}
+
+func (m *Mdstat) getMdstatPath() string {
+	path := "/proc/mdstat"
+	if m.FileName != "" {
+		path = m.FileName
+	} else if os.Getenv("HOST_PROC") != "" {
+		path = filepath.Join(os.Getenv("HOST_PROC"), "mdstat")
+	}
+	m.Log.Debugf("using [%s] for mdstat", path)
+	return path
+}
+
+func (m *Mdstat) Init() error {
+	m.mdstatPath = m.getMdstatPath()
+	return nil
+}
+
+func (m *Mdstat) Gather(acc telegraf.Accumulator) error {
+	file, err := os.Open(m.mdstatPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return processText(file, acc)
+}
+
+// array is the state of an array while parsing its lines.
+type array struct {
+	tags   map[string]string
+	fields map[string]interface{}
+}
+
+// processText parses the arrays of mdstat, each starting with a line like
+//   md2 : active raid5 sdd1[3] sdc1[1] sdb2[0] sde1[4](F)
+// followed by indented lines with the size, disks and sync progress.
+func processText(r io.Reader, acc telegraf.Accumulator) error {
+	var current *array
+	flush := func() {
+		if current != nil {
+			acc.AddFields("mdstat", current.fields, current.tags)
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "Personalities") || strings.HasPrefix(trimmed, "unused devices"):
+			flush()
+		case line[0] != ' ' && line[0] != '\t':
+			flush()
+			a, err := parseArray(trimmed)
+			if err != nil {
+				return err
+			}
+			current = a
+		case current != nil:
+			parseStatus(trimmed, current)
+		}
+	}
+	flush()
+	return scanner.Err()
+}
+
+// parseArray parses the line naming an array, its state, level and devices.
+func parseArray(line string) (*array, error) {
+	parts := strings.Fields(line)
+	if len(parts) < 3 || parts[1] != ":" {
+		return nil, fmt.Errorf("invalid array line %q", line)
+	}
+
+	a := &array{
+		tags: map[string]string{
+			"name":           parts[0],
+			"activity_state": parts[2],
+		},
+		fields: map[string]interface{}{
+			"read_only":   false,
+			"sync_action": "idle",
+		},
+	}
+
+	var devices []string
+	var failed, spare int64
+	for _, part := range parts[3:] {
+		switch {
+		case part == "(read-only)" || part == "(auto-read-only)":
+			a.fields["read_only"] = true
+		case strings.Contains(part, "["):
+			devices = append(devices, part[:strings.Index(part, "[")])
+			if strings.HasSuffix(part, "(F)") {
+				failed++
+			} else if strings.HasSuffix(part, "(S)") {
+				spare++
+			}
+		default:
+			a.tags["level"] = part
+		}
+	}
+	a.tags["devices"] = strings.Join(devices, ",")
+
+	// Arrays without redundancy lack the number of disks in the status, all
+	// the disks which are neither failed nor spare are active.
+	total := int64(len(devices)) - spare
+	a.fields["disks_total"] = total
+	a.fields["disks_active"] = total - failed
+	a.fields["disks_down"] = failed
+	a.fields["disks_failed"] = failed
+	a.fields["disks_spare"] = spare
+	return a, nil
+}
+
+// parseStatus parses the indented lines of an array.
+func parseStatus(line string, a *array) {
+	if strings.Contains(line, " blocks") {
+		parts := strings.Fields(line)
+		if v, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
+			a.fields["blocks_total"] = v
+		}
+		if match := disksRE.FindStringSubmatch(line); match != nil {
+			total, _ := strconv.ParseInt(match[1], 10, 64)
+			active, _ := strconv.ParseInt(match[2], 10, 64)
+			a.fields["disks_total"] = total
+			a.fields["disks_active"] = active
+			a.fields["disks_down"] = total - active
+		}
+		return
+	}
+
+	if match := progressRE.FindStringSubmatch(line); match != nil {
+		a.fields["sync_action"] = match[1]
+		a.fields["blocks_synced_pct"], _ = strconv.ParseFloat(match[2], 64)
+		a.fields["blocks_synced"], _ = strconv.ParseInt(match[3], 10, 64)
+		a.fields["blocks_synced_finish_time"], _ = strconv.ParseFloat(match[5], 64)
+		a.fields["blocks_synced_speed"], _ = strconv.ParseInt(match[6], 10, 64)
+		return
+	}
+
+	if match := pendingRE.FindStringSubmatch(line); match != nil {
+		a.fields["sync_action"] = match[1]
+		a.fields["blocks_synced_pct"] = 0.0
+		a.fields["blocks_synced"] = int64(0)
+	}
+}
+
+func init() {
+	inputs.Add("mdstat", func() telegraf.Input {
+		return &Mdstat{}
+	})
+}
@@ -0,0 +1,134 @@
+package mdstat
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestMdstatGather(t *testing.T) {
+	m := &Mdstat{
+		FileName: "testdata/mdstat",
+		Log:      testutil.Logger{},
+	}
+	require.NoError(t, m.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(m.Gather))
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("mdstat",
+			map[string]string{"name": "md1", "activity_state": "active", "level": "raid1", "devices": "sdb1,sda1"},
+			map[string]interface{}{
+				"read_only":    false,
+				"sync_action":  "idle",
+				"blocks_total": int64(1048512),
+				"disks_total":  int64(2),
+				"disks_active": int64(2),
+				"disks_down":   int64(0),
+				"disks_failed": int64(0),
+				"disks_spare":  int64(0),
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("mdstat",
+			map[string]string{"name": "md2", "activity_state": "active", "level": "raid5", "devices": "sdd1,sdc1,sdb2,sde1,sdf1"},
+			map[string]interface{}{
+				"read_only":                 false,
+				"sync_action":               "recovery",
+				"blocks_total":              int64(3906764800),
+				"blocks_synced":             int64(246944640),
+				"blocks_synced_pct":         12.6,
+				"blocks_synced_finish_time": 152.3,
+				"blocks_synced_speed":       int64(186700),
+				"disks_total":               int64(3),
+				"disks_active":              int64(2),
+				"disks_down":                int64(1),
+				"disks_failed":              int64(1),
+				"disks_spare":               int64(1),
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("mdstat",
+			map[string]string{"name": "md3", "activity_state": "active", "level": "raid1", "devices": "sdg1,sdh1"},
+			map[string]interface{}{
+				"read_only":         true,
+				"sync_action":       "resync",
+				"blocks_total":      int64(524224),
+				"blocks_synced":     int64(0),
+				"blocks_synced_pct": 0.0,
+				"disks_total":       int64(2),
+				"disks_active":      int64(2),
+				"disks_down":        int64(0),
+				"disks_failed":      int64(0),
+				"disks_spare":       int64(0),
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("mdstat",
+			map[string]string{"name": "md4", "activity_state": "active", "level": "raid0", "devices": "sdi1,sdj1"},
+			map[string]interface{}{
+				"read_only":    false,
+				"sync_action":  "idle",
+				"blocks_total": int64(1953257472),
+				"disks_total":  int64(2),
+				"disks_active": int64(2),
+				"disks_down":   int64(0),
+				"disks_failed": int64(0),
+				"disks_spare":  int64(0),
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("mdstat",
+			map[string]string{"name": "md127", "activity_state": "inactive", "devices": "sdk1"},
+			map[string]interface{}{
+				"read_only":    false,
+				"sync_action":  "idle",
+				"blocks_total": int64(976630488),
+				"disks_total":  int64(0),
+				"disks_active": int64(0),
+				"disks_down":   int64(0),
+				"disks_failed": int64(0),
+				"disks_spare":  int64(1),
+			},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+func TestMdstatResync(t *testing.T) {
+	text := `Personalities : [raid1]
+md0 : active raid1 sdb1[1] sda1[0]
+      1953382400 blocks super 1.2 [2/2] [UU]
+      [=============>.......]  resync = 65.4% (1277517568/1953382400) finish=55.8min speed=201804K/sec
+
+unused devices: <none>
+`
+	var acc testutil.Accumulator
+	require.NoError(t, processText(strings.NewReader(text), &acc))
+	require.Len(t, acc.Metrics, 1)
+	acc.AssertContainsFields(t, "mdstat", map[string]interface{}{
+		"read_only":                 false,
+		"sync_action":               "resync",
+		"blocks_total":              int64(1953382400),
+		"blocks_synced":             int64(1277517568),
+		"blocks_synced_pct":         65.4,
+		"blocks_synced_finish_time": 55.8,
+		"blocks_synced_speed":       int64(201804),
+		"disks_total":               int64(2),
+		"disks_active":              int64(2),
+		"disks_down":                int64(0),
+		"disks_failed":              int64(0),
+		"disks_spare":               int64(0),
+	})
+}
+
+func TestMdstatInvalid(t *testing.T) {
+	var acc testutil.Accumulator
+	require.Error(t, processText(strings.NewReader("md0 active raid1\n"), &acc))
+
+	m := &Mdstat{FileName: "testdata/nonexistent", Log: testutil.Logger{}}
+	require.NoError(t, m.Init())
+	require.Error(t, m.Gather(&acc))
+}
@@ -33,6 +33,11 @@ const (
 	Connected
 )
 
+const (
+	protocolV311 = "3.1.1"
+	protocolV5   = "5"
+)
+
 type Client interface {
 	Connect() mqtt.Token
 	SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token
@@ -44,8 +49,11 @@ type ClientFactory func(o *mqtt.ClientOptions) Client
 
 type MQTTConsumer struct {
 	Servers                []string          `toml:"servers"`
+	Protocol               string            `toml:"protocol"`
 	Topics                 []string          `toml:"topics"`
 	TopicTag               *string           `toml:"topic_tag"`
+	TopicParsing           []TopicParsing    `toml:"topic_parsing"`
+	UserPropertiesAsTags   bool              `toml:"user_properties_as_tags"`
 	Username               string            `toml:"username"`
 	Password               string            `toml:"password"`
 	QoS                    int               `toml:"qos"`
@@ -66,6 +74,7 @@ type MQTTConsumer struct {
 	clientFactory ClientFactory
 	client        Client
 	opts          *mqtt.ClientOptions
+	v5            *v5Connection
 	acc           telegraf.TrackingAccumulator
 	state         ConnectionState
 	sem           semaphore
@@ -85,7 +94,12 @@ var sampleConfig = `
   ##            servers = ["ws://localhost:1883"]
   servers = ["tcp://127.0.0.1:1883"]
 
-  ## Topics that will be subscribed to.
+  ## MQTT protocol version, either "3.1.1" or "5".
+  # protocol = "3.1.1"
+
+  ## Topics that will be subscribed to.  With MQTT 5 shared subscriptions
+  ## like "$share/telegraf/sensors/#" distribute the messages across several
+  ## consumers.
   topics = [
     "telegraf/host01/cpu",
     "telegraf/+/mem",
@@ -96,6 +110,9 @@ var sampleConfig = `
   ## to the empty string no topic tag will be created.
   # topic_tag = "topic"
 
+  ## MQTT v5 only: add the user properties of the messages as tags.
+  # user_properties_as_tags = false
+
   ## QoS policy for messages
   ##   0 = at most once
   ##   1 = at least once
@@ -144,6 +161,19 @@ var sampleConfig = `
   ## more about them here:
   ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_INPUT.md
   data_format = "influx"
+
+  ## Rules extracting the measurement, tags and fields from the levels of
+  ## the message topic.  The first rule whose topic filter matches the topic
+  ## of a message is applied.  Each pattern has one entry per topic level,
+  ## "_" ignores the level.
+  # [[inputs.mqtt_consumer.topic_parsing]]
+  #   topic = "sensors/+/+/temperature"
+  #   measurement = "_/_/_/measurement"
+  #   tags = "_/site/room/_"
+  #   fields = ""
+  #   ## Types of the fields, one of "int", "uint", "float", "bool" or
+  #   ## "string" (default).
+  #   [inputs.mqtt_consumer.topic_parsing.types]
 `
 
 func (m *MQTTConsumer) SampleConfig() string {
@@ -178,14 +208,31 @@ func (m *MQTTConsumer) Init() error {
 		m.topicTag = *m.TopicTag
 	}
 
-	opts, err := m.createOpts()
-	if err != nil {
-		return err
+	for i := range m.TopicParsing {
+		if err := m.TopicParsing[i].init(); err != nil {
+			return fmt.Errorf("topic_parsing %q: %v", m.TopicParsing[i].Topic, err)
+		}
 	}
 
-	m.opts = opts
 	m.messages = map[telegraf.TrackingID]bool{}
 
+	switch m.Protocol {
+	case "", protocolV311:
+		opts, err := m.createOpts()
+		if err != nil {
+			return err
+		}
+		m.opts = opts
+	case protocolV5:
+		v5, err := m.createV5Connection()
+		if err != nil {
+			return err
+		}
+		m.v5 = v5
+	default:
+		return fmt.Errorf("unsupported protocol %q", m.Protocol)
+	}
+
 	return nil
 }
 
@@ -196,6 +243,10 @@ func (m *MQTTConsumer) Start(acc telegraf.Accumulator) error {
 	m.sem = make(semaphore, m.MaxUndeliveredMessages)
 	m.ctx, m.cancel = context.WithCancel(context.Background())
 
+	if m.v5 != nil {
+		return m.v5.start(m.ctx)
+	}
+
 	m.client = m.clientFactory(m.opts)
 
 	// AddRoute sets up the function for handling messages.  These need to be
@@ -256,6 +307,12 @@ func (m *MQTTConsumer) onConnectionLost(c mqtt.Client, err error) {
 }
 
 func (m *MQTTConsumer) recvMessage(c mqtt.Client, msg mqtt.Message) {
+	m.receive(msg.Topic(), msg.Payload(), nil)
+}
+
+// receive waits for a free slot of the undelivered messages and adds the
+// metrics of the message.
+func (m *MQTTConsumer) receive(topic string, payload []byte, properties map[string]string) {
 	for {
 		select {
 		case track := <-m.acc.Delivered():
@@ -270,7 +327,7 @@ func (m *MQTTConsumer) recvMessage(c mqtt.Client, msg mqtt.Message) {
 			delete(m.messages, track.ID())
 			m.messagesMutex.Unlock()
 		case m.sem <- empty{}:
-			err := m.onMessage(m.acc, msg)
+			err := m.onMessage(m.acc, topic, payload, properties)
 			if err != nil {
 				m.acc.AddError(err)
 				<-m.sem
@@ -280,19 +337,36 @@ func (m *MQTTConsumer) recvMessage(c mqtt.Client, msg mqtt.Message) {
 	}
 }
 
-func (m *MQTTConsumer) onMessage(acc telegraf.TrackingAccumulator, msg mqtt.Message) error {
-	metrics, err := m.parser.Parse(msg.Payload())
+func (m *MQTTConsumer) onMessage(acc telegraf.TrackingAccumulator, topic string, payload []byte, properties map[string]string) error {
+	metrics, err := m.parser.Parse(payload)
 	if err != nil {
 		return err
 	}
 
 	if m.topicTag != "" {
-		topic := msg.Topic()
 		for _, metric := range metrics {
 			metric.AddTag(m.topicTag, topic)
 		}
 	}
 
+	for key, value := range properties {
+		for _, metric := range metrics {
+			metric.AddTag(key, value)
+		}
+	}
+
+	for _, rule := range m.TopicParsing {
+		if !rule.matches(topic) {
+			continue
+		}
+		for _, metric := range metrics {
+			if err := rule.apply(topic, metric); err != nil {
+				return err
+			}
+		}
+		break
+	}
+
 	id := acc.AddTrackingMetricGroup(metrics)
 	m.messagesMutex.Lock()
 	m.messages[id] = true
@@ -301,6 +375,12 @@ func (m *MQTTConsumer) onMessage(acc telegraf.TrackingAccumulator, msg mqtt.Mess
 }
 
 func (m *MQTTConsumer) Stop() {
+	if m.v5 != nil {
+		m.v5.stop()
+		m.cancel()
+		return
+	}
+
 	if m.state == Connected {
 		m.Log.Debugf("Disconnecting %v", m.Servers)
 		m.client.Disconnect(200)
@@ -311,6 +391,11 @@ func (m *MQTTConsumer) Stop() {
 }
 
 func (m *MQTTConsumer) Gather(acc telegraf.Accumulator) error {
+	// MQTT 5 connections are re-established in the background.
+	if m.v5 != nil {
+		return nil
+	}
+
 	if m.state == Disconnected {
 		m.state = Connecting
 		m.Log.Debugf("Connecting %v", m.Servers)
@@ -353,25 +438,33 @@ func (m *MQTTConsumer) createOpts() (*mqtt.ClientOptions, error) {
 		return opts, fmt.Errorf("could not get host informations")
 	}
 
+	for _, server := range m.brokers(tlsCfg != nil) {
+		opts.AddBroker(server)
+	}
+	opts.SetAutoReconnect(false)
+	opts.SetKeepAlive(time.Second * 60)
+	opts.SetCleanSession(!m.PersistentSession)
+	opts.SetConnectionLostHandler(m.onConnectionLost)
+
+	return opts, nil
+}
+
+func (m *MQTTConsumer) brokers(tls bool) []string {
+	brokers := make([]string, 0, len(m.Servers))
 	for _, server := range m.Servers {
 		// Preserve support for host:port style servers; deprecated in Telegraf 1.4.4
 		if !strings.Contains(server, "://") {
 			m.Log.Warnf("Server %q should be updated to use `scheme://host:port` format", server)
-			if tlsCfg == nil {
+			if !tls {
 				server = "tcp://" + server
 			} else {
 				server = "ssl://" + server
 			}
 		}
 
-		opts.AddBroker(server)
+		brokers = append(brokers, server)
 	}
-	opts.SetAutoReconnect(false)
-	opts.SetKeepAlive(time.Second * 60)
-	opts.SetCleanSession(!m.PersistentSession)
-	opts.SetConnectionLostHandler(m.onConnectionLost)
-
-	return opts, nil
+	return brokers
 }
 
 func New(factory ClientFactory) *MQTTConsumer {
@@ -1,9 +1,11 @@
 package mqtt_consumer
 
 import (
+	"net"
 	"testing"
 	"time"
 
+	"github.com/eclipse/paho.golang/packets"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/plugins/parsers"
@@ -381,3 +383,191 @@ func TestSubscribeNotCalledIfSession(t *testing.T) {
 
 	require.Equal(t, client.subscribeCallCount, 0)
 }
+
+func TestTopicParsing(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     TopicParsing
+		topic    string
+		expected telegraf.Metric
+	}{
+		{
+			name: "measurement, tags and typed field",
+			rule: TopicParsing{
+				Topic:       "sensors/+/+/+",
+				Measurement: "_/_/measurement/_",
+				Tags:        "_/site/_/_",
+				Fields:      "_/_/_/floor",
+				FieldTypes:  map[string]string{"floor": "int"},
+			},
+			topic: "sensors/berlin/temperature/3",
+			expected: testutil.MustMetric(
+				"temperature",
+				map[string]string{"site": "berlin"},
+				map[string]interface{}{"time_idle": 42, "floor": int64(3)},
+				time.Unix(0, 0),
+			),
+		},
+		{
+			name: "multi-level wildcard",
+			rule: TopicParsing{
+				Topic: "sensors/+/#",
+				Tags:  "_/site/_",
+			},
+			topic: "sensors/berlin/temperature/3",
+			expected: testutil.MustMetric(
+				"cpu",
+				map[string]string{"site": "berlin"},
+				map[string]interface{}{"time_idle": 42},
+				time.Unix(0, 0),
+			),
+		},
+		{
+			name: "no match",
+			rule: TopicParsing{
+				Topic: "sensors/+",
+				Tags:  "_/site",
+			},
+			topic: "sensors/berlin/temperature",
+			expected: testutil.MustMetric(
+				"cpu",
+				map[string]string{},
+				map[string]interface{}{"time_idle": 42},
+				time.Unix(0, 0),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.rule.init())
+
+			metric := testutil.MustMetric(
+				"cpu",
+				map[string]string{},
+				map[string]interface{}{"time_idle": 42},
+				time.Unix(0, 0),
+			)
+			if tt.rule.matches(tt.topic) {
+				require.NoError(t, tt.rule.apply(tt.topic, metric))
+			}
+			testutil.RequireMetricEqual(t, tt.expected, metric)
+		})
+	}
+}
+
+func TestTopicParsingInvalid(t *testing.T) {
+	rules := []TopicParsing{
+		{Topic: ""},
+		{Topic: "sensors/#/x"},
+		{Topic: "sensors/+", Tags: "site"},
+		{Topic: "sensors/+", Measurement: "a/b"},
+		{Topic: "sensors/#", Tags: "_/site"},
+		{Topic: "sensors/+", Fields: "_/value", FieldTypes: map[string]string{"value": "complex"}},
+	}
+	for _, rule := range rules {
+		require.Error(t, rule.init(), rule)
+	}
+
+	rule := TopicParsing{Topic: "sensors/+", Fields: "_/value", FieldTypes: map[string]string{"value": "int"}}
+	require.NoError(t, rule.init())
+	metric := testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"time_idle": 42}, time.Unix(0, 0))
+	require.Error(t, rule.apply("sensors/abc", metric))
+}
+
+// fakeBroker is a MQTT v5 broker accepting a single connection.  It
+// publishes a message with a user property once the client subscribed.
+type fakeBroker struct {
+	listener      net.Listener
+	subscriptions chan map[string]packets.SubOptions
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	b := &fakeBroker{listener: listener, subscriptions: make(chan map[string]packets.SubOptions, 1)}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b.serve(conn)
+	}()
+	return b
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	for {
+		p, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		switch p.Type {
+		case packets.CONNECT:
+			packets.NewControlPacket(packets.CONNACK).WriteTo(conn)
+		case packets.PINGREQ:
+			packets.NewControlPacket(packets.PINGRESP).WriteTo(conn)
+		case packets.SUBSCRIBE:
+			subscribe := p.Content.(*packets.Subscribe)
+			ack := packets.NewControlPacket(packets.SUBACK)
+			ack.Content.(*packets.Suback).PacketID = subscribe.PacketID
+			ack.Content.(*packets.Suback).Reasons = make([]byte, len(subscribe.Subscriptions))
+			ack.WriteTo(conn)
+			b.subscriptions <- subscribe.Subscriptions
+
+			publish := packets.NewControlPacket(packets.PUBLISH)
+			publish.Content.(*packets.Publish).Topic = "sensors/berlin"
+			publish.Content.(*packets.Publish).Payload = []byte("cpu time_idle=42i")
+			publish.Content.(*packets.Publish).Properties = &packets.Properties{
+				User: []packets.User{{Key: "source", Value: "device01"}},
+			}
+			publish.WriteTo(conn)
+		case packets.DISCONNECT:
+			return
+		}
+	}
+}
+
+func TestConsumeV5(t *testing.T) {
+	broker := newFakeBroker(t)
+	defer broker.listener.Close()
+
+	plugin := New(nil)
+	plugin.Log = testutil.Logger{}
+	plugin.Servers = []string{"tcp://" + broker.listener.Addr().String()}
+	plugin.Protocol = "5"
+	plugin.QoS = 1
+	plugin.Topics = []string{"$share/telegraf/sensors/+"}
+	plugin.UserPropertiesAsTags = true
+	plugin.TopicParsing = []TopicParsing{{Topic: "sensors/+", Tags: "_/site"}}
+
+	parser, err := parsers.NewInfluxParser()
+	require.NoError(t, err)
+	plugin.SetParser(parser)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	select {
+	case subscriptions := <-broker.subscriptions:
+		require.Equal(t, map[string]packets.SubOptions{"$share/telegraf/sensors/+": {QoS: 1}}, subscriptions)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no subscription received")
+	}
+
+	acc.Wait(1)
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"topic": "sensors/berlin", "source": "device01", "site": "berlin"},
+			map[string]interface{}{"time_idle": 42},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
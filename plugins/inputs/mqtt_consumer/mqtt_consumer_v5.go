@@ -0,0 +1,151 @@
+package mqtt_consumer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/influxdata/telegraf/internal"
+)
+
+// v5Connection consumes messages using MQTT 5.  The connection is
+// re-established in the background and the topics are subscribed again
+// unless the broker kept the session.
+type v5Connection struct {
+	config  autopaho.ClientConfig
+	manager *autopaho.ConnectionManager
+	timeout time.Duration
+}
+
+func (m *MQTTConsumer) createV5Connection() (*v5Connection, error) {
+	if len(m.Servers) == 0 {
+		return nil, fmt.Errorf("could not get host informations")
+	}
+
+	tlsCfg, err := m.ClientConfig.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var brokers []*url.URL
+	for _, server := range m.brokers(tlsCfg != nil) {
+		u, err := url.Parse(server)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server %q: %v", server, err)
+		}
+		brokers = append(brokers, u)
+	}
+
+	clientID := m.ClientID
+	if clientID == "" {
+		clientID = "Telegraf-Consumer-" + internal.RandomString(5)
+	}
+
+	config := autopaho.ClientConfig{
+		BrokerUrls:     brokers,
+		TlsCfg:         tlsCfg,
+		KeepAlive:      60,
+		ConnectTimeout: m.ConnectionTimeout.Duration,
+		OnConnectionUp: m.onConnectionUpV5,
+		OnConnectError: func(err error) {
+			m.acc.AddError(fmt.Errorf("connection error: %v", err))
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: clientID,
+			Router:   paho.NewSingleHandlerRouter(m.recvMessageV5),
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				m.state = Disconnected
+				m.acc.AddError(fmt.Errorf("disconnected by server: %s", reason(d.ReasonCode, d.Properties)))
+			},
+			OnClientError: func(err error) {
+				m.state = Disconnected
+				m.acc.AddError(fmt.Errorf("connection lost: %v", err))
+			},
+		},
+	}
+	config.SetUsernamePassword(m.Username, []byte(m.Password))
+	config.SetConnectPacketConfigurator(func(c *paho.Connect) *paho.Connect {
+		c.CleanStart = !m.PersistentSession
+		if m.PersistentSession {
+			// Keep the session until the broker expires it.
+			expiry := uint32(0xFFFFFFFF)
+			c.Properties = &paho.ConnectProperties{SessionExpiryInterval: &expiry}
+		}
+		return c
+	})
+
+	return &v5Connection{config: config, timeout: m.ConnectionTimeout.Duration}, nil
+}
+
+func (c *v5Connection) start(ctx context.Context) error {
+	manager, err := autopaho.NewConnection(ctx, c.config)
+	if err != nil {
+		return err
+	}
+	c.manager = manager
+	return nil
+}
+
+func (c *v5Connection) stop() {
+	if c.manager == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	c.manager.Disconnect(ctx)
+	c.manager = nil
+}
+
+func (m *MQTTConsumer) onConnectionUpV5(cm *autopaho.ConnectionManager, connack *paho.Connack) {
+	m.Log.Infof("Connected %v", m.Servers)
+	m.state = Connected
+
+	// Persistent sessions should skip subscription if a session is present, as
+	// the subscriptions are stored by the server.
+	if connack.SessionPresent {
+		m.Log.Debugf("Session found %v", m.Servers)
+		return
+	}
+
+	subscriptions := make(map[string]paho.SubscribeOptions)
+	for _, topic := range m.Topics {
+		subscriptions[topic] = paho.SubscribeOptions{QoS: byte(m.QoS)}
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, m.ConnectionTimeout.Duration)
+	defer cancel()
+	suback, err := cm.Subscribe(ctx, &paho.Subscribe{Subscriptions: subscriptions})
+	if err != nil {
+		var codes []string
+		if suback != nil {
+			for _, code := range suback.Reasons {
+				codes = append(codes, fmt.Sprintf("0x%02x", code))
+			}
+		}
+		m.acc.AddError(fmt.Errorf("subscription error: topics: %s: %v (reason codes %s)",
+			strings.Join(m.Topics, ","), err, strings.Join(codes, ",")))
+	}
+}
+
+func (m *MQTTConsumer) recvMessageV5(p *paho.Publish) {
+	var properties map[string]string
+	if m.UserPropertiesAsTags && p.Properties != nil && len(p.Properties.User) > 0 {
+		properties = make(map[string]string, len(p.Properties.User))
+		for _, property := range p.Properties.User {
+			properties[property.Key] = property.Value
+		}
+	}
+	m.receive(p.Topic, p.Payload, properties)
+}
+
+// reason formats the reason code and string of a disconnect.
+func reason(code byte, properties *paho.DisconnectProperties) string {
+	if properties != nil && properties.ReasonString != "" {
+		return fmt.Sprintf("reason code 0x%02x %s", code, properties.ReasonString)
+	}
+	return fmt.Sprintf("reason code 0x%02x", code)
+}
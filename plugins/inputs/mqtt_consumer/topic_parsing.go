@@ -0,0 +1,141 @@
+package mqtt_consumer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+// TopicParsing extracts the measurement, tags and fields of metrics from the
+// levels of the message topic.
+type TopicParsing struct {
+	Topic       string            `toml:"topic"`
+	Measurement string            `toml:"measurement"`
+	Tags        string            `toml:"tags"`
+	Fields      string            `toml:"fields"`
+	FieldTypes  map[string]string `toml:"types"`
+
+	filter      []string
+	measurement int
+	tags        map[int]string
+	fields      map[int]string
+}
+
+func (p *TopicParsing) init() error {
+	if p.Topic == "" {
+		return fmt.Errorf("topic filter is required")
+	}
+	p.filter = strings.Split(p.Topic, "/")
+	for i, level := range p.filter {
+		if level == "#" && i != len(p.filter)-1 {
+			return fmt.Errorf("multi-level wildcard must be the last level")
+		}
+	}
+
+	measurement, err := p.levels(p.Measurement)
+	if err != nil {
+		return fmt.Errorf("measurement: %v", err)
+	}
+	if len(measurement) > 1 {
+		return fmt.Errorf("measurement: only one level can be used")
+	}
+	p.measurement = -1
+	for i := range measurement {
+		p.measurement = i
+	}
+
+	if p.tags, err = p.levels(p.Tags); err != nil {
+		return fmt.Errorf("tags: %v", err)
+	}
+	if p.fields, err = p.levels(p.Fields); err != nil {
+		return fmt.Errorf("fields: %v", err)
+	}
+
+	for field, typ := range p.FieldTypes {
+		switch typ {
+		case "int", "uint", "float", "bool", "string":
+		default:
+			return fmt.Errorf("invalid type %q of field %q", typ, field)
+		}
+	}
+	return nil
+}
+
+// levels returns the names of the used levels of the pattern by index.
+func (p *TopicParsing) levels(pattern string) (map[int]string, error) {
+	levels := make(map[int]string)
+	if pattern == "" {
+		return levels, nil
+	}
+
+	parts := strings.Split(pattern, "/")
+	if len(parts) != len(p.filter) {
+		return nil, fmt.Errorf("pattern %q has %d levels but the topic filter has %d", pattern, len(parts), len(p.filter))
+	}
+	for i, part := range parts {
+		if part == "_" {
+			continue
+		}
+		if p.filter[i] == "#" {
+			return nil, fmt.Errorf("level %d of pattern %q matches the multi-level wildcard", i, pattern)
+		}
+		levels[i] = part
+	}
+	return levels, nil
+}
+
+// matches returns true if the topic matches the topic filter.
+func (p *TopicParsing) matches(topic string) bool {
+	levels := strings.Split(topic, "/")
+	for i, filter := range p.filter {
+		switch {
+		case filter == "#":
+			return true
+		case i >= len(levels):
+			return false
+		case filter != "+" && filter != levels[i]:
+			return false
+		}
+	}
+	return len(levels) == len(p.filter)
+}
+
+// apply sets the measurement, tags and fields of the metric from the topic,
+// the topic must match the filter.
+func (p *TopicParsing) apply(topic string, metric telegraf.Metric) error {
+	levels := strings.Split(topic, "/")
+
+	if p.measurement >= 0 {
+		metric.SetName(levels[p.measurement])
+	}
+
+	for i, key := range p.tags {
+		metric.AddTag(key, levels[i])
+	}
+
+	for i, key := range p.fields {
+		value, err := p.convert(key, levels[i])
+		if err != nil {
+			return fmt.Errorf("field %q of topic %q: %v", key, topic, err)
+		}
+		metric.AddField(key, value)
+	}
+	return nil
+}
+
+func (p *TopicParsing) convert(key, value string) (interface{}, error) {
+	switch p.FieldTypes[key] {
+	case "int":
+		return strconv.ParseInt(value, 10, 64)
+	case "uint":
+		return strconv.ParseUint(value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(value, 64)
+	case "bool":
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,438 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+const (
+	v5HeaderLength    = 24
+	v5RecordLength    = 48
+	v9HeaderLength    = 20
+	ipfixHeaderLength = 16
+
+	// variableLength marks IPFIX fields whose length precedes the value.
+	variableLength = 0xffff
+)
+
+// templateKey identifies a template, templates are only valid for the
+// exporter and observation domain (source id of NetFlow v9) defining them.
+type templateKey struct {
+	exporter string
+	domain   uint32
+	id       uint16
+}
+
+type templateField struct {
+	key    fieldKey
+	length uint16
+}
+
+type template struct {
+	fields []templateField
+	// options templates describe the exporter instead of flows, their data
+	// records are skipped.
+	options bool
+	// minLength is the length of a record with empty variable length fields.
+	minLength int
+	// updated is the time the template was last announced.
+	updated time.Time
+}
+
+// Decoder turns NetFlow v5, v9 and IPFIX packets into metrics and keeps the
+// templates announced by the exporters.
+type Decoder struct {
+	// TemplateTimeout is the time after which templates not announced
+	// again are removed, zero keeps them forever.
+	TemplateTimeout time.Duration
+	Log             telegraf.Logger
+
+	definitions map[fieldKey]fieldDefinition
+	templates   map[templateKey]*template
+	// nextExpiry is the time the expired templates are removed next.
+	nextExpiry time.Time
+}
+
+func NewDecoder(definitions map[fieldKey]fieldDefinition) *Decoder {
+	return &Decoder{
+		definitions: definitions,
+		templates:   make(map[templateKey]*template),
+	}
+}
+
+// Decode decodes a packet received from the exporter.
+func (d *Decoder) Decode(exporter net.Addr, data []byte) ([]telegraf.Metric, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("packet too short")
+	}
+
+	source := exporter.String()
+	if addr, ok := exporter.(*net.UDPAddr); ok {
+		source = addr.IP.String()
+	}
+
+	now := time.Now()
+	d.expire(now)
+	switch version := binary.BigEndian.Uint16(data); version {
+	case 5:
+		return d.decodeV5(source, data, now)
+	case 9:
+		return d.decodeV9(exporter.String(), source, data, now)
+	case 10:
+		return d.decodeIPFIX(exporter.String(), source, data, now)
+	default:
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+}
+
+func (d *Decoder) decodeV5(source string, data []byte, now time.Time) ([]telegraf.Metric, error) {
+	if len(data) < v5HeaderLength {
+		return nil, fmt.Errorf("NetFlow v5 header too short")
+	}
+	count := int(binary.BigEndian.Uint16(data[2:]))
+	if len(data) < v5HeaderLength+count*v5RecordLength {
+		return nil, fmt.Errorf("NetFlow v5 packet with %d records truncated", count)
+	}
+	engineType := data[20]
+	engineID := data[21]
+	samplingInterval := binary.BigEndian.Uint16(data[22:]) & 0x3fff
+
+	metrics := make([]telegraf.Metric, 0, count)
+	for i := 0; i < count; i++ {
+		r := data[v5HeaderLength+i*v5RecordLength:]
+		tags := map[string]string{
+			"source":      source,
+			"version":     "NetFlow v5",
+			"src":         net.IP(r[0:4]).String(),
+			"dst":         net.IP(r[4:8]).String(),
+			"next_hop":    net.IP(r[8:12]).String(),
+			"in_snmp":     strconv.FormatUint(uint64(binary.BigEndian.Uint16(r[12:])), 10),
+			"out_snmp":    strconv.FormatUint(uint64(binary.BigEndian.Uint16(r[14:])), 10),
+			"src_port":    strconv.FormatUint(uint64(binary.BigEndian.Uint16(r[32:])), 10),
+			"dst_port":    strconv.FormatUint(uint64(binary.BigEndian.Uint16(r[34:])), 10),
+			"protocol":    strconv.FormatUint(uint64(r[38]), 10),
+			"src_tos":     strconv.FormatUint(uint64(r[39]), 10),
+			"src_as":      strconv.FormatUint(uint64(binary.BigEndian.Uint16(r[40:])), 10),
+			"dst_as":      strconv.FormatUint(uint64(binary.BigEndian.Uint16(r[42:])), 10),
+			"src_mask":    strconv.FormatUint(uint64(r[44]), 10),
+			"dst_mask":    strconv.FormatUint(uint64(r[45]), 10),
+			"engine_type": strconv.FormatUint(uint64(engineType), 10),
+			"engine_id":   strconv.FormatUint(uint64(engineID), 10),
+		}
+		fields := map[string]interface{}{
+			"in_packets":        uint64(binary.BigEndian.Uint32(r[16:])),
+			"in_bytes":          uint64(binary.BigEndian.Uint32(r[20:])),
+			"first_switched":    uint64(binary.BigEndian.Uint32(r[24:])),
+			"last_switched":     uint64(binary.BigEndian.Uint32(r[28:])),
+			"tcp_flags":         uint64(r[37]),
+			"sampling_interval": uint64(samplingInterval),
+		}
+		m, err := metric.New("netflow", tags, fields, now)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+func (d *Decoder) decodeV9(exporter, source string, data []byte, now time.Time) ([]telegraf.Metric, error) {
+	if len(data) < v9HeaderLength {
+		return nil, fmt.Errorf("NetFlow v9 header too short")
+	}
+	domain := binary.BigEndian.Uint32(data[16:])
+	tags := map[string]string{"source": source, "version": "NetFlow v9"}
+
+	var metrics []telegraf.Metric
+	err := forEachSet(data[v9HeaderLength:], func(id uint16, body []byte) error {
+		switch {
+		case id == 0:
+			return d.parseTemplates(exporter, domain, body, false, now)
+		case id == 1:
+			return d.parseV9OptionsTemplates(exporter, domain, body, now)
+		case id >= 256:
+			m, err := d.decodeData(templateKey{exporter, domain, id}, body, tags, now)
+			metrics = append(metrics, m...)
+			return err
+		}
+		return nil
+	})
+	return metrics, err
+}
+
+func (d *Decoder) decodeIPFIX(exporter, source string, data []byte, now time.Time) ([]telegraf.Metric, error) {
+	if len(data) < ipfixHeaderLength {
+		return nil, fmt.Errorf("IPFIX header too short")
+	}
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if length < ipfixHeaderLength || length > len(data) {
+		return nil, fmt.Errorf("invalid IPFIX message length %d", length)
+	}
+	domain := binary.BigEndian.Uint32(data[12:])
+	tags := map[string]string{"source": source, "version": "IPFIX"}
+
+	var metrics []telegraf.Metric
+	err := forEachSet(data[ipfixHeaderLength:length], func(id uint16, body []byte) error {
+		switch {
+		case id == 2:
+			return d.parseTemplates(exporter, domain, body, true, now)
+		case id == 3:
+			return d.parseIPFIXOptionsTemplates(exporter, domain, body, now)
+		case id >= 256:
+			m, err := d.decodeData(templateKey{exporter, domain, id}, body, tags, now)
+			metrics = append(metrics, m...)
+			return err
+		}
+		return nil
+	})
+	return metrics, err
+}
+
+// forEachSet calls fn with the id and body of each flowset or set.
+func forEachSet(data []byte, fn func(id uint16, body []byte) error) error {
+	for len(data) >= 4 {
+		id := binary.BigEndian.Uint16(data)
+		length := int(binary.BigEndian.Uint16(data[2:]))
+		if length < 4 || length > len(data) {
+			return fmt.Errorf("invalid length %d of set %d", length, id)
+		}
+		if err := fn(id, data[4:length]); err != nil {
+			return err
+		}
+		data = data[length:]
+	}
+	return nil
+}
+
+// parseTemplates parses the template records of a NetFlow v9 template
+// flowset or an IPFIX template set.
+func (d *Decoder) parseTemplates(exporter string, domain uint32, body []byte, ipfix bool, now time.Time) error {
+	for len(body) >= 4 {
+		id := binary.BigEndian.Uint16(body)
+		count := int(binary.BigEndian.Uint16(body[2:]))
+		body = body[4:]
+
+		// A template record without fields withdraws the template, the id of
+		// the template set withdraws all templates of the domain.
+		if ipfix && count == 0 {
+			d.withdraw(exporter, domain, id)
+			continue
+		}
+		if id < 256 {
+			// The rest of the set is padding.
+			return nil
+		}
+
+		fields, rest, err := parseFieldSpecs(body, count, ipfix)
+		if err != nil {
+			return fmt.Errorf("template %d: %v", id, err)
+		}
+		body = rest
+		d.addTemplate(templateKey{exporter, domain, id}, fields, false, now)
+	}
+	return nil
+}
+
+// parseV9OptionsTemplates parses the records of a NetFlow v9 options
+// template flowset, the lengths of the scopes and options are in bytes.
+func (d *Decoder) parseV9OptionsTemplates(exporter string, domain uint32, body []byte, now time.Time) error {
+	for len(body) >= 6 {
+		id := binary.BigEndian.Uint16(body)
+		scopeLength := int(binary.BigEndian.Uint16(body[2:]))
+		optionLength := int(binary.BigEndian.Uint16(body[4:]))
+		body = body[6:]
+		if id < 256 {
+			// The rest of the flowset is padding.
+			return nil
+		}
+
+		fields, rest, err := parseFieldSpecs(body, (scopeLength+optionLength)/4, false)
+		if err != nil {
+			return fmt.Errorf("options template %d: %v", id, err)
+		}
+		body = rest
+		d.addTemplate(templateKey{exporter, domain, id}, fields, true, now)
+	}
+	return nil
+}
+
+// parseIPFIXOptionsTemplates parses the records of an IPFIX options template
+// set, the scope fields are part of the field count.
+func (d *Decoder) parseIPFIXOptionsTemplates(exporter string, domain uint32, body []byte, now time.Time) error {
+	for len(body) >= 4 {
+		id := binary.BigEndian.Uint16(body)
+		count := int(binary.BigEndian.Uint16(body[2:]))
+		if count == 0 {
+			d.withdraw(exporter, domain, id)
+			body = body[4:]
+			continue
+		}
+		if len(body) < 6 {
+			return fmt.Errorf("options template %d truncated", id)
+		}
+		body = body[6:]
+
+		fields, rest, err := parseFieldSpecs(body, count, true)
+		if err != nil {
+			return fmt.Errorf("options template %d: %v", id, err)
+		}
+		body = rest
+		d.addTemplate(templateKey{exporter, domain, id}, fields, true, now)
+	}
+	return nil
+}
+
+// parseFieldSpecs parses the given number of field specifiers, enterprise
+// specific IPFIX fields are followed by the enterprise number.
+func parseFieldSpecs(body []byte, count int, ipfix bool) ([]templateField, []byte, error) {
+	fields := make([]templateField, 0, count)
+	for i := 0; i < count; i++ {
+		if len(body) < 4 {
+			return nil, nil, fmt.Errorf("truncated after %d of %d fields", i, count)
+		}
+		id := binary.BigEndian.Uint16(body)
+		length := binary.BigEndian.Uint16(body[2:])
+		body = body[4:]
+
+		var enterprise uint32
+		if ipfix && id&0x8000 != 0 {
+			if len(body) < 4 {
+				return nil, nil, fmt.Errorf("truncated enterprise number of field %d", i)
+			}
+			id &= 0x7fff
+			enterprise = binary.BigEndian.Uint32(body)
+			body = body[4:]
+		}
+		fields = append(fields, templateField{
+			key:    fieldKey{enterprise: enterprise, id: id},
+			length: length,
+		})
+	}
+	return fields, body, nil
+}
+
+func (d *Decoder) addTemplate(key templateKey, fields []templateField, options bool, now time.Time) {
+	t := &template{fields: fields, options: options, updated: now}
+	for _, f := range fields {
+		if f.length == variableLength {
+			t.minLength++
+		} else {
+			t.minLength += int(f.length)
+		}
+	}
+	if t.minLength == 0 {
+		d.Log.Debugf("Ignoring empty template %d of %s", key.id, key.exporter)
+		return
+	}
+	d.templates[key] = t
+}
+
+// withdraw removes a template, the ids of the template sets remove all
+// templates respectively options templates of the domain.
+func (d *Decoder) withdraw(exporter string, domain uint32, id uint16) {
+	switch {
+	case id >= 256:
+		delete(d.templates, templateKey{exporter, domain, id})
+	case id == 2 || id == 3:
+		for key, t := range d.templates {
+			if key.exporter == exporter && key.domain == domain && t.options == (id == 3) {
+				delete(d.templates, key)
+			}
+		}
+	}
+}
+
+// expire removes the templates not announced again within the timeout, the
+// templates of exporters gone are removed too this way.
+func (d *Decoder) expire(now time.Time) {
+	if d.TemplateTimeout <= 0 || now.Before(d.nextExpiry) {
+		return
+	}
+	for key, t := range d.templates {
+		if now.Sub(t.updated) > d.TemplateTimeout {
+			d.Log.Debugf("Template %d of %s (domain %d) expired", key.id, key.exporter, key.domain)
+			delete(d.templates, key)
+		}
+	}
+	// Checking every tenth of the timeout keeps templates at most 10%
+	// longer than the timeout.
+	d.nextExpiry = now.Add(d.TemplateTimeout / 10)
+}
+
+// decodeData decodes the records of a data set, the set is skipped if the
+// template is not known yet.
+func (d *Decoder) decodeData(
+	key templateKey,
+	body []byte,
+	baseTags map[string]string,
+	now time.Time,
+) ([]telegraf.Metric, error) {
+	t, ok := d.templates[key]
+	if !ok {
+		d.Log.Debugf("Skipping data of unknown template %d of %s (domain %d)", key.id, key.exporter, key.domain)
+		return nil, nil
+	}
+	if t.options {
+		return nil, nil
+	}
+
+	var metrics []telegraf.Metric
+	// The set may end with padding shorter than a record.
+	for len(body) >= t.minLength {
+		tags := make(map[string]string, len(baseTags)+len(t.fields))
+		for k, v := range baseTags {
+			tags[k] = v
+		}
+		fields := make(map[string]interface{}, len(t.fields))
+
+		for _, f := range t.fields {
+			length := int(f.length)
+			if f.length == variableLength {
+				if len(body) < 1 {
+					return metrics, fmt.Errorf("record of template %d truncated", key.id)
+				}
+				length = int(body[0])
+				body = body[1:]
+				if length == 255 {
+					if len(body) < 2 {
+						return metrics, fmt.Errorf("record of template %d truncated", key.id)
+					}
+					length = int(binary.BigEndian.Uint16(body))
+					body = body[2:]
+				}
+			}
+			if len(body) < length {
+				return metrics, fmt.Errorf("record of template %d truncated", key.id)
+			}
+			value := body[:length]
+			body = body[length:]
+
+			def, ok := d.definitions[f.key]
+			if !ok {
+				continue
+			}
+			v := decodeValue(def.typ, value)
+			if def.tag {
+				tags[def.name] = fmt.Sprint(v)
+			} else {
+				fields[def.name] = v
+			}
+		}
+
+		if len(fields) == 0 {
+			continue
+		}
+		m, err := metric.New("netflow", tags, fields, now)
+		if err != nil {
+			return metrics, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
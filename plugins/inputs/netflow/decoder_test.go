@@ -0,0 +1,324 @@
+package netflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+var exporter = &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 50000}
+
+// encode writes the values in network byte order.
+func encode(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		if b, ok := v.([]byte); ok {
+			buf.Write(b)
+			continue
+		}
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	return buf.Bytes()
+}
+
+// set prepends the id and length to the body of a flowset or set.
+func set(id uint16, body ...[]byte) []byte {
+	data := bytes.Join(body, nil)
+	return encode(id, uint16(len(data)+4), data)
+}
+
+func v9Packet(sets ...[]byte) []byte {
+	// version, count, sys uptime, unix secs, sequence, source id
+	return append(encode(uint16(9), uint16(len(sets)), uint32(1000), uint32(1600000000), uint32(1), uint32(7)), bytes.Join(sets, nil)...)
+}
+
+func ipfixPacket(sets ...[]byte) []byte {
+	body := bytes.Join(sets, nil)
+	// version, length, export time, sequence, observation domain
+	return append(encode(uint16(10), uint16(len(body)+16), uint32(1600000000), uint32(1), uint32(3)), body...)
+}
+
+func newTestDecoder(t *testing.T, custom ...FieldConfig) *Decoder {
+	definitions, err := newFieldDefinitions(custom)
+	require.NoError(t, err)
+	d := NewDecoder(definitions)
+	d.Log = testutil.Logger{}
+	return d
+}
+
+func TestDecodeV5(t *testing.T) {
+	header := encode(uint16(5), uint16(1), uint32(1000), uint32(1600000000), uint32(0), uint32(42), uint8(1), uint8(2), uint16(0x4000|100))
+	record := encode(
+		net.IPv4(10, 0, 0, 1).To4(), net.IPv4(10, 0, 0, 2).To4(), net.IPv4(10, 0, 0, 254).To4(),
+		uint16(3), uint16(4), // input, output
+		uint32(10), uint32(1500), // packets, octets
+		uint32(900), uint32(950), // first, last
+		uint16(443), uint16(51000), // ports
+		uint8(0), uint8(0x1b), uint8(6), uint8(0), // pad, tcp flags, protocol, tos
+		uint16(64500), uint16(64501), // as numbers
+		uint8(24), uint8(16), uint16(0), // masks, pad
+	)
+
+	d := newTestDecoder(t)
+	metrics, err := d.Decode(exporter, append(header, record...))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+
+	expected := testutil.MustMetric(
+		"netflow",
+		map[string]string{
+			"source":      "192.0.2.1",
+			"version":     "NetFlow v5",
+			"src":         "10.0.0.1",
+			"dst":         "10.0.0.2",
+			"next_hop":    "10.0.0.254",
+			"in_snmp":     "3",
+			"out_snmp":    "4",
+			"src_port":    "443",
+			"dst_port":    "51000",
+			"protocol":    "6",
+			"src_tos":     "0",
+			"src_as":      "64500",
+			"dst_as":      "64501",
+			"src_mask":    "24",
+			"dst_mask":    "16",
+			"engine_type": "1",
+			"engine_id":   "2",
+		},
+		map[string]interface{}{
+			"in_packets":        uint64(10),
+			"in_bytes":          uint64(1500),
+			"first_switched":    uint64(900),
+			"last_switched":     uint64(950),
+			"tcp_flags":         uint64(0x1b),
+			"sampling_interval": uint64(100),
+		},
+		time.Unix(0, 0),
+	)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{expected}, metrics, testutil.IgnoreTime())
+
+	_, err = d.Decode(exporter, header)
+	require.Error(t, err)
+}
+
+// v9Template has the addresses, ports, protocol, counters and AS numbers.
+var v9Template = set(0, encode(
+	uint16(256), uint16(8),
+	uint16(8), uint16(4), // source address
+	uint16(12), uint16(4), // destination address
+	uint16(7), uint16(2), // source port
+	uint16(11), uint16(2), // destination port
+	uint16(4), uint16(1), // protocol
+	uint16(1), uint16(4), // bytes
+	uint16(2), uint16(4), // packets
+	uint16(16), uint16(2), // source AS
+))
+
+func v9Record(src, dst string, bytes uint32) []byte {
+	return encode(net.ParseIP(src).To4(), net.ParseIP(dst).To4(), uint16(53), uint16(40000), uint8(17), bytes, uint32(2), uint16(64500))
+}
+
+func TestDecodeV9(t *testing.T) {
+	d := newTestDecoder(t)
+
+	// The data is skipped until the template is known.
+	data := set(256, v9Record("10.0.0.1", "10.0.0.2", 120), v9Record("10.0.0.3", "10.0.0.4", 80), []byte{0, 0})
+	metrics, err := d.Decode(exporter, v9Packet(data))
+	require.NoError(t, err)
+	require.Empty(t, metrics)
+
+	metrics, err = d.Decode(exporter, v9Packet(v9Template, data))
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"netflow",
+			map[string]string{
+				"source":   "192.0.2.1",
+				"version":  "NetFlow v9",
+				"src":      "10.0.0.1",
+				"dst":      "10.0.0.2",
+				"src_port": "53",
+				"dst_port": "40000",
+				"protocol": "17",
+				"src_as":   "64500",
+			},
+			map[string]interface{}{"in_bytes": uint64(120), "in_packets": uint64(2)},
+			time.Unix(0, 0),
+		),
+		testutil.MustMetric(
+			"netflow",
+			map[string]string{
+				"source":   "192.0.2.1",
+				"version":  "NetFlow v9",
+				"src":      "10.0.0.3",
+				"dst":      "10.0.0.4",
+				"src_port": "53",
+				"dst_port": "40000",
+				"protocol": "17",
+				"src_as":   "64500",
+			},
+			map[string]interface{}{"in_bytes": uint64(80), "in_packets": uint64(2)},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics, testutil.IgnoreTime())
+
+	// Templates are only valid for the exporter announcing them.
+	other := &net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+	metrics, err = d.Decode(other, v9Packet(data))
+	require.NoError(t, err)
+	require.Empty(t, metrics)
+}
+
+func TestDecodeTemplateTimeout(t *testing.T) {
+	d := newTestDecoder(t)
+	d.TemplateTimeout = time.Hour
+
+	data := set(256, v9Record("10.0.0.1", "10.0.0.2", 120))
+	metrics, err := d.Decode(exporter, v9Packet(v9Template, data))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+
+	// Templates not announced again within the timeout are removed.
+	key := templateKey{exporter.String(), 7, 256}
+	require.Contains(t, d.templates, key)
+	d.templates[key].updated = time.Now().Add(-2 * time.Hour)
+	d.nextExpiry = time.Time{}
+	metrics, err = d.Decode(exporter, v9Packet(data))
+	require.NoError(t, err)
+	require.Empty(t, metrics)
+	require.Empty(t, d.templates)
+}
+
+func TestDecodeV9OptionsTemplate(t *testing.T) {
+	d := newTestDecoder(t)
+
+	// Scope system (4 bytes), option sampling interval (4 bytes).
+	options := set(1, encode(uint16(257), uint16(4), uint16(4), uint16(1), uint16(4), uint16(34), uint16(4)), []byte{0, 0})
+	data := set(257, encode(uint32(1), uint32(100)))
+
+	metrics, err := d.Decode(exporter, v9Packet(options, data))
+	require.NoError(t, err)
+	require.Empty(t, metrics)
+	require.True(t, d.templates[templateKey{exporter.String(), 7, 257}].options)
+}
+
+func TestDecodeIPFIX(t *testing.T) {
+	d := newTestDecoder(t,
+		FieldConfig{Enterprise: 9, ID: 12235, Name: "application_name", Type: "string", Tag: true},
+		FieldConfig{ID: 1, Name: "octets", Type: "uint"},
+	)
+
+	template := set(2, encode(
+		uint16(300), uint16(5),
+		uint16(27), uint16(16), // source IPv6 address
+		uint16(28), uint16(16), // destination IPv6 address
+		uint16(1), uint16(8), // bytes
+		uint16(0x8000|12235), uint16(0xffff), uint32(9), // enterprise specific, variable length
+		uint16(56), uint16(6), // source MAC address
+	))
+	data := set(300, encode(
+		net.ParseIP("2001:db8::1").To16(),
+		net.ParseIP("2001:db8::2").To16(),
+		uint64(4096),
+		uint8(5), []byte("https"),
+		[]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+	), []byte{0, 0, 0})
+
+	metrics, err := d.Decode(exporter, ipfixPacket(template, data))
+	require.NoError(t, err)
+
+	expected := testutil.MustMetric(
+		"netflow",
+		map[string]string{
+			"source":           "192.0.2.1",
+			"version":          "IPFIX",
+			"src":              "2001:db8::1",
+			"dst":              "2001:db8::2",
+			"src_mac":          "00:11:22:33:44:55",
+			"application_name": "https",
+		},
+		map[string]interface{}{"octets": uint64(4096)},
+		time.Unix(0, 0),
+	)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{expected}, metrics, testutil.IgnoreTime())
+
+	// Withdrawn templates are forgotten.
+	metrics, err = d.Decode(exporter, ipfixPacket(set(2, encode(uint16(300), uint16(0))), data))
+	require.NoError(t, err)
+	require.Empty(t, metrics)
+}
+
+func TestDecodeIPFIXOptionsTemplate(t *testing.T) {
+	d := newTestDecoder(t)
+
+	// One scope field (observation domain id) and the sampling interval.
+	options := set(3, encode(uint16(400), uint16(2), uint16(1), uint16(149), uint16(4), uint16(34), uint16(4)))
+	data := set(400, encode(uint32(3), uint32(100)))
+	metrics, err := d.Decode(exporter, ipfixPacket(options, data))
+	require.NoError(t, err)
+	require.Empty(t, metrics)
+	require.Len(t, d.templates, 1)
+
+	// The id of the options template set withdraws all options templates.
+	_, err = d.Decode(exporter, ipfixPacket(set(3, encode(uint16(3), uint16(0)))))
+	require.NoError(t, err)
+	require.Empty(t, d.templates)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	d := newTestDecoder(t)
+
+	_, err := d.Decode(exporter, []byte{0, 7, 0, 0})
+	require.Error(t, err)
+
+	// The length of the set exceeds the packet.
+	packet := v9Packet(v9Template)
+	_, err = d.Decode(exporter, packet[:len(packet)-2])
+	require.Error(t, err)
+
+	// Truncated record.
+	_, err = d.Decode(exporter, ipfixPacket(
+		set(2, encode(uint16(300), uint16(1), uint16(0x8000|1), uint16(0xffff), uint32(9))),
+		set(300, encode(uint8(10), []byte("abc"))),
+	))
+	require.Error(t, err)
+}
+
+func TestDecodeValue(t *testing.T) {
+	require.Equal(t, uint64(0x0102), decodeValue(typeUint, []byte{1, 2}))
+	require.Equal(t, int64(-2), decodeValue(typeInt, []byte{0xff, 0xfe}))
+	require.Equal(t, "10.1.2.3", decodeValue(typeIP, []byte{10, 1, 2, 3}))
+	require.Equal(t, "abc", decodeValue(typeString, []byte("abc\x00\x00")))
+	require.Equal(t, "0a0b", decodeValue(typeHex, []byte{10, 11}))
+	// Values of unexpected lengths are returned as hex.
+	require.Equal(t, "0a0b", decodeValue(typeIP, []byte{10, 11}))
+}
+
+func TestFieldDefinitions(t *testing.T) {
+	_, err := newFieldDefinitions([]FieldConfig{{ID: 100, Name: "x", Type: "float"}})
+	require.Error(t, err)
+	_, err = newFieldDefinitions([]FieldConfig{{ID: 100}})
+	require.Error(t, err)
+
+	definitions, err := newFieldDefinitions([]FieldConfig{{Enterprise: 29305, ID: 100, Name: "x"}})
+	require.NoError(t, err)
+	require.Equal(t, fieldDefinition{name: "x", typ: typeHex}, definitions[fieldKey{29305, 100}])
+	require.Equal(t, "src", definitions[fieldKey{0, 8}].name)
+
+	// NetFlow v9 vendor fields use ids above the IPFIX enterprise bit.
+	definitions, err = newFieldDefinitions([]FieldConfig{{ID: 33000, Name: "ingress_acl_id"}})
+	require.NoError(t, err)
+	require.Equal(t, "ingress_acl_id", definitions[fieldKey{0, 33000}].name)
+	_, err = newFieldDefinitions([]FieldConfig{{Enterprise: 9, ID: 33000, Name: "x"}})
+	require.Error(t, err)
+}
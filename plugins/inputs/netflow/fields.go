@@ -0,0 +1,171 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Types of the values of the fields.
+const (
+	typeUint   = "uint"
+	typeInt    = "int"
+	typeIP     = "ip"
+	typeMAC    = "mac"
+	typeString = "string"
+	typeHex    = "hex"
+)
+
+// fieldKey identifies an information element, the enterprise number is 0 for
+// IANA elements and the fields of NetFlow v9.
+type fieldKey struct {
+	enterprise uint32
+	id         uint16
+}
+
+// fieldDefinition describes how to decode an information element.
+type fieldDefinition struct {
+	name string
+	typ  string
+	tag  bool
+}
+
+// FieldConfig defines a custom or enterprise-specific field.
+type FieldConfig struct {
+	Enterprise uint32 `toml:"enterprise"`
+	ID         uint16 `toml:"id"`
+	Name       string `toml:"name"`
+	Type       string `toml:"type"`
+	Tag        bool   `toml:"tag"`
+}
+
+// ianaFields are the common information elements, the element ids of
+// NetFlow v9 are the same as the ones of IPFIX (RFC 7012).
+var ianaFields = map[uint16]fieldDefinition{
+	1:   {"in_bytes", typeUint, false},
+	2:   {"in_packets", typeUint, false},
+	3:   {"flows", typeUint, false},
+	4:   {"protocol", typeUint, true},
+	5:   {"src_tos", typeUint, true},
+	6:   {"tcp_flags", typeUint, false},
+	7:   {"src_port", typeUint, true},
+	8:   {"src", typeIP, true},
+	9:   {"src_mask", typeUint, true},
+	10:  {"in_snmp", typeUint, true},
+	11:  {"dst_port", typeUint, true},
+	12:  {"dst", typeIP, true},
+	13:  {"dst_mask", typeUint, true},
+	14:  {"out_snmp", typeUint, true},
+	15:  {"next_hop", typeIP, true},
+	16:  {"src_as", typeUint, true},
+	17:  {"dst_as", typeUint, true},
+	18:  {"bgp_next_hop", typeIP, true},
+	21:  {"last_switched", typeUint, false},
+	22:  {"first_switched", typeUint, false},
+	23:  {"out_bytes", typeUint, false},
+	24:  {"out_packets", typeUint, false},
+	27:  {"src", typeIP, true},
+	28:  {"dst", typeIP, true},
+	29:  {"src_mask", typeUint, true},
+	30:  {"dst_mask", typeUint, true},
+	31:  {"flow_label", typeUint, false},
+	32:  {"icmp_type_code", typeUint, false},
+	34:  {"sampling_interval", typeUint, false},
+	35:  {"sampling_algorithm", typeUint, false},
+	38:  {"engine_type", typeUint, true},
+	39:  {"engine_id", typeUint, true},
+	56:  {"src_mac", typeMAC, true},
+	57:  {"out_dst_mac", typeMAC, true},
+	58:  {"vlan", typeUint, true},
+	59:  {"out_vlan", typeUint, true},
+	60:  {"ip_version", typeUint, true},
+	61:  {"direction", typeUint, true},
+	62:  {"next_hop", typeIP, true},
+	63:  {"bgp_next_hop", typeIP, true},
+	80:  {"dst_mac", typeMAC, true},
+	81:  {"out_src_mac", typeMAC, true},
+	85:  {"total_bytes", typeUint, false},
+	86:  {"total_packets", typeUint, false},
+	136: {"flow_end_reason", typeUint, false},
+	148: {"flow_id", typeUint, false},
+	150: {"flow_start", typeUint, false},
+	151: {"flow_end", typeUint, false},
+	152: {"flow_start_ms", typeUint, false},
+	153: {"flow_end_ms", typeUint, false},
+	176: {"icmp_type", typeUint, false},
+	177: {"icmp_code", typeUint, false},
+	225: {"post_nat_src", typeIP, true},
+	226: {"post_nat_dst", typeIP, true},
+	227: {"post_napt_src_port", typeUint, true},
+	228: {"post_napt_dst_port", typeUint, true},
+}
+
+// newFieldDefinitions returns the known information elements, the custom
+// fields replace the common ones with the same id.
+func newFieldDefinitions(custom []FieldConfig) (map[fieldKey]fieldDefinition, error) {
+	definitions := make(map[fieldKey]fieldDefinition, len(ianaFields)+len(custom))
+	for id, def := range ianaFields {
+		definitions[fieldKey{id: id}] = def
+	}
+
+	for _, cfg := range custom {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("name of field %d/%d is missing", cfg.Enterprise, cfg.ID)
+		}
+		// Only enterprise specific IPFIX fields have the enterprise bit
+		// stripped, NetFlow v9 uses the whole range for vendor fields.
+		if cfg.ID == 0 || (cfg.Enterprise != 0 && cfg.ID > 0x7fff) {
+			return nil, fmt.Errorf("invalid id %d of field %q", cfg.ID, cfg.Name)
+		}
+		typ := strings.ToLower(cfg.Type)
+		switch typ {
+		case "":
+			typ = typeHex
+		case typeUint, typeInt, typeIP, typeMAC, typeString, typeHex:
+		default:
+			return nil, fmt.Errorf("invalid type %q of field %q", cfg.Type, cfg.Name)
+		}
+		definitions[fieldKey{enterprise: cfg.Enterprise, id: cfg.ID}] = fieldDefinition{
+			name: cfg.Name,
+			typ:  typ,
+			tag:  cfg.Tag,
+		}
+	}
+	return definitions, nil
+}
+
+// decodeValue converts the raw value of a field, values of unexpected
+// lengths are returned as hex strings.
+func decodeValue(typ string, data []byte) interface{} {
+	switch typ {
+	case typeUint:
+		if len(data) >= 1 && len(data) <= 8 {
+			return decodeUint(data)
+		}
+	case typeInt:
+		if len(data) >= 1 && len(data) <= 8 {
+			// Sign extend the value to 64 bits.
+			shift := uint(64 - 8*len(data))
+			return int64(decodeUint(data)<<shift) >> shift
+		}
+	case typeIP:
+		if len(data) == net.IPv4len || len(data) == net.IPv6len {
+			return net.IP(data).String()
+		}
+	case typeMAC:
+		if len(data) == 6 {
+			return net.HardwareAddr(data).String()
+		}
+	case typeString:
+		return strings.TrimRight(string(data), "\x00")
+	}
+	return hex.EncodeToString(data)
+}
+
+func decodeUint(data []byte) uint64 {
+	var buf [8]byte
+	copy(buf[8-len(data):], data)
+	return binary.BigEndian.Uint64(buf[:])
+}
@@ -0,0 +1,174 @@
+package netflow
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+const sampleConfig = `
+  ## Address to listen for NetFlow v5, v9 and IPFIX packets.
+  ##   example: service_address = "udp://:2055"
+  ##            service_address = "udp4://:2055"
+  ##            service_address = "udp6://:4739"
+  service_address = "udp://:2055"
+
+  ## Set the size of the operating system's receive buffer.
+  ##   example: read_buffer_size = "64KiB"
+  # read_buffer_size = ""
+
+  ## Time after which NetFlow v9 and IPFIX templates not announced again by
+  ## the exporter are removed, 0 keeps them forever.
+  # template_timeout = "1h"
+
+  ## Custom definitions of fields unknown to the plugin, like enterprise
+  ## specific IPFIX fields.  A definition replaces the known field with the
+  ## same enterprise number and id.
+  # [[inputs.netflow.field]]
+  #   ## Private enterprise number, 0 for IANA fields and NetFlow v9.
+  #   enterprise = 9
+  #   ## Id of the field, without the enterprise bit.
+  #   id = 12235
+  #   ## Name of the field or tag.
+  #   name = "application_name"
+  #   ## Type of the value, one of "uint", "int", "ip", "mac", "string" and
+  #   ## "hex".
+  #   type = "string"
+  #   ## If true, the value is added as a tag instead of a field.
+  #   tag = false
+`
+
+const (
+	maxPacketSize = 64 * 1024
+)
+
+type NetFlow struct {
+	ServiceAddress  string            `toml:"service_address"`
+	ReadBufferSize  internal.Size     `toml:"read_buffer_size"`
+	TemplateTimeout internal.Duration `toml:"template_timeout"`
+	Fields          []FieldConfig     `toml:"field"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	addr    net.Addr
+	decoder *Decoder
+	conn    net.PacketConn
+	wg      sync.WaitGroup
+}
+
+// Description answers a description of this input plugin
+func (n *NetFlow) Description() string {
+	return "NetFlow v5, v9 and IPFIX Protocol Listener"
+}
+
+// SampleConfig answers a sample configuration
+func (n *NetFlow) SampleConfig() string {
+	return sampleConfig
+}
+
+func (n *NetFlow) Init() error {
+	definitions, err := newFieldDefinitions(n.Fields)
+	if err != nil {
+		return err
+	}
+	n.decoder = NewDecoder(definitions)
+	n.decoder.TemplateTimeout = n.TemplateTimeout.Duration
+	n.decoder.Log = n.Log
+	return nil
+}
+
+// Start starts listening for NetFlow packets on the configured address
+func (n *NetFlow) Start(acc telegraf.Accumulator) error {
+	u, err := url.Parse(n.ServiceAddress)
+	if err != nil {
+		return err
+	}
+
+	conn, err := listenUDP(u.Scheme, u.Host)
+	if err != nil {
+		return err
+	}
+	n.conn = conn
+	n.addr = conn.LocalAddr()
+
+	if n.ReadBufferSize.Size > 0 {
+		conn.SetReadBuffer(int(n.ReadBufferSize.Size))
+	}
+
+	n.Log.Infof("Listening on %s://%s", n.addr.Network(), n.addr.String())
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.read(acc)
+	}()
+
+	return nil
+}
+
+// Gather is a NOOP for NetFlow as it receives the packets asynchronously
+func (n *NetFlow) Gather(_ telegraf.Accumulator) error {
+	return nil
+}
+
+func (n *NetFlow) Stop() {
+	if n.conn != nil {
+		n.conn.Close()
+	}
+	n.wg.Wait()
+}
+
+func (n *NetFlow) Address() net.Addr {
+	return n.addr
+}
+
+func (n *NetFlow) read(acc telegraf.Accumulator) {
+	buf := make([]byte, maxPacketSize)
+	for {
+		size, addr, err := n.conn.ReadFrom(buf)
+		if err != nil {
+			if !strings.HasSuffix(err.Error(), ": use of closed network connection") {
+				acc.AddError(err)
+			}
+			break
+		}
+
+		// The decoder keeps no references to the buffer.
+		metrics, err := n.decoder.Decode(addr, buf[:size])
+		if err != nil {
+			acc.AddError(fmt.Errorf("unable to parse packet from %s: %s", addr, err))
+		}
+		for _, m := range metrics {
+			acc.AddMetric(m)
+		}
+	}
+}
+
+func listenUDP(network string, address string) (*net.UDPConn, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+		addr, err := net.ResolveUDPAddr(network, address)
+		if err != nil {
+			return nil, err
+		}
+		return net.ListenUDP(network, addr)
+	default:
+		return nil, fmt.Errorf("unsupported network type: %s", network)
+	}
+}
+
+func init() {
+	inputs.Add("netflow", func() telegraf.Input {
+		return &NetFlow{
+			ServiceAddress:  "udp://:2055",
+			TemplateTimeout: internal.Duration{Duration: time.Hour},
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package netflow
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestNetFlow(t *testing.T) {
+	netflow := &NetFlow{
+		ServiceAddress: "udp://127.0.0.1:0",
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, netflow.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, netflow.Start(&acc))
+	defer netflow.Stop()
+
+	client, err := net.Dial(netflow.Address().Network(), netflow.Address().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	// The template and data are sent in separate packets.
+	_, err = client.Write(v9Packet(v9Template))
+	require.NoError(t, err)
+	_, err = client.Write(v9Packet(set(256, v9Record("10.0.0.1", "10.0.0.2", 120))))
+	require.NoError(t, err)
+
+	acc.Wait(1)
+	acc.Lock()
+	defer acc.Unlock()
+	require.Equal(t, "netflow", acc.Metrics[0].Measurement)
+	require.Equal(t, "127.0.0.1", acc.Metrics[0].Tags["source"])
+	require.Equal(t, "10.0.0.1", acc.Metrics[0].Tags["src"])
+	require.Equal(t, uint64(120), acc.Metrics[0].Fields["in_bytes"])
+}
+
+func TestInitInvalidField(t *testing.T) {
+	netflow := &NetFlow{
+		ServiceAddress: "udp://127.0.0.1:0",
+		Fields:         []FieldConfig{{ID: 100, Name: "x", Type: "float"}},
+		Log:            testutil.Logger{},
+	}
+	require.Error(t, netflow.Init())
+}
@@ -0,0 +1,253 @@
+package nfsd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+type NFSD struct {
+	IncludeOperations []string        `toml:"include_operations"`
+	ExcludeOperations []string        `toml:"exclude_operations"`
+	Log               telegraf.Logger `toml:"-"`
+
+	operations    filter.Filter
+	nfsdPath      string
+	poolStatsPath string
+}
+
+const sampleConfig = `
+  ## Operations to include or exclude from the per-operation counts, for
+  ## example "READ" or "GETATTR".  Globs are supported; by default the counts
+  ## of all operations are collected.
+  # include_operations = []
+  # exclude_operations = []
+`
+
+// Names of the procedures of NFSv2 and NFSv3 and of the operations of
+// NFSv4 in the order of the counts.
+var (
+	nfs2Procedures = []string{
+		"NULL", "GETATTR", "SETATTR", "ROOT", "LOOKUP", "READLINK", "READ",
+		"WRCACHE", "WRITE", "CREATE", "REMOVE", "RENAME", "LINK", "SYMLINK",
+		"MKDIR", "RMDIR", "READDIR", "STATFS",
+	}
+	nfs3Procedures = []string{
+		"NULL", "GETATTR", "SETATTR", "LOOKUP", "ACCESS", "READLINK", "READ",
+		"WRITE", "CREATE", "MKDIR", "SYMLINK", "MKNOD", "REMOVE", "RMDIR",
+		"RENAME", "LINK", "READDIR", "READDIRPLUS", "FSSTAT", "FSINFO",
+		"PATHCONF", "COMMIT",
+	}
+	nfs4Procedures = []string{"NULL", "COMPOUND"}
+	nfs4Operations = []string{
+		"", "", "", "ACCESS", "CLOSE", "COMMIT", "CREATE", "DELEGPURGE",
+		"DELEGRETURN", "GETATTR", "GETFH", "LINK", "LOCK", "LOCKT", "LOCKU",
+		"LOOKUP", "LOOKUPP", "NVERIFY", "OPEN", "OPENATTR", "OPEN_CONFIRM",
+		"OPEN_DOWNGRADE", "PUTFH", "PUTPUBFH", "PUTROOTFH", "READ", "READDIR",
+		"READLINK", "REMOVE", "RENAME", "RENEW", "RESTOREFH", "SAVEFH",
+		"SECINFO", "SETATTR", "SETCLIENTID", "SETCLIENTID_CONFIRM", "VERIFY",
+		"WRITE", "RELEASE_LOCKOWNER", "BACKCHANNEL_CTL",
+		"BIND_CONN_TO_SESSION", "EXCHANGE_ID", "CREATE_SESSION",
+		"DESTROY_SESSION", "FREE_STATEID", "GET_DIR_DELEGATION",
+		"GETDEVICEINFO", "GETDEVICELIST", "LAYOUTCOMMIT", "LAYOUTGET",
+		"LAYOUTRETURN", "SECINFO_NO_NAME", "SEQUENCE", "SET_SSV",
+		"TEST_STATEID", "WANT_DELEGATION", "DESTROY_CLIENTID",
+		"RECLAIM_COMPLETE", "ALLOCATE", "COPY", "COPY_NOTIFY", "DEALLOCATE",
+		"IO_ADVISE", "LAYOUTERROR", "LAYOUTSTATS", "OFFLOAD_CANCEL",
+		"OFFLOAD_STATUS", "READ_PLUS", "SEEK", "WRITE_SAME", "CLONE",
+		"GETXATTR", "SETXATTR", "LISTXATTRS", "REMOVEXATTR",
+	}
+)
+
+// statFields are the names of the values of the lines of the server
+// statistics reported in the nfsd measurement.
+var statFields = map[string][]string{
+	"rc":  {"rc_hits", "rc_misses", "rc_nocache"},
+	"fh":  {"fh_stale"},
+	"io":  {"io_read", "io_write"},
+	"th":  {"th_threads", "th_fullcnt"},
+	"net": {"net_count", "net_udp", "net_tcp", "net_tcpconn"},
+	"rpc": {"rpc_calls", "rpc_badcalls", "rpc_badfmt", "rpc_badauth", "rpc_badclnt"},
+}
+
+func (n *NFSD) SampleConfig() string {
+	return sampleConfig
+}
+
+func (n *NFSD) Description() string {
+	return "Read NFS server statistics from /proc/net/rpc/nfsd"
+}
+
+func (n *NFSD) getProcPath() string {
+	path := "/proc"
+	if os.Getenv("HOST_PROC") != "" {
+		path = os.Getenv("HOST_PROC")
+	}
+	n.Log.Debugf("using [%s] for proc", path)
+	return path
+}
+
+func (n *NFSD) Init() error {
+	proc := n.getProcPath()
+	n.nfsdPath = filepath.Join(proc, "net", "rpc", "nfsd")
+	n.poolStatsPath = filepath.Join(proc, "fs", "nfsd", "pool_stats")
+
+	var err error
+	n.operations, err = filter.NewIncludeExcludeFilter(n.IncludeOperations, n.ExcludeOperations)
+	if err != nil {
+		return fmt.Errorf("invalid operations: %v", err)
+	}
+	return nil
+}
+
+func (n *NFSD) Gather(acc telegraf.Accumulator) error {
+	file, err := os.Open(n.nfsdPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := n.processText(file, acc); err != nil {
+		return err
+	}
+
+	// The statistics of the thread pools are only available if the nfsd
+	// filesystem is mounted.
+	pools, err := os.Open(n.poolStatsPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			acc.AddError(err)
+		}
+		return nil
+	}
+	defer pools.Close()
+
+	acc.AddError(processPoolStats(pools, acc))
+	return nil
+}
+
+// processText parses the server statistics:
+//   rc 0 1286 7829
+//   th 8 0 0.000 0.000 0.000 0.000 0.000 0.000 0.000 0.000 0.000 0.000
+//   proc3 22 2 10 0 5 11 0 100 200 ...
+// The first value of the procedure lines is the number of counts following.
+func (n *NFSD) processText(r io.Reader, acc telegraf.Accumulator) error {
+	fields := make(map[string]interface{})
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 2 {
+			continue
+		}
+
+		if names, ok := statFields[parts[0]]; ok {
+			for i, name := range names {
+				if i+1 >= len(parts) {
+					break
+				}
+				v, err := strconv.ParseInt(parts[i+1], 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid value %q of %q", parts[i+1], parts[0])
+				}
+				fields[name] = v
+			}
+			continue
+		}
+
+		var version string
+		var names []string
+		switch parts[0] {
+		case "proc2":
+			version, names = "2", nfs2Procedures
+		case "proc3":
+			version, names = "3", nfs3Procedures
+		case "proc4":
+			version, names = "4", nfs4Procedures
+		case "proc4ops":
+			version, names = "4", nfs4Operations
+		default:
+			continue
+		}
+		if err := n.processOperations(version, names, parts[2:], acc); err != nil {
+			return fmt.Errorf("invalid %q: %v", parts[0], err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(fields) > 0 {
+		acc.AddFields("nfsd", fields, nil)
+	}
+	return nil
+}
+
+func (n *NFSD) processOperations(version string, names []string, counts []string, acc telegraf.Accumulator) error {
+	for i, count := range counts {
+		name := fmt.Sprintf("OP_%d", i)
+		if i < len(names) {
+			name = names[i]
+		}
+		// Operation numbers not assigned to an operation.
+		if name == "" {
+			continue
+		}
+		if !n.operations.Match(name) {
+			continue
+		}
+
+		v, err := strconv.ParseInt(count, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid count %q", count)
+		}
+		tags := map[string]string{"version": version, "operation": name}
+		acc.AddFields("nfsd_ops", map[string]interface{}{"ops": v}, tags)
+	}
+	return nil
+}
+
+// processPoolStats parses the statistics of the thread pools:
+//   # pool packets-arrived sockets-enqueued threads-woken threads-timedout
+//   0 1234 56 1178 0
+func processPoolStats(r io.Reader, acc telegraf.Accumulator) error {
+	scanner := bufio.NewScanner(r)
+	var header []string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			header = strings.Fields(strings.TrimPrefix(line, "#"))
+			continue
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) < 2 || len(header) != len(parts) {
+			continue
+		}
+
+		fields := make(map[string]interface{}, len(parts)-1)
+		for i, part := range parts[1:] {
+			v, err := strconv.ParseInt(part, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid pool statistic %q", part)
+			}
+			fields[strings.Replace(header[i+1], "-", "_", -1)] = v
+		}
+		acc.AddFields("nfsd_pool", fields, map[string]string{"pool": parts[0]})
+	}
+	return scanner.Err()
+}
+
+func init() {
+	inputs.Add("nfsd", func() telegraf.Input {
+		return &NFSD{}
+	})
+}
@@ -0,0 +1,127 @@
+package nfsd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func newNFSD(t *testing.T, include []string) *NFSD {
+	os.Setenv("HOST_PROC", "testdata/proc")
+	defer os.Unsetenv("HOST_PROC")
+
+	n := &NFSD{
+		IncludeOperations: include,
+		Log:               testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+	return n
+}
+
+func TestNFSDGather(t *testing.T) {
+	n := newNFSD(t, []string{"READ", "WRITE", "GETATTR", "COMPOUND"})
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(n.Gather))
+
+	ops := func(version, operation string, count int64) telegraf.Metric {
+		return testutil.MustMetric("nfsd_ops",
+			map[string]string{"version": version, "operation": operation},
+			map[string]interface{}{"ops": count},
+			time.Unix(0, 0))
+	}
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("nfsd",
+			map[string]string{},
+			map[string]interface{}{
+				"rc_hits":      int64(0),
+				"rc_misses":    int64(1286),
+				"rc_nocache":   int64(7829),
+				"fh_stale":     int64(3),
+				"io_read":      int64(1073741824),
+				"io_write":     int64(2147483648),
+				"th_threads":   int64(8),
+				"th_fullcnt":   int64(12),
+				"net_count":    int64(9118),
+				"net_udp":      int64(0),
+				"net_tcp":      int64(9118),
+				"net_tcpconn":  int64(5),
+				"rpc_calls":    int64(9115),
+				"rpc_badcalls": int64(2),
+				"rpc_badfmt":   int64(1),
+				"rpc_badauth":  int64(1),
+				"rpc_badclnt":  int64(0),
+			},
+			time.Unix(0, 0)),
+		ops("3", "GETATTR", 10),
+		ops("3", "READ", 100),
+		ops("3", "WRITE", 200),
+		ops("4", "COMPOUND", 9113),
+		ops("4", "GETATTR", 80),
+		ops("4", "READ", 2),
+		ops("4", "WRITE", 40),
+		testutil.MustMetric("nfsd_pool",
+			map[string]string{"pool": "0"},
+			map[string]interface{}{
+				"packets_arrived":  int64(9118),
+				"sockets_enqueued": int64(56),
+				"threads_woken":    int64(9062),
+				"threads_timedout": int64(3),
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("nfsd_pool",
+			map[string]string{"pool": "1"},
+			map[string]interface{}{
+				"packets_arrived":  int64(4210),
+				"sockets_enqueued": int64(12),
+				"threads_woken":    int64(4198),
+				"threads_timedout": int64(0),
+			},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.SortMetrics(), testutil.IgnoreTime())
+}
+
+func TestNFSDAllOperations(t *testing.T) {
+	n := newNFSD(t, nil)
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(n.Gather))
+
+	// 22 NFSv3 procedures, 2 NFSv4 procedures and 72 NFSv4 operations less
+	// the 3 unassigned ones.
+	var count int
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() == "nfsd_ops" {
+			count++
+		}
+	}
+	require.Equal(t, 93, count)
+}
+
+func TestNFSDUnknownOperation(t *testing.T) {
+	n := newNFSD(t, nil)
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.processOperations("4", nfs4Procedures, []string{"1", "2", "3"}, &acc))
+	acc.AssertContainsTaggedFields(t, "nfsd_ops",
+		map[string]interface{}{"ops": int64(3)},
+		map[string]string{"version": "4", "operation": "OP_2"})
+
+	require.Error(t, n.processOperations("3", nfs3Procedures, []string{"x"}, &acc))
+}
+
+func TestNFSDMissing(t *testing.T) {
+	n := &NFSD{Log: testutil.Logger{}}
+	require.NoError(t, n.Init())
+	n.nfsdPath = "testdata/nonexistent"
+
+	var acc testutil.Accumulator
+	require.Error(t, n.Gather(&acc))
+}
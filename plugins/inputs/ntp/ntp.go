@@ -0,0 +1,214 @@
+package ntp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/beevik/ntp"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	tlsint "github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+const sampleConfig = `
+  ## NTP servers to query, as "host" or "host:port".  With NTS enabled these
+  ## are the NTS-KE servers, the default port is then 4460.
+  servers = ["pool.ntp.org"]
+
+  ## Maximum time to wait for the response of each server.
+  # timeout = "5s"
+
+  ## NTP protocol version of the queries, 2 to 4.
+  # version = 4
+
+  ## Local address to send the queries from.
+  # local_address = ""
+
+  ## Authenticate the queries with Network Time Security (RFC 8915), all
+  ## servers must support NTS.  Requires version 4.
+  # nts = false
+
+  ## Optional TLS Config for the NTS key establishment, the system CAs
+  ## verify the servers by default.
+  # tls_ca = "/etc/telegraf/ca.pem"
+  ## Use TLS but skip chain & host verification
+  # insecure_skip_verify = false
+`
+
+// leapStatus are the names of the leap indicators, as reported by chronyc.
+var leapStatus = map[ntp.LeapIndicator]string{
+	ntp.LeapNoWarning: "normal",
+	ntp.LeapAddSecond: "insert_second",
+	ntp.LeapDelSecond: "delete_second",
+	ntp.LeapNotInSync: "not_synchronised",
+}
+
+type NTP struct {
+	Servers      []string        `toml:"servers"`
+	Timeout      config.Duration `toml:"timeout"`
+	Version      int             `toml:"version"`
+	LocalAddress string          `toml:"local_address"`
+	NTS          bool            `toml:"nts"`
+	tlsint.ClientConfig
+	Log telegraf.Logger `toml:"-"`
+
+	servers   []server
+	tlsConfig *tls.Config
+}
+
+// server is the address of an NTP server, the port is 0 for the default
+// port.
+type server struct {
+	name string
+	host string
+	port int
+	// session are the keys and cookies of an NTS server, servers are
+	// queried by one goroutine at a time.
+	session *ntsSession
+}
+
+func (n *NTP) SampleConfig() string {
+	return sampleConfig
+}
+
+func (n *NTP) Description() string {
+	return "Query NTP servers for the offset of the local clock"
+}
+
+func (n *NTP) Init() error {
+	if len(n.Servers) == 0 {
+		return errors.New("no servers configured")
+	}
+	if n.Version < 2 || n.Version > 4 {
+		return fmt.Errorf("invalid version %d", n.Version)
+	}
+	if n.NTS && n.Version != 4 {
+		return fmt.Errorf("NTS requires version 4")
+	}
+
+	tlsConfig, err := n.ClientConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	n.tlsConfig = tlsConfig
+
+	n.servers = make([]server, 0, len(n.Servers))
+	for _, name := range n.Servers {
+		s, err := parseServer(name)
+		if err != nil {
+			return err
+		}
+		n.servers = append(n.servers, s)
+	}
+	return nil
+}
+
+// parseServer splits the port off a server address, IPv6 addresses with a
+// port must be in brackets.
+func parseServer(name string) (server, error) {
+	host, portStr, err := net.SplitHostPort(name)
+	if err != nil {
+		return server{name: name, host: name}, nil
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return server{}, fmt.Errorf("invalid port in server %q", name)
+	}
+	return server{name: name, host: host, port: port}, nil
+}
+
+func (n *NTP) Gather(acc telegraf.Accumulator) error {
+	var wg sync.WaitGroup
+	for i := range n.servers {
+		wg.Add(1)
+		go func(s *server) {
+			defer wg.Done()
+			if err := n.gatherServer(s, acc); err != nil {
+				acc.AddError(fmt.Errorf("querying %q failed: %v", s.name, err))
+			}
+		}(&n.servers[i])
+	}
+	wg.Wait()
+	return nil
+}
+
+func (n *NTP) gatherServer(s *server, acc telegraf.Accumulator) error {
+	var resp *ntp.Response
+	var err error
+	if n.NTS {
+		resp, err = n.queryNTS(s)
+	} else {
+		resp, err = ntp.QueryWithOptions(s.host, ntp.QueryOptions{
+			Timeout:      time.Duration(n.Timeout),
+			Version:      n.Version,
+			LocalAddress: n.LocalAddress,
+			Port:         s.port,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	tags := map[string]string{
+		"server":      s.name,
+		"stratum":     strconv.Itoa(int(resp.Stratum)),
+		"leap_status": leapStatus[resp.Leap],
+	}
+
+	// A stratum of 0 is a kiss-of-death message, the reference id holds the
+	// code and the timestamps are not valid.
+	if resp.Stratum == 0 {
+		acc.AddFields("ntp", map[string]interface{}{"kiss_code": resp.KissCode}, tags)
+		return nil
+	}
+
+	tags["reference_id"] = referenceID(resp.Stratum, resp.ReferenceID)
+	fields := map[string]interface{}{
+		"offset":          resp.ClockOffset.Seconds(),
+		"rtt":             resp.RTT.Seconds(),
+		"root_delay":      resp.RootDelay.Seconds(),
+		"root_dispersion": resp.RootDispersion.Seconds(),
+		"root_distance":   resp.RootDistance.Seconds(),
+		"precision":       resp.Precision.Seconds(),
+	}
+	acc.AddFields("ntp", fields, tags)
+	return nil
+}
+
+// referenceID formats the reference id of a response, the name of the
+// reference clock of primary servers and the IPv4 address of the upstream
+// server, or a hash of its IPv6 address, of secondary servers.
+func referenceID(stratum uint8, id uint32) string {
+	b := []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	if stratum > 1 {
+		return net.IP(b).String()
+	}
+
+	name := make([]byte, 0, len(b))
+	for _, c := range b {
+		if c == 0 {
+			break
+		}
+		name = append(name, c)
+	}
+	return string(name)
+}
+
+func init() {
+	inputs.Add("ntp", func() telegraf.Input {
+		return &NTP{
+			Timeout: config.Duration(5 * time.Second),
+			Version: 4,
+		}
+	})
+}
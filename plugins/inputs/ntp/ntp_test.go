@@ -0,0 +1,166 @@
+package ntp
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+// toNtpTime returns the NTP timestamp of a time, the seconds since 1900 in
+// the upper and the fraction of a second in the lower 32 bits.
+func toNtpTime(t time.Time) uint64 {
+	secs := uint64(t.Unix() + 2208988800)
+	frac := (uint64(t.Nanosecond()) << 32) / 1e9
+	return secs<<32 | frac
+}
+
+// serveNTP answers NTP queries on a local UDP port with the given stratum
+// and reference id and returns the address of the server.  No queries are
+// answered if reply is false.
+func serveNTP(t *testing.T, stratum uint8, refID uint32, reply bool) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		req := make([]byte, 48)
+		for {
+			n, addr, err := conn.ReadFrom(req)
+			if err != nil {
+				return
+			}
+			if n < 48 || !reply {
+				continue
+			}
+
+			now := toNtpTime(time.Now())
+			resp := make([]byte, 48)
+			// No leap warning, version 4 and server mode, a precision of
+			// 2^-20 seconds, a root delay of 1/32 and a root dispersion of
+			// 1/16 seconds.
+			resp[0] = 4<<3 | 4
+			resp[1] = stratum
+			resp[2] = 6
+			resp[3] = 0xec
+			binary.BigEndian.PutUint32(resp[4:], 0x0800)
+			binary.BigEndian.PutUint32(resp[8:], 0x1000)
+			binary.BigEndian.PutUint32(resp[12:], refID)
+			binary.BigEndian.PutUint64(resp[16:], now-1<<32)
+			copy(resp[24:32], req[40:48])
+			binary.BigEndian.PutUint64(resp[32:], now)
+			binary.BigEndian.PutUint64(resp[40:], now)
+			if _, err := conn.WriteTo(resp, addr); err != nil {
+				return
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func newNTP(t *testing.T, servers ...string) *NTP {
+	n := &NTP{
+		Servers: servers,
+		Timeout: config.Duration(time.Second),
+		Version: 4,
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+	return n
+}
+
+func TestNTPGather(t *testing.T) {
+	addr := serveNTP(t, 2, 0xc0000201, true)
+	n := newNTP(t, addr)
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(n.Gather))
+	require.Len(t, acc.Metrics, 1)
+
+	m := acc.Metrics[0]
+	require.Equal(t, "ntp", m.Measurement)
+	require.Equal(t, map[string]string{
+		"server":       addr,
+		"stratum":      "2",
+		"leap_status":  "normal",
+		"reference_id": "192.0.2.1",
+	}, m.Tags)
+
+	require.InDelta(t, 0, m.Fields["offset"], 0.5)
+	require.InDelta(t, 0, m.Fields["rtt"], 0.5)
+	require.Equal(t, 0.03125, m.Fields["root_delay"])
+	require.Equal(t, 0.0625, m.Fields["root_dispersion"])
+	require.InDelta(t, 0.078, m.Fields["root_distance"], 0.5)
+	require.Equal(t, 9.53e-07, m.Fields["precision"])
+}
+
+func TestNTPKissOfDeath(t *testing.T) {
+	addr := serveNTP(t, 0, 0x52415445, true)
+	n := newNTP(t, addr)
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(n.Gather))
+	acc.AssertContainsTaggedFields(t, "ntp",
+		map[string]interface{}{"kiss_code": "RATE"},
+		map[string]string{"server": addr, "stratum": "0", "leap_status": "normal"})
+}
+
+func TestNTPTimeout(t *testing.T) {
+	addr := serveNTP(t, 2, 0, false)
+	answering := serveNTP(t, 2, 0, true)
+	n := newNTP(t, addr, answering)
+	n.Timeout = config.Duration(100 * time.Millisecond)
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.Contains(t, acc.Errors[0].Error(), addr)
+	require.Len(t, acc.Metrics, 1)
+	require.Equal(t, answering, acc.Metrics[0].Tags["server"])
+}
+
+func TestNTPInit(t *testing.T) {
+	tests := []struct {
+		name string
+		n    *NTP
+	}{
+		{"no servers", &NTP{Version: 4}},
+		{"invalid version", &NTP{Servers: []string{"pool.ntp.org"}, Version: 5}},
+		{"invalid port", &NTP{Servers: []string{"pool.ntp.org:ntp"}, Version: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, tt.n.Init())
+		})
+	}
+}
+
+func TestParseServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected server
+	}{
+		{"pool.ntp.org", server{name: "pool.ntp.org", host: "pool.ntp.org"}},
+		{"127.0.0.1:1123", server{name: "127.0.0.1:1123", host: "127.0.0.1", port: 1123}},
+		{"2001:db8::1", server{name: "2001:db8::1", host: "2001:db8::1"}},
+		{"[2001:db8::1]:123", server{name: "[2001:db8::1]:123", host: "2001:db8::1", port: 123}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseServer(tt.name)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, s)
+		})
+	}
+}
+
+func TestReferenceID(t *testing.T) {
+	require.Equal(t, "GPS", referenceID(1, 0x47505300))
+	require.Equal(t, "PPS", referenceID(1, 0x50505300))
+	require.Equal(t, "10.0.0.1", referenceID(3, 0x0a000001))
+}
@@ -0,0 +1,460 @@
+package ntp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// Network Time Security (RFC 8915): the keys and cookies are negotiated by
+// the NTS key establishment (NTS-KE) over TLS, the NTP requests carry a
+// cookie and are authenticated with the keys, the responses return new
+// cookies encrypted.
+const (
+	ntsKEPort  = 4460
+	ntsKEALPN  = "ntske/1"
+	ntpPort    = 123
+	ntsLabel   = "EXPORTER-network-time-security"
+	ntsKeySize = 32
+	// maxCookies is the number of cookies the client keeps, missing cookies
+	// are requested again with placeholders.
+	maxCookies = 8
+	// maxKEResponse limits the size of the NTS-KE response.
+	maxKEResponse = 64 * 1024
+
+	protocolNTPv4     = 0
+	aeadAESSIVCMAC256 = 15
+
+	recordCritical     = 0x8000
+	recordEnd          = 0
+	recordNextProtocol = 1
+	recordError        = 2
+	recordWarning      = 3
+	recordAEAD         = 4
+	recordCookie       = 5
+	recordServer       = 6
+	recordPort         = 7
+
+	extUniqueID          = 0x0104
+	extCookie            = 0x0204
+	extCookiePlaceholder = 0x0304
+	extAuthenticator     = 0x0404
+
+	ntpHeaderSize = 48
+	ntpEpoch      = 2208988800
+)
+
+// errNTSNAK is returned if the server did not accept the cookie and a new
+// key establishment is needed.
+var errNTSNAK = errors.New("NTS cookie rejected")
+
+// ntsSession holds the keys and cookies negotiated with a server.
+type ntsSession struct {
+	// address of the NTP server, which may differ from the NTS-KE server.
+	address string
+	c2s     *aesSIV
+	s2c     *aesSIV
+	cookies [][]byte
+}
+
+// queryNTS queries the server with NTS, the key establishment is done once
+// and again if the cookies are used up or rejected.
+func (n *NTP) queryNTS(s *server) (*ntp.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if s.session == nil || len(s.session.cookies) == 0 {
+			session, err := n.keyExchange(s)
+			if err != nil {
+				return nil, fmt.Errorf("NTS key establishment failed: %v", err)
+			}
+			s.session = session
+		}
+
+		resp, err := n.exchangeNTS(s.session)
+		if err == errNTSNAK && attempt == 0 {
+			n.Log.Debugf("Cookie rejected by %q, renewing the keys", s.name)
+			s.session = nil
+			continue
+		}
+		if err == errNTSNAK {
+			s.session = nil
+		}
+		return resp, err
+	}
+}
+
+// keyExchange negotiates the keys and cookies with the NTS-KE server.
+func (n *NTP) keyExchange(s *server) (*ntsSession, error) {
+	timeout := time.Duration(n.Timeout)
+	dialer := &net.Dialer{Timeout: timeout}
+	if n.LocalAddress != "" {
+		laddr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(n.LocalAddress, "0"))
+		if err != nil {
+			return nil, err
+		}
+		dialer.LocalAddr = laddr
+	}
+
+	tlsConfig := n.tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = s.host
+	}
+	tlsConfig.NextProtos = []string{ntsKEALPN}
+	tlsConfig.MinVersion = tls.VersionTLS13
+
+	port := s.port
+	if port == 0 {
+		port = ntsKEPort
+	}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(s.host, strconv.Itoa(port)), tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	state := conn.ConnectionState()
+	if state.NegotiatedProtocol != ntsKEALPN {
+		return nil, errors.New("server does not support NTS-KE")
+	}
+
+	var req []byte
+	req = appendRecord(req, recordNextProtocol, []byte{0, protocolNTPv4})
+	req = appendRecord(req, recordAEAD, []byte{0, aeadAESSIVCMAC256})
+	req = appendRecord(req, recordEnd, nil)
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	session := &ntsSession{}
+	host, ntpPortNumber := s.host, ntpPort
+	var protocol, aead bool
+	r := bufio.NewReader(io.LimitReader(conn, maxKEResponse))
+	for done := false; !done; {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+		typ := binary.BigEndian.Uint16(header[:])
+		critical := typ&recordCritical != 0
+		typ &^= recordCritical
+		body := make([]byte, binary.BigEndian.Uint16(header[2:]))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case recordEnd:
+			done = true
+		case recordNextProtocol:
+			protocol = bytes.Equal(body, []byte{0, protocolNTPv4})
+		case recordError:
+			if len(body) < 2 {
+				return nil, errors.New("server reported an error")
+			}
+			return nil, fmt.Errorf("server reported error %d", binary.BigEndian.Uint16(body))
+		case recordWarning:
+			if len(body) >= 2 {
+				n.Log.Warnf("NTS-KE server %q reported warning %d", s.name, binary.BigEndian.Uint16(body))
+			}
+		case recordAEAD:
+			aead = bytes.Equal(body, []byte{0, aeadAESSIVCMAC256})
+		case recordCookie:
+			session.cookies = append(session.cookies, body)
+		case recordServer:
+			host = string(body)
+		case recordPort:
+			if len(body) != 2 {
+				return nil, errors.New("invalid port record")
+			}
+			ntpPortNumber = int(binary.BigEndian.Uint16(body))
+		default:
+			if critical {
+				return nil, fmt.Errorf("unsupported critical record %d", typ)
+			}
+		}
+	}
+	if !protocol {
+		return nil, errors.New("server does not support NTPv4")
+	}
+	if !aead {
+		return nil, errors.New("server does not support AEAD_AES_SIV_CMAC_256")
+	}
+	if len(session.cookies) == 0 {
+		return nil, errors.New("server sent no cookies")
+	}
+	session.address = net.JoinHostPort(host, strconv.Itoa(ntpPortNumber))
+
+	// The keys are exported from the TLS session, the context is the
+	// protocol, the algorithm and the direction.
+	for i, key := range []**aesSIV{&session.c2s, &session.s2c} {
+		context := []byte{0, protocolNTPv4, 0, aeadAESSIVCMAC256, byte(i)}
+		material, err := state.ExportKeyingMaterial(ntsLabel, context, ntsKeySize)
+		if err != nil {
+			return nil, err
+		}
+		if *key, err = newAESSIV(material); err != nil {
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+// exchangeNTS sends an authenticated NTP request using the next cookie of
+// the session and verifies the response.
+func (n *NTP) exchangeNTS(session *ntsSession) (*ntp.Response, error) {
+	raddr, err := net.ResolveUDPAddr("udp", session.address)
+	if err != nil {
+		return nil, err
+	}
+	var laddr *net.UDPAddr
+	if n.LocalAddress != "" {
+		laddr, err = net.ResolveUDPAddr("udp", net.JoinHostPort(n.LocalAddress, "0"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	conn, err := net.DialUDP("udp", laddr, raddr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(time.Duration(n.Timeout))); err != nil {
+		return nil, err
+	}
+
+	// Every cookie is used once, a lost response costs a cookie.
+	cookie := session.cookies[0]
+	session.cookies = session.cookies[1:]
+
+	// The leap indicator is "not synchronised", version 4 and client mode.
+	// The transmit timestamp is random, like the unique identifier, so it
+	// does not reveal the local clock.
+	req := make([]byte, ntpHeaderSize, 1024)
+	req[0] = 3<<6 | 4<<3 | 3
+	uniqueID := make([]byte, 32)
+	nonce := make([]byte, 16)
+	for _, b := range [][]byte{req[40:48], uniqueID, nonce} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+	}
+	req = appendExtension(req, extUniqueID, uniqueID)
+	req = appendExtension(req, extCookie, cookie)
+	for i := len(session.cookies) + 1; i < maxCookies; i++ {
+		req = appendExtension(req, extCookiePlaceholder, make([]byte, len(cookie)))
+	}
+	req = appendExtension(req, extAuthenticator, authenticator(nonce, session.c2s.seal(nil, req, nonce)))
+
+	xmitTime := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 64*1024)
+	size, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	recvTime := xmitTime.Add(time.Since(xmitTime))
+	data := buf[:size]
+
+	if len(data) < ntpHeaderSize {
+		return nil, errors.New("response too short")
+	}
+	if data[0]&0x07 != 4 {
+		return nil, errors.New("invalid mode in response")
+	}
+	if !bytes.Equal(data[24:32], req[40:48]) {
+		return nil, errors.New("server response mismatch")
+	}
+
+	var uniqueIDMatches, authenticated bool
+	var cookies [][]byte
+	err = forEachExtension(data[ntpHeaderSize:], func(typ uint16, body []byte, offset int) (bool, error) {
+		switch typ {
+		case extUniqueID:
+			uniqueIDMatches = bytes.Equal(body, uniqueID)
+		case extAuthenticator:
+			nonce, ciphertext, err := parseAuthenticator(body)
+			if err != nil {
+				return false, err
+			}
+			plaintext, err := session.s2c.open(ciphertext, data[:ntpHeaderSize+offset], nonce)
+			if err != nil {
+				return false, err
+			}
+			authenticated = true
+			// The encrypted extension fields hold the new cookies, the
+			// fields after the authenticator are not authenticated.
+			return false, forEachExtension(plaintext, func(typ uint16, body []byte, _ int) (bool, error) {
+				if typ == extCookie {
+					cookies = append(cookies, body)
+				}
+				return true, nil
+			})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !uniqueIDMatches {
+		return nil, errors.New("server response mismatch")
+	}
+
+	stratum := data[1]
+	refID := binary.BigEndian.Uint32(data[12:])
+	if !authenticated {
+		// The server could not decrypt the cookie, the NAK is the only
+		// unauthenticated response to act on.
+		if stratum == 0 && refID == 0x4e54534e { // "NTSN"
+			return nil, errNTSNAK
+		}
+		return nil, errors.New("response is not authenticated")
+	}
+	session.cookies = append(session.cookies, cookies...)
+
+	recv := binary.BigEndian.Uint64(data[32:])
+	xmit := binary.BigEndian.Uint64(data[40:])
+	if xmit == 0 {
+		return nil, errors.New("invalid transmit time in response")
+	}
+	if recv > xmit {
+		return nil, errors.New("server clock ticked backwards")
+	}
+	return newResponse(data, xmitTime, recvTime), nil
+}
+
+// newResponse calculates the offset and round trip time like the plain NTP
+// queries, from the times the request was sent and the response received.
+func newResponse(data []byte, xmitTime, recvTime time.Time) *ntp.Response {
+	rec := ntpTimestamp(binary.BigEndian.Uint64(data[32:]))
+	xmt := ntpTimestamp(binary.BigEndian.Uint64(data[40:]))
+
+	rtt := recvTime.Sub(xmitTime) - xmt.Sub(rec)
+	if rtt < 0 {
+		rtt = 0
+	}
+	r := &ntp.Response{
+		Time:           xmt,
+		ClockOffset:    (rec.Sub(xmitTime) + xmt.Sub(recvTime)) / 2,
+		RTT:            rtt,
+		Precision:      interval(int8(data[3])),
+		Stratum:        data[1],
+		ReferenceID:    binary.BigEndian.Uint32(data[12:]),
+		ReferenceTime:  ntpTimestamp(binary.BigEndian.Uint64(data[16:])),
+		RootDelay:      shortDuration(binary.BigEndian.Uint32(data[4:])),
+		RootDispersion: shortDuration(binary.BigEndian.Uint32(data[8:])),
+		Leap:           ntp.LeapIndicator(data[0] >> 6),
+		Poll:           interval(int8(data[2])),
+	}
+	r.RootDistance = (r.RTT+r.RootDelay)/2 + r.RootDispersion
+	if r.Stratum == 0 {
+		id := data[12:16]
+		for _, c := range id {
+			if c < 32 || c > 126 {
+				return r
+			}
+		}
+		r.KissCode = string(id)
+	}
+	return r
+}
+
+// appendRecord appends an NTS-KE record, all records sent are critical.
+func appendRecord(b []byte, typ uint16, body []byte) []byte {
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(b[len(b)-4:], typ|recordCritical)
+	binary.BigEndian.PutUint16(b[len(b)-2:], uint16(len(body)))
+	return append(b, body...)
+}
+
+// appendExtension appends an NTP extension field padded to a multiple of
+// four bytes.
+func appendExtension(b []byte, typ uint16, body []byte) []byte {
+	length := 4 + (len(body)+3)/4*4
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(b[len(b)-4:], typ)
+	binary.BigEndian.PutUint16(b[len(b)-2:], uint16(length))
+	b = append(b, body...)
+	return append(b, make([]byte, length-4-len(body))...)
+}
+
+// forEachExtension calls fn with the type, body and offset of each
+// extension field until fn returns false.
+func forEachExtension(data []byte, fn func(typ uint16, body []byte, offset int) (bool, error)) error {
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < 4 {
+			return errors.New("truncated extension field")
+		}
+		typ := binary.BigEndian.Uint16(data[offset:])
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if length < 4 || length%4 != 0 || length > len(data)-offset {
+			return fmt.Errorf("invalid length %d of extension field %#04x", length, typ)
+		}
+		next, err := fn(typ, data[offset+4:offset+length], offset)
+		if err != nil || !next {
+			return err
+		}
+		offset += length
+	}
+	return nil
+}
+
+// authenticator returns the body of the NTS authenticator extension field.
+func authenticator(nonce, ciphertext []byte) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint16(b, uint16(len(nonce)))
+	binary.BigEndian.PutUint16(b[2:], uint16(len(ciphertext)))
+	b = append(b, nonce...)
+	b = append(b, make([]byte, (4-len(nonce)%4)%4)...)
+	return append(b, ciphertext...)
+}
+
+// parseAuthenticator returns the nonce and ciphertext of an NTS
+// authenticator extension field.
+func parseAuthenticator(body []byte) ([]byte, []byte, error) {
+	if len(body) < 4 {
+		return nil, nil, errors.New("truncated authenticator")
+	}
+	nonceLength := int(binary.BigEndian.Uint16(body))
+	ciphertextLength := int(binary.BigEndian.Uint16(body[2:]))
+	start := 4 + (nonceLength+3)/4*4
+	if start+ciphertextLength > len(body) {
+		return nil, nil, errors.New("truncated authenticator")
+	}
+	return body[4 : 4+nonceLength], body[start : start+ciphertextLength], nil
+}
+
+// ntpTimestamp converts a 64 bit NTP timestamp, the seconds since 1900 and
+// the fraction of a second.
+func ntpTimestamp(t uint64) time.Time {
+	nsec := (t & 0xffffffff) * 1e9 >> 32
+	return time.Unix(int64(t>>32)-ntpEpoch, int64(nsec))
+}
+
+// shortDuration converts a 32 bit NTP duration, 16 bits of seconds and 16
+// bits of fraction.
+func shortDuration(d uint32) time.Duration {
+	return time.Duration((uint64(d)*uint64(time.Second) + 1<<15) >> 16)
+}
+
+// interval converts a poll interval or precision in log2 seconds.
+func interval(exp int8) time.Duration {
+	if exp >= 0 {
+		return time.Second << uint(exp)
+	}
+	return time.Second >> uint(-exp)
+}
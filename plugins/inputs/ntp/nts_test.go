@@ -0,0 +1,333 @@
+package ntp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestAESSIV(t *testing.T) {
+	decode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		return b
+	}
+
+	// Test vectors of RFC 5297, appendix A.
+	s, err := newAESSIV(decode("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"))
+	require.NoError(t, err)
+	ad := decode("101112131415161718191a1b1c1d1e1f2021222324252627")
+	ciphertext := s.seal(decode("112233445566778899aabbccddee"), ad)
+	require.Equal(t, "85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c", hex.EncodeToString(ciphertext))
+	plaintext, err := s.open(ciphertext, ad)
+	require.NoError(t, err)
+	require.Equal(t, "112233445566778899aabbccddee", hex.EncodeToString(plaintext))
+
+	s, err = newAESSIV(decode("7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f"))
+	require.NoError(t, err)
+	ciphertext = s.seal([]byte("this is some plaintext to encrypt using SIV-AES"),
+		decode("00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100"),
+		decode("102030405060708090a0"),
+		decode("09f911029d74e35bd84156c5635688c0"))
+	require.Equal(t, "7bdb6e3b432667eb06f4d14bff2fbd0fcb900f2fddbe404326601965c889bf17"+
+		"dba77ceb094fa663b7a3f748ba8af829ea64ad544a272e9c485b62a3fd5c0d", hex.EncodeToString(ciphertext))
+
+	// Modified ciphertexts are rejected.
+	ciphertext[20] ^= 1
+	_, err = s.open(ciphertext, decode("00112233445566778899aabbccddeeff"))
+	require.Error(t, err)
+}
+
+// ntsServer is an NTS-KE server along with the NTP server it hands out
+// cookies for.  The cookies simply hold the keys of the client.
+type ntsServer struct {
+	t       *testing.T
+	ke      net.Listener
+	ntp     net.PacketConn
+	cookies int
+	sync.Mutex
+	exchanges int
+	requests  int
+	// nak rejects the cookies of the next requests.
+	nak int
+	// tamper modifies the authenticated responses.
+	tamper bool
+}
+
+func newNTSServer(t *testing.T, cookies int) *ntsServer {
+	pki := testutil.NewPKI("../../../testutil/pki")
+	cert, err := tls.LoadX509KeyPair(pki.ServerCertPath(), pki.ServerKeyPath())
+	require.NoError(t, err)
+	ke, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{ntsKEALPN},
+		MinVersion:   tls.VersionTLS13,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { ke.Close() })
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	s := &ntsServer{t: t, ke: ke, ntp: conn, cookies: cookies}
+	go s.serveKE()
+	go s.serveNTP()
+	return s
+}
+
+func (s *ntsServer) address() string {
+	return s.ke.Addr().String()
+}
+
+func (s *ntsServer) serveKE() {
+	for {
+		conn, err := s.ke.Accept()
+		if err != nil {
+			return
+		}
+		s.exchange(conn.(*tls.Conn))
+	}
+}
+
+func (s *ntsServer) exchange(conn *tls.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint16(header[2:]))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+		if binary.BigEndian.Uint16(header)&^recordCritical == recordEnd {
+			break
+		}
+	}
+
+	state := conn.ConnectionState()
+	var cookie []byte
+	for _, direction := range []byte{0, 1} {
+		key, err := state.ExportKeyingMaterial(ntsLabel, []byte{0, 0, 0, aeadAESSIVCMAC256, direction}, ntsKeySize)
+		if err != nil {
+			return
+		}
+		cookie = append(cookie, key...)
+	}
+
+	var resp []byte
+	resp = appendRecord(resp, recordNextProtocol, []byte{0, protocolNTPv4})
+	resp = appendRecord(resp, recordAEAD, []byte{0, aeadAESSIVCMAC256})
+	for i := 0; i < s.cookies; i++ {
+		resp = appendRecord(resp, recordCookie, cookie)
+	}
+	resp = appendRecord(resp, recordServer, []byte("127.0.0.1"))
+	port := make([]byte, 2)
+	binary.BigEndian.PutUint16(port, uint16(s.ntp.LocalAddr().(*net.UDPAddr).Port))
+	resp = appendRecord(resp, recordPort, port)
+	resp = appendRecord(resp, recordEnd, nil)
+	if _, err := conn.Write(resp); err != nil {
+		return
+	}
+
+	s.Lock()
+	s.exchanges++
+	s.Unlock()
+}
+
+func (s *ntsServer) serveNTP() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := s.ntp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp := s.respond(buf[:n]); resp != nil {
+			if _, err := s.ntp.WriteTo(resp, addr); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// respond answers an NTS request, nil drops invalid requests.
+func (s *ntsServer) respond(req []byte) []byte {
+	var uniqueID, cookie []byte
+	var placeholders int
+	var authenticated bool
+	err := forEachExtension(req[ntpHeaderSize:], func(typ uint16, body []byte, offset int) (bool, error) {
+		switch typ {
+		case extUniqueID:
+			uniqueID = body
+		case extCookie:
+			cookie = body
+		case extCookiePlaceholder:
+			placeholders++
+		case extAuthenticator:
+			c2s, err := newAESSIV(cookie[:ntsKeySize])
+			require.NoError(s.t, err)
+			nonce, ciphertext, err := parseAuthenticator(body)
+			require.NoError(s.t, err)
+			_, err = c2s.open(ciphertext, req[:ntpHeaderSize+offset], nonce)
+			authenticated = err == nil
+		}
+		return true, nil
+	})
+	if err != nil || !authenticated {
+		return nil
+	}
+
+	s.Lock()
+	s.requests++
+	nak := s.nak > 0
+	if nak {
+		s.nak--
+	}
+	tamper := s.tamper
+	s.Unlock()
+
+	now := toNtpTime(time.Now())
+	resp := make([]byte, ntpHeaderSize)
+	resp[0] = 4<<3 | 4
+	copy(resp[24:32], req[40:48])
+	if nak {
+		binary.BigEndian.PutUint32(resp[12:], 0x4e54534e)
+		return appendExtension(resp, extUniqueID, uniqueID)
+	}
+	resp[1] = 1
+	resp[3] = 0xec
+	binary.BigEndian.PutUint32(resp[4:], 0x0800)
+	binary.BigEndian.PutUint32(resp[8:], 0x1000)
+	copy(resp[12:16], "GPS")
+	binary.BigEndian.PutUint64(resp[16:], now-1<<32)
+	binary.BigEndian.PutUint64(resp[32:], now)
+	binary.BigEndian.PutUint64(resp[40:], now)
+	resp = appendExtension(resp, extUniqueID, uniqueID)
+
+	var plaintext []byte
+	for i := 0; i <= placeholders; i++ {
+		plaintext = appendExtension(plaintext, extCookie, cookie)
+	}
+	s2c, err := newAESSIV(cookie[ntsKeySize:])
+	require.NoError(s.t, err)
+	nonce := make([]byte, 16)
+	ciphertext := s2c.seal(plaintext, resp, nonce)
+	if tamper {
+		ciphertext[0] ^= 1
+	}
+	return appendExtension(resp, extAuthenticator, authenticator(nonce, ciphertext))
+}
+
+func newNTSClient(t *testing.T, servers ...string) *NTP {
+	n := &NTP{
+		Servers: servers,
+		Timeout: config.Duration(time.Second),
+		Version: 4,
+		NTS:     true,
+		Log:     testutil.Logger{},
+	}
+	n.TLSCA = testutil.NewPKI("../../../testutil/pki").CACertPath()
+	require.NoError(t, n.Init())
+	return n
+}
+
+func TestNTSGather(t *testing.T) {
+	server := newNTSServer(t, maxCookies)
+	n := newNTSClient(t, server.address())
+
+	for i := 0; i < 3; i++ {
+		var acc testutil.Accumulator
+		require.NoError(t, acc.GatherError(n.Gather))
+		require.Len(t, acc.Metrics, 1)
+
+		m := acc.Metrics[0]
+		require.Equal(t, map[string]string{
+			"server":       server.address(),
+			"stratum":      "1",
+			"leap_status":  "normal",
+			"reference_id": "GPS",
+		}, m.Tags)
+		require.InDelta(t, 0, m.Fields["offset"], 0.5)
+		require.InDelta(t, 0, m.Fields["rtt"], 0.5)
+		require.Equal(t, 0.03125, m.Fields["root_delay"])
+		require.Equal(t, 0.0625, m.Fields["root_dispersion"])
+		require.Equal(t, 9.53e-07, m.Fields["precision"])
+	}
+
+	// The keys are established once and the cookies are renewed by the
+	// responses.
+	server.Lock()
+	require.Equal(t, 1, server.exchanges)
+	require.Equal(t, 3, server.requests)
+	server.Unlock()
+	require.Len(t, n.servers[0].session.cookies, maxCookies)
+}
+
+func TestNTSCookiesRefilled(t *testing.T) {
+	// Missing cookies are requested with placeholders.
+	server := newNTSServer(t, 2)
+	n := newNTSClient(t, server.address())
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(n.Gather))
+	require.Len(t, n.servers[0].session.cookies, maxCookies)
+}
+
+func TestNTSRejectedCookie(t *testing.T) {
+	server := newNTSServer(t, maxCookies)
+	server.Lock()
+	server.nak = 1
+	server.Unlock()
+	n := newNTSClient(t, server.address())
+
+	// The keys are established again once the cookie is rejected.
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(n.Gather))
+	require.Len(t, acc.Metrics, 1)
+	server.Lock()
+	require.Equal(t, 2, server.exchanges)
+	server.Unlock()
+}
+
+func TestNTSUnauthenticated(t *testing.T) {
+	server := newNTSServer(t, maxCookies)
+	server.Lock()
+	server.tamper = true
+	server.Unlock()
+	n := newNTSClient(t, server.address())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.Contains(t, acc.Errors[0].Error(), "authentication failed")
+	require.Empty(t, acc.Metrics)
+}
+
+func TestNTSUntrustedServer(t *testing.T) {
+	server := newNTSServer(t, maxCookies)
+	n := newNTSClient(t, server.address())
+	n.TLSCA = ""
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.Contains(t, acc.Errors[0].Error(), "NTS key establishment failed")
+}
+
+func TestNTSInit(t *testing.T) {
+	n := &NTP{Servers: []string{"time.cloudflare.com"}, Version: 3, NTS: true}
+	require.Error(t, n.Init())
+}
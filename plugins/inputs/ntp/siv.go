@@ -0,0 +1,142 @@
+package ntp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// aesSIV is AEAD_AES_SIV_CMAC_256 (RFC 5297), the algorithm all NTS servers
+// support.  The first half of the 32 byte key authenticates and the second
+// half encrypts.
+type aesSIV struct {
+	mac cipher.Block
+	ctr cipher.Block
+}
+
+func newAESSIV(key []byte) (*aesSIV, error) {
+	if len(key) != 32 {
+		return nil, errors.New("invalid AES-SIV key length")
+	}
+	mac, err := aes.NewCipher(key[:16])
+	if err != nil {
+		return nil, err
+	}
+	ctr, err := aes.NewCipher(key[16:])
+	if err != nil {
+		return nil, err
+	}
+	return &aesSIV{mac: mac, ctr: ctr}, nil
+}
+
+// seal encrypts the plaintext and authenticates it along with the
+// associated data, the nonce is the last associated data.  The result is
+// the 16 byte synthetic IV followed by the ciphertext.
+func (s *aesSIV) seal(plaintext []byte, ad ...[]byte) []byte {
+	v := s.s2v(ad, plaintext)
+	out := make([]byte, aes.BlockSize+len(plaintext))
+	copy(out, v)
+	s.xorKeyStream(out[aes.BlockSize:], plaintext, v)
+	return out
+}
+
+// open decrypts a ciphertext created by seal and verifies it.
+func (s *aesSIV) open(ciphertext []byte, ad ...[]byte) ([]byte, error) {
+	if len(ciphertext) < aes.BlockSize {
+		return nil, errors.New("AES-SIV ciphertext too short")
+	}
+	v := ciphertext[:aes.BlockSize]
+	plaintext := make([]byte, len(ciphertext)-aes.BlockSize)
+	s.xorKeyStream(plaintext, ciphertext[aes.BlockSize:], v)
+	if subtle.ConstantTimeCompare(s.s2v(ad, plaintext), v) != 1 {
+		return nil, errors.New("AES-SIV authentication failed")
+	}
+	return plaintext, nil
+}
+
+// xorKeyStream encrypts or decrypts with AES-CTR, the counter is the
+// synthetic IV with the top bits of its last two 32 bit words cleared.
+func (s *aesSIV) xorKeyStream(dst, src, v []byte) {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, v)
+	iv[8] &= 0x7f
+	iv[12] &= 0x7f
+	cipher.NewCTR(s.ctr, iv).XORKeyStream(dst, src)
+}
+
+// s2v derives the synthetic IV from the associated data and the plaintext.
+func (s *aesSIV) s2v(ad [][]byte, plaintext []byte) []byte {
+	d := s.cmac(make([]byte, aes.BlockSize))
+	for _, a := range ad {
+		d = dbl(d)
+		xorBytes(d, s.cmac(a))
+	}
+
+	var t []byte
+	if len(plaintext) >= aes.BlockSize {
+		t = make([]byte, len(plaintext))
+		copy(t, plaintext)
+		xorBytes(t[len(t)-aes.BlockSize:], d)
+	} else {
+		t = dbl(d)
+		xorBytes(t, pad(plaintext))
+	}
+	return s.cmac(t)
+}
+
+// cmac is AES-CMAC (RFC 4493) with the authentication key.
+func (s *aesSIV) cmac(msg []byte) []byte {
+	k1 := make([]byte, aes.BlockSize)
+	s.mac.Encrypt(k1, k1)
+	k1 = dbl(k1)
+
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	var last []byte
+	if n > 0 && len(msg)%aes.BlockSize == 0 {
+		last = append([]byte(nil), msg[(n-1)*aes.BlockSize:]...)
+		xorBytes(last, k1)
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		last = pad(msg[(n-1)*aes.BlockSize:])
+		xorBytes(last, dbl(k1))
+	}
+
+	x := make([]byte, aes.BlockSize)
+	for i := 0; i < n-1; i++ {
+		xorBytes(x, msg[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		s.mac.Encrypt(x, x)
+	}
+	xorBytes(x, last)
+	s.mac.Encrypt(x, x)
+	return x
+}
+
+// dbl multiplies a block by x in GF(2^128).
+func dbl(b []byte) []byte {
+	out := make([]byte, aes.BlockSize)
+	for i := 0; i < aes.BlockSize-1; i++ {
+		out[i] = b[i]<<1 | b[i+1]>>7
+	}
+	out[aes.BlockSize-1] = b[aes.BlockSize-1] << 1
+	if b[0]&0x80 != 0 {
+		out[aes.BlockSize-1] ^= 0x87
+	}
+	return out
+}
+
+// pad fills a partial block with a one bit followed by zeros.
+func pad(b []byte) []byte {
+	out := make([]byte, aes.BlockSize)
+	copy(out, b)
+	out[len(b)] = 0x80
+	return out
+}
+
+func xorBytes(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
@@ -2,6 +2,7 @@ package pika
 
 import (
 	"bufio"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/url"
@@ -10,10 +11,11 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-redis/redis"
 	"github.com/influxdata/telegraf"
-	"github.com/influxdata/telegraf/plugins/common/tls"
+	tlsint "github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
 
@@ -24,19 +26,30 @@ type PikaCommand struct {
 }
 
 type Pika struct {
-	Commands []*PikaCommand
-	Servers  []string
-	Password string
-	tls.ClientConfig
+	Commands       []*PikaCommand
+	Servers        []string
+	Password       string
+	ReplicationLag bool  `toml:"replication_lag"`
+	KeyspaceScan   bool  `toml:"keyspace_scan"`
+	SlowlogEntries int64 `toml:"slowlog_entries"`
+	tlsint.ClientConfig
 
 	Log telegraf.Logger
 
 	clients     []Client
 	initialized bool
+
+	mu sync.Mutex
+	// masters are the clients of the masters of the slaves, by address.
+	masters map[string]Client
+	// slowlogIDs are the ids of the newest slowlog entries already reported,
+	// by address of the server.
+	slowlogIDs map[string]int64
 }
 
 type Client interface {
 	Do(returnType string, args ...interface{}) (interface{}, error)
+	DoRaw(args ...interface{}) (interface{}, error)
 	Info() *redis.StringCmd
 	Options() *redis.Options
 	BaseTags() map[string]string
@@ -73,9 +86,9 @@ type PikaFieldTypes struct {
 	UsedCPUUserChildren         float64 `json:"used_cpu_user_children"`
 	UsedMemory                  int64   `json:"used_memory"`
 	ServerId                    int64   `json:"server_id"`
-	IsBgsaving                  string  `json:"is_bgsaving"`
-	IsCompact                   string  `json:"is_compact"`
-	IsScaningKeyspace           string  `json:"is_scaning_keyspace"`
+	IsBgsaving                  int64   `json:"is_bgsaving"`
+	IsCompact                   int64   `json:"is_compact"`
+	IsScaningKeyspace           int64   `json:"is_scaning_keyspace"`
 	LogSize                     int64   `json:"log_size"`
 	DBFatal                     int64   `json:"db_fatal"`
 	DBMemtableUsage             int64   `json:"db_memtable_usage"`
@@ -98,6 +111,12 @@ func (r *PikaClient) Do(returnType string, args ...interface{}) (interface{}, er
 	}
 }
 
+// DoRaw returns the reply of the command as returned by the client, arrays
+// are returned as []interface{}.
+func (r *PikaClient) DoRaw(args ...interface{}) (interface{}, error) {
+	return r.client.Do(args...).Result()
+}
+
 func (r *PikaClient) Info() *redis.StringCmd {
 	return r.client.Info("ALL")
 }
@@ -122,11 +141,25 @@ var sampleConfig = `
   ##  e.g.
   ##    tcp://localhost:9221
   ##    tcp://:password@192.168.99.100
+  ##    tls://localhost:9221
   ##
   ## If no servers are specified, then localhost is used as the host.
   ## If no port is specified, 9221 is used
   servers = ["tcp://localhost:9221"]
 
+  ## If true, query the masters of slaves and report the replication lag of
+  ## each database computed from the binlog offsets.  The masters are
+  ## connected using the password and TLS settings of the slave.
+  # replication_lag = false
+
+  ## If true, start a keyspace scan ("INFO keyspace 1") in each interval, the
+  ## results are reported in the next interval.
+  # keyspace_scan = false
+
+  ## Number of slowlog entries to read in each interval, entries are only
+  ## reported once.  0 disables reading the slowlog.
+  # slowlog_entries = 0
+
   ## Optional. Specify pika commands to retrieve values
   # [[inputs.pika.commands]]
   # command = ["get", "sample-key"]
@@ -169,8 +202,11 @@ func (r *Pika) init() error {
 
 	r.clients = make([]Client, len(r.Servers))
 
+	r.masters = make(map[string]Client)
+	r.slowlogIDs = make(map[string]int64)
+
 	for i, serv := range r.Servers {
-		if !strings.HasPrefix(serv, "tcp://") {
+		if !strings.HasPrefix(serv, "tcp://") && !strings.HasPrefix(serv, "tls://") {
 			r.Log.Warn("Server URL found without scheme; please update your configuration file")
 			serv = "tcp://" + serv
 		}
@@ -198,31 +234,42 @@ func (r *Pika) init() error {
 		if err != nil {
 			return err
 		}
-
-		client := redis.NewClient(
-			&redis.Options{
-				Addr:      address,
-				Password:  password,
-				Network:   u.Scheme,
-				PoolSize:  1,
-				TLSConfig: tlsConfig,
-			},
-		)
+		if u.Scheme == "tls" {
+			if tlsConfig == nil {
+				tlsConfig = &tls.Config{}
+			}
+			if tlsConfig.ServerName == "" {
+				tlsConfig.ServerName = u.Hostname()
+			}
+		}
 
 		tags := map[string]string{}
 		tags["server"] = u.Hostname()
 		tags["port"] = u.Port()
 
-		r.clients[i] = &PikaClient{
-			client: client,
-			tags:   tags,
-		}
+		r.clients[i] = newPikaClient(address, password, tlsConfig, tags)
 	}
 
 	r.initialized = true
 	return nil
 }
 
+func newPikaClient(address, password string, tlsConfig *tls.Config, tags map[string]string) *PikaClient {
+	client := redis.NewClient(
+		&redis.Options{
+			Addr:      address,
+			Password:  password,
+			Network:   "tcp",
+			PoolSize:  1,
+			TLSConfig: tlsConfig,
+		},
+	)
+	return &PikaClient{
+		client: client,
+		tags:   tags,
+	}
+}
+
 // Reads stats from all configured servers accumulates stats.
 // Returns one of the errors encountered while gather stats (if any).
 func (r *Pika) Gather(acc telegraf.Accumulator) error {
@@ -241,6 +288,9 @@ func (r *Pika) Gather(acc telegraf.Accumulator) error {
 			defer wg.Done()
 			acc.AddError(r.gatherServer(client, acc))
 			acc.AddError(r.gatherCommandValues(client, acc))
+			if r.SlowlogEntries > 0 {
+				acc.AddError(r.gatherSlowlog(client, acc))
+			}
 		}(client)
 	}
 
@@ -270,8 +320,24 @@ func (r *Pika) gatherServer(client Client, acc telegraf.Accumulator) error {
 		return fmt.Errorf("redis(%v) - %s", client.Options().Addr, err)
 	}
 
+	tags := client.BaseTags()
 	rdr := strings.NewReader(info)
-	return gatherInfoOutput(rdr, acc, client.BaseTags())
+	if err := gatherInfoOutput(rdr, acc, tags); err != nil {
+		return err
+	}
+
+	// The scan runs in the background, its results are part of the INFO
+	// output of the next interval.
+	if r.KeyspaceScan {
+		if _, err := client.DoRaw("info", "keyspace", "1"); err != nil {
+			return fmt.Errorf("redis(%v) - starting keyspace scan failed: %s", client.Options().Addr, err)
+		}
+	}
+
+	if r.ReplicationLag {
+		return r.gatherReplicationLag(client, info, acc, tags)
+	}
+	return nil
 }
 
 // gatherInfoOutput gathers
@@ -282,6 +348,18 @@ func gatherInfoOutput(
 ) error {
 	var section string
 
+	// dbs are the fields of the pika_db metrics by database.
+	dbs := make(map[string]map[string]interface{})
+	db := func(name string) map[string]interface{} {
+		if _, ok := dbs[name]; !ok {
+			dbs[name] = make(map[string]interface{})
+		}
+		return dbs[name]
+	}
+	// The keyspace scan of the following databases.
+	var scanTime time.Time
+	var scanDuration int64 = -1
+
 	scanner := bufio.NewScanner(rdr)
 	fields := make(map[string]interface{})
 	for scanner.Scan() {
@@ -292,10 +370,23 @@ func gatherInfoOutput(
 		}
 
 		if strings.HasPrefix(line, "# Time:") {
+			value := strings.TrimSpace(strings.TrimPrefix(line, "# Time:"))
+			t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+			if err != nil {
+				t = time.Time{}
+			}
+			scanTime = t
 			continue
 		}
 
 		if strings.HasPrefix(line, "# Duration:") {
+			// The duration is "In Processing" while the scan is running.
+			value := strings.TrimSpace(strings.TrimPrefix(line, "# Duration:"))
+			d, err := strconv.ParseInt(strings.TrimSuffix(value, "s"), 10, 64)
+			if err != nil {
+				d = -1
+			}
+			scanDuration = d
 			continue
 		}
 
@@ -312,6 +403,18 @@ func gatherInfoOutput(
 			continue
 		}
 
+		if strings.EqualFold(section, "Replication") {
+			if name, offset, ok := parseBinlogLine(line); ok {
+				fields := db(name)
+				fields["binlog_filenum"] = offset.filenum
+				fields["binlog_offset"] = offset.offset
+				if offset.safetyPurge != "" {
+					fields["safety_purge"] = offset.safetyPurge
+				}
+				continue
+			}
+		}
+
 		re := regexp.MustCompile(`^db\d+\s`)
 		var parts []string
 		if !strings.EqualFold(section, "Replication") && re.MatchString(line) {
@@ -350,7 +453,23 @@ func gatherInfoOutput(
 		if !ok {
 			if section == "Keyspace" {
 				kline := strings.TrimSpace(parts[1])
-				gatherKeyspaceLine(name, kline, acc, tags)
+				kfields := gatherKeyspaceLine(name, kline, acc, tags)
+				if len(kfields) > 0 {
+					fields := db(name)
+					// The number of keys is reported as "key" by type.
+					for k, dbk := range map[string]string{"key": "keys", "expires": "expires", "invalid_keys": "invalid_keys"} {
+						if v, ok := kfields[k].(int64); ok {
+							total, _ := fields[dbk].(int64)
+							fields[dbk] = total + v
+						}
+					}
+					if !scanTime.IsZero() {
+						fields["keyspace_scan_time"] = scanTime.Unix()
+					}
+					if scanDuration >= 0 {
+						fields["keyspace_scan_duration"] = scanDuration
+					}
+				}
 				continue
 			}
 			if section == "Command_Exec_Count" {
@@ -410,6 +529,15 @@ func gatherInfoOutput(
 
 
 	acc.AddFields("pika", fields, tags)
+
+	for name, fields := range dbs {
+		dbTags := make(map[string]string, len(tags)+1)
+		for k, v := range tags {
+			dbTags[k] = v
+		}
+		dbTags["database"] = name
+		acc.AddFields("pika_db", fields, dbTags)
+	}
 	return nil
 }
 
@@ -435,12 +563,13 @@ return subsMap
 //     db0 Zsets_keys=0, expires=0, invalid_keys=0
 //     db0 Sets_keys=0, expires=0, invalid_keys=0
 // And there is one for each db on the redis instance
+// The fields are returned to sum them up by database.
 func gatherKeyspaceLine(
 	name string,
 	line string,
 	acc telegraf.Accumulator,
 	globalTags map[string]string,
-) {
+) map[string]interface{} {
 	if strings.Contains(line, "keys=") {
 		fields := make(map[string]interface{})
 		tags := make(map[string]string)
@@ -472,7 +601,9 @@ func gatherKeyspaceLine(
 			}
 		}
 		acc.AddFields("pika_keyspace", fields, tags)
+		return fields
 	}
+	return nil
 }
 
 // Parse the special cmdstat lines.
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
-	_ "time"
+	"time"
 
 	"github.com/go-redis/redis"
 	"github.com/influxdata/telegraf/testutil"
@@ -24,10 +24,18 @@ func (t *testClient) Info() *redis.StringCmd {
 	return nil
 }
 
+func (t *testClient) Options() *redis.Options {
+	return &redis.Options{Addr: "pika.net:9221"}
+}
+
 func (t *testClient) Do(returnType string, args ...interface{}) (interface{}, error) {
 	return 2, nil
 }
 
+func (t *testClient) DoRaw(args ...interface{}) (interface{}, error) {
+	return nil, nil
+}
+
 func TestRedisConnectIntegration(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
@@ -121,6 +129,21 @@ func TestRedis_ParseMetrics(t *testing.T) {
 	}
 	acc.AssertContainsTaggedFields(t, "pika_keyspace", keyspaceFields, keyspaceTags)
 
+	scanTime, err := time.ParseInLocation("2006-01-02 15:04:05", "2021-05-31 12:34:17", time.Local)
+	require.NoError(t, err)
+	dbTags := map[string]string{"host": "pika.net", "replication_role": "master", "database": "db0"}
+	dbFields := map[string]interface{}{
+		"keys":                   int64(400000080),
+		"expires":                int64(0),
+		"invalid_keys":           int64(0),
+		"keyspace_scan_time":     scanTime.Unix(),
+		"keyspace_scan_duration": int64(360),
+		"binlog_filenum":         int64(813),
+		"binlog_offset":          int64(29855587),
+		"safety_purge":           "write2file803",
+	}
+	acc.AssertContainsTaggedFields(t, "pika_db", dbFields, dbTags)
+
 	cmdstatSetTags := map[string]string{"host": "pika.net", "command": "info"}
 	cmdstatSetFields := map[string]interface{}{
 		"info":         int64(212545),
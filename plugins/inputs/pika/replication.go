@@ -0,0 +1,199 @@
+package pika
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+// defaultBinlogFileSize is the default of the binlog-file-size setting, used
+// if the setting cannot be read from the master.
+const defaultBinlogFileSize = 100 * 1024 * 1024
+
+// binlogLine matches the binlog offsets of the databases in the Replication
+// section like "db0 binlog_offset=813 29855587,safety_purge=write2file803".
+var binlogLine = regexp.MustCompile(`^(db\d+)[ :]binlog_offset=(\d+) (\d+)(?:,safety_purge=(\S+))?`)
+
+// binlogOffset is the position in the binlog, the number of the binlog file
+// and the offset within the file.
+type binlogOffset struct {
+	filenum     int64
+	offset      int64
+	safetyPurge string
+}
+
+// replicationInfo is the replication state of a server.
+type replicationInfo struct {
+	role       string
+	masterHost string
+	masterPort string
+	linkStatus string
+	binlogs    map[string]binlogOffset
+}
+
+func parseBinlogLine(line string) (string, binlogOffset, bool) {
+	m := binlogLine.FindStringSubmatch(line)
+	if m == nil {
+		return "", binlogOffset{}, false
+	}
+	filenum, err := strconv.ParseInt(m[2], 10, 64)
+	if err != nil {
+		return "", binlogOffset{}, false
+	}
+	offset, err := strconv.ParseInt(m[3], 10, 64)
+	if err != nil {
+		return "", binlogOffset{}, false
+	}
+	return m[1], binlogOffset{filenum: filenum, offset: offset, safetyPurge: m[4]}, true
+}
+
+// parseReplication reads the replication state from the INFO output.
+func parseReplication(info string) replicationInfo {
+	repl := replicationInfo{binlogs: make(map[string]binlogOffset)}
+
+	var section string
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "# ") {
+			section = line[2:]
+			continue
+		}
+		if !strings.HasPrefix(section, "Replication") {
+			continue
+		}
+
+		if name, offset, ok := parseBinlogLine(line); ok {
+			repl.binlogs[name] = offset
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch parts[0] {
+		case "role":
+			repl.role = value
+		case "master_host":
+			repl.masterHost = value
+		case "master_port":
+			repl.masterPort = value
+		case "master_link_status":
+			repl.linkStatus = value
+		}
+	}
+	return repl
+}
+
+// lag returns the number of bytes the slave offset is behind the master
+// offset, the binlog files of the master have the given size.
+func lag(master, slave binlogOffset, fileSize int64) int64 {
+	lag := (master.filenum-slave.filenum)*fileSize + master.offset - slave.offset
+	if lag < 0 {
+		return 0
+	}
+	return lag
+}
+
+// gatherReplicationLag reports the lag of each database of a slave, compared
+// to the binlog offsets of its master.
+func (r *Pika) gatherReplicationLag(
+	client Client,
+	info string,
+	acc telegraf.Accumulator,
+	globalTags map[string]string,
+) error {
+	repl := parseReplication(info)
+	if !strings.EqualFold(repl.role, "slave") || repl.masterHost == "" {
+		return nil
+	}
+
+	address := net.JoinHostPort(repl.masterHost, repl.masterPort)
+	master := r.masterClient(client, address)
+
+	reply, err := master.DoRaw("info", "replication")
+	if err != nil {
+		return fmt.Errorf("redis(%v) - reading replication info of master %v failed: %s", client.Options().Addr, address, err)
+	}
+	masterInfo, ok := reply.(string)
+	if !ok {
+		return fmt.Errorf("redis(%v) - unexpected replication info of master %v: %T", client.Options().Addr, address, reply)
+	}
+	masterRepl := parseReplication(masterInfo)
+	fileSize := r.binlogFileSize(master)
+
+	for name, offset := range repl.binlogs {
+		masterOffset, ok := masterRepl.binlogs[name]
+		if !ok {
+			continue
+		}
+
+		tags := make(map[string]string, len(globalTags)+3)
+		for k, v := range globalTags {
+			tags[k] = v
+		}
+		tags["database"] = name
+		tags["master_host"] = repl.masterHost
+		tags["master_port"] = repl.masterPort
+
+		fields := map[string]interface{}{
+			"lag":                   lag(masterOffset, offset, fileSize),
+			"binlog_filenum":        offset.filenum,
+			"binlog_offset":         offset.offset,
+			"master_binlog_filenum": masterOffset.filenum,
+			"master_binlog_offset":  masterOffset.offset,
+			"master_link_up":        repl.linkStatus == "up",
+		}
+		acc.AddFields("pika_replication_lag", fields, tags)
+	}
+	return nil
+}
+
+// masterClient returns the client of the master, it connects with the
+// credentials and TLS settings of the slave.  The certificate of the master
+// is verified for its own host unless tls_server_name is set.
+func (r *Pika) masterClient(slave Client, address string) Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, ok := r.masters[address]; ok {
+		return client
+	}
+	options := slave.Options()
+	tlsConfig := options.TLSConfig
+	if tlsConfig != nil && r.ClientConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			tlsConfig.ServerName = host
+		}
+	}
+	client := newPikaClient(address, options.Password, tlsConfig, nil)
+	r.masters[address] = client
+	return client
+}
+
+// binlogFileSize reads the size of the binlog files of the server.
+func (r *Pika) binlogFileSize(client Client) int64 {
+	reply, err := client.DoRaw("config", "get", "binlog-file-size")
+	if err != nil {
+		r.Log.Debugf("Reading binlog-file-size of %v failed: %s", client.Options().Addr, err)
+		return defaultBinlogFileSize
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return defaultBinlogFileSize
+	}
+	value, _ := values[1].(string)
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return defaultBinlogFileSize
+	}
+	return size
+}
@@ -0,0 +1,406 @@
+package pika
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	tlsint "github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+// fakeServer speaks enough of the Redis protocol to stand in for a Pika
+// server.
+type fakeServer struct {
+	sync.Mutex
+	listener       net.Listener
+	password       string
+	info           string
+	binlogFileSize string
+	slowlog        []slowlogEntry
+	commands       [][]string
+}
+
+func newFakeServer(t *testing.T, info string) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return startFakeServer(listener, info)
+}
+
+func newFakeTLSServer(t *testing.T, info string) *fakeServer {
+	pki := testutil.NewPKI("../../../testutil/pki")
+	serverConfig := &tlsint.ServerConfig{
+		TLSAllowedCACerts: []string{pki.CACertPath()},
+		TLSCert:           pki.ServerCertPath(),
+		TLSKey:            pki.ServerKeyPath(),
+	}
+	config, err := serverConfig.TLSConfig()
+	require.NoError(t, err)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	return startFakeServer(listener, info)
+}
+
+func startFakeServer(listener net.Listener, info string) *fakeServer {
+	s := &fakeServer{listener: listener, info: info, binlogFileSize: "104857600"}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := false
+	for {
+		command, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+
+		var reply string
+		if strings.EqualFold(command[0], "AUTH") {
+			if len(command) == 2 && command[1] == s.password {
+				authenticated = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-ERR invalid password\r\n"
+			}
+		} else if s.password != "" && !authenticated {
+			reply = "-ERR NOAUTH Authentication required.\r\n"
+		} else {
+			reply = s.reply(command)
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) reply(command []string) string {
+	s.Lock()
+	defer s.Unlock()
+	s.commands = append(s.commands, command)
+
+	switch strings.ToUpper(command[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "INFO":
+		return bulk(s.info)
+	case "CONFIG":
+		if len(command) == 3 && command[2] == "binlog-file-size" {
+			return "*2\r\n" + bulk(command[2]) + bulk(s.binlogFileSize)
+		}
+		return "*0\r\n"
+	case "SLOWLOG":
+		n, _ := strconv.Atoi(command[2])
+		entries := s.slowlog
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+		reply := fmt.Sprintf("*%d\r\n", len(entries))
+		for _, entry := range entries {
+			reply += fmt.Sprintf("*4\r\n:%d\r\n:%d\r\n:%d\r\n*%d\r\n", entry.id, entry.timestamp, entry.duration, len(entry.args))
+			for _, arg := range entry.args {
+				reply += bulk(arg)
+			}
+		}
+		return reply
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", command[0])
+	}
+}
+
+func (s *fakeServer) received() [][]string {
+	s.Lock()
+	defer s.Unlock()
+	commands := s.commands
+	s.commands = nil
+	return commands
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	command := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		command = append(command, string(buf[:size]))
+	}
+	return command, nil
+}
+
+const masterInfo = `# Replication(MASTER)
+role:master
+connected_slaves:1
+slave0:ip=127.0.0.1,port=9221,conn_fd=120,lag=(db0:400)
+db0 binlog_offset=814 200,safety_purge=write2file803
+`
+
+const slaveInfo = `# Replication(SLAVE)
+role:slave
+master_host:127.0.0.1
+master_port:%s
+master_link_status:up
+slave_priority:100
+slave_read_only:1
+db0 binlog_offset=813 104857400,safety_purge=write2file803
+`
+
+func TestGatherReplicationLag(t *testing.T) {
+	master := newFakeServer(t, masterInfo)
+	defer master.listener.Close()
+	slave := newFakeServer(t, fmt.Sprintf(slaveInfo, master.port()))
+	defer slave.listener.Close()
+
+	r := &Pika{
+		Servers:        []string{"tcp://" + slave.listener.Addr().String()},
+		ReplicationLag: true,
+		Log:            testutil.Logger{},
+	}
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(r.Gather))
+
+	acc.AssertContainsTaggedFields(t, "pika_replication_lag",
+		map[string]interface{}{
+			"lag":                   int64(400),
+			"binlog_filenum":        int64(813),
+			"binlog_offset":         int64(104857400),
+			"master_binlog_filenum": int64(814),
+			"master_binlog_offset":  int64(200),
+			"master_link_up":        true,
+		},
+		map[string]string{
+			"server":           "127.0.0.1",
+			"port":             slave.port(),
+			"replication_role": "slave",
+			"database":         "db0",
+			"master_host":      "127.0.0.1",
+			"master_port":      master.port(),
+		},
+	)
+
+	// The offsets of the databases are reported by each server.
+	acc.AssertContainsTaggedFields(t, "pika_db",
+		map[string]interface{}{
+			"binlog_filenum": int64(813),
+			"binlog_offset":  int64(104857400),
+			"safety_purge":   "write2file803",
+		},
+		map[string]string{
+			"server":           "127.0.0.1",
+			"port":             slave.port(),
+			"replication_role": "slave",
+			"database":         "db0",
+		},
+	)
+}
+
+func TestMasterClientTLSServerName(t *testing.T) {
+	r := &Pika{masters: make(map[string]Client)}
+	slave := newPikaClient("slave.example.com:9221", "secret", &tls.Config{ServerName: "slave.example.com"}, nil)
+
+	// The master is verified for its own host name.
+	master := r.masterClient(slave, "master.example.com:9221")
+	require.Equal(t, "master.example.com", master.Options().TLSConfig.ServerName)
+	require.Equal(t, "secret", master.Options().Password)
+	require.Equal(t, "slave.example.com", slave.Options().TLSConfig.ServerName)
+
+	// A configured server name is used for all servers.
+	r = &Pika{masters: make(map[string]Client)}
+	r.ServerName = "pika.example.com"
+	slave = newPikaClient("slave.example.com:9221", "", &tls.Config{ServerName: "pika.example.com"}, nil)
+	master = r.masterClient(slave, "master.example.com:9221")
+	require.Equal(t, "pika.example.com", master.Options().TLSConfig.ServerName)
+}
+
+func TestLag(t *testing.T) {
+	size := int64(1000)
+	require.Equal(t, int64(0), lag(binlogOffset{filenum: 3, offset: 10}, binlogOffset{filenum: 3, offset: 10}, size))
+	require.Equal(t, int64(5), lag(binlogOffset{filenum: 3, offset: 15}, binlogOffset{filenum: 3, offset: 10}, size))
+	require.Equal(t, int64(1005), lag(binlogOffset{filenum: 4, offset: 15}, binlogOffset{filenum: 3, offset: 10}, size))
+	// The slave cannot be ahead of the master, the master moved on meanwhile.
+	require.Equal(t, int64(0), lag(binlogOffset{filenum: 3, offset: 10}, binlogOffset{filenum: 3, offset: 15}, size))
+}
+
+func TestGatherSlowlog(t *testing.T) {
+	server := newFakeServer(t, masterInfo)
+	defer server.listener.Close()
+	server.slowlog = []slowlogEntry{
+		{id: 2, timestamp: 1622464000, duration: 25000, args: []string{"KEYS", "*"}},
+		{id: 1, timestamp: 1622463000, duration: 12000, args: []string{"HGETALL", "user:1"}},
+	}
+
+	r := &Pika{
+		Servers:        []string{"tcp://" + server.listener.Addr().String()},
+		SlowlogEntries: 10,
+		Log:            testutil.Logger{},
+	}
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(r.Gather))
+	require.Equal(t, []int64{2, 1}, slowlogIDs(&acc))
+
+	tags := map[string]string{"server": "127.0.0.1", "port": server.port(), "command": "keys"}
+	acc.AssertContainsTaggedFields(t, "pika_slowlog",
+		map[string]interface{}{"id": int64(2), "duration": int64(25000), "args": "KEYS *"},
+		tags,
+	)
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() == "pika_slowlog" && m.Tags()["command"] == "keys" {
+			require.Equal(t, time.Unix(1622464000, 0), m.Time())
+		}
+	}
+
+	// Entries are reported once.
+	server.Lock()
+	server.slowlog = append([]slowlogEntry{
+		{id: 3, timestamp: 1622465000, duration: 30000, args: []string{"FLUSHDB"}},
+	}, server.slowlog...)
+	server.Unlock()
+
+	acc.ClearMetrics()
+	require.NoError(t, acc.GatherError(r.Gather))
+	require.Equal(t, []int64{3}, slowlogIDs(&acc))
+
+	// After a restart of the server the ids start again.
+	server.Lock()
+	server.slowlog = []slowlogEntry{
+		{id: 0, timestamp: 1622466000, duration: 11000, args: []string{"GET", "a"}},
+	}
+	server.Unlock()
+
+	acc.ClearMetrics()
+	require.NoError(t, acc.GatherError(r.Gather))
+	require.Equal(t, []int64{0}, slowlogIDs(&acc))
+	require.Equal(t, "get", acc.TagValue("pika_slowlog", "command"))
+}
+
+func slowlogIDs(acc *testutil.Accumulator) []int64 {
+	var ids []int64
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() == "pika_slowlog" {
+			id, _ := m.GetField("id")
+			ids = append(ids, id.(int64))
+		}
+	}
+	return ids
+}
+
+func TestGatherKeyspaceScan(t *testing.T) {
+	server := newFakeServer(t, masterInfo)
+	defer server.listener.Close()
+
+	r := &Pika{
+		Servers:      []string{"tcp://" + server.listener.Addr().String()},
+		KeyspaceScan: true,
+		Log:          testutil.Logger{},
+	}
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(r.Gather))
+	require.Contains(t, server.received(), []string{"info", "keyspace", "1"})
+}
+
+func TestGatherPassword(t *testing.T) {
+	server := newFakeServer(t, masterInfo)
+	defer server.listener.Close()
+	server.password = "secret"
+
+	r := &Pika{
+		Servers: []string{"tcp://" + server.listener.Addr().String()},
+		Log:     testutil.Logger{},
+	}
+	var acc testutil.Accumulator
+	require.Error(t, acc.GatherError(r.Gather))
+
+	r = &Pika{
+		Servers:  []string{"tcp://" + server.listener.Addr().String()},
+		Password: "secret",
+		Log:      testutil.Logger{},
+	}
+	acc = testutil.Accumulator{}
+	require.NoError(t, acc.GatherError(r.Gather))
+	require.True(t, acc.HasMeasurement("pika"))
+
+	r = &Pika{
+		Servers: []string{"tcp://:secret@" + server.listener.Addr().String()},
+		Log:     testutil.Logger{},
+	}
+	acc = testutil.Accumulator{}
+	require.NoError(t, acc.GatherError(r.Gather))
+}
+
+func TestGatherTLS(t *testing.T) {
+	server := newFakeTLSServer(t, masterInfo)
+	defer server.listener.Close()
+
+	pki := testutil.NewPKI("../../../testutil/pki")
+	r := &Pika{
+		Servers: []string{"tls://" + server.listener.Addr().String()},
+		Log:     testutil.Logger{},
+	}
+	r.TLSCA = pki.CACertPath()
+	r.TLSCert = pki.ClientCertPath()
+	r.TLSKey = pki.ClientKeyPath()
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(r.Gather))
+	acc.AssertContainsTaggedFields(t, "pika_db",
+		map[string]interface{}{
+			"binlog_filenum": int64(814),
+			"binlog_offset":  int64(200),
+			"safety_purge":   "write2file803",
+		},
+		map[string]string{
+			"server":           "127.0.0.1",
+			"port":             server.port(),
+			"replication_role": "master",
+			"database":         "db0",
+		},
+	)
+}
@@ -0,0 +1,114 @@
+package pika
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// maxSlowlogArgsLength limits the length of the args field.
+const maxSlowlogArgsLength = 256
+
+// slowlogEntry is an entry of the reply of "SLOWLOG GET", the duration is in
+// microseconds.
+type slowlogEntry struct {
+	id        int64
+	timestamp int64
+	duration  int64
+	args      []string
+}
+
+func parseSlowlog(reply interface{}) ([]slowlogEntry, error) {
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected slowlog reply %T", reply)
+	}
+
+	entries := make([]slowlogEntry, 0, len(items))
+	for _, item := range items {
+		values, ok := item.([]interface{})
+		if !ok || len(values) < 4 {
+			return nil, fmt.Errorf("unexpected slowlog entry %v", item)
+		}
+		id, ok1 := values[0].(int64)
+		timestamp, ok2 := values[1].(int64)
+		duration, ok3 := values[2].(int64)
+		rawArgs, ok4 := values[3].([]interface{})
+		if !ok1 || !ok2 || !ok3 || !ok4 {
+			return nil, fmt.Errorf("unexpected slowlog entry %v", item)
+		}
+
+		args := make([]string, 0, len(rawArgs))
+		for _, arg := range rawArgs {
+			args = append(args, fmt.Sprint(arg))
+		}
+		entries = append(entries, slowlogEntry{
+			id:        id,
+			timestamp: timestamp,
+			duration:  duration,
+			args:      args,
+		})
+	}
+	return entries, nil
+}
+
+// gatherSlowlog reports the slowlog entries not reported in earlier intervals.
+// The ids of the entries are increasing, they start again at 0 when the
+// server restarts.
+func (r *Pika) gatherSlowlog(client Client, acc telegraf.Accumulator) error {
+	address := client.Options().Addr
+	reply, err := client.DoRaw("slowlog", "get", r.SlowlogEntries)
+	if err != nil {
+		return fmt.Errorf("redis(%v) - reading slowlog failed: %s", address, err)
+	}
+	entries, err := parseSlowlog(reply)
+	if err != nil {
+		return fmt.Errorf("redis(%v) - %s", address, err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	newest := entries[0].id
+	for _, entry := range entries {
+		if entry.id > newest {
+			newest = entry.id
+		}
+	}
+
+	r.mu.Lock()
+	last, seen := r.slowlogIDs[address]
+	r.slowlogIDs[address] = newest
+	r.mu.Unlock()
+
+	// The server restarted if the newest entry is older than the last one
+	// reported, all entries are new then.
+	if seen && newest < last {
+		seen = false
+	}
+
+	for _, entry := range entries {
+		if seen && entry.id <= last {
+			continue
+		}
+
+		tags := client.BaseTags()
+		if len(entry.args) > 0 {
+			tags["command"] = strings.ToLower(entry.args[0])
+		}
+
+		args := strings.Join(entry.args, " ")
+		if len(args) > maxSlowlogArgsLength {
+			args = args[:maxSlowlogArgsLength]
+		}
+		fields := map[string]interface{}{
+			"id":       entry.id,
+			"duration": entry.duration,
+			"args":     args,
+		}
+		acc.AddFields("pika_slowlog", fields, tags, time.Unix(entry.timestamp, 0))
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/postgresql"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
 
@@ -88,7 +89,7 @@ func (p *Postgresql) gatherServer(address string, acc telegraf.Accumulator) erro
 		columns []string
 	)
 
-	s := Service{
+	s := postgresql.Service{
 			Address: address,
 			Outputaddress: p.Outputaddress,
 			MaxIdle: 1,
@@ -157,7 +158,7 @@ type scanner interface {
 	Scan(dest ...interface{}) error
 }
 
-func (p *Postgresql) accRow(s Service, row scanner, acc telegraf.Accumulator, columns []string) error {
+func (p *Postgresql) accRow(s postgresql.Service, row scanner, acc telegraf.Accumulator, columns []string) error {
 	var columnVars []interface{}
 	var dbname bytes.Buffer
 
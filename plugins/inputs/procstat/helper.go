@@ -0,0 +1,206 @@
+package procstat
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// helperRequest asks procgather for the stats of many processes at once.
+// Requests and responses are JSON documents, one per line.
+type helperRequest struct {
+	ID     uint64 `json:"id"`
+	PIDs   []PID  `json:"pids"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// helperResponse contains the fields of each process as numbers and the
+// types of the fields, as integral floats are encoded without a fraction.
+type helperResponse struct {
+	ID     uint64                         `json:"id"`
+	Stats  map[PID]map[string]json.Number `json:"stats"`
+	Types  map[string]string              `json:"types,omitempty"`
+	Errors map[PID]string                 `json:"errors,omitempty"`
+	Error  string                         `json:"error,omitempty"`
+}
+
+// helper talks to a long-running procgather, either spawned with
+// "procgather -serve" or listening on a unix socket.  The helper is started
+// again on the next request after it failed or timed out.
+type helper struct {
+	path    string
+	socket  string
+	sudo    bool
+	timeout time.Duration
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	conn   io.ReadWriteCloser
+	reader *bufio.Reader
+	nextID uint64
+}
+
+// pipeConn joins the pipes of the spawned helper.
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+func (h *helper) start() error {
+	if h.socket != "" {
+		conn, err := net.DialTimeout("unix", h.socket, h.timeout)
+		if err != nil {
+			return fmt.Errorf("connecting to procgather failed: %v", err)
+		}
+		h.conn = conn
+		h.reader = bufio.NewReader(conn)
+		return nil
+	}
+
+	if h.path == "" {
+		return fmt.Errorf("procgather not found")
+	}
+	cmd := exec.Command(h.path, "-serve")
+	if h.sudo {
+		cmd = exec.Command("sudo", "-n", h.path, "-serve")
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting procgather failed: %v", err)
+	}
+
+	h.cmd = cmd
+	h.conn = &pipeConn{Reader: stdout, WriteCloser: stdin}
+	h.reader = bufio.NewReader(stdout)
+	return nil
+}
+
+// stop closes the connection and terminates a spawned helper.
+func (h *helper) stop() {
+	if h.conn != nil {
+		h.conn.Close()
+		h.conn = nil
+	}
+	if h.cmd != nil {
+		h.cmd.Process.Kill()
+		h.cmd.Wait()
+		h.cmd = nil
+	}
+}
+
+// Close terminates the helper.
+func (h *helper) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stop()
+}
+
+// gather returns the fields of the processes, processes which ended in the
+// meantime are missing in the result.
+func (h *helper) gather(pids []PID, prefix string) (map[PID]map[string]interface{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conn == nil {
+		if err := h.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	h.nextID++
+	req := helperRequest{ID: h.nextID, PIDs: pids, Prefix: prefix}
+
+	type result struct {
+		resp *helperResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	conn, reader := h.conn, h.reader
+	go func() {
+		resp, err := roundTrip(conn, reader, &req)
+		done <- result{resp, err}
+	}()
+
+	timer := time.NewTimer(h.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			h.stop()
+			return nil, fmt.Errorf("procgather failed: %v", r.err)
+		}
+		if r.resp.Error != "" {
+			return nil, fmt.Errorf("procgather failed: %s", r.resp.Error)
+		}
+		return r.resp.fields(), nil
+	case <-timer.C:
+		// Stopping the helper also ends the pending round trip.
+		h.stop()
+		<-done
+		return nil, fmt.Errorf("procgather did not respond within %s", h.timeout)
+	}
+}
+
+// fields returns the fields of each process typed like the fields gathered
+// without the helper.
+func (r *helperResponse) fields() map[PID]map[string]interface{} {
+	stats := make(map[PID]map[string]interface{}, len(r.Stats))
+	for pid, values := range r.Stats {
+		fields := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			var err error
+			switch r.Types[k] {
+			case "float":
+				fields[k], err = v.Float64()
+			case "uint":
+				fields[k], err = strconv.ParseUint(v.String(), 10, 64)
+			default:
+				fields[k], err = v.Int64()
+			}
+			if err != nil {
+				delete(fields, k)
+			}
+		}
+		stats[pid] = fields
+	}
+	return stats
+}
+
+// roundTrip sends the request and reads the response, responses to earlier
+// requests are skipped.
+func roundTrip(w io.Writer, r *bufio.Reader, req *helperRequest) (*helperResponse, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return nil, err
+	}
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		var resp helperResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return nil, fmt.Errorf("invalid response: %v", err)
+		}
+		if resp.ID == req.ID {
+			return &resp, nil
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package procstat
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHelper answers the requests like "procgather -serve -listen".
+type fakeHelper struct {
+	sync.Mutex
+	listener net.Listener
+	requests []helperRequest
+	conns    int
+	hang     bool
+}
+
+func newFakeHelper(t *testing.T) (*fakeHelper, string) {
+	dir, err := ioutil.TempDir("", "procstat")
+	require.NoError(t, err)
+	socket := filepath.Join(dir, "procgather.sock")
+
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	h := &fakeHelper{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			h.Lock()
+			h.conns++
+			h.Unlock()
+			go h.serve(conn)
+		}
+	}()
+	return h, socket
+}
+
+func (h *fakeHelper) close() {
+	h.listener.Close()
+	os.RemoveAll(filepath.Dir(h.listener.Addr().String()))
+}
+
+func (h *fakeHelper) serve(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req helperRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return
+		}
+
+		h.Lock()
+		h.requests = append(h.requests, req)
+		hang := h.hang
+		h.Unlock()
+		if hang {
+			continue
+		}
+
+		prefix := ""
+		if req.Prefix != "" {
+			prefix = req.Prefix + "_"
+		}
+		resp := helperResponse{
+			ID:    req.ID,
+			Stats: make(map[PID]map[string]json.Number),
+			Types: map[string]string{
+				prefix + "num_fds":       "int",
+				prefix + "memory_rss":    "uint",
+				prefix + "cpu_usage":     "float",
+				prefix + "cpu_time_user": "float",
+			},
+		}
+		for _, pid := range req.PIDs {
+			// Integral floats are encoded without a fraction.
+			resp.Stats[pid] = map[string]json.Number{
+				prefix + "num_fds":       "12",
+				prefix + "memory_rss":    "4096",
+				prefix + "cpu_usage":     "1.5",
+				prefix + "cpu_time_user": "0",
+			}
+		}
+		if err := encoder.Encode(&resp); err != nil {
+			return
+		}
+	}
+}
+
+func TestHelperGather(t *testing.T) {
+	fake, socket := newFakeHelper(t)
+	defer fake.close()
+
+	h := &helper{socket: socket, timeout: time.Second}
+	defer h.Close()
+
+	stats, err := h.gather([]PID{1, 2}, "")
+	require.NoError(t, err)
+	fields := map[string]interface{}{
+		"num_fds":       int64(12),
+		"memory_rss":    uint64(4096),
+		"cpu_usage":     1.5,
+		"cpu_time_user": 0.0,
+	}
+	require.Equal(t, map[PID]map[string]interface{}{1: fields, 2: fields}, stats)
+
+	// The connection is reused.
+	_, err = h.gather([]PID{1}, "")
+	require.NoError(t, err)
+	fake.Lock()
+	require.Equal(t, 1, fake.conns)
+	require.Len(t, fake.requests, 2)
+	require.Equal(t, uint64(2), fake.requests[1].ID)
+	fake.Unlock()
+}
+
+func TestHelperTimeout(t *testing.T) {
+	fake, socket := newFakeHelper(t)
+	defer fake.close()
+	fake.hang = true
+
+	h := &helper{socket: socket, timeout: 50 * time.Millisecond}
+	defer h.Close()
+
+	_, err := h.gather([]PID{1}, "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "did not respond")
+
+	// The helper is connected again with the next request.
+	fake.Lock()
+	fake.hang = false
+	fake.Unlock()
+	_, err = h.gather([]PID{1}, "")
+	require.NoError(t, err)
+	fake.Lock()
+	require.Equal(t, 2, fake.conns)
+	fake.Unlock()
+}
+
+func TestHelperUnavailable(t *testing.T) {
+	h := &helper{socket: "/nonexistent/procgather.sock", timeout: time.Second}
+	_, err := h.gather([]PID{1}, "")
+	require.Error(t, err)
+}
+
+func TestGather_Helper(t *testing.T) {
+	fake, socket := newFakeHelper(t)
+	defer fake.close()
+
+	var acc testutil.Accumulator
+	p := Procstat{
+		Exe:             exe,
+		Prefix:          "foo",
+		HelperSocket:    socket,
+		Timeout:         config.Duration(time.Second),
+		createPIDFinder: pidFinder([]PID{pid, pid + 1}, nil),
+		createProcess:   newTestProc,
+	}
+	require.NoError(t, p.Init())
+	defer p.Stop()
+	require.NoError(t, acc.GatherError(p.Gather))
+
+	// All processes are requested at once and the values are typed like the
+	// fields gathered without the helper.
+	fake.Lock()
+	require.Len(t, fake.requests, 1)
+	require.ElementsMatch(t, []PID{pid, pid + 1}, fake.requests[0].PIDs)
+	require.Equal(t, "foo", fake.requests[0].Prefix)
+	fake.Unlock()
+
+	require.True(t, acc.HasInt64Field("procstat", "foo_num_fds"))
+	require.True(t, acc.HasUIntField("procstat", "foo_memory_rss"))
+	require.True(t, acc.HasFloatField("procstat", "foo_cpu_usage"))
+	require.True(t, acc.HasFloatField("procstat", "foo_cpu_time_user"))
+}
@@ -15,7 +15,6 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
-	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/shirou/gopsutil/process"
 )
@@ -54,9 +53,12 @@ type Procstat struct {
 
 	PathProcgather string          `toml:"path_procgather"`
 	UseSudo        bool            `toml:"use_sudo"`
+	HelperSocket   string          `toml:"helper_socket"`
 	MaxWorkers     int             `toml:"max_workers"`
 	Timeout        config.Duration `toml:"timeout"`
 	Log            telegraf.Logger `toml:"-"`
+
+	helper *helper
 }
 
 var sampleConfig = `
@@ -104,16 +106,21 @@ var sampleConfig = `
   # pid_finder = "pgrep"
 
   ## On most platform, process io, fd and mem requires root access.
-  ## Setting 'use sudo' to true will make use of sudo to run procgather.
-  ## Sudo must be configured to allow the telegraf user to run procgather
-  ## without a password.
+  ## Setting 'use sudo' to true will start "procgather -serve" once using sudo
+  ## and request the stats of all processes from it in every interval.  Sudo
+  ## must be configured to allow the telegraf user to run procgather without
+  ## a password.
   # use_sudo = false
   # max_workers = 10
 
   ## Specify the path to the procgather executable
   # path_procgather = "/usr/bin/procgather"
 
-  ## Timeout for the procgather command to complete.
+  ## Unix socket of a procgather started separately with
+  ## "procgather -serve -listen <socket>", used instead of starting it.
+  # helper_socket = ""
+
+  ## Timeout for procgather to respond, it is restarted after a timeout.
   # timeout = "3s"
 `
 
@@ -180,20 +187,34 @@ func (p *Procstat) Gather(acc telegraf.Accumulator) error {
 			p.Exe, p.PidFile, p.Pattern, p.User, err.Error()))
 	}
 	p.procs = procs
+
+	// The stats of all processes are requested from the helper at once.
+	var helperStats map[PID]map[string]interface{}
+	if p.helper != nil && len(p.procs) > 0 {
+		pids := make([]PID, 0, len(p.procs))
+		for pid := range p.procs {
+			pids = append(pids, pid)
+		}
+		helperStats, err = p.helper.gather(pids, p.Prefix)
+		if err != nil {
+			acc.AddError(err)
+		}
+	}
+
 	maxWorker := p.MaxWorkers
 	if maxWorker == 0 {
 		maxWorker = 10
 	}
 
 	safeRun := make(chan struct{}, maxWorker)
-	for _, proc := range p.procs {
+	for pid, proc := range p.procs {
 		wg.Add(1)
 		safeRun <- struct{}{} // block if channel is already filled
-		go func(r Process) {
+		go func(r Process, helperFields map[string]interface{}) {
 			defer wg.Done()
-			p.addMetric(r, acc, now)
+			p.addMetric(r, acc, now, helperFields)
 			<-safeRun
-		}(proc)
+		}(proc, helperStats[pid])
 	}
 
 	wg.Wait()
@@ -211,32 +232,9 @@ func (p *Procstat) Gather(acc telegraf.Accumulator) error {
 	return nil
 }
 
-// Wrap with sudo
-var runCmd = func(timeout config.Duration, sudo bool, command string, args ...string) ([]byte, error) {
-	cmd := exec.Command(command, args...)
-	if sudo {
-		cmd = exec.Command("sudo", append([]string{"-n", command}, args...)...)
-	}
-	return internal.CombinedOutputTimeout(cmd, time.Duration(timeout))
-}
-
-func formatValue(v string) interface{} {
-	if strings.Contains(v, ".") {
-		f, err := strconv.ParseFloat(v, 64)
-		if err == nil {
-			return f
-		}
-	} else {
-		i, err := strconv.ParseInt(v, 10, 64)
-		if err == nil {
-			return i
-		}
-	}
-	return v
-}
-
-// Add metrics a single Process
-func (p *Procstat) addMetric(proc Process, acc telegraf.Accumulator, t time.Time) {
+// Add metrics a single Process, helperFields are the fields gathered by
+// procgather if it is used.
+func (p *Procstat) addMetric(proc Process, acc telegraf.Accumulator, t time.Time, helperFields map[string]interface{}) {
 	var prefix string
 	if p.Prefix != "" {
 		prefix = p.Prefix + "_"
@@ -274,29 +272,9 @@ func (p *Procstat) addMetric(proc Process, acc telegraf.Accumulator, t time.Time
 		fields["pid"] = int32(proc.PID())
 	}
 
-	if p.UseSudo {
-		procPid := fmt.Sprintf("%d", proc.PID())
-		var out []byte
-		var err error
-
-		if p.Prefix != "" {
-			out, err = runCmd(p.Timeout, p.UseSudo, p.PathProcgather, "-pid", procPid, "-all", "-prefix", p.Prefix)
-		} else {
-			out, err = runCmd(p.Timeout, p.UseSudo, p.PathProcgather, "-pid", procPid, "-all")
-		}
-
-		if err == nil {
-			lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-			for _, line := range lines {
-				if len(line) == 0 {
-					continue
-				}
-				stats := strings.SplitN(line, " ", 2)
-				if len(stats) < 2 {
-					continue
-				}
-				fields[stats[0]] = formatValue(stats[1])
-			}
+	if p.helper != nil {
+		for k, v := range helperFields {
+			fields[k] = v
 		}
 	} else {
 
@@ -605,13 +583,34 @@ func (p *Procstat) Init() error {
 		}
 	}
 
-        if p.Timeout == 0 {
-                p.Timeout = config.Duration(time.Second * 3)
-        }
+	if p.Timeout == 0 {
+		p.Timeout = config.Duration(time.Second * 3)
+	}
+
+	if p.UseSudo || p.HelperSocket != "" {
+		p.helper = &helper{
+			path:    p.PathProcgather,
+			socket:  p.HelperSocket,
+			sudo:    p.UseSudo,
+			timeout: time.Duration(p.Timeout),
+		}
+	}
+
+	return nil
+}
 
+// Start implements telegraf.ServiceInput so the helper is terminated on Stop.
+func (p *Procstat) Start(telegraf.Accumulator) error {
 	return nil
 }
 
+// Stop terminates procgather if it was started.
+func (p *Procstat) Stop() {
+	if p.helper != nil {
+		p.helper.Close()
+	}
+}
+
 func init() {
 	inputs.Add("procstat", func() telegraf.Input {
 		return &Procstat{}
@@ -0,0 +1,82 @@
+package psi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/globpath"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+var sampleConfig = `
+  ## Root of the proc filesystem, the system-wide pressure is read from
+  ## <proc_root>/pressure.  Defaults to $HOST_PROC or "/proc".
+  # proc_root = "/proc"
+
+  ## Mount point of the unified cgroup v2 hierarchy.  Defaults to
+  ## $HOST_SYS/fs/cgroup or "/sys/fs/cgroup".
+  # cgroup_root = "/sys/fs/cgroup"
+
+  ## Cgroups to collect, as paths relative to the cgroup root.  Globs are
+  ## supported, "**" matches any number of levels and "/" is the root cgroup.
+  ## Consider restricting the cgroups to the ones you really want to monitor
+  ## to avoid cardinality issues.  No cgroups are collected by default.
+  # cgroups = ["/", "system.slice/*.service"]
+
+  ## Files read for each cgroup.
+  # cgroup_files = ["cpu.stat", "memory.stat", "io.stat", "cpu.pressure", "memory.pressure", "io.pressure"]
+`
+
+type PSI struct {
+	ProcRoot    string   `toml:"proc_root"`
+	CgroupRoot  string   `toml:"cgroup_root"`
+	Cgroups     []string `toml:"cgroups"`
+	CgroupFiles []string `toml:"cgroup_files"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	cgroups []*globpath.GlobPath
+}
+
+func (p *PSI) Description() string {
+	return "Read pressure stall information and cgroup v2 statistics"
+}
+
+func (p *PSI) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *PSI) Init() error {
+	if p.ProcRoot == "" {
+		p.ProcRoot = "/proc"
+		if env := os.Getenv("HOST_PROC"); env != "" {
+			p.ProcRoot = env
+		}
+	}
+	if p.CgroupRoot == "" {
+		p.CgroupRoot = "/sys/fs/cgroup"
+		if env := os.Getenv("HOST_SYS"); env != "" {
+			p.CgroupRoot = filepath.Join(env, "fs", "cgroup")
+		}
+	}
+
+	p.cgroups = nil
+	for _, pattern := range p.Cgroups {
+		g, err := globpath.Compile(filepath.Join(p.CgroupRoot, pattern))
+		if err != nil {
+			return fmt.Errorf("invalid cgroup pattern %q: %v", pattern, err)
+		}
+		p.cgroups = append(p.cgroups, g)
+	}
+	return nil
+}
+
+func init() {
+	inputs.Add("psi", func() telegraf.Input {
+		return &PSI{
+			CgroupFiles: []string{"cpu.stat", "memory.stat", "io.stat", "cpu.pressure", "memory.pressure", "io.pressure"},
+		}
+	})
+}
@@ -0,0 +1,252 @@
+// +build linux
+
+package psi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+// resources with pressure stall information.
+var resources = []string{"cpu", "memory", "io"}
+
+func (p *PSI) Gather(acc telegraf.Accumulator) error {
+	acc.AddError(p.gatherSystem(acc))
+
+	for _, dir := range p.cgroupDirs() {
+		p.gatherCgroup(dir, acc)
+	}
+	return nil
+}
+
+// gatherSystem reads the system-wide pressure of the resources.
+func (p *PSI) gatherSystem(acc telegraf.Accumulator) error {
+	dir := filepath.Join(p.ProcRoot, "pressure")
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("pressure stall information not available: %v", err)
+	}
+
+	for _, resource := range resources {
+		data, err := ioutil.ReadFile(filepath.Join(dir, resource))
+		if err != nil {
+			acc.AddError(err)
+			continue
+		}
+		lines, err := parsePressure(data)
+		if err != nil {
+			acc.AddError(fmt.Errorf("parsing %s pressure failed: %v", resource, err))
+			continue
+		}
+		for typ, fields := range lines {
+			tags := map[string]string{"resource": resource, "type": typ}
+			acc.AddFields("pressure", fields, tags)
+		}
+	}
+	return nil
+}
+
+// cgroupDirs returns the directories of the cgroups matching the patterns.
+func (p *PSI) cgroupDirs() []string {
+	seen := make(map[string]bool)
+	for _, g := range p.cgroups {
+		for _, match := range g.Match() {
+			if info, err := os.Stat(match); err == nil && info.IsDir() {
+				seen[filepath.Clean(match)] = true
+			}
+		}
+	}
+
+	dirs := make([]string, 0, len(seen))
+	for dir := range seen {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// cgroupTags returns the tags of the cgroup in the directory, the path of the
+// cgroup and of its parent relative to the cgroup root.
+func (p *PSI) cgroupTags(dir string) map[string]string {
+	rel, err := filepath.Rel(p.CgroupRoot, dir)
+	if err != nil || rel == "." {
+		return map[string]string{"cgroup": "/"}
+	}
+	cgroup := "/" + filepath.ToSlash(rel)
+	return map[string]string{
+		"cgroup": cgroup,
+		"parent": path.Dir(cgroup),
+	}
+}
+
+// gatherCgroup reads the files of a cgroup.  Files of controllers not
+// enabled for the cgroup are missing and skipped.
+func (p *PSI) gatherCgroup(dir string, acc telegraf.Accumulator) {
+	tags := p.cgroupTags(dir)
+
+	// The fields of the files of a controller are added as a single metric.
+	controllers := make(map[string]map[string]interface{})
+	var order []string
+
+	for _, file := range p.CgroupFiles {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				acc.AddError(err)
+			}
+			continue
+		}
+
+		controller, name := file, ""
+		if i := strings.IndexByte(file, '.'); i >= 0 {
+			controller, name = file[:i], file[i+1:]
+		}
+
+		switch {
+		case name == "pressure":
+			lines, err := parsePressure(data)
+			if err != nil {
+				acc.AddError(fmt.Errorf("parsing %s of cgroup %s failed: %v", file, tags["cgroup"], err))
+				continue
+			}
+			for typ, fields := range lines {
+				ptags := copyTags(tags)
+				ptags["resource"] = controller
+				ptags["type"] = typ
+				acc.AddFields("cgroup_pressure", fields, ptags)
+			}
+		case file == "io.stat":
+			for device, fields := range parseNestedKeyed(data) {
+				iotags := copyTags(tags)
+				iotags["device"] = device
+				acc.AddFields("cgroup_io", fields, iotags)
+			}
+		default:
+			fields, ok := controllers[controller]
+			if !ok {
+				fields = make(map[string]interface{})
+				controllers[controller] = fields
+				order = append(order, controller)
+			}
+			parseFlatKeyed(data, name, fields)
+		}
+	}
+
+	for _, controller := range order {
+		if fields := controllers[controller]; len(fields) > 0 {
+			acc.AddFields("cgroup_"+controller, fields, copyTags(tags))
+		}
+	}
+}
+
+// parsePressure parses the pressure of a resource by type, "some" or "full":
+//   some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func parsePressure(data []byte) (map[string]map[string]interface{}, error) {
+	lines := make(map[string]map[string]interface{})
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) == 0 {
+			continue
+		}
+
+		fields := make(map[string]interface{}, len(parts)-1)
+		for _, part := range parts[1:] {
+			kv := strings.SplitN(part, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			if kv[0] == "total" {
+				v, err := strconv.ParseInt(kv[1], 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				fields[kv[0]] = v
+				continue
+			}
+			v, err := strconv.ParseFloat(kv[1], 64)
+			if err != nil {
+				return nil, err
+			}
+			fields[kv[0]] = v
+		}
+		lines[parts[0]] = fields
+	}
+	return lines, scanner.Err()
+}
+
+// parseFlatKeyed adds the values of a flat keyed file like memory.stat, or
+// the value of a single value file like memory.current as the given name.
+// Values which are not numbers, like "max", are skipped.
+func parseFlatKeyed(data []byte, name string, fields map[string]interface{}) {
+	content := strings.TrimSpace(string(data))
+	if !strings.ContainsAny(content, " \n") {
+		if v, ok := parseNumber(content); ok {
+			fields[name] = v
+		}
+		return
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			continue
+		}
+		if v, ok := parseNumber(parts[1]); ok {
+			fields[parts[0]] = v
+		}
+	}
+}
+
+// parseNestedKeyed parses files like io.stat by the first value of a line:
+//   8:0 rbytes=90112 wbytes=0 rios=3 wios=0 dbytes=0 dios=0
+func parseNestedKeyed(data []byte) map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{})
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
+		}
+		fields := make(map[string]interface{}, len(parts)-1)
+		for _, part := range parts[1:] {
+			kv := strings.SplitN(part, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			if v, ok := parseNumber(kv[1]); ok {
+				fields[kv[0]] = v
+			}
+		}
+		if len(fields) > 0 {
+			result[parts[0]] = fields
+		}
+	}
+	return result
+}
+
+func parseNumber(s string) (interface{}, bool) {
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return v, true
+	}
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, true
+	}
+	return nil, false
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	c := make(map[string]string, len(tags)+2)
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
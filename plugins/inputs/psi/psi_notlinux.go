@@ -0,0 +1,11 @@
+// +build !linux
+
+package psi
+
+import (
+	"github.com/influxdata/telegraf"
+)
+
+func (p *PSI) Gather(acc telegraf.Accumulator) error {
+	return nil
+}
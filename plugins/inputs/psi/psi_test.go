@@ -0,0 +1,159 @@
+// +build linux
+
+package psi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestGatherSystem(t *testing.T) {
+	p := &PSI{
+		ProcRoot:   "testdata/proc",
+		CgroupRoot: "testdata/cgroup",
+	}
+	require.NoError(t, p.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(p.Gather))
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("pressure",
+			map[string]string{"resource": "cpu", "type": "some"},
+			map[string]interface{}{"avg10": 1.53, "avg60": 0.87, "avg300": 0.25, "total": int64(10465325)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("pressure",
+			map[string]string{"resource": "memory", "type": "some"},
+			map[string]interface{}{"avg10": 0.0, "avg60": 0.1, "avg300": 0.02, "total": int64(482936)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("pressure",
+			map[string]string{"resource": "memory", "type": "full"},
+			map[string]interface{}{"avg10": 0.0, "avg60": 0.05, "avg300": 0.01, "total": int64(310456)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("pressure",
+			map[string]string{"resource": "io", "type": "some"},
+			map[string]interface{}{"avg10": 0.2, "avg60": 0.3, "avg300": 0.1, "total": int64(2738482)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("pressure",
+			map[string]string{"resource": "io", "type": "full"},
+			map[string]interface{}{"avg10": 0.1, "avg60": 0.15, "avg300": 0.05, "total": int64(2015930)},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.SortMetrics(), testutil.IgnoreTime())
+}
+
+func TestGatherSystemUnavailable(t *testing.T) {
+	p := &PSI{
+		ProcRoot:   "testdata/nonexistent",
+		CgroupRoot: "testdata/cgroup",
+	}
+	require.NoError(t, p.Init())
+
+	var acc testutil.Accumulator
+	require.Error(t, acc.GatherError(p.Gather))
+}
+
+func TestGatherCgroups(t *testing.T) {
+	p := &PSI{
+		ProcRoot:    "testdata/proc",
+		CgroupRoot:  "testdata/cgroup",
+		Cgroups:     []string{"/", "system.slice/sshd.service", "system.slice/*.service"},
+		CgroupFiles: []string{"cpu.stat", "memory.stat", "memory.current", "memory.max", "io.stat", "cpu.pressure", "memory.pressure"},
+	}
+	require.NoError(t, p.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(p.Gather))
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cgroup_cpu",
+			map[string]string{"cgroup": "/"},
+			map[string]interface{}{"usage_usec": int64(84931734), "user_usec": int64(53918271), "system_usec": int64(31013463)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cgroup_pressure",
+			map[string]string{"cgroup": "/", "resource": "cpu", "type": "some"},
+			map[string]interface{}{"avg10": 1.53, "avg60": 0.87, "avg300": 0.25, "total": int64(10465325)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cgroup_cpu",
+			map[string]string{"cgroup": "/system.slice/cron.service", "parent": "/system.slice"},
+			map[string]interface{}{"usage_usec": int64(5012), "user_usec": int64(3001), "system_usec": int64(2011)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cgroup_cpu",
+			map[string]string{"cgroup": "/system.slice/sshd.service", "parent": "/system.slice"},
+			map[string]interface{}{
+				"usage_usec":     int64(120394),
+				"user_usec":      int64(80211),
+				"system_usec":    int64(40183),
+				"nr_periods":     int64(0),
+				"nr_throttled":   int64(0),
+				"throttled_usec": int64(0),
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cgroup_memory",
+			map[string]string{"cgroup": "/system.slice/sshd.service", "parent": "/system.slice"},
+			map[string]interface{}{
+				"anon":         int64(2134016),
+				"file":         int64(8941568),
+				"kernel_stack": int64(65536),
+				"current":      int64(11075584),
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cgroup_io",
+			map[string]string{"cgroup": "/system.slice/sshd.service", "parent": "/system.slice", "device": "8:0"},
+			map[string]interface{}{
+				"rbytes": int64(90112),
+				"wbytes": int64(4096),
+				"rios":   int64(3),
+				"wios":   int64(1),
+				"dbytes": int64(0),
+				"dios":   int64(0),
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cgroup_pressure",
+			map[string]string{"cgroup": "/system.slice/sshd.service", "parent": "/system.slice", "resource": "memory", "type": "some"},
+			map[string]interface{}{"avg10": 0.0, "avg60": 0.0, "avg300": 0.0, "total": int64(1204)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cgroup_pressure",
+			map[string]string{"cgroup": "/system.slice/sshd.service", "parent": "/system.slice", "resource": "memory", "type": "full"},
+			map[string]interface{}{"avg10": 0.0, "avg60": 0.0, "avg300": 0.0, "total": int64(1103)},
+			time.Unix(0, 0)),
+	}
+
+	var actual []telegraf.Metric
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() != "pressure" {
+			actual = append(actual, m)
+		}
+	}
+	testutil.RequireMetricsEqual(t, expected, actual, testutil.SortMetrics(), testutil.IgnoreTime())
+}
+
+func TestGatherCgroupsRecursive(t *testing.T) {
+	p := &PSI{
+		ProcRoot:    "testdata/proc",
+		CgroupRoot:  "testdata/cgroup",
+		Cgroups:     []string{"**"},
+		CgroupFiles: []string{"cpu.stat"},
+	}
+	require.NoError(t, p.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(p.Gather))
+
+	var cgroups []string
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() == "cgroup_cpu" {
+			cgroups = append(cgroups, m.Tags()["cgroup"])
+		}
+	}
+	require.ElementsMatch(t, []string{
+		"/system.slice/cron.service",
+		"/system.slice/sshd.service",
+		"/user.slice",
+	}, cgroups)
+}
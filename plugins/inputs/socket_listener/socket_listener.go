@@ -116,6 +116,9 @@ func (ssl *streamSocketListener) read(c net.Conn) {
 		ssl.Log.Error("Read error: %v", err)
 		return
 	}
+	if closer, ok := decoder.(io.Closer); ok {
+		defer closer.Close()
+	}
 
 	scnr := bufio.NewScanner(decoder)
 	for {
@@ -183,13 +186,14 @@ func (psl *packetSocketListener) listen() {
 }
 
 type SocketListener struct {
-	ServiceAddress  string             `toml:"service_address"`
-	MaxConnections  int                `toml:"max_connections"`
-	ReadBufferSize  internal.Size      `toml:"read_buffer_size"`
-	ReadTimeout     *internal.Duration `toml:"read_timeout"`
-	KeepAlivePeriod *internal.Duration `toml:"keep_alive_period"`
-	SocketMode      string             `toml:"socket_mode"`
-	ContentEncoding string             `toml:"content_encoding"`
+	ServiceAddress       string             `toml:"service_address"`
+	MaxConnections       int                `toml:"max_connections"`
+	ReadBufferSize       internal.Size      `toml:"read_buffer_size"`
+	ReadTimeout          *internal.Duration `toml:"read_timeout"`
+	KeepAlivePeriod      *internal.Duration `toml:"keep_alive_period"`
+	SocketMode           string             `toml:"socket_mode"`
+	ContentEncoding      string             `toml:"content_encoding"`
+	MaxDecompressionSize internal.Size      `toml:"max_decompression_size"`
 	tlsint.ServerConfig
 
 	wg sync.WaitGroup
@@ -261,9 +265,13 @@ func (sl *SocketListener) SampleConfig() string {
   ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_INPUT.md
   # data_format = "influx"
 
-  ## Content encoding for message payloads, can be set to "gzip" to or
-  ## "identity" to apply no encoding.
+  ## Content encoding for message payloads, can be set to "gzip", "zstd",
+  ## "x-snappy-framed", "lz4" or "identity" to apply no encoding.  The
+  ## snappy block format, "snappy", is only supported for packet sockets.
   # content_encoding = "identity"
+
+  ## Maximum size of a decoded packet, larger packets are dropped.
+  # max_decompression_size = "500MB"
 `
 }
 
@@ -336,7 +344,11 @@ func (sl *SocketListener) Start(acc telegraf.Accumulator) error {
 			ssl.listen()
 		}()
 	case "udp", "udp4", "udp6", "ip", "ip4", "ip6", "unixgram":
-		decoder, err := internal.NewContentDecoder(sl.ContentEncoding)
+		if sl.MaxDecompressionSize.Size == 0 {
+			sl.MaxDecompressionSize.Size = internal.DefaultMaxDecompressionSize
+		}
+		decoder, err := internal.NewContentDecoder(sl.ContentEncoding,
+			internal.WithMaxDecompressionSize(sl.MaxDecompressionSize.Size))
 		if err != nil {
 			return err
 		}
@@ -226,11 +226,53 @@ func TestSocketListenerDecode_udp(t *testing.T) {
 	testSocketListener(t, sl, client)
 }
 
+func TestSocketListenerDecodeEncodings(t *testing.T) {
+	tests := []struct {
+		network  string
+		encoding string
+	}{
+		{network: "tcp", encoding: "zstd"},
+		{network: "tcp", encoding: "x-snappy-framed"},
+		{network: "tcp", encoding: "lz4"},
+		{network: "udp", encoding: "zstd"},
+		{network: "udp", encoding: "snappy"},
+		{network: "udp", encoding: "lz4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network+"_"+tt.encoding, func(t *testing.T) {
+			defer testEmptyLog(t)()
+
+			sl := newSocketListener()
+			sl.Log = testutil.Logger{}
+			sl.ServiceAddress = tt.network + "://127.0.0.1:0"
+			sl.ReadBufferSize = internal.Size{Size: 1024}
+			sl.ContentEncoding = tt.encoding
+
+			acc := &testutil.Accumulator{}
+			err := sl.Start(acc)
+			require.NoError(t, err)
+			defer sl.Stop()
+
+			var addr string
+			switch c := sl.Closer.(type) {
+			case net.Listener:
+				addr = c.Addr().String()
+			case net.PacketConn:
+				addr = c.LocalAddr().String()
+			}
+			client, err := net.Dial(tt.network, addr)
+			require.NoError(t, err)
+
+			testSocketListener(t, sl, client)
+		})
+	}
+}
+
 func testSocketListener(t *testing.T, sl *SocketListener, client net.Conn) {
 	mstr12 := []byte("test,foo=bar v=1i 123456789\ntest,foo=baz v=2i 123456790\n")
 	mstr3 := []byte("test,foo=zab v=3i 123456791\n")
 
-	if sl.ContentEncoding == "gzip" {
+	if sl.ContentEncoding != "" {
 		encoder, err := internal.NewContentEncoder(sl.ContentEncoding)
 		require.NoError(t, err)
 		mstr12, err = encoder.Encode(mstr12)
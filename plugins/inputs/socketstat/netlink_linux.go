@@ -0,0 +1,211 @@
+// +build linux
+
+package socketstat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Definitions of linux/sock_diag.h and linux/inet_diag.h.
+const (
+	sockDiagByFamily = 20
+	inetDiagInfo     = 2
+
+	sizeofInetDiagReqV2 = 56
+	sizeofInetDiagMsg   = 72
+
+	// tcpInfiniteSsthresh is the slow start threshold before it is set.
+	tcpInfiniteSsthresh = 0x7fffffff
+)
+
+// netlinkTimeout bounds the time waiting for the kernel to answer.
+var netlinkTimeout = 5 * time.Second
+
+// netlinkSockets queries the sockets of the protocol using sock_diag,
+// including the TCP details if requested.
+func netlinkSockets(proto string, details bool) ([]socket, error) {
+	var family, protocol uint8
+	switch proto {
+	case "tcp":
+		family, protocol = unix.AF_INET, unix.IPPROTO_TCP
+	case "tcp6":
+		family, protocol = unix.AF_INET6, unix.IPPROTO_TCP
+	case "udp":
+		family, protocol = unix.AF_INET, unix.IPPROTO_UDP
+	case "udp6":
+		family, protocol = unix.AF_INET6, unix.IPPROTO_UDP
+	default:
+		return nil, fmt.Errorf("protocol %q not supported by netlink", proto)
+	}
+
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_INET_DIAG)
+	if err != nil {
+		return nil, fmt.Errorf("opening netlink socket failed: %v", err)
+	}
+	defer unix.Close(fd)
+
+	tv := unix.NsecToTimeval(netlinkTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return nil, err
+	}
+
+	const seq = 1
+	req := inetDiagRequest(family, protocol, details, seq)
+	if err := unix.Sendto(fd, req, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("sending netlink request failed: %v", err)
+	}
+
+	var sockets []socket
+	buf := make([]byte, 8*os.Getpagesize())
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("receiving netlink response failed: %v", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, msg := range msgs {
+			if msg.Header.Seq != seq {
+				continue
+			}
+			switch msg.Header.Type {
+			case unix.NLMSG_DONE:
+				return sockets, nil
+			case unix.NLMSG_ERROR:
+				if len(msg.Data) >= 4 {
+					if errno := int32(nativeEndian.Uint32(msg.Data)); errno != 0 {
+						return nil, fmt.Errorf("netlink request failed: %v", syscall.Errno(-errno))
+					}
+				}
+				return sockets, nil
+			case sockDiagByFamily:
+				sock, err := parseInetDiagMsg(msg.Data)
+				if err != nil {
+					return nil, err
+				}
+				sockets = append(sockets, sock)
+			}
+		}
+	}
+}
+
+// inetDiagRequest builds the netlink message dumping the sockets of all
+// states of a protocol, struct inet_diag_req_v2.
+func inetDiagRequest(family, protocol uint8, details bool, seq uint32) []byte {
+	b := make([]byte, unix.SizeofNlMsghdr+sizeofInetDiagReqV2)
+
+	nativeEndian.PutUint32(b[0:4], uint32(len(b)))
+	nativeEndian.PutUint16(b[4:6], sockDiagByFamily)
+	nativeEndian.PutUint16(b[6:8], unix.NLM_F_REQUEST|unix.NLM_F_DUMP)
+	nativeEndian.PutUint32(b[8:12], seq)
+
+	req := b[unix.SizeofNlMsghdr:]
+	req[0] = family
+	req[1] = protocol
+	if details {
+		req[2] = 1 << (inetDiagInfo - 1)
+	}
+	nativeEndian.PutUint32(req[4:8], 0xffffffff)
+	return b
+}
+
+// parseInetDiagMsg parses a socket, struct inet_diag_msg followed by its
+// attributes.
+func parseInetDiagMsg(b []byte) (socket, error) {
+	var sock socket
+	if len(b) < sizeofInetDiagMsg {
+		return sock, fmt.Errorf("short netlink message of %d bytes", len(b))
+	}
+
+	sock.state = tcpStates[b[1]]
+	sock.localPort = binary.BigEndian.Uint16(b[4:6])
+	sock.remotePort = binary.BigEndian.Uint16(b[6:8])
+	if b[0] == unix.AF_INET {
+		sock.localAddr = net.IP(append([]byte(nil), b[8:12]...))
+		sock.remoteAddr = net.IP(append([]byte(nil), b[24:28]...))
+	} else {
+		sock.localAddr = net.IP(append([]byte(nil), b[8:24]...))
+		sock.remoteAddr = net.IP(append([]byte(nil), b[24:40]...))
+	}
+	sock.rxQueue = uint64(nativeEndian.Uint32(b[56:60]))
+	sock.txQueue = uint64(nativeEndian.Uint32(b[60:64]))
+	sock.inode = uint64(nativeEndian.Uint32(b[68:72]))
+
+	for attrs := b[sizeofInetDiagMsg:]; len(attrs) >= unix.SizeofRtAttr; {
+		l := int(nativeEndian.Uint16(attrs[0:2]))
+		if l < unix.SizeofRtAttr || l > len(attrs) {
+			break
+		}
+		if nativeEndian.Uint16(attrs[2:4]) == inetDiagInfo {
+			sock.info = parseTCPInfo(attrs[unix.SizeofRtAttr:l])
+		}
+
+		l = (l + unix.RTA_ALIGNTO - 1) &^ (unix.RTA_ALIGNTO - 1)
+		if l > len(attrs) {
+			break
+		}
+		attrs = attrs[l:]
+	}
+	return sock, nil
+}
+
+// parseTCPInfo parses struct tcp_info.  The struct grew over the kernel
+// versions, fields beyond the ones of the oldest supported layout are only
+// added if present.
+func parseTCPInfo(b []byte) map[string]interface{} {
+	if len(b) < 104 {
+		return nil
+	}
+	u32 := func(offset int) int64 {
+		return int64(nativeEndian.Uint32(b[offset : offset+4]))
+	}
+
+	info := map[string]interface{}{
+		"retransmits":       int64(b[2]),
+		"probes":            int64(b[3]),
+		"backoff":           int64(b[4]),
+		"rto_ms":            u32(8) / 1000,
+		"ato_ms":            u32(12) / 1000,
+		"snd_mss":           u32(16),
+		"rcv_mss":           u32(20),
+		"unacked":           u32(24),
+		"sacked":            u32(28),
+		"lost":              u32(32),
+		"retrans":           u32(36),
+		"last_data_sent_ms": u32(44),
+		"last_data_recv_ms": u32(52),
+		"last_ack_recv_ms":  u32(56),
+		"pmtu":              u32(60),
+		"rcv_ssthresh":      u32(64),
+		"rtt_us":            u32(68),
+		"rttvar_us":         u32(72),
+		"cwnd":              u32(80),
+		"advmss":            u32(84),
+		"reordering":        u32(88),
+		"rcv_rtt_us":        u32(92),
+		"rcv_space":         u32(96),
+		"total_retrans":     u32(100),
+	}
+	if ssthresh := u32(76); ssthresh < tcpInfiniteSsthresh {
+		info["ssthresh"] = ssthresh
+	}
+	if len(b) >= 136 {
+		info["bytes_acked"] = int64(nativeEndian.Uint64(b[120:128]))
+		info["bytes_received"] = int64(nativeEndian.Uint64(b[128:136]))
+	}
+	if len(b) >= 144 {
+		info["segs_out"] = u32(136)
+		info["segs_in"] = u32(140)
+	}
+	return info
+}
@@ -0,0 +1,146 @@
+package socketstat
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+var sampleConfig = `
+  ## Source of the socket information, either "proc" to read the socket tables
+  ## in <proc_root>/net or "netlink" to query the kernel using sock_diag.  The
+  ## round trip time and the other TCP details beyond the queue sizes,
+  ## retransmits and congestion window are only available with "netlink".
+  ## Unix sockets are always read from <proc_root>/net/unix.
+  # method = "proc"
+
+  ## Root of the proc filesystem.  Defaults to $HOST_PROC or "/proc".
+  # proc_root = "/proc"
+
+  ## Protocols to gather the connection counts of; available are "tcp",
+  ## "tcp6", "udp", "udp6" and "unix".
+  # protocols = ["tcp", "tcp6", "udp", "udp6", "unix"]
+
+  ## Report the details of each TCP socket with a local or remote port in
+  ## ports, or owned by a process with a name matching processes.  Resolving
+  ## the processes of sockets owned by other users requires root privileges or
+  ## the CAP_SYS_PTRACE capability.  Beware that reporting every connection
+  ## of a busy service can lead to a high cardinality.
+  # ports = [443, 5432]
+  # processes = ["nginx", "postgres*"]
+
+  ## States of the TCP sockets reported by ports and processes.
+  # socket_states = ["established"]
+`
+
+// tcpStates are the TCP states by their number in the kernel.
+var tcpStates = map[uint8]string{
+	1:  "established",
+	2:  "syn_sent",
+	3:  "syn_recv",
+	4:  "fin_wait1",
+	5:  "fin_wait2",
+	6:  "time_wait",
+	7:  "close",
+	8:  "close_wait",
+	9:  "last_ack",
+	10: "listen",
+	11: "closing",
+	12: "new_syn_recv",
+}
+
+// unixStates are the states of unix sockets.
+var unixStates = map[uint8]string{
+	1: "unconnected",
+	2: "connecting",
+	3: "connected",
+	4: "disconnecting",
+}
+
+type SocketStat struct {
+	Method       string   `toml:"method"`
+	ProcRoot     string   `toml:"proc_root"`
+	Protocols    []string `toml:"protocols"`
+	Ports        []uint16 `toml:"ports"`
+	Processes    []string `toml:"processes"`
+	SocketStates []string `toml:"socket_states"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	ports     map[uint16]bool
+	processes filter.Filter
+	states    map[string]bool
+}
+
+func (s *SocketStat) Description() string {
+	return "Gather connection counts and TCP socket details like ss"
+}
+
+func (s *SocketStat) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *SocketStat) Init() error {
+	switch s.Method {
+	case "":
+		s.Method = "proc"
+	case "proc", "netlink":
+	default:
+		return fmt.Errorf("unknown method %q", s.Method)
+	}
+
+	if s.ProcRoot == "" {
+		s.ProcRoot = "/proc"
+		if env := os.Getenv("HOST_PROC"); env != "" {
+			s.ProcRoot = env
+		}
+	}
+
+	for _, proto := range s.Protocols {
+		switch proto {
+		case "tcp", "tcp6", "udp", "udp6", "unix":
+		default:
+			return fmt.Errorf("unknown protocol %q", proto)
+		}
+	}
+
+	s.ports = make(map[uint16]bool, len(s.Ports))
+	for _, port := range s.Ports {
+		s.ports[port] = true
+	}
+
+	var err error
+	if s.processes, err = filter.Compile(s.Processes); err != nil {
+		return fmt.Errorf("invalid processes: %v", err)
+	}
+
+	s.states = make(map[string]bool, len(s.SocketStates))
+	for _, state := range s.SocketStates {
+		if !isTCPState(state) {
+			return fmt.Errorf("unknown socket state %q", state)
+		}
+		s.states[state] = true
+	}
+	return nil
+}
+
+func isTCPState(name string) bool {
+	for _, state := range tcpStates {
+		if state == name {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	inputs.Add("socketstat", func() telegraf.Input {
+		return &SocketStat{
+			Protocols:    []string{"tcp", "tcp6", "udp", "udp6", "unix"},
+			SocketStates: []string{"established"},
+		}
+	})
+}
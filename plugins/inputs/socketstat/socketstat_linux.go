@@ -0,0 +1,344 @@
+// +build linux
+
+package socketstat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/influxdata/telegraf"
+)
+
+// userHZ is the frequency of the clock ticks the timers in the socket tables
+// are reported in.
+const userHZ = 100
+
+// soAcceptCon is the flag of listening unix sockets.
+const soAcceptCon = 0x10000
+
+// nativeEndian is the byte order of the host, used by the kernel for the
+// addresses in the socket tables and by netlink.
+var nativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	var x uint16 = 1
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+type socket struct {
+	state      string
+	localAddr  net.IP
+	localPort  uint16
+	remoteAddr net.IP
+	remotePort uint16
+	txQueue    uint64
+	rxQueue    uint64
+	inode      uint64
+
+	// info are the TCP details of the socket.
+	info map[string]interface{}
+}
+
+// owner is the process owning a socket.
+type owner struct {
+	pid  string
+	name string
+}
+
+func (s *SocketStat) Gather(acc telegraf.Accumulator) error {
+	report := len(s.ports) > 0 || s.processes != nil
+
+	var owners map[uint64]owner
+	for _, proto := range s.Protocols {
+		isTCP := proto == "tcp" || proto == "tcp6"
+
+		sockets, err := s.sockets(proto, isTCP && report)
+		if err != nil {
+			acc.AddError(fmt.Errorf("gathering %s sockets failed: %v", proto, err))
+			continue
+		}
+		addCounts(proto, sockets, acc)
+
+		if !isTCP || !report {
+			continue
+		}
+		if owners == nil {
+			owners = s.socketOwners()
+		}
+		s.addSockets(proto, sockets, owners, acc)
+	}
+	return nil
+}
+
+func (s *SocketStat) sockets(proto string, details bool) ([]socket, error) {
+	if proto != "unix" && s.Method == "netlink" {
+		return netlinkSockets(proto, details)
+	}
+
+	f, err := os.Open(filepath.Join(s.ProcRoot, "net", proto))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if proto == "unix" {
+		return parseUnix(f)
+	}
+	return parseInet(f)
+}
+
+// addCounts adds the number of sockets by state.
+func addCounts(proto string, sockets []socket, acc telegraf.Accumulator) {
+	fields := map[string]interface{}{"total": int64(len(sockets))}
+	switch proto {
+	case "tcp", "tcp6":
+		for _, state := range tcpStates {
+			fields[state] = int64(0)
+		}
+	case "udp", "udp6":
+		fields["established"] = int64(0)
+		fields["close"] = int64(0)
+	case "unix":
+		for _, state := range unixStates {
+			fields[state] = int64(0)
+		}
+		fields["listen"] = int64(0)
+	}
+
+	for _, sock := range sockets {
+		if sock.state == "" {
+			continue
+		}
+		count, _ := fields[sock.state].(int64)
+		fields[sock.state] = count + 1
+	}
+	acc.AddFields("socketstat", fields, map[string]string{"proto": proto})
+}
+
+// addSockets adds the details of the TCP sockets matching the filters.
+func (s *SocketStat) addSockets(proto string, sockets []socket, owners map[uint64]owner, acc telegraf.Accumulator) {
+	for _, sock := range sockets {
+		if !s.states[sock.state] {
+			continue
+		}
+
+		o, owned := owners[sock.inode]
+		matched := s.ports[sock.localPort] || s.ports[sock.remotePort]
+		if !matched && owned && s.processes != nil {
+			matched = s.processes.Match(o.name)
+		}
+		if !matched {
+			continue
+		}
+
+		tags := map[string]string{
+			"proto":       proto,
+			"state":       sock.state,
+			"local_addr":  sock.localAddr.String(),
+			"local_port":  strconv.Itoa(int(sock.localPort)),
+			"remote_addr": sock.remoteAddr.String(),
+			"remote_port": strconv.Itoa(int(sock.remotePort)),
+		}
+		if owned {
+			tags["pid"] = o.pid
+			tags["process"] = o.name
+		}
+
+		fields := map[string]interface{}{
+			"tx_queue": int64(sock.txQueue),
+			"rx_queue": int64(sock.rxQueue),
+		}
+		for k, v := range sock.info {
+			fields[k] = v
+		}
+		acc.AddFields("socketstat_tcp", fields, tags)
+	}
+}
+
+// socketOwners maps the inodes of the sockets to the processes having them
+// open.  Processes which exit while reading them or which are not accessible
+// are skipped.
+func (s *SocketStat) socketOwners() map[uint64]owner {
+	owners := make(map[uint64]owner)
+
+	entries, err := ioutil.ReadDir(s.ProcRoot)
+	if err != nil {
+		s.Log.Errorf("Reading processes failed: %v", err)
+		return owners
+	}
+	for _, entry := range entries {
+		pid := entry.Name()
+		if _, err := strconv.ParseUint(pid, 10, 32); err != nil {
+			continue
+		}
+
+		fddir := filepath.Join(s.ProcRoot, pid, "fd")
+		fds, err := ioutil.ReadDir(fddir)
+		if err != nil {
+			continue
+		}
+		var name string
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fddir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(link[len("socket:["):], "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+			if name == "" {
+				comm, err := ioutil.ReadFile(filepath.Join(s.ProcRoot, pid, "comm"))
+				if err != nil {
+					break
+				}
+				name = strings.TrimSpace(string(comm))
+			}
+			owners[inode] = owner{pid: pid, name: name}
+		}
+	}
+	return owners
+}
+
+// parseInet parses the socket table of an internet protocol, like
+// /proc/net/tcp:
+//   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+//    0: 0100007F:0277 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 20342 1 0000000000000000 100 0 0 10 0
+// The trailing values of TCP sockets are the reference count, the address of
+// the socket, the retransmit and acknowledgement timeouts, the quick ack
+// state, the congestion window and the slow start threshold.
+func parseInet(r io.Reader) ([]socket, error) {
+	var sockets []socket
+
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 10 {
+			continue
+		}
+
+		var sock socket
+		var err error
+		if sock.localAddr, sock.localPort, err = parseInetAddr(parts[1]); err != nil {
+			return nil, err
+		}
+		if sock.remoteAddr, sock.remotePort, err = parseInetAddr(parts[2]); err != nil {
+			return nil, err
+		}
+
+		state, err := strconv.ParseUint(parts[3], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state %q", parts[3])
+		}
+		sock.state = tcpStates[uint8(state)]
+
+		queues := strings.SplitN(parts[4], ":", 2)
+		if len(queues) != 2 {
+			return nil, fmt.Errorf("invalid queues %q", parts[4])
+		}
+		if sock.txQueue, err = strconv.ParseUint(queues[0], 16, 64); err != nil {
+			return nil, err
+		}
+		if sock.rxQueue, err = strconv.ParseUint(queues[1], 16, 64); err != nil {
+			return nil, err
+		}
+		if sock.inode, err = strconv.ParseUint(parts[9], 10, 64); err != nil {
+			return nil, err
+		}
+
+		sock.info = make(map[string]interface{})
+		if v, err := strconv.ParseInt(parts[6], 16, 64); err == nil {
+			sock.info["retransmits"] = v
+		}
+		// Sockets in the time wait state lack the trailing values.
+		if len(parts) >= 17 {
+			if v, err := strconv.ParseInt(parts[12], 10, 64); err == nil {
+				sock.info["rto_ms"] = v * 1000 / userHZ
+			}
+			if v, err := strconv.ParseInt(parts[13], 10, 64); err == nil {
+				sock.info["ato_ms"] = v * 1000 / userHZ
+			}
+			if v, err := strconv.ParseInt(parts[15], 10, 64); err == nil {
+				sock.info["cwnd"] = v
+			}
+			if v, err := strconv.ParseInt(parts[16], 10, 64); err == nil && v >= 0 {
+				sock.info["ssthresh"] = v
+			}
+		}
+		sockets = append(sockets, sock)
+	}
+	return sockets, scanner.Err()
+}
+
+// parseInetAddr parses an address like 0100007F:0277.  The address is printed
+// as 32-bit words in host byte order, the port in network byte order.
+func parseInetAddr(s string) (net.IP, uint16, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("invalid address %q", s)
+	}
+
+	raw, err := hex.DecodeString(parts[0])
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid address %q", s)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		nativeEndian.PutUint32(ip[i:], binary.BigEndian.Uint32(raw[i:]))
+	}
+
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid port in address %q", s)
+	}
+	return ip, uint16(port), nil
+}
+
+// parseUnix parses the table of unix sockets:
+//   Num       RefCount Protocol Flags    Type St Inode Path
+//   0000000000000000: 00000002 00000000 00010000 0001 01 20536 /run/systemd/private
+func parseUnix(r io.Reader) ([]socket, error) {
+	var sockets []socket
+
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 7 {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(parts[3], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flags %q", parts[3])
+		}
+		state, err := strconv.ParseUint(parts[5], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state %q", parts[5])
+		}
+		inode, err := strconv.ParseUint(parts[6], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid inode %q", parts[6])
+		}
+
+		sock := socket{state: unixStates[uint8(state)], inode: inode}
+		if flags&soAcceptCon != 0 {
+			sock.state = "listen"
+		}
+		sockets = append(sockets, sock)
+	}
+	return sockets, scanner.Err()
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package socketstat
+
+import (
+	"github.com/influxdata/telegraf"
+)
+
+func (s *SocketStat) Gather(acc telegraf.Accumulator) error {
+	return nil
+}
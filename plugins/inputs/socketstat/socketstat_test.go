@@ -0,0 +1,300 @@
+// +build linux
+
+package socketstat
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+// newProcRoot creates a proc filesystem with the socket tables of testdata
+// and processes having some of the sockets open.
+func newProcRoot(t *testing.T) string {
+	root := t.TempDir()
+
+	tables, err := filepath.Abs("testdata/proc/net")
+	require.NoError(t, err)
+	require.NoError(t, os.Symlink(tables, filepath.Join(root, "net")))
+
+	processes := map[string]struct {
+		name string
+		fds  map[string]string
+	}{
+		"812": {"nginx", map[string]string{"3": "socket:[22345]", "4": "socket:[22346]", "5": "/dev/null"}},
+		"900": {"postgres", map[string]string{"7": "socket:[22890]"}},
+	}
+	for pid, p := range processes {
+		fddir := filepath.Join(root, pid, "fd")
+		require.NoError(t, os.MkdirAll(fddir, 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(root, pid, "comm"), []byte(p.name+"\n"), 0644))
+		for fd, link := range p.fds {
+			require.NoError(t, os.Symlink(link, filepath.Join(fddir, fd)))
+		}
+	}
+	return root
+}
+
+func TestInit(t *testing.T) {
+	s := &SocketStat{}
+	require.NoError(t, s.Init())
+	require.Equal(t, "proc", s.Method)
+
+	tests := []struct {
+		name string
+		s    *SocketStat
+	}{
+		{"unknown method", &SocketStat{Method: "ss"}},
+		{"unknown protocol", &SocketStat{Protocols: []string{"sctp"}}},
+		{"unknown state", &SocketStat{SocketStates: []string{"open"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, tt.s.Init())
+		})
+	}
+}
+
+func TestGatherCounts(t *testing.T) {
+	s := &SocketStat{
+		ProcRoot:  "testdata/proc",
+		Protocols: []string{"tcp", "tcp6", "udp", "udp6", "unix"},
+		Log:       testutil.Logger{},
+	}
+	require.NoError(t, s.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(s.Gather))
+
+	tcp := func(counts map[string]int64) map[string]interface{} {
+		fields := make(map[string]interface{})
+		for _, state := range tcpStates {
+			fields[state] = counts[state]
+		}
+		fields["total"] = counts["total"]
+		return fields
+	}
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("socketstat",
+			map[string]string{"proto": "tcp"},
+			tcp(map[string]int64{"listen": 2, "established": 3, "time_wait": 1, "total": 6}),
+			time.Unix(0, 0)),
+		testutil.MustMetric("socketstat",
+			map[string]string{"proto": "tcp6"},
+			tcp(map[string]int64{"listen": 1, "established": 1, "total": 2}),
+			time.Unix(0, 0)),
+		testutil.MustMetric("socketstat",
+			map[string]string{"proto": "udp"},
+			map[string]interface{}{"established": int64(1), "close": int64(1), "total": int64(2)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("socketstat",
+			map[string]string{"proto": "udp6"},
+			map[string]interface{}{"established": int64(0), "close": int64(0), "total": int64(0)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("socketstat",
+			map[string]string{"proto": "unix"},
+			map[string]interface{}{
+				"listen":        int64(2),
+				"unconnected":   int64(1),
+				"connecting":    int64(0),
+				"connected":     int64(2),
+				"disconnecting": int64(0),
+				"total":         int64(5),
+			},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.SortMetrics(), testutil.IgnoreTime())
+}
+
+func TestGatherSockets(t *testing.T) {
+	s := &SocketStat{
+		ProcRoot:     newProcRoot(t),
+		Protocols:    []string{"tcp", "udp"},
+		Ports:        []uint16{5432},
+		Processes:    []string{"ngin*"},
+		SocketStates: []string{"established"},
+		Log:          testutil.Logger{},
+	}
+	require.NoError(t, s.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(s.Gather))
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("socketstat_tcp",
+			map[string]string{
+				"proto":       "tcp",
+				"state":       "established",
+				"local_addr":  "10.0.0.10",
+				"local_port":  "443",
+				"remote_addr": "10.0.0.11",
+				"remote_port": "54321",
+				"pid":         "812",
+				"process":     "nginx",
+			},
+			map[string]interface{}{
+				"tx_queue":    int64(288),
+				"rx_queue":    int64(0),
+				"retransmits": int64(2),
+				"rto_ms":      int64(410),
+				"ato_ms":      int64(40),
+				"cwnd":        int64(10),
+				"ssthresh":    int64(7),
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("socketstat_tcp",
+			map[string]string{
+				"proto":       "tcp",
+				"state":       "established",
+				"local_addr":  "10.0.0.10",
+				"local_port":  "443",
+				"remote_addr": "10.0.0.12",
+				"remote_port": "49153",
+				"pid":         "812",
+				"process":     "nginx",
+			},
+			map[string]interface{}{
+				"tx_queue":    int64(0),
+				"rx_queue":    int64(0),
+				"retransmits": int64(0),
+				"rto_ms":      int64(200),
+				"ato_ms":      int64(40),
+				"cwnd":        int64(28),
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("socketstat_tcp",
+			map[string]string{
+				"proto":       "tcp",
+				"state":       "established",
+				"local_addr":  "127.0.0.1",
+				"local_port":  "55458",
+				"remote_addr": "127.0.0.1",
+				"remote_port": "5432",
+			},
+			map[string]interface{}{
+				"tx_queue":    int64(0),
+				"rx_queue":    int64(0),
+				"retransmits": int64(0),
+				"rto_ms":      int64(200),
+				"ato_ms":      int64(40),
+				"cwnd":        int64(10),
+			},
+			time.Unix(0, 0)),
+	}
+
+	var actual []telegraf.Metric
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() == "socketstat_tcp" {
+			actual = append(actual, m)
+		}
+	}
+	testutil.RequireMetricsEqual(t, expected, actual, testutil.SortMetrics(), testutil.IgnoreTime())
+}
+
+func TestParseInetAddr(t *testing.T) {
+	ip, port, err := parseInetAddr("0100007F:1538")
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", ip.String())
+	require.Equal(t, uint16(5432), port)
+
+	ip, port, err = parseInetAddr("0000000000000000FFFF00000100007F:01BB")
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", ip.String())
+	require.Equal(t, uint16(443), port)
+
+	ip, _, err = parseInetAddr("000080FE00000000FF0C0A02FE3ED81E:0016")
+	require.NoError(t, err)
+	require.Equal(t, "fe80::20a:cff:1ed8:3efe", ip.String())
+
+	for _, addr := range []string{"0100007F", "01007F:0016", "0100007F:XYZ"} {
+		_, _, err := parseInetAddr(addr)
+		require.Error(t, err, addr)
+	}
+}
+
+func TestParseInetDiagMsg(t *testing.T) {
+	b := make([]byte, sizeofInetDiagMsg, sizeofInetDiagMsg+4+144)
+	b[0] = 2 // AF_INET
+	b[1] = 1 // established
+	binary.BigEndian.PutUint16(b[4:6], 443)
+	binary.BigEndian.PutUint16(b[6:8], 54321)
+	copy(b[8:12], net.ParseIP("10.0.0.10").To4())
+	copy(b[24:28], net.ParseIP("10.0.0.11").To4())
+	nativeEndian.PutUint32(b[56:60], 12)
+	nativeEndian.PutUint32(b[60:64], 288)
+	nativeEndian.PutUint32(b[68:72], 22345)
+
+	info := make([]byte, 144)
+	info[2] = 1
+	nativeEndian.PutUint32(info[8:12], 204000)
+	nativeEndian.PutUint32(info[68:72], 1520)
+	nativeEndian.PutUint32(info[72:76], 380)
+	nativeEndian.PutUint32(info[76:80], tcpInfiniteSsthresh)
+	nativeEndian.PutUint32(info[80:84], 10)
+	nativeEndian.PutUint32(info[100:104], 3)
+	nativeEndian.PutUint64(info[120:128], 123456)
+
+	attr := make([]byte, 4)
+	nativeEndian.PutUint16(attr[0:2], uint16(4+len(info)))
+	nativeEndian.PutUint16(attr[2:4], inetDiagInfo)
+	b = append(append(b, attr...), info...)
+
+	sock, err := parseInetDiagMsg(b)
+	require.NoError(t, err)
+	require.Equal(t, "established", sock.state)
+	require.Equal(t, "10.0.0.10", sock.localAddr.String())
+	require.Equal(t, uint16(443), sock.localPort)
+	require.Equal(t, "10.0.0.11", sock.remoteAddr.String())
+	require.Equal(t, uint16(54321), sock.remotePort)
+	require.Equal(t, uint64(12), sock.rxQueue)
+	require.Equal(t, uint64(288), sock.txQueue)
+	require.Equal(t, uint64(22345), sock.inode)
+
+	require.Equal(t, int64(1), sock.info["retransmits"])
+	require.Equal(t, int64(204), sock.info["rto_ms"])
+	require.Equal(t, int64(1520), sock.info["rtt_us"])
+	require.Equal(t, int64(380), sock.info["rttvar_us"])
+	require.Equal(t, int64(10), sock.info["cwnd"])
+	require.Equal(t, int64(3), sock.info["total_retrans"])
+	require.Equal(t, int64(123456), sock.info["bytes_acked"])
+	require.NotContains(t, sock.info, "ssthresh")
+
+	_, err = parseInetDiagMsg(b[:40])
+	require.Error(t, err)
+}
+
+func TestNetlink(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp4", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sockets, err := netlinkSockets("tcp", true)
+	if err != nil {
+		t.Skipf("sock_diag not available: %v", err)
+	}
+
+	port := uint16(listener.Addr().(*net.TCPAddr).Port)
+	var found bool
+	for _, sock := range sockets {
+		if sock.remotePort == port && sock.state == "established" {
+			found = true
+			require.Equal(t, "127.0.0.1", sock.localAddr.String())
+			require.Contains(t, sock.info, "rtt_us")
+		}
+	}
+	require.True(t, found)
+}
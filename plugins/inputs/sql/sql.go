@@ -0,0 +1,374 @@
+package sql
+
+import (
+	"context"
+	gosql "database/sql"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/inputs"
+
+	_ "github.com/denisenkom/go-mssqldb" // register the SQL Server driver
+	_ "github.com/go-sql-driver/mysql"   // register the MySQL driver
+	_ "github.com/jackc/pgx/stdlib"      // register the PostgreSQL driver
+)
+
+var sampleConfig = `
+  ## Database driver
+  ## Valid options: mysql (MySQL), pgx (PostgreSQL), sqlserver (SQL Server),
+  ##                sqlite (SQLite)
+  driver = "mysql"
+
+  ## Data source name
+  ## The format of the data source name is different for each database driver.
+  ## See the plugin readme for details.
+  data_source_name = "username:password@tcp(localhost:3306)/dbname"
+
+  ## Timeout for each query.
+  # timeout = "5s"
+
+  ## Maximum number of open and idle connections and the maximum time a
+  ## connection may be reused.  0 means unlimited.
+  # connection_max_open = 0
+  # connection_max_idle = 2
+  # connection_max_lifetime = "0s"
+
+  ## Queries to run, each row of the result is a metric.
+  [[inputs.sql.query]]
+    ## The query text, or the path of a file containing it.
+    query = "SELECT region, COUNT(*) AS orders, SUM(total) AS revenue FROM orders GROUP BY region"
+    # query_script = "/etc/telegraf/orders.sql"
+
+    ## Name of the measurement, or the column containing it.
+    # measurement = "sql"
+    # measurement_column = ""
+
+    ## Minimum time between two runs of the query, the query runs at most
+    ## once per gather interval.  0 runs it in every interval.
+    # interval = "0s"
+
+    ## Columns used as tags and fields, glob patterns are allowed.  No column
+    ## is a tag by default, all columns which are not tags are fields.
+    # tag_columns_include = ["region"]
+    # tag_columns_exclude = []
+    # field_columns_include = []
+    # field_columns_exclude = []
+
+    ## Column containing the time of the metric, the time of the gather is
+    ## used if unset.  The format is used if the column is not a timestamp:
+    ## one of "unix", "unix_ms", "unix_us", "unix_ns" or a Go time layout.
+    # time_column = ""
+    # time_format = "unix"
+`
+
+// drivers are the supported database/sql drivers.
+var drivers = map[string]bool{
+	"mysql":     true,
+	"pgx":       true,
+	"sqlserver": true,
+	"sqlite":    true,
+}
+
+// Query is a query whose result rows are turned into metrics.
+type Query struct {
+	Query               string            `toml:"query"`
+	Script              string            `toml:"query_script"`
+	Measurement         string            `toml:"measurement"`
+	MeasurementColumn   string            `toml:"measurement_column"`
+	Interval            internal.Duration `toml:"interval"`
+	TagColumnsInclude   []string          `toml:"tag_columns_include"`
+	TagColumnsExclude   []string          `toml:"tag_columns_exclude"`
+	FieldColumnsInclude []string          `toml:"field_columns_include"`
+	FieldColumnsExclude []string          `toml:"field_columns_exclude"`
+	TimeColumn          string            `toml:"time_column"`
+	TimeFormat          string            `toml:"time_format"`
+
+	tagFilter   filter.Filter
+	fieldFilter filter.Filter
+	lastRun     time.Time
+}
+
+type SQL struct {
+	Driver                string            `toml:"driver"`
+	DataSourceName        string            `toml:"data_source_name"`
+	Timeout               internal.Duration `toml:"timeout"`
+	ConnectionMaxOpen     int               `toml:"connection_max_open"`
+	ConnectionMaxIdle     int               `toml:"connection_max_idle"`
+	ConnectionMaxLifetime internal.Duration `toml:"connection_max_lifetime"`
+	Queries               []*Query          `toml:"query"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	db *gosql.DB
+}
+
+func (*SQL) Description() string {
+	return "Read metrics from SQL queries"
+}
+
+func (*SQL) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *SQL) Init() error {
+	if !drivers[s.Driver] {
+		return fmt.Errorf("unsupported driver %q", s.Driver)
+	}
+	if len(s.Queries) == 0 {
+		return fmt.Errorf("no queries configured")
+	}
+
+	for i, q := range s.Queries {
+		if q.Query != "" && q.Script != "" {
+			return fmt.Errorf("query %d: only one of query and query_script may be set", i+1)
+		}
+		if q.Script != "" {
+			content, err := ioutil.ReadFile(q.Script)
+			if err != nil {
+				return fmt.Errorf("query %d: reading query script failed: %v", i+1, err)
+			}
+			q.Query = string(content)
+		}
+		if strings.TrimSpace(q.Query) == "" {
+			return fmt.Errorf("query %d: the query is empty", i+1)
+		}
+		if q.Measurement == "" {
+			q.Measurement = "sql"
+		}
+		if q.TimeFormat == "" {
+			q.TimeFormat = "unix"
+		}
+
+		// No column is a tag unless included.
+		if len(q.TagColumnsInclude) > 0 {
+			f, err := filter.NewIncludeExcludeFilter(q.TagColumnsInclude, q.TagColumnsExclude)
+			if err != nil {
+				return fmt.Errorf("query %d: invalid tag columns: %v", i+1, err)
+			}
+			q.tagFilter = f
+		}
+		f, err := filter.NewIncludeExcludeFilter(q.FieldColumnsInclude, q.FieldColumnsExclude)
+		if err != nil {
+			return fmt.Errorf("query %d: invalid field columns: %v", i+1, err)
+		}
+		q.fieldFilter = f
+	}
+	return nil
+}
+
+func (s *SQL) Start(_ telegraf.Accumulator) error {
+	db, err := gosql.Open(s.Driver, s.DataSourceName)
+	if err != nil {
+		return err
+	}
+
+	db.SetMaxOpenConns(s.ConnectionMaxOpen)
+	db.SetMaxIdleConns(s.ConnectionMaxIdle)
+	db.SetConnMaxLifetime(s.ConnectionMaxLifetime.Duration)
+
+	s.db = db
+	return nil
+}
+
+func (s *SQL) Stop() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *SQL) Gather(acc telegraf.Accumulator) error {
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	for _, q := range s.Queries {
+		// Allow for the jitter of the gather interval.
+		if q.Interval.Duration > 0 && now.Sub(q.lastRun) < q.Interval.Duration-time.Second {
+			continue
+		}
+		q.lastRun = now
+
+		wg.Add(1)
+		go func(q *Query) {
+			defer wg.Done()
+			if err := s.run(q, acc, now); err != nil {
+				acc.AddError(fmt.Errorf("query %q failed: %v", firstLine(q.Query), err))
+			}
+		}(q)
+	}
+	wg.Wait()
+	return nil
+}
+
+// run executes the query and adds a metric for each row.
+func (s *SQL) run(q *Query, acc telegraf.Accumulator, now time.Time) error {
+	ctx := context.Background()
+	if s.Timeout.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout.Duration)
+		defer cancel()
+	}
+
+	rows, err := s.db.QueryContext(ctx, q.Query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+
+		measurement := q.Measurement
+		timestamp := now
+		tags := make(map[string]string)
+		fields := make(map[string]interface{})
+		for i, column := range columns {
+			name := column.Name()
+			value := convertValue(values[i], column.DatabaseTypeName())
+			if value == nil {
+				continue
+			}
+
+			switch {
+			case name == q.MeasurementColumn:
+				measurement = toString(value)
+			case name == q.TimeColumn:
+				t, err := parseTime(value, q.TimeFormat)
+				if err != nil {
+					return fmt.Errorf("parsing time column %q failed: %v", name, err)
+				}
+				timestamp = t
+			case q.tagFilter != nil && q.tagFilter.Match(name):
+				tags[name] = toString(value)
+			case q.fieldFilter.Match(name):
+				if t, ok := value.(time.Time); ok {
+					value = t.UTC().Format(time.RFC3339Nano)
+				}
+				fields[name] = value
+			}
+		}
+
+		if len(fields) == 0 {
+			s.Log.Debugf("Skipping row without fields of query %q", firstLine(q.Query))
+			continue
+		}
+		acc.AddFields(measurement, fields, tags, timestamp)
+	}
+	return rows.Err()
+}
+
+// Database type names of the columns whose values are converted from text.
+var (
+	integerTypes = map[string]bool{
+		"TINYINT": true, "SMALLINT": true, "MEDIUMINT": true, "INT": true, "INTEGER": true,
+		"BIGINT": true, "INT2": true, "INT4": true, "INT8": true, "YEAR": true,
+	}
+	floatTypes = map[string]bool{
+		"DECIMAL": true, "NUMERIC": true, "FLOAT": true, "DOUBLE": true, "REAL": true,
+		"FLOAT4": true, "FLOAT8": true, "MONEY": true, "SMALLMONEY": true,
+	}
+	boolTypes = map[string]bool{
+		"BOOL": true, "BOOLEAN": true, "BIT": true,
+	}
+)
+
+// convertValue converts a value returned by the driver to a metric value.
+// Some drivers return numbers as text, these are converted according to the
+// type of the column.
+func convertValue(value interface{}, typeName string) interface{} {
+	var text string
+	switch v := value.(type) {
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	default:
+		return value
+	}
+
+	typeName = strings.TrimPrefix(strings.ToUpper(typeName), "UNSIGNED ")
+	switch {
+	case integerTypes[typeName]:
+		if v, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return v
+		}
+		if v, err := strconv.ParseUint(text, 10, 64); err == nil {
+			return v
+		}
+	case floatTypes[typeName]:
+		if v, err := strconv.ParseFloat(text, 64); err == nil {
+			return v
+		}
+	case boolTypes[typeName]:
+		if v, err := strconv.ParseBool(text); err == nil {
+			return v
+		}
+	}
+	return text
+}
+
+func parseTime(value interface{}, format string) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case uint64:
+		return internal.ParseTimestamp(format, strconv.FormatUint(v, 10), "")
+	case bool:
+		return time.Time{}, fmt.Errorf("unsupported value %v", v)
+	default:
+		return internal.ParseTimestamp(format, value, "")
+	}
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// firstLine shortens the query for error messages.
+func firstLine(query string) string {
+	query = strings.TrimSpace(query)
+	if i := strings.IndexByte(query, '\n'); i >= 0 {
+		return query[:i] + "..."
+	}
+	return query
+}
+
+func init() {
+	inputs.Add("sql", func() telegraf.Input {
+		return &SQL{
+			Timeout:           internal.Duration{Duration: 5 * time.Second},
+			ConnectionMaxIdle: 2,
+		}
+	})
+}
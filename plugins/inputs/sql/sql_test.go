@@ -0,0 +1,89 @@
+package sql
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "query.sql")
+	require.NoError(t, ioutil.WriteFile(script, []byte("SELECT 1 AS one\n"), 0644))
+
+	s := &SQL{
+		Driver:  "pgx",
+		Queries: []*Query{{Script: script}},
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, s.Init())
+	require.Equal(t, "SELECT 1 AS one\n", s.Queries[0].Query)
+	require.Equal(t, "sql", s.Queries[0].Measurement)
+	require.Equal(t, "unix", s.Queries[0].TimeFormat)
+
+	tests := []struct {
+		name string
+		sql  *SQL
+	}{
+		{"unknown driver", &SQL{Driver: "oracle", Queries: []*Query{{Query: "SELECT 1"}}}},
+		{"no queries", &SQL{Driver: "mysql"}},
+		{"query and script", &SQL{Driver: "mysql", Queries: []*Query{{Query: "SELECT 1", Script: script}}}},
+		{"empty query", &SQL{Driver: "mysql", Queries: []*Query{{Query: " "}}}},
+		{"missing script", &SQL{Driver: "mysql", Queries: []*Query{{Script: "/nonexistent.sql"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, tt.sql.Init())
+		})
+	}
+}
+
+func TestConvertValue(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		typeName string
+		expected interface{}
+	}{
+		{[]byte("42"), "BIGINT", int64(42)},
+		{[]byte("18446744073709551615"), "UNSIGNED BIGINT", uint64(18446744073709551615)},
+		{[]byte("1.25"), "DECIMAL", 1.25},
+		{"3.5", "numeric", 3.5},
+		{[]byte("1"), "BOOL", true},
+		{[]byte("eu-west"), "VARCHAR", "eu-west"},
+		{[]byte("x"), "INT", "x"},
+		{int64(7), "INTEGER", int64(7)},
+		{int32(7), "INT4", int64(7)},
+		{float32(0.5), "FLOAT4", 0.5},
+		{nil, "TEXT", nil},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, convertValue(tt.value, tt.typeName), "%v (%s)", tt.value, tt.typeName)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	ts := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	parsed, err := parseTime(ts, "unix")
+	require.NoError(t, err)
+	require.Equal(t, ts, parsed)
+
+	parsed, err = parseTime(ts.Unix(), "unix")
+	require.NoError(t, err)
+	require.Equal(t, ts, parsed)
+
+	parsed, err = parseTime(ts.UnixNano()/1e6, "unix_ms")
+	require.NoError(t, err)
+	require.Equal(t, ts, parsed)
+
+	parsed, err = parseTime("2021-06-01 12:00:00", "2006-01-02 15:04:05")
+	require.NoError(t, err)
+	require.Equal(t, ts, parsed)
+
+	_, err = parseTime(true, "unix")
+	require.Error(t, err)
+}
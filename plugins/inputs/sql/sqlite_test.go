@@ -0,0 +1,146 @@
+// +build linux
+// +build 386 amd64 arm arm64
+
+package sql
+
+import (
+	gosql "database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func newTestDatabase(t *testing.T) string {
+	dbfile := filepath.Join(t.TempDir(), "test.db")
+	db, err := gosql.Open("sqlite", dbfile)
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE orders(region TEXT, product TEXT, total REAL, created INTEGER)",
+		"INSERT INTO orders VALUES('eu', 'a', 10.5, 1622548800)",
+		"INSERT INTO orders VALUES('eu', 'b', 4.5, 1622548860)",
+		"INSERT INTO orders VALUES('us', 'a', 7.0, 1622548920)",
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	return dbfile
+}
+
+func TestSqlite(t *testing.T) {
+	s := &SQL{
+		Driver:         "sqlite",
+		DataSourceName: newTestDatabase(t),
+		Timeout:        internal.Duration{Duration: 5 * time.Second},
+		Queries: []*Query{
+			{
+				Query:             "SELECT region, COUNT(*) AS orders, SUM(total) AS revenue, MAX(created) AS last FROM orders GROUP BY region ORDER BY region",
+				Measurement:       "orders",
+				TagColumnsInclude: []string{"region"},
+				TimeColumn:        "last",
+			},
+			{
+				Query:               "SELECT 'products' AS name, product, COUNT(*) AS count, 'x' AS ignored FROM orders GROUP BY product ORDER BY product",
+				MeasurementColumn:   "name",
+				TagColumnsInclude:   []string{"prod*"},
+				FieldColumnsExclude: []string{"ignored"},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, s.Init())
+	require.NoError(t, s.Start(nil))
+	defer s.Stop()
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(s.Gather))
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"orders",
+			map[string]string{"region": "eu"},
+			map[string]interface{}{"orders": int64(2), "revenue": 15.0},
+			time.Unix(1622548860, 0),
+		),
+		testutil.MustMetric(
+			"orders",
+			map[string]string{"region": "us"},
+			map[string]interface{}{"orders": int64(1), "revenue": 7.0},
+			time.Unix(1622548920, 0),
+		),
+	}
+	var orders, products []telegraf.Metric
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() == "orders" {
+			orders = append(orders, m)
+		} else {
+			products = append(products, m)
+		}
+	}
+	testutil.RequireMetricsEqual(t, expected, orders, testutil.SortMetrics())
+
+	expected = []telegraf.Metric{
+		testutil.MustMetric(
+			"products",
+			map[string]string{"product": "a"},
+			map[string]interface{}{"count": int64(2)},
+			time.Unix(0, 0),
+		),
+		testutil.MustMetric(
+			"products",
+			map[string]string{"product": "b"},
+			map[string]interface{}{"count": int64(1)},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, products, testutil.SortMetrics(), testutil.IgnoreTime())
+}
+
+func TestSqliteInterval(t *testing.T) {
+	s := &SQL{
+		Driver:         "sqlite",
+		DataSourceName: newTestDatabase(t),
+		Queries: []*Query{
+			{Query: "SELECT COUNT(*) AS orders FROM orders"},
+			{Query: "SELECT SUM(total) AS revenue FROM orders", Interval: internal.Duration{Duration: time.Hour}},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, s.Init())
+	require.NoError(t, s.Start(nil))
+	defer s.Stop()
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(s.Gather))
+	require.Len(t, acc.GetTelegrafMetrics(), 2)
+
+	// The second query waits for its interval.
+	acc.ClearMetrics()
+	require.NoError(t, acc.GatherError(s.Gather))
+	require.Len(t, acc.GetTelegrafMetrics(), 1)
+	require.True(t, acc.HasInt64Field("sql", "orders"))
+}
+
+func TestSqliteQueryError(t *testing.T) {
+	s := &SQL{
+		Driver:         "sqlite",
+		DataSourceName: newTestDatabase(t),
+		Queries:        []*Query{{Query: "SELECT * FROM missing"}},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, s.Init())
+	require.NoError(t, s.Start(nil))
+	defer s.Stop()
+
+	var acc testutil.Accumulator
+	require.NoError(t, s.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.Contains(t, acc.Errors[0].Error(), "SELECT * FROM missing")
+}
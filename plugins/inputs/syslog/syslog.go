@@ -11,7 +11,6 @@ import (
 	"strings"
 	"sync"
 	"time"
-	"unicode"
 
 	"github.com/influxdata/go-syslog/v2"
 	"github.com/influxdata/go-syslog/v2/nontransparent"
@@ -23,6 +22,7 @@ import (
 	framing "github.com/influxdata/telegraf/internal/syslog"
 	tlsConfig "github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
+	parser "github.com/influxdata/telegraf/plugins/parsers/syslog"
 )
 
 const defaultReadTimeout = time.Second * 5
@@ -240,7 +240,7 @@ func (s *Syslog) listenPacket(acc telegraf.Accumulator) {
 
 		message, err := p.Parse(b[:n])
 		if message != nil {
-			acc.AddFields("syslog", fields(message, s), tags(message), s.time())
+			acc.AddFields("syslog", parser.Fields(message, s.Separator), parser.Tags(message), s.time())
 		}
 		if err != nil {
 			acc.AddError(err)
@@ -354,71 +354,10 @@ func (s *Syslog) store(res syslog.Result, acc telegraf.Accumulator) {
 		acc.AddError(res.Error)
 	}
 	if res.Message != nil {
-		acc.AddFields("syslog", fields(res.Message, s), tags(res.Message), s.time())
+		acc.AddFields("syslog", parser.Fields(res.Message, s.Separator), parser.Tags(res.Message), s.time())
 	}
 }
 
-func tags(msg syslog.Message) map[string]string {
-	ts := map[string]string{}
-
-	// Not checking assuming a minimally valid message
-	ts["severity"] = *msg.SeverityShortLevel()
-	ts["facility"] = *msg.FacilityLevel()
-
-	if msg.Hostname() != nil {
-		ts["hostname"] = *msg.Hostname()
-	}
-
-	if msg.Appname() != nil {
-		ts["appname"] = *msg.Appname()
-	}
-
-	return ts
-}
-
-func fields(msg syslog.Message, s *Syslog) map[string]interface{} {
-	// Not checking assuming a minimally valid message
-	flds := map[string]interface{}{
-		"version": msg.Version(),
-	}
-	flds["severity_code"] = int(*msg.Severity())
-	flds["facility_code"] = int(*msg.Facility())
-
-	if msg.Timestamp() != nil {
-		flds["timestamp"] = (*msg.Timestamp()).UnixNano()
-	}
-
-	if msg.ProcID() != nil {
-		flds["procid"] = *msg.ProcID()
-	}
-
-	if msg.MsgID() != nil {
-		flds["msgid"] = *msg.MsgID()
-	}
-
-	if msg.Message() != nil {
-		flds["message"] = strings.TrimRightFunc(*msg.Message(), func(r rune) bool {
-			return unicode.IsSpace(r)
-		})
-	}
-
-	if msg.StructuredData() != nil {
-		for sdid, sdparams := range *msg.StructuredData() {
-			if len(sdparams) == 0 {
-				// When SD-ID does not have params we indicate its presence with a bool
-				flds[sdid] = true
-				continue
-			}
-			for name, value := range sdparams {
-				// Using whitespace as separator since it is not allowed by the grammar within SDID
-				flds[sdid+s.Separator+name] = value
-			}
-		}
-	}
-
-	return flds
-}
-
 type unixCloser struct {
 	path   string
 	closer io.Closer
@@ -0,0 +1,17 @@
+// +build !windows,!solaris
+
+package tail
+
+import (
+	"os"
+	"syscall"
+)
+
+// getFileID returns the device and inode of a file.
+func getFileID(info os.FileInfo) (fileID, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{Device: uint64(stat.Dev), Inode: uint64(stat.Ino)}, true
+}
@@ -0,0 +1,11 @@
+// +build windows
+
+package tail
+
+import "os"
+
+// getFileID returns false as the file information on Windows does not
+// identify the file, files are only recognized by their head checksum.
+func getFileID(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
@@ -0,0 +1,471 @@
+// +build !solaris
+
+package tail
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/dimchansky/utfbom"
+	"github.com/influxdata/tail"
+	"github.com/influxdata/telegraf"
+)
+
+// headSize is the maximum number of bytes at the start of a file of which
+// the checksum is stored, to recognize a file replaced by another one reusing
+// its inode.
+const headSize = 1024
+
+// fileID identifies a file independent of its path.
+type fileID struct {
+	Device uint64
+	Inode  uint64
+}
+
+// fileState is the position stored in the state file for a tailed file.
+type fileState struct {
+	Offset       int64  `json:"offset"`
+	Device       uint64 `json:"device,omitempty"`
+	Inode        uint64 `json:"inode,omitempty"`
+	HeadSize     int64  `json:"head_size"`
+	HeadChecksum string `json:"head_checksum"`
+}
+
+type stateFile struct {
+	Files map[string]fileState `json:"files"`
+}
+
+func (s fileState) id() (fileID, bool) {
+	return fileID{Device: s.Device, Inode: s.Inode}, s.Inode != 0
+}
+
+// matches returns true if the file at path is the file of the state and was
+// not truncated since.
+func (s fileState) matches(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.Size() < s.Offset {
+		return false
+	}
+	if id, ok := getFileID(info); ok {
+		if stored, ok := s.id(); ok && id != stored {
+			return false
+		}
+	}
+	checksum, err := headChecksum(f, s.HeadSize)
+	return err == nil && checksum == s.HeadChecksum
+}
+
+// newFileState returns the state of the file at path read up to offset.  It
+// fails if the file at path is not the file with the given id anymore.
+func newFileState(path string, id fileID, hasID bool, offset int64) (fileState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fileState{}, err
+	}
+	if current, ok := getFileID(info); hasID && (!ok || current != id) {
+		return fileState{}, errors.New("file was replaced")
+	}
+
+	size := offset
+	if size > headSize {
+		size = headSize
+	}
+	checksum, err := headChecksum(f, size)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{
+		Offset:       offset,
+		Device:       id.Device,
+		Inode:        id.Inode,
+		HeadSize:     size,
+		HeadChecksum: checksum,
+	}, nil
+}
+
+// headChecksum returns the checksum of the first size bytes of a file.
+func headChecksum(f *os.File, size int64) (string, error) {
+	head := make([]byte, size)
+	if _, err := io.ReadFull(io.NewSectionReader(f, 0, size), head); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(head)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// loadState reads the state file, a missing file is an empty state.
+func loadState(path string) (map[string]fileState, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return make(map[string]fileState), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state stateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]fileState)
+	}
+	return state.Files, nil
+}
+
+// writeState replaces the state file atomically to not lose the positions
+// if Telegraf is interrupted while writing.
+func writeState(path string, files map[string]fileState) error {
+	data, err := json.Marshal(stateFile{Files: files})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// bomSize returns the number of bytes of a byte order mark.
+func bomSize(enc utfbom.Encoding) int64 {
+	switch enc {
+	case utfbom.UTF8:
+		return 3
+	case utfbom.UTF16BigEndian, utfbom.UTF16LittleEndian:
+		return 2
+	case utfbom.UTF32BigEndian, utfbom.UTF32LittleEndian:
+		return 4
+	}
+	return 0
+}
+
+// trackedFile is a tailed file of which the offset is stored in the state
+// file.
+type trackedFile struct {
+	path string
+	// start is the offset the file is first read from.
+	start int64
+	// opened receives the size of the byte order mark skipped each time the
+	// file is opened, the file is reopened when it is rotated or truncated.
+	opened chan int64
+
+	// current is the opening of the file lines are read from, protected by
+	// Tail.stateMu.
+	current *generation
+	// finished is set once the reading of a file not followed ended,
+	// protected by Tail.stateMu.
+	finished bool
+}
+
+// generation is the state of one opening of a tracked file.
+type generation struct {
+	id    fileID
+	hasID bool
+	// committed is the offset up to which the metrics of all lines are
+	// delivered.
+	committed int64
+	// pending are the lines read after committed in the order of the file.
+	pending []pendingLine
+	// failed is set once metrics of the file are rejected, the offset is
+	// not advanced anymore to read the lines again after a restart.
+	failed bool
+}
+
+// pendingLine is a line, or multiline event, of which the metrics are not
+// delivered yet.
+type pendingLine struct {
+	id     telegraf.TrackingID
+	offset int64
+	done   bool
+}
+
+// add appends a line ending at offset, lines without metrics are done.
+func (g *generation) add(id telegraf.TrackingID, offset int64, done bool) {
+	if g.failed {
+		return
+	}
+	if done && len(g.pending) == 0 {
+		g.committed = offset
+		return
+	}
+	g.pending = append(g.pending, pendingLine{id: id, offset: offset, done: done})
+}
+
+// deliver marks the line of id done, returns false if the metrics are
+// rejected.
+func (g *generation) deliver(id telegraf.TrackingID, delivered bool) bool {
+	if g.failed {
+		return true
+	}
+	if !delivered {
+		g.failed = true
+		g.pending = nil
+		return false
+	}
+
+	for i := range g.pending {
+		if g.pending[i].id == id {
+			g.pending[i].done = true
+			break
+		}
+	}
+	for len(g.pending) > 0 && g.pending[0].done {
+		g.committed = g.pending[0].offset
+		g.pending = g.pending[1:]
+	}
+	return true
+}
+
+// resumeOffset returns the offset to start reading the file at path from
+// according to the state file.
+func (t *Tail) resumeOffset(path string, fromBeginning bool) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+
+	if s, ok := t.state[path]; ok && s.matches(path) {
+		delete(t.state, path)
+		t.Log.Debugf("Resuming %q at offset %d", path, s.Offset)
+		return s.Offset
+	}
+
+	// The file may have been renamed while Telegraf was stopped.
+	if id, ok := getFileID(info); ok {
+		for previous, s := range t.state {
+			if stored, ok := s.id(); ok && stored == id && s.matches(path) {
+				delete(t.state, previous)
+				t.Log.Debugf("Resuming %q, previously %q, at offset %d", path, previous, s.Offset)
+				return s.Offset
+			}
+		}
+	}
+
+	// A different file is at the path, the file was rotated or truncated
+	// while Telegraf was stopped and all of its content is new.
+	if _, ok := t.state[path]; ok {
+		t.Log.Debugf("%q was rotated or truncated, reading from the beginning", path)
+		return 0
+	}
+
+	if fromBeginning {
+		return 0
+	}
+	return info.Size()
+}
+
+// readRotated reads the rest of the files of the state file rotated while
+// Telegraf was stopped and no longer matching the files to tail.
+func (t *Tail) readRotated() {
+	for path, s := range t.state {
+		id, ok := s.id()
+		if !ok {
+			continue
+		}
+
+		rotated := findFile(filepath.Dir(path), id)
+		if rotated == "" || !s.matches(rotated) {
+			continue
+		}
+		if _, ok := t.tailers[rotated]; ok {
+			continue
+		}
+
+		// The offset is stored under the new path until the metrics of all
+		// lines are delivered.
+		t.Log.Debugf("Reading the rest of %q rotated to %q", path, rotated)
+		seek := &tail.SeekInfo{Whence: 0, Offset: s.Offset}
+		tf := &trackedFile{
+			path:   rotated,
+			start:  s.Offset,
+			opened: make(chan int64),
+		}
+		if err := t.tailFile(rotated, seek, false, tf); err != nil {
+			t.Log.Errorf("Reading %q: %v", rotated, err)
+		}
+	}
+}
+
+// findFile returns the path of the file with the given id in dir.
+func findFile(dir string, id fileID) string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if current, ok := getFileID(info); ok && current == id {
+			return filepath.Join(dir, info.Name())
+		}
+	}
+	return ""
+}
+
+// opened starts a new generation of a tracked file and returns the offset
+// of the file lines are read from.
+func (t *Tail) opened(tf *trackedFile, skipped int64) int64 {
+	g := &generation{committed: skipped}
+	t.stateMu.Lock()
+	if tf.current == nil {
+		g.committed += tf.start
+	}
+	tf.current = g
+	t.stateMu.Unlock()
+
+	// The path may be replaced again before the file is stated, the head
+	// checksum then does not match and the file is read from the beginning
+	// after a restart.
+	if info, err := os.Stat(tf.path); err == nil {
+		id, hasID := getFileID(info)
+		t.stateMu.Lock()
+		g.id, g.hasID = id, hasID
+		t.stateMu.Unlock()
+	}
+	return g.committed
+}
+
+// addMetrics adds the metrics of a line ending at offset.  The tracking id
+// is registered before the delivery can be handled, as empty groups are
+// delivered immediately.
+func (t *Tail) addMetrics(tf *trackedFile, metrics []telegraf.Metric, offset int64) {
+	if tf == nil {
+		t.acc.AddTrackingMetricGroup(metrics)
+		return
+	}
+
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+	id := t.acc.AddTrackingMetricGroup(metrics)
+	g := tf.current
+	if g.failed {
+		return
+	}
+	g.add(id, offset, false)
+	t.pending[id] = g
+}
+
+// skipLine records a line ending at offset without metrics.
+func (t *Tail) skipLine(tf *trackedFile, offset int64) {
+	if tf == nil {
+		return
+	}
+
+	t.stateMu.Lock()
+	tf.current.add(0, offset, true)
+	t.stateMu.Unlock()
+}
+
+// delivered advances the offset of the file the delivered metrics are read
+// from.
+func (t *Tail) delivered(info telegraf.DeliveryInfo) {
+	if t.StateFile == "" {
+		return
+	}
+
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+	g, ok := t.pending[info.ID()]
+	if !ok {
+		return
+	}
+	delete(t.pending, info.ID())
+	if !g.deliver(info.ID(), info.Delivered()) {
+		t.Log.Warnf("Metrics were not delivered, lines are read again after a restart")
+	}
+}
+
+// saveState writes the offsets up to which the metrics of the tracked
+// files are delivered to the state file.
+func (t *Tail) saveState() error {
+	if t.StateFile == "" {
+		return nil
+	}
+
+	type position struct {
+		id       fileID
+		hasID    bool
+		offset   int64
+		finished bool
+	}
+	positions := make(map[string]position, len(t.tracked))
+	t.stateMu.Lock()
+	for path, tf := range t.tracked {
+		if g := tf.current; g != nil {
+			finished := tf.finished && !g.failed && len(g.pending) == 0
+			positions[path] = position{id: g.id, hasID: g.hasID, offset: g.committed, finished: finished}
+		}
+	}
+	t.stateMu.Unlock()
+
+	files := make(map[string]fileState, len(positions))
+	var changed bool
+	for path, p := range positions {
+		// The position of a rotated file is not needed anymore once the
+		// metrics of all of its lines are delivered.
+		if info, err := os.Stat(path); p.finished && err == nil && info.Size() <= p.offset {
+			t.stateMu.Lock()
+			delete(t.tracked, path)
+			t.stateMu.Unlock()
+			continue
+		}
+
+		s, err := newFileState(path, p.id, p.hasID, p.offset)
+		if err != nil {
+			// The file is rotated and not reopened yet, keep its last
+			// position.
+			saved, ok := t.saved[path]
+			if !ok {
+				continue
+			}
+			s = saved
+		}
+		if s != t.saved[path] {
+			changed = true
+		}
+		files[path] = s
+	}
+	if !changed && len(files) == len(t.saved) {
+		return nil
+	}
+
+	if err := writeState(t.StateFile, files); err != nil {
+		return err
+	}
+	t.saved = files
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 	"sync"
@@ -42,6 +43,7 @@ type Tail struct {
 	MaxUndeliveredLines int      `toml:"max_undelivered_lines"`
 	CharacterEncoding   string   `toml:"character_encoding"`
 	PathTag             string   `toml:"path_tag"`
+	StateFile           string   `toml:"state_file"`
 
 	Log        telegraf.Logger `toml:"-"`
 	tailers    map[string]*tail.Tail
@@ -58,6 +60,19 @@ type Tail struct {
 	cancel  context.CancelFunc
 	sem     semaphore
 	decoder *encoding.Decoder
+
+	// stopping is done once the plugin is stopped, before the tailers.
+	stopping    context.Context
+	stopTailers context.CancelFunc
+
+	stateMu sync.Mutex
+	// state are the positions loaded from the state file, the positions
+	// are removed once used at startup.
+	state map[string]fileState
+	// saved are the positions last written to the state file.
+	saved   map[string]fileState
+	tracked map[string]*trackedFile
+	pending map[telegraf.TrackingID]*generation
 }
 
 func NewTail() *Tail {
@@ -121,6 +136,13 @@ const sampleConfig = `
   ## Set the tag that will contain the path of the tailed file. If you don't want this tag, set it to an empty string.
   # path_tag = "path"
 
+  ## File the offsets of the tailed files are stored in, to continue reading
+  ## where Telegraf stopped after a restart.  Only the offsets of lines whose
+  ## metrics are written by the outputs are stored, files rotated or
+  ## truncated while Telegraf was stopped are read from the beginning.
+  ## Requires an empty character_encoding and is not supported with pipe.
+  # state_file = "/var/lib/telegraf/tail.state"
+
   ## multiline parser/codec
   ## https://www.elastic.co/guide/en/logstash/2.4/plugins-filters-multiline.html
   #[inputs.tail.multiline]
@@ -157,12 +179,26 @@ func (t *Tail) Init() error {
 	}
 	t.sem = make(semaphore, t.MaxUndeliveredLines)
 
+	if t.StateFile != "" {
+		// The offsets are counted from the length of the lines read, which
+		// differs from the bytes in the file if the content is decoded.
+		if t.CharacterEncoding != "" && t.CharacterEncoding != "none" {
+			return errors.New("state_file requires an empty character_encoding")
+		}
+		if t.Pipe {
+			return errors.New("state_file is not supported with pipe")
+		}
+	}
+
 	var err error
 	t.decoder, err = encoding.NewDecoder(t.CharacterEncoding)
 	return err
 }
 
 func (t *Tail) Gather(acc telegraf.Accumulator) error {
+	if err := t.saveState(); err != nil {
+		acc.AddError(fmt.Errorf("writing state file failed: %v", err))
+	}
 	return t.tailNewFiles(true)
 }
 
@@ -170,6 +206,7 @@ func (t *Tail) Start(acc telegraf.Accumulator) error {
 	t.acc = acc.WithTracking(t.MaxUndeliveredLines)
 
 	t.ctx, t.cancel = context.WithCancel(context.Background())
+	t.stopping, t.stopTailers = context.WithCancel(context.Background())
 
 	t.wg.Add(1)
 	go func() {
@@ -178,8 +215,9 @@ func (t *Tail) Start(acc telegraf.Accumulator) error {
 			select {
 			case <-t.ctx.Done():
 				return
-			case <-t.acc.Delivered():
+			case info := <-t.acc.Delivered():
 				<-t.sem
+				t.delivered(info)
 			}
 		}
 	}()
@@ -193,8 +231,19 @@ func (t *Tail) Start(acc telegraf.Accumulator) error {
 
 	t.tailers = make(map[string]*tail.Tail)
 
+	if t.StateFile != "" {
+		t.tracked = make(map[string]*trackedFile)
+		t.pending = make(map[telegraf.TrackingID]*generation)
+		if t.state, err = loadState(t.StateFile); err != nil {
+			t.Log.Errorf("Reading state file failed: %v", err)
+		}
+	}
+
 	err = t.tailNewFiles(t.FromBeginning)
 
+	t.readRotated()
+	t.state = nil
+
 	// clear offsets
 	t.offsets = make(map[string]int64)
 	// assumption that once Start is called, all parallel plugins have already been initialized
@@ -206,11 +255,6 @@ func (t *Tail) Start(acc telegraf.Accumulator) error {
 }
 
 func (t *Tail) tailNewFiles(fromBeginning bool) error {
-	var poll bool
-	if t.WatchMethod == "poll" {
-		poll = true
-	}
-
 	// Create a "tailer" for each file
 	for _, filepath := range t.Files {
 		g, err := globpath.Compile(filepath)
@@ -224,7 +268,19 @@ func (t *Tail) tailNewFiles(fromBeginning bool) error {
 			}
 
 			var seek *tail.SeekInfo
-			if !t.Pipe && !fromBeginning {
+			var tf *trackedFile
+			if t.StateFile != "" {
+				offset := t.resumeOffset(file, fromBeginning)
+				seek = &tail.SeekInfo{
+					Whence: 0,
+					Offset: offset,
+				}
+				tf = &trackedFile{
+					path:   file,
+					start:  offset,
+					opened: make(chan int64),
+				}
+			} else if !t.Pipe && !fromBeginning {
 				if offset, ok := t.offsets[file]; ok {
 					t.Log.Debugf("Using offset %d for %q", offset, file)
 					seek = &tail.SeekInfo{
@@ -239,50 +295,78 @@ func (t *Tail) tailNewFiles(fromBeginning bool) error {
 				}
 			}
 
-			tailer, err := tail.TailFile(file,
-				tail.Config{
-					ReOpen:    true,
-					Follow:    true,
-					Location:  seek,
-					MustExist: true,
-					Poll:      poll,
-					Pipe:      t.Pipe,
-					Logger:    tail.DiscardingLogger,
-					OpenReaderFunc: func(rd io.Reader) io.Reader {
-						r, _ := utfbom.Skip(t.decoder.Reader(rd))
-						return r
-					},
-				})
-
-			if err != nil {
+			if err := t.tailFile(file, seek, true, tf); err != nil {
 				t.Log.Debugf("Failed to open file (%s): %v", file, err)
-				continue
 			}
+		}
+	}
+	return nil
+}
+
+// tailFile starts reading a file at seek, following it for new lines if
+// follow is set.  The offset of the lines read is tracked if tf is set.
+func (t *Tail) tailFile(file string, seek *tail.SeekInfo, follow bool, tf *trackedFile) error {
+	var poll bool
+	if t.WatchMethod == "poll" {
+		poll = true
+	}
 
-			t.Log.Debugf("Tail added for %q", file)
+	tailer, err := tail.TailFile(file,
+		tail.Config{
+			ReOpen:    follow,
+			Follow:    follow,
+			Location:  seek,
+			MustExist: true,
+			Poll:      poll,
+			Pipe:      t.Pipe,
+			Logger:    tail.DiscardingLogger,
+			OpenReaderFunc: func(rd io.Reader) io.Reader {
+				r, enc := utfbom.Skip(t.decoder.Reader(rd))
+				if tf != nil {
+					select {
+					case tf.opened <- bomSize(enc):
+					case <-t.stopping.Done():
+					}
+				}
+				return r
+			},
+		})
+	if err != nil {
+		return err
+	}
 
-			parser, err := t.parserFunc()
-			if err != nil {
-				t.Log.Errorf("Creating parser: %s", err.Error())
-				continue
-			}
+	t.Log.Debugf("Tail added for %q", file)
 
-			// create a goroutine for each "tailer"
-			t.wg.Add(1)
+	parser, err := t.parserFunc()
+	if err != nil {
+		t.Log.Errorf("Creating parser: %s", err.Error())
+		return nil
+	}
 
-			go func() {
-				defer t.wg.Done()
-				t.receiver(parser, tailer)
+	// create a goroutine for each "tailer"
+	t.wg.Add(1)
 
-				t.Log.Debugf("Tail removed for %q", tailer.Filename)
+	go func() {
+		defer t.wg.Done()
+		t.receiver(parser, tailer, tf)
 
-				if err := tailer.Err(); err != nil {
-					t.Log.Errorf("Tailing %q: %s", tailer.Filename, err.Error())
-				}
-			}()
+		t.Log.Debugf("Tail removed for %q", tailer.Filename)
 
-			t.tailers[tailer.Filename] = tailer
+		if err := tailer.Err(); err != nil {
+			t.Log.Errorf("Tailing %q: %s", tailer.Filename, err.Error())
 		}
+		if tf != nil && !follow {
+			t.stateMu.Lock()
+			tf.finished = true
+			t.stateMu.Unlock()
+		}
+	}()
+
+	t.tailers[tailer.Filename] = tailer
+	if tf != nil {
+		t.stateMu.Lock()
+		t.tracked[tf.path] = tf
+		t.stateMu.Unlock()
 	}
 	return nil
 }
@@ -314,9 +398,17 @@ func parseLine(parser parsers.Parser, line string, firstLine bool) ([]telegraf.M
 
 // Receiver is launched as a goroutine to continuously watch a tailed logfile
 // for changes, parse any incoming msgs, and add to the accumulator.
-func (t *Tail) receiver(parser parsers.Parser, tailer *tail.Tail) {
+func (t *Tail) receiver(parser parsers.Parser, tailer *tail.Tail, tf *trackedFile) {
 	var firstLine = true
 
+	// The offset after the last line read and of the first line in the
+	// multiline buffer, only counted for tracked files.
+	var opened <-chan int64
+	var offset, start int64
+	if tf != nil {
+		opened = tf.opened
+	}
+
 	// holds the individual lines of multi-line log entries.
 	var buffer bytes.Buffer
 
@@ -344,6 +436,12 @@ func (t *Tail) receiver(parser parsers.Parser, tailer *tail.Tail) {
 		select {
 		case <-t.ctx.Done():
 			channelOpen = false
+		case skipped := <-opened:
+			// Lines of the previous file still in the multiline buffer do
+			// not advance the offset of the reopened file.
+			offset = t.opened(tf, skipped)
+			start = offset
+			continue
 		case line, tailerOpen = <-tailer.Lines:
 			if !tailerOpen {
 				channelOpen = false
@@ -354,11 +452,22 @@ func (t *Tail) receiver(parser parsers.Parser, tailer *tail.Tail) {
 		var text string
 
 		if line != nil {
+			lineStart := offset
+			if line.Err == nil {
+				offset += int64(len(line.Text)) + 1
+			}
+
 			// Fix up files with Windows line endings.
 			text = strings.TrimRight(line.Text, "\r")
 
 			if t.multiline.IsEnabled() {
-				if text = t.multiline.ProcessLine(text, &buffer); text == "" {
+				wasEmpty := buffer.Len() == 0
+				text = t.multiline.ProcessLine(text, &buffer)
+				if buffer.Len() > 0 && (wasEmpty || text != "") {
+					// The buffer only holds the current line.
+					start = lineStart
+				}
+				if text == "" {
 					continue
 				}
 			}
@@ -378,10 +487,18 @@ func (t *Tail) receiver(parser parsers.Parser, tailer *tail.Tail) {
 			continue
 		}
 
+		// The offset up to which the file is read once the metrics of the
+		// text are delivered, excluding lines still in the buffer.
+		end := offset
+		if buffer.Len() > 0 {
+			end = start
+		}
+
 		metrics, err := parseLine(parser, text, firstLine)
 		if err != nil {
 			t.Log.Errorf("Malformed log line in %q: [%q]: %s",
 				tailer.Filename, text, err.Error())
+			t.skipLine(tf, end)
 			continue
 		}
 		firstLine = false
@@ -395,7 +512,7 @@ func (t *Tail) receiver(parser parsers.Parser, tailer *tail.Tail) {
 		// try writing out metric first without blocking
 		select {
 		case t.sem <- empty{}:
-			t.acc.AddTrackingMetricGroup(metrics)
+			t.addMetrics(tf, metrics, end)
 			if t.ctx.Err() != nil {
 				return // exit!
 			}
@@ -409,14 +526,17 @@ func (t *Tail) receiver(parser parsers.Parser, tailer *tail.Tail) {
 		case <-t.ctx.Done():
 			return
 		case t.sem <- empty{}:
-			t.acc.AddTrackingMetricGroup(metrics)
+			t.addMetrics(tf, metrics, end)
 		}
 	}
 }
 
 func (t *Tail) Stop() {
+	t.stopTailers()
 	for _, tailer := range t.tailers {
-		if !t.Pipe && !t.FromBeginning {
+		// Tailers of rotated files are not followed and close the file
+		// once read.
+		if !t.Pipe && !t.FromBeginning && tailer.Follow {
 			// store offset for resume
 			offset, err := tailer.Tell()
 			if err == nil {
@@ -434,6 +554,10 @@ func (t *Tail) Stop() {
 	t.cancel()
 	t.wg.Wait()
 
+	if err := t.saveState(); err != nil {
+		t.Log.Errorf("Writing state file failed: %v", err)
+	}
+
 	// persist offsets
 	offsetsMutex.Lock()
 	for k, v := range t.offsets {
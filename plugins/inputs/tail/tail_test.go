@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/parsers"
 	"github.com/influxdata/telegraf/plugins/parsers/csv"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
@@ -596,3 +598,276 @@ func getTestdataDir() string {
 
 	return filepath.Join(dir, "testdata")
 }
+
+// trackingAccumulator keeps the tracking metrics added until they are
+// delivered by accept.
+type trackingAccumulator struct {
+	*testutil.Accumulator
+	delivered chan telegraf.DeliveryInfo
+
+	sync.Mutex
+	tracked []telegraf.Metric
+}
+
+func newTrackingAccumulator() *trackingAccumulator {
+	return &trackingAccumulator{
+		Accumulator: &testutil.Accumulator{},
+		delivered:   make(chan telegraf.DeliveryInfo, 1000),
+	}
+}
+
+func (a *trackingAccumulator) WithTracking(maxTracked int) telegraf.TrackingAccumulator {
+	return a
+}
+
+func (a *trackingAccumulator) AddTrackingMetricGroup(group []telegraf.Metric) telegraf.TrackingID {
+	tracked, id := metric.WithGroupTracking(group, func(info telegraf.DeliveryInfo) {
+		a.delivered <- info
+	})
+	a.Lock()
+	a.tracked = append(a.tracked, tracked...)
+	a.Unlock()
+	a.Accumulator.AddMetrics(tracked)
+	return id
+}
+
+func (a *trackingAccumulator) Delivered() <-chan telegraf.DeliveryInfo {
+	return a.delivered
+}
+
+// accept delivers the first n metrics not delivered yet.
+func (a *trackingAccumulator) accept(n int) {
+	a.Lock()
+	defer a.Unlock()
+	for _, m := range a.tracked[:n] {
+		m.Accept()
+	}
+	a.tracked = a.tracked[n:]
+}
+
+// values returns the values of the metrics added in order.
+func (a *trackingAccumulator) values() []float64 {
+	var values []float64
+	for _, m := range a.GetTelegrafMetrics() {
+		v, _ := m.GetField("value")
+		values = append(values, v.(float64))
+	}
+	return values
+}
+
+func newStateTail(t *testing.T, file, stateFile string) *Tail {
+	tt := NewTestTail()
+	tt.Log = testutil.Logger{}
+	tt.Files = []string{file}
+	tt.StateFile = stateFile
+	tt.SetParserFunc(parsers.NewInfluxParser)
+	require.NoError(t, tt.Init())
+	return tt
+}
+
+// runStateTail runs the plugin until n metrics are added, delivering them
+// if deliver is set.
+func runStateTail(t *testing.T, file, stateFile string, n int, deliver bool) *trackingAccumulator {
+	tt := newStateTail(t, file, stateFile)
+	acc := newTrackingAccumulator()
+	require.NoError(t, tt.Start(acc))
+	defer tt.Stop()
+
+	acc.Wait(n)
+	if deliver {
+		acc.accept(n)
+		waitDelivered(t, tt)
+	}
+	return acc
+}
+
+func waitDelivered(t *testing.T, tt *Tail) {
+	require.Eventually(t, func() bool {
+		tt.stateMu.Lock()
+		defer tt.stateMu.Unlock()
+		return len(tt.pending) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func readStateOffset(t *testing.T, stateFile, file string) int64 {
+	state, err := loadState(stateFile)
+	require.NoError(t, err)
+	require.Contains(t, state, file)
+	return state[file].Offset
+}
+
+func appendFile(t *testing.T, file, content string) {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestTailStateFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.log")
+	stateFile := filepath.Join(dir, "tail.state")
+	appendFile(t, file, "cpu value=1\ncpu value=2\n")
+
+	// Without a stored offset the file is read from the end.
+	tt := newStateTail(t, file, stateFile)
+	acc := newTrackingAccumulator()
+	require.NoError(t, tt.Start(acc))
+	appendFile(t, file, "cpu value=3\n")
+	acc.Wait(1)
+	acc.accept(1)
+	waitDelivered(t, tt)
+	tt.Stop()
+	require.Equal(t, []float64{3}, acc.values())
+	require.Equal(t, int64(36), readStateOffset(t, stateFile, file))
+
+	// Lines written while stopped are read after a restart, lines whose
+	// metrics are not delivered are read again.
+	appendFile(t, file, "cpu value=4\n")
+	acc = runStateTail(t, file, stateFile, 1, false)
+	require.Equal(t, []float64{4}, acc.values())
+	require.Equal(t, int64(36), readStateOffset(t, stateFile, file))
+
+	appendFile(t, file, "cpu value=5\n")
+	acc = runStateTail(t, file, stateFile, 2, true)
+	require.Equal(t, []float64{4, 5}, acc.values())
+	require.Equal(t, int64(60), readStateOffset(t, stateFile, file))
+}
+
+func TestTailStateFileTruncated(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.log")
+	stateFile := filepath.Join(dir, "tail.state")
+	appendFile(t, file, "cpu value=1\ncpu value=2\n")
+
+	tt := newStateTail(t, file, stateFile)
+	tt.FromBeginning = true
+	acc := newTrackingAccumulator()
+	require.NoError(t, tt.Start(acc))
+	acc.Wait(2)
+	acc.accept(2)
+	waitDelivered(t, tt)
+	tt.Stop()
+	require.Equal(t, int64(24), readStateOffset(t, stateFile, file))
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("cpu value=3\n"), 0644))
+	acc = runStateTail(t, file, stateFile, 1, true)
+	require.Equal(t, []float64{3}, acc.values())
+	require.Equal(t, int64(12), readStateOffset(t, stateFile, file))
+}
+
+func TestTailStateFileRotated(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("rotated files are recognized by their inode")
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.log")
+	stateFile := filepath.Join(dir, "tail.state")
+	appendFile(t, file, "cpu value=1\n")
+
+	tt := newStateTail(t, file, stateFile)
+	tt.FromBeginning = true
+	acc := newTrackingAccumulator()
+	require.NoError(t, tt.Start(acc))
+	acc.Wait(1)
+	acc.accept(1)
+	waitDelivered(t, tt)
+	tt.Stop()
+
+	// The rest of the rotated file and the new file are read, the offset
+	// of the rotated file is kept until its metrics are delivered.
+	appendFile(t, file, "cpu value=2\n")
+	require.NoError(t, os.Rename(file, file+".1"))
+	appendFile(t, file, "cpu value=3\n")
+
+	acc = runStateTail(t, file, stateFile, 2, false)
+	require.ElementsMatch(t, []float64{2, 3}, acc.values())
+	require.Equal(t, int64(12), readStateOffset(t, stateFile, file+".1"))
+	require.Equal(t, int64(0), readStateOffset(t, stateFile, file))
+
+	acc = runStateTail(t, file, stateFile, 2, true)
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"path": file + ".1"},
+			map[string]interface{}{"value": 2.0},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{"path": file},
+			map[string]interface{}{"value": 3.0},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.SortMetrics(), testutil.IgnoreTime())
+	require.Equal(t, int64(12), readStateOffset(t, stateFile, file))
+
+	state, err := loadState(stateFile)
+	require.NoError(t, err)
+	require.NotContains(t, state, file+".1")
+}
+
+func TestTailStateFileMultiline(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.log")
+	stateFile := filepath.Join(dir, "tail.state")
+	appendFile(t, file, "cpu value=1\ncpu value=2\n")
+
+	tt := newStateTail(t, file, stateFile)
+	tt.FromBeginning = true
+	tt.MultilineConfig = MultilineConfig{
+		Pattern:        `^\s`,
+		MatchWhichLine: Previous,
+		Timeout:        &internal.Duration{Duration: 100 * time.Millisecond},
+	}
+	acc := newTrackingAccumulator()
+	require.NoError(t, tt.Start(acc))
+	defer tt.Stop()
+
+	// The first line is added once the second line is read, which stays in
+	// the buffer until the timeout.
+	acc.Wait(1)
+	acc.accept(1)
+	require.Eventually(t, func() bool {
+		require.NoError(t, tt.saveState())
+		return readStateOffset(t, stateFile, file) == 12
+	}, 5*time.Second, 10*time.Millisecond)
+
+	acc.Wait(2)
+	acc.accept(1)
+	require.Eventually(t, func() bool {
+		require.NoError(t, tt.saveState())
+		return readStateOffset(t, stateFile, file) == 24
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTailStateFileInit(t *testing.T) {
+	tt := NewTestTail()
+	tt.StateFile = "tail.state"
+	tt.CharacterEncoding = "utf-8"
+	require.Error(t, tt.Init())
+
+	tt = NewTestTail()
+	tt.StateFile = "tail.state"
+	tt.Pipe = true
+	require.Error(t, tt.Init())
+}
+
+func TestGenerationDeliver(t *testing.T) {
+	g := &generation{committed: 10}
+	g.add(1, 20, false)
+	g.add(0, 30, true)
+	g.add(2, 40, false)
+
+	// Lines are committed in the order of the file.
+	require.True(t, g.deliver(2, true))
+	require.Equal(t, int64(10), g.committed)
+	require.True(t, g.deliver(1, true))
+	require.Equal(t, int64(40), g.committed)
+
+	// Nothing is committed anymore once metrics are rejected.
+	g.add(3, 50, false)
+	require.False(t, g.deliver(3, false))
+	g.add(4, 60, false)
+	require.True(t, g.deliver(4, true))
+	require.Equal(t, int64(40), g.committed)
+}
@@ -54,6 +54,8 @@ type AMQP struct {
 	Timeout            internal.Duration `toml:"timeout"`
 	UseBatchFormat     bool              `toml:"use_batch_format"`
 	ContentEncoding    string            `toml:"content_encoding"`
+	PublisherConfirms  bool              `toml:"publisher_confirms"`
+	Mandatory          bool              `toml:"mandatory"`
 	Log                telegraf.Logger   `toml:"-"`
 	tls.ClientConfig
 
@@ -67,6 +69,9 @@ type AMQP struct {
 
 type Client interface {
 	Publish(key string, body []byte) error
+	// WaitConfirms waits for the broker to confirm the messages published,
+	// it returns immediately if publisher confirms are disabled.
+	WaitConfirms() error
 	Close() error
 }
 
@@ -139,6 +144,16 @@ var sampleConfig = `
   ## timeout (not recommended).
   # timeout = "5s"
 
+  ## If true, wait until the broker confirms every published message.  A write
+  ## only succeeds once all messages of the batch are confirmed, messages
+  ## rejected by the broker or not confirmed within the timeout cause the
+  ## batch to be retried.  Messages may be delivered more than once.
+  # publisher_confirms = false
+
+  ## If true, publish messages as mandatory.  Messages the broker cannot route
+  ## to any queue are returned and logged as errors, they are not retried.
+  # mandatory = false
+
   ## Optional TLS Config
   # tls_ca = "/etc/telegraf/ca.pem"
   # tls_cert = "/etc/telegraf/cert.pem"
@@ -259,13 +274,27 @@ func (q *AMQP) Write(metrics []telegraf.Metric) error {
 					return err
 				}
 			} else {
-				q.client = nil
+				// The state of the channel is unknown, so use a new
+				// connection.
+				if q.client != nil {
+					q.client.Close()
+					q.client = nil
+				}
 				return err
 			}
 		}
 		first = false
 	}
 
+	// All messages are published before waiting for their confirmations.
+	if q.client != nil {
+		if err := q.client.WaitConfirms(); err != nil {
+			q.client.Close()
+			q.client = nil
+			return err
+		}
+	}
+
 	if q.sentMessages >= q.MaxMessages && q.MaxMessages > 0 {
 		q.Log.Debug("Sent MaxMessages; closing connection")
 		q.client.Close()
@@ -321,6 +350,8 @@ func (q *AMQP) makeClientConfig() (*ClientConfig, error) {
 		exchangePassive: q.ExchangePassive,
 		encoding:        q.ContentEncoding,
 		timeout:         q.Timeout.Duration,
+		confirm:         q.PublisherConfirms,
+		mandatory:       q.Mandatory,
 	}
 
 	switch q.ExchangeDurability {
@@ -1,20 +1,25 @@
 package amqp
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/testutil"
 	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/require"
 )
 
 type MockClient struct {
-	PublishF func(key string, body []byte) error
-	CloseF   func() error
+	PublishF      func(key string, body []byte) error
+	WaitConfirmsF func() error
+	CloseF        func() error
 
-	PublishCallCount int
-	CloseCallCount   int
+	PublishCallCount      int
+	WaitConfirmsCallCount int
+	CloseCallCount        int
 
 	t *testing.T
 }
@@ -24,6 +29,14 @@ func (c *MockClient) Publish(key string, body []byte) error {
 	return c.PublishF(key, body)
 }
 
+func (c *MockClient) WaitConfirms() error {
+	c.WaitConfirmsCallCount++
+	if c.WaitConfirmsF == nil {
+		return nil
+	}
+	return c.WaitConfirmsF()
+}
+
 func (c *MockClient) Close() error {
 	c.CloseCallCount++
 	return c.CloseF()
@@ -138,6 +151,22 @@ func TestConnect(t *testing.T) {
 				require.NoError(t, err)
 			},
 		},
+		{
+			name: "publisher confirms",
+			output: &AMQP{
+				PublisherConfirms: true,
+				Mandatory:         true,
+				connect: func(config *ClientConfig) (Client, error) {
+					return NewMockClient(), nil
+				},
+			},
+			errFunc: func(t *testing.T, output *AMQP, err error) {
+				config := output.config
+				require.True(t, config.confirm)
+				require.True(t, config.mandatory)
+				require.NoError(t, err)
+			},
+		},
 		{
 			name: "url support",
 			output: &AMQP{
@@ -160,3 +189,77 @@ func TestConnect(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteUnconfirmed(t *testing.T) {
+	var clients []*MockClient
+	rejected := true
+	output := &AMQP{
+		PublisherConfirms: true,
+		Log:               testutil.Logger{},
+		connect: func(config *ClientConfig) (Client, error) {
+			client := &MockClient{
+				PublishF: func(key string, body []byte) error {
+					return nil
+				},
+				WaitConfirmsF: func() error {
+					if rejected {
+						return errors.New("1 messages were rejected by the broker")
+					}
+					return nil
+				},
+				CloseF: func() error {
+					return nil
+				},
+			}
+			clients = append(clients, client)
+			return client, nil
+		},
+	}
+	output.SetSerializer(influx.NewSerializer())
+	require.NoError(t, output.Connect())
+
+	// A rejected message fails the write and the connection is replaced.
+	err := output.Write(testutil.MockMetrics())
+	require.Error(t, err)
+	require.Len(t, clients, 1)
+	require.Equal(t, 1, clients[0].CloseCallCount)
+
+	// The confirmations are awaited once after publishing all messages.
+	rejected = false
+	other := testutil.MustMetric("test2", map[string]string{"tag1": "value2"}, map[string]interface{}{"value": 2}, time.Unix(0, 0))
+	metrics := append(testutil.MockMetrics(), other)
+	output.RoutingTag = "tag1"
+	require.NoError(t, output.Write(metrics))
+	require.Len(t, clients, 2)
+	require.Equal(t, 2, clients[1].PublishCallCount)
+	require.Equal(t, 1, clients[1].WaitConfirmsCallCount)
+}
+
+func TestClientWaitConfirms(t *testing.T) {
+	c := &client{
+		config:    &ClientConfig{confirm: true, timeout: time.Second},
+		pending:   make(map[uint64]string),
+		confirmed: make(chan struct{}, 1),
+	}
+	confirms := make(chan amqp.Confirmation)
+	go c.collectConfirms(confirms)
+
+	// Confirmations are matched by delivery tag, in any order.
+	c.pending[1] = "a"
+	c.pending[2] = "b"
+	go func() {
+		confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+		confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	}()
+	require.NoError(t, c.WaitConfirms())
+
+	c.pending[3] = "c"
+	go func() {
+		confirms <- amqp.Confirmation{DeliveryTag: 3, Ack: false}
+	}()
+	require.Error(t, c.WaitConfirms())
+
+	c.pending = map[uint64]string{4: "d"}
+	close(confirms)
+	require.Equal(t, amqp.ErrClosed, c.WaitConfirms())
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"math/rand"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/streadway/amqp"
@@ -25,12 +26,27 @@ type ClientConfig struct {
 	tlsConfig         *tls.Config
 	timeout           time.Duration
 	auth              []amqp.Authentication
+	confirm           bool
+	mandatory         bool
 }
 
 type client struct {
 	conn    *amqp.Connection
 	channel *amqp.Channel
 	config  *ClientConfig
+
+	// published is the delivery tag of the last message published in
+	// confirm mode, pending holds the routing keys of the messages not
+	// confirmed yet by their delivery tag.
+	published uint64
+	pending   map[uint64]string
+
+	// confirmations received and not processed yet, confirmed is signaled
+	// when a confirmation is added or the channel is closed.
+	mu            sync.Mutex
+	confirmations []amqp.Confirmation
+	closed        bool
+	confirmed     chan struct{}
 }
 
 // Connect opens a connection to one of the brokers at random
@@ -74,6 +90,31 @@ func Connect(config *ClientConfig) (*client, error) {
 		return nil, err
 	}
 
+	if config.confirm {
+		err = channel.Confirm(false)
+		if err != nil {
+			return nil, fmt.Errorf("error enabling publisher confirms: %v", err)
+		}
+		client.pending = make(map[uint64]string)
+		client.confirmed = make(chan struct{}, 1)
+		// The confirmations are collected in the background, the library
+		// blocks the channel until they are received and a batch has many
+		// messages in flight.
+		go client.collectConfirms(channel.NotifyPublish(make(chan amqp.Confirmation, 1)))
+	}
+
+	if config.mandatory {
+		// Unroutable messages are dropped by the broker after returning
+		// them, they are logged but do not fail the write as publishing them
+		// again would not make them routable.
+		returns := channel.NotifyReturn(make(chan amqp.Return, 1))
+		go func() {
+			for r := range returns {
+				log.Printf("E! Output [amqp] message with routing key %q returned: %s", r.RoutingKey, returnReason(r))
+			}
+		}()
+	}
+
 	return client, nil
 }
 
@@ -111,13 +152,13 @@ func (c *client) DeclareExchange() error {
 }
 
 func (c *client) Publish(key string, body []byte) error {
-	// Note that since the channel is not in confirm mode, the absence of
-	// an error does not indicate successful delivery.
-	return c.channel.Publish(
-		c.config.exchange, // exchange
-		key,               // routing key
-		false,             // mandatory
-		false,             // immediate
+	// Note that unless the channel is in confirm mode, the absence of an
+	// error does not indicate successful delivery.
+	err := c.channel.Publish(
+		c.config.exchange,  // exchange
+		key,                // routing key
+		c.config.mandatory, // mandatory
+		false,              // immediate
 		amqp.Publishing{
 			Headers:         c.config.headers,
 			ContentType:     "text/plain",
@@ -125,6 +166,90 @@ func (c *client) Publish(key string, body []byte) error {
 			Body:            body,
 			DeliveryMode:    c.config.deliveryMode,
 		})
+	if err != nil || !c.config.confirm {
+		return err
+	}
+
+	// The channel numbers the messages in confirm mode starting with 1.
+	c.published++
+	c.pending[c.published] = key
+	return nil
+}
+
+func (c *client) collectConfirms(confirms chan amqp.Confirmation) {
+	for confirm := range confirms {
+		c.mu.Lock()
+		c.confirmations = append(c.confirmations, confirm)
+		c.mu.Unlock()
+		c.signal()
+	}
+
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.signal()
+}
+
+func (c *client) signal() {
+	select {
+	case c.confirmed <- struct{}{}:
+	default:
+	}
+}
+
+// WaitConfirms waits until the broker acknowledges all messages published
+// since the last call.  Confirmations are matched to the messages by their
+// delivery tag, it fails if any message is rejected or the confirmations do
+// not arrive within the timeout.
+func (c *client) WaitConfirms() error {
+	if !c.config.confirm {
+		return nil
+	}
+
+	var timeout <-chan time.Time
+	if c.config.timeout > 0 {
+		timer := time.NewTimer(c.config.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var rejected []string
+	for {
+		c.mu.Lock()
+		confirmations, closed := c.confirmations, c.closed
+		c.confirmations = nil
+		c.mu.Unlock()
+
+		for _, confirm := range confirmations {
+			key, ok := c.pending[confirm.DeliveryTag]
+			if !ok {
+				continue
+			}
+			delete(c.pending, confirm.DeliveryTag)
+			if !confirm.Ack {
+				rejected = append(rejected, key)
+			}
+		}
+
+		switch {
+		case len(rejected) > 0:
+			return fmt.Errorf("%d messages were rejected by the broker, first routing key %q", len(rejected), rejected[0])
+		case len(c.pending) == 0:
+			return nil
+		case closed:
+			return amqp.ErrClosed
+		}
+
+		select {
+		case <-c.confirmed:
+		case <-timeout:
+			return fmt.Errorf("timeout waiting for confirmation of %d messages", len(c.pending))
+		}
+	}
+}
+
+func returnReason(r amqp.Return) string {
+	return fmt.Sprintf("%d %s", r.ReplyCode, r.ReplyText)
 }
 
 func (c *client) Close() error {
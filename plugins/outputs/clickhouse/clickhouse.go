@@ -0,0 +1,529 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+var sampleConfig = `
+  ## Protocol used to talk to the server, either "native" or "http".
+  # protocol = "native"
+
+  ## Address of the server, use "tcp://host:9000" for the native and
+  ## "http://host:8123" for the http protocol.
+  # address = "tcp://localhost:9000"
+
+  ## Credentials and database to write to.
+  # username = "default"
+  # password = ""
+  # database = "default"
+
+  ## Timeout for a single write including schema changes.
+  # timeout = "10s"
+
+  ## HTTP Content-Encoding of insert requests when using the http protocol,
+  ## can be set to "gzip", "zstd" or "identity".
+  # content_encoding = "gzip"
+
+  ## Optional TLS Config
+  # tls_ca = "/etc/telegraf/ca.pem"
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+  ## Use TLS but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## Name of the timestamp column.
+  # timestamp_column = "timestamp"
+
+  ## How tags are stored: "columns" creates a String column per tag, "map"
+  ## stores all tags in a Map(String, String) column (http protocol only) and
+  ## "nested" in a Nested(key String, value String) column.  Map and nested
+  ## keep the schema stable when tag sets vary.
+  # tags_as = "columns"
+  ## Name of the map or nested tags column.
+  # tags_column = "tags"
+
+  ## Create missing tables and add missing columns.
+  # table_create = true
+  ## Table engine and clauses of created tables.
+  # engine = "MergeTree()"
+  # order_by = ["timestamp"]
+  # partition_by = "toYYYYMM(timestamp)"
+`
+
+const (
+	tagsAsColumns = "columns"
+	tagsAsMap     = "map"
+	tagsAsNested  = "nested"
+)
+
+// client is the protocol used to talk to the server.
+type client interface {
+	// Exec runs a statement without result.
+	Exec(ctx context.Context, query string) error
+	// Columns returns the columns of the table with their types, the result
+	// is empty if the table does not exist.
+	Columns(ctx context.Context, database, table string) (map[string]string, error)
+	// Insert writes the rows in a single batch.
+	Insert(ctx context.Context, database, table string, columns []column, rows [][]interface{}) error
+	Close() error
+}
+
+type ClickHouse struct {
+	Protocol        string            `toml:"protocol"`
+	Address         string            `toml:"address"`
+	Username        string            `toml:"username"`
+	Password        string            `toml:"password"`
+	Database        string            `toml:"database"`
+	Timeout         internal.Duration `toml:"timeout"`
+	ContentEncoding string            `toml:"content_encoding"`
+	TimestampColumn string            `toml:"timestamp_column"`
+	TagsAs          string            `toml:"tags_as"`
+	TagsColumn      string            `toml:"tags_column"`
+	TableCreate     bool              `toml:"table_create"`
+	Engine          string            `toml:"engine"`
+	OrderBy         []string          `toml:"order_by"`
+	PartitionBy     string            `toml:"partition_by"`
+	tls.ClientConfig
+
+	Log telegraf.Logger `toml:"-"`
+
+	client client
+
+	// columns and their types known to exist per table
+	tables map[string]map[string]string
+}
+
+type columnKind int
+
+const (
+	kindTimestamp columnKind = iota
+	kindTag
+	kindTags
+	kindTagKeys
+	kindTagValues
+	kindField
+)
+
+// column is a column of a table with its type and the part of the metric
+// it is filled from.
+type column struct {
+	name   string
+	chType string
+	kind   columnKind
+}
+
+// table collects the metrics written to a table in a single write.
+type table struct {
+	name    string
+	tags    []string
+	fields  []column
+	metrics []telegraf.Metric
+}
+
+func (*ClickHouse) Description() string {
+	return "Write metrics to ClickHouse"
+}
+
+func (*ClickHouse) SampleConfig() string {
+	return sampleConfig
+}
+
+func (c *ClickHouse) Init() error {
+	switch c.TagsAs {
+	case tagsAsColumns, tagsAsNested:
+	case tagsAsMap:
+		if c.Protocol != "http" {
+			return fmt.Errorf("tags_as %q requires the http protocol", c.TagsAs)
+		}
+	default:
+		return fmt.Errorf("invalid tags_as %q", c.TagsAs)
+	}
+
+	switch c.Protocol {
+	case "native", "http":
+	default:
+		return fmt.Errorf("invalid protocol %q", c.Protocol)
+	}
+
+	c.tables = make(map[string]map[string]string)
+	return nil
+}
+
+func (c *ClickHouse) Connect() error {
+	tlsConfig, err := c.ClientConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	switch c.Protocol {
+	case "http":
+		c.client, err = newHTTPClient(c.Address, c.Username, c.Password, c.ContentEncoding, c.Timeout.Duration, tlsConfig)
+	default:
+		c.client, err = newNativeClient(c.Address, c.Username, c.Password, c.Database, tlsConfig)
+	}
+	return err
+}
+
+func (c *ClickHouse) Close() error {
+	if c.client == nil {
+		return nil
+	}
+	return c.client.Close()
+}
+
+func (c *ClickHouse) Write(metrics []telegraf.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout.Duration)
+	defer cancel()
+
+	for _, t := range c.group(metrics) {
+		if err := c.write(ctx, t); err != nil {
+			// The table might have been changed by someone else, look it up
+			// again on the next write.
+			delete(c.tables, t.name)
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ClickHouse) write(ctx context.Context, t *table) error {
+	columns := c.columns(t)
+	if err := c.ensureTable(ctx, t.name, columns); err != nil {
+		return err
+	}
+
+	known := c.tables[t.name]
+	rows := make([][]interface{}, 0, len(t.metrics))
+	for _, m := range t.metrics {
+		row := make([]interface{}, 0, len(columns))
+		for _, col := range columns {
+			row = append(row, c.value(m, col, known[col.name]))
+		}
+		rows = append(rows, row)
+	}
+
+	if err := c.client.Insert(ctx, c.Database, t.name, columns, rows); err != nil {
+		return fmt.Errorf("inserting into table %q failed: %v", t.name, err)
+	}
+	return nil
+}
+
+// group sorts the metrics into tables by measurement.
+func (c *ClickHouse) group(metrics []telegraf.Metric) []*table {
+	var tables []*table
+	byName := make(map[string]*table)
+	seen := make(map[string]map[string]bool)
+	for _, m := range metrics {
+		t, ok := byName[m.Name()]
+		if !ok {
+			t = &table{name: m.Name()}
+			byName[m.Name()] = t
+			seen[m.Name()] = make(map[string]bool)
+			tables = append(tables, t)
+		}
+
+		for _, tag := range m.TagList() {
+			if !seen[t.name]["tag "+tag.Key] {
+				seen[t.name]["tag "+tag.Key] = true
+				t.tags = append(t.tags, tag.Key)
+			}
+		}
+		for _, field := range m.FieldList() {
+			chType := fieldType(field.Value)
+			if chType == "" || seen[t.name]["field "+field.Key] {
+				continue
+			}
+			seen[t.name]["field "+field.Key] = true
+			t.fields = append(t.fields, column{name: field.Key, chType: chType, kind: kindField})
+		}
+		t.metrics = append(t.metrics, m)
+	}
+
+	for _, t := range tables {
+		sort.Strings(t.tags)
+	}
+	return tables
+}
+
+func fieldType(v interface{}) string {
+	switch v.(type) {
+	case int64:
+		return "Nullable(Int64)"
+	case uint64:
+		return "Nullable(UInt64)"
+	case float64:
+		return "Nullable(Float64)"
+	case string:
+		return "Nullable(String)"
+	case bool:
+		return "Nullable(UInt8)"
+	default:
+		return ""
+	}
+}
+
+// columns returns the columns written for the table, tags and fields
+// clashing with an earlier column are left out.
+func (c *ClickHouse) columns(t *table) []column {
+	var columns []column
+	seen := make(map[string]bool)
+	add := func(col column) {
+		if !seen[col.name] {
+			seen[col.name] = true
+			columns = append(columns, col)
+		}
+	}
+
+	add(column{name: c.TimestampColumn, chType: "DateTime64(9, 'UTC')", kind: kindTimestamp})
+	switch c.TagsAs {
+	case tagsAsMap:
+		add(column{name: c.TagsColumn, chType: "Map(String, String)", kind: kindTags})
+	case tagsAsNested:
+		add(column{name: c.TagsColumn + ".key", chType: "Array(String)", kind: kindTagKeys})
+		add(column{name: c.TagsColumn + ".value", chType: "Array(String)", kind: kindTagValues})
+	default:
+		for _, tag := range t.tags {
+			add(column{name: tag, chType: "String", kind: kindTag})
+		}
+	}
+	for _, field := range t.fields {
+		add(field)
+	}
+	return columns
+}
+
+// ensureTable creates the table or adds the missing columns.
+func (c *ClickHouse) ensureTable(ctx context.Context, name string, columns []column) error {
+	known, ok := c.tables[name]
+	if !ok {
+		var err error
+		known, err = c.client.Columns(ctx, c.Database, name)
+		if err != nil {
+			return fmt.Errorf("looking up columns of table %q failed: %v", name, err)
+		}
+		c.tables[name] = known
+	}
+
+	var missing []column
+	for _, col := range columns {
+		if _, ok := known[col.name]; !ok {
+			missing = append(missing, col)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if !c.TableCreate {
+		return fmt.Errorf("table %q lacks columns %v and table_create is disabled", name, columnNames(missing))
+	}
+
+	for _, query := range c.schemaStatements(name, known, columns) {
+		if err := c.client.Exec(ctx, query); err != nil {
+			return fmt.Errorf("updating schema of table %q failed: %v", name, err)
+		}
+	}
+	for _, col := range missing {
+		known[col.name] = col.chType
+	}
+	return nil
+}
+
+// schemaStatements returns the statements creating the table or adding the
+// missing columns.  Nested columns are declared as a whole.
+func (c *ClickHouse) schemaStatements(name string, known map[string]string, columns []column) []string {
+	var definitions []string
+	for _, col := range columns {
+		if _, ok := known[col.name]; ok {
+			continue
+		}
+		switch col.kind {
+		case kindTagKeys:
+			definitions = append(definitions, quoteIdentifier(c.TagsColumn)+" Nested(key String, value String)")
+		case kindTagValues:
+		default:
+			definitions = append(definitions, quoteIdentifier(col.name)+" "+col.chType)
+		}
+	}
+
+	if len(known) > 0 {
+		statements := make([]string, 0, len(definitions))
+		for _, definition := range definitions {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s",
+				c.fullName(name), definition))
+		}
+		return statements
+	}
+
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = %s",
+		c.fullName(name), strings.Join(definitions, ", "), c.Engine)
+	if c.PartitionBy != "" {
+		query += " PARTITION BY " + c.PartitionBy
+	}
+	if len(c.OrderBy) > 0 {
+		query += " ORDER BY (" + strings.Join(c.OrderBy, ", ") + ")"
+	} else {
+		query += " ORDER BY tuple()"
+	}
+	return []string{query}
+}
+
+// value returns the value of the column for the metric, field values are
+// converted to the type of the existing column.
+func (c *ClickHouse) value(m telegraf.Metric, col column, chType string) interface{} {
+	switch col.kind {
+	case kindTimestamp:
+		return m.Time().UTC()
+	case kindTag:
+		v, _ := m.GetTag(col.name)
+		return v
+	case kindTags:
+		return m.Tags()
+	case kindTagKeys, kindTagValues:
+		tags := m.TagList()
+		values := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			if col.kind == kindTagKeys {
+				values = append(values, tag.Key)
+			} else {
+				values = append(values, tag.Value)
+			}
+		}
+		return values
+	}
+
+	v, ok := m.GetField(col.name)
+	if !ok {
+		return nil
+	}
+	converted, ok := convert(v, chType)
+	if !ok {
+		c.Log.Debugf("Dropping value of field %q, it does not fit column type %q", col.name, chType)
+	}
+	return converted
+}
+
+// convert adapts the value to the type of an existing column, the column
+// may have been created by an earlier value of another type.
+func convert(v interface{}, chType string) (interface{}, bool) {
+	if strings.HasPrefix(chType, "Nullable(") {
+		chType = strings.TrimSuffix(strings.TrimPrefix(chType, "Nullable("), ")")
+	}
+
+	switch chType {
+	case "Int64":
+		switch v := v.(type) {
+		case int64:
+			return v, true
+		case uint64:
+			if v <= 1<<63-1 {
+				return int64(v), true
+			}
+		case bool:
+			return boolToUint8(v), true
+		}
+		return nil, false
+	case "UInt64":
+		switch v := v.(type) {
+		case uint64:
+			return v, true
+		case int64:
+			if v >= 0 {
+				return uint64(v), true
+			}
+		case bool:
+			return boolToUint8(v), true
+		}
+		return nil, false
+	case "UInt8":
+		if v, ok := v.(bool); ok {
+			return boolToUint8(v), true
+		}
+		return nil, false
+	case "Float64":
+		switch v := v.(type) {
+		case int64:
+			return float64(v), true
+		case uint64:
+			return float64(v), true
+		case float64:
+			return v, true
+		}
+		return nil, false
+	case "String":
+		switch v := v.(type) {
+		case string:
+			return v, true
+		case int64:
+			return strconv.FormatInt(v, 10), true
+		case uint64:
+			return strconv.FormatUint(v, 10), true
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		case bool:
+			return strconv.FormatBool(v), true
+		}
+		return nil, false
+	default:
+		if v, ok := v.(bool); ok {
+			return boolToUint8(v), true
+		}
+		return v, true
+	}
+}
+
+func boolToUint8(v bool) uint8 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func columnNames(columns []column) []string {
+	names := make([]string, 0, len(columns))
+	for _, col := range columns {
+		names = append(names, col.name)
+	}
+	return names
+}
+
+func (c *ClickHouse) fullName(name string) string {
+	return quoteIdentifier(c.Database) + "." + quoteIdentifier(name)
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(name) + "`"
+}
+
+func quoteString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func init() {
+	outputs.Add("clickhouse", func() telegraf.Output {
+		return &ClickHouse{
+			Protocol:        "native",
+			Address:         "tcp://localhost:9000",
+			Username:        "default",
+			Database:        "default",
+			Timeout:         internal.Duration{Duration: 10 * time.Second},
+			ContentEncoding: "gzip",
+			TimestampColumn: "timestamp",
+			TagsAs:          tagsAsColumns,
+			TagsColumn:      "tags",
+			TableCreate:     true,
+			Engine:          "MergeTree()",
+			OrderBy:         []string{"timestamp"},
+			PartitionBy:     "toYYYYMM(timestamp)",
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package clickhouse
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func newTestClickHouse() *ClickHouse {
+	return &ClickHouse{
+		Protocol:        "native",
+		Username:        "default",
+		Database:        "default",
+		Timeout:         internal.Duration{Duration: 5 * time.Second},
+		ContentEncoding: "gzip",
+		TimestampColumn: "timestamp",
+		TagsAs:          tagsAsColumns,
+		TagsColumn:      "tags",
+		TableCreate:     true,
+		Engine:          "MergeTree()",
+		OrderBy:         []string{"timestamp"},
+		PartitionBy:     "toYYYYMM(timestamp)",
+		Log:             testutil.Logger{},
+	}
+}
+
+func testMetrics() []telegraf.Metric {
+	return []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": 42.5, "count": uint64(3)},
+			time.Unix(1600000000, 0),
+		),
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "b", "region": "eu"},
+			map[string]interface{}{"usage": 1.5, "up": true},
+			time.Unix(1600000001, 500),
+		),
+	}
+}
+
+func TestInit(t *testing.T) {
+	c := newTestClickHouse()
+	require.NoError(t, c.Init())
+
+	c.TagsAs = tagsAsMap
+	require.Error(t, c.Init())
+	c.Protocol = "http"
+	require.NoError(t, c.Init())
+
+	c.TagsAs = "json"
+	require.Error(t, c.Init())
+}
+
+func TestSchemaStatements(t *testing.T) {
+	c := newTestClickHouse()
+	tables := c.group(testMetrics())
+	require.Len(t, tables, 1)
+
+	columns := c.columns(tables[0])
+	require.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS `default`.`cpu` (`timestamp` DateTime64(9, 'UTC'), `host` String, `region` String, " +
+			"`usage` Nullable(Float64), `count` Nullable(UInt64), `up` Nullable(UInt8)) " +
+			"ENGINE = MergeTree() PARTITION BY toYYYYMM(timestamp) ORDER BY (timestamp)",
+	}, c.schemaStatements("cpu", map[string]string{}, columns))
+
+	known := map[string]string{"timestamp": "DateTime64(9, 'UTC')", "host": "String", "usage": "Nullable(Float64)"}
+	require.Equal(t, []string{
+		"ALTER TABLE `default`.`cpu` ADD COLUMN IF NOT EXISTS `region` String",
+		"ALTER TABLE `default`.`cpu` ADD COLUMN IF NOT EXISTS `count` Nullable(UInt64)",
+		"ALTER TABLE `default`.`cpu` ADD COLUMN IF NOT EXISTS `up` Nullable(UInt8)",
+	}, c.schemaStatements("cpu", known, columns))
+
+	c.TagsAs = tagsAsNested
+	c.PartitionBy = ""
+	c.OrderBy = nil
+	columns = c.columns(tables[0])
+	require.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS `default`.`cpu` (`timestamp` DateTime64(9, 'UTC'), `tags` Nested(key String, value String), " +
+			"`usage` Nullable(Float64), `count` Nullable(UInt64), `up` Nullable(UInt8)) ENGINE = MergeTree() ORDER BY tuple()",
+	}, c.schemaStatements("cpu", map[string]string{}, columns))
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		chType   string
+		expected interface{}
+		ok       bool
+	}{
+		{int64(-1), "Nullable(Int64)", int64(-1), true},
+		{uint64(1 << 63), "Nullable(Int64)", nil, false},
+		{int64(-1), "Nullable(UInt64)", nil, false},
+		{int64(2), "Nullable(Float64)", float64(2), true},
+		{true, "Nullable(UInt8)", uint8(1), true},
+		{1.5, "Nullable(UInt8)", nil, false},
+		{1.5, "Nullable(String)", "1.5", true},
+		{"a", "Nullable(Float64)", nil, false},
+		{false, "Int32", uint8(0), true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %s", tt.value, tt.chType), func(t *testing.T) {
+			v, ok := convert(tt.value, tt.chType)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.expected, v)
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	require.Equal(t, "`a\\`b`", quoteIdentifier("a`b"))
+	require.Equal(t, `'it\'s'`, quoteString("it's"))
+}
+
+// fakeServer emulates the HTTP interface of ClickHouse.
+type fakeServer struct {
+	sync.Mutex
+	columns map[string]string
+	queries []string
+	rows    []map[string]interface{}
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if r.Header.Get("X-ClickHouse-User") != "default" {
+		http.Error(w, "Authentication failed", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query().Get("query")
+	s.queries = append(s.queries, query)
+	switch {
+	case strings.HasPrefix(query, "SELECT name, type FROM system.columns"):
+		for name, chType := range s.columns {
+			json.NewEncoder(w).Encode(map[string]string{"name": name, "type": chType})
+		}
+	case strings.HasPrefix(query, "CREATE TABLE"):
+		s.columns = map[string]string{"timestamp": "DateTime64(9, 'UTC')"}
+	case strings.HasPrefix(query, "INSERT"):
+		decoder, err := internal.NewContentDecoder(r.Header.Get("Content-Encoding"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		body, err = decoder.Decode(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		scanner := bufio.NewScanner(strings.NewReader(string(body)))
+		for scanner.Scan() {
+			row := make(map[string]interface{})
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.rows = append(s.rows, row)
+		}
+	}
+}
+
+func TestWriteHTTP(t *testing.T) {
+	server := &fakeServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c := newTestClickHouse()
+	c.Protocol = "http"
+	c.Address = ts.URL
+	c.TagsAs = tagsAsMap
+	require.NoError(t, c.Init())
+	require.NoError(t, c.Connect())
+	defer c.Close()
+
+	require.NoError(t, c.Write(testMetrics()))
+	require.Equal(t, []string{
+		"SELECT name, type FROM system.columns WHERE database = 'default' AND table = 'cpu' FORMAT JSONEachRow",
+		"CREATE TABLE IF NOT EXISTS `default`.`cpu` (`timestamp` DateTime64(9, 'UTC'), `tags` Map(String, String), " +
+			"`usage` Nullable(Float64), `count` Nullable(UInt64), `up` Nullable(UInt8)) " +
+			"ENGINE = MergeTree() PARTITION BY toYYYYMM(timestamp) ORDER BY (timestamp)",
+		"INSERT INTO `default`.`cpu` FORMAT JSONEachRow",
+	}, server.queries)
+	require.Equal(t, []map[string]interface{}{
+		{
+			"timestamp": "2020-09-13 12:26:40",
+			"tags":      map[string]interface{}{"host": "a"},
+			"usage":     42.5,
+			"count":     float64(3),
+			"up":        nil,
+		},
+		{
+			"timestamp": "2020-09-13 12:26:41.0000005",
+			"tags":      map[string]interface{}{"host": "b", "region": "eu"},
+			"usage":     1.5,
+			"count":     nil,
+			"up":        float64(1),
+		},
+	}, server.rows)
+
+	// The schema is cached, only the insert is sent.
+	server.queries = nil
+	require.NoError(t, c.Write(testMetrics()[:1]))
+	require.Equal(t, []string{"INSERT INTO `default`.`cpu` FORMAT JSONEachRow"}, server.queries)
+}
+
+func TestWriteHTTPError(t *testing.T) {
+	server := &fakeServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c := newTestClickHouse()
+	c.Protocol = "http"
+	c.Address = ts.URL
+	c.Username = "nobody"
+	require.NoError(t, c.Init())
+	require.NoError(t, c.Connect())
+	defer c.Close()
+
+	err := c.Write(testMetrics())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Authentication failed")
+	require.Empty(t, c.tables)
+}
+
+func TestWriteNativeIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	c := newTestClickHouse()
+	c.Address = fmt.Sprintf("tcp://%s:9000", testutil.GetLocalHost())
+	c.TagsAs = tagsAsNested
+	require.NoError(t, c.Init())
+	require.NoError(t, c.Connect())
+	defer c.Close()
+
+	require.NoError(t, c.Write(testMetrics()))
+	require.NoError(t, c.Write(testMetrics()))
+}
@@ -0,0 +1,161 @@
+package clickhouse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+// httpClient talks to the HTTP interface of the server, rows are sent in
+// the JSONEachRow format.
+type httpClient struct {
+	url      *url.URL
+	username string
+	password string
+	encoding string
+	encoder  internal.ContentEncoder
+	client   *http.Client
+}
+
+func newHTTPClient(address, username, password, encoding string, timeout time.Duration, tlsConfig *tls.Config) (*httpClient, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %v", address, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid address %q: the http protocol requires the http or https scheme", address)
+	}
+
+	encoder, err := internal.NewContentEncoder(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpClient{
+		url:      u,
+		username: username,
+		password: password,
+		encoding: encoding,
+		encoder:  encoder,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+			Timeout: timeout,
+		},
+	}, nil
+}
+
+func (c *httpClient) Exec(ctx context.Context, query string) error {
+	body, err := c.do(ctx, query, nil)
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+func (c *httpClient) Columns(ctx context.Context, database, table string) (map[string]string, error) {
+	query := fmt.Sprintf("SELECT name, type FROM system.columns WHERE database = %s AND table = %s FORMAT JSONEachRow",
+		quoteString(database), quoteString(table))
+	body, err := c.do(ctx, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	columns := make(map[string]string)
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var col struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &col); err != nil {
+			return nil, fmt.Errorf("decoding column failed: %v", err)
+		}
+		columns[col.Name] = col.Type
+	}
+	return columns, scanner.Err()
+}
+
+func (c *httpClient) Insert(ctx context.Context, database, table string, columns []column, rows [][]interface{}) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, row := range rows {
+		obj := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			v := row[i]
+			if t, ok := v.(time.Time); ok {
+				v = t.UTC().Format("2006-01-02 15:04:05.999999999")
+			}
+			obj[col.name] = v
+		}
+		if err := encoder.Encode(obj); err != nil {
+			return err
+		}
+	}
+
+	data, err := c.encoder.Encode(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s.%s FORMAT JSONEachRow", quoteIdentifier(database), quoteIdentifier(table))
+	body, err := c.do(ctx, query, data)
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+// do sends the query, data is appended to the query as the request body.
+func (c *httpClient) do(ctx context.Context, query string, data []byte) (io.ReadCloser, error) {
+	u := *c.url
+	params := u.Query()
+	params.Set("query", query)
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-ClickHouse-User", c.username)
+	if c.password != "" {
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+	if data != nil && c.encoding != "" && c.encoding != "identity" {
+		req.Header.Set("Content-Encoding", c.encoding)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("server responded with %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
+
+func (c *httpClient) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,116 @@
+package clickhouse
+
+import (
+	"context"
+	"crypto/tls"
+	gosql "database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go"
+)
+
+// nativeClient talks the native TCP protocol through the database/sql
+// driver.
+type nativeClient struct {
+	db        *gosql.DB
+	tlsConfig string
+}
+
+func newNativeClient(address, username, password, database string, tlsConfig *tls.Config) (*nativeClient, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %v", address, err)
+	}
+	if u.Scheme != "tcp" {
+		return nil, fmt.Errorf("invalid address %q: the native protocol requires the tcp scheme", address)
+	}
+
+	c := &nativeClient{}
+	query := u.Query()
+	query.Set("username", username)
+	query.Set("password", password)
+	query.Set("database", database)
+	if tlsConfig != nil {
+		// The driver only accepts TLS configs by name.
+		c.tlsConfig = fmt.Sprintf("telegraf-%p", c)
+		if err := clickhouse.RegisterTLSConfig(c.tlsConfig, tlsConfig); err != nil {
+			return nil, err
+		}
+		query.Set("tls_config", c.tlsConfig)
+	}
+	u.RawQuery = query.Encode()
+
+	c.db, err = gosql.Open("clickhouse", u.String())
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *nativeClient) Exec(ctx context.Context, query string) error {
+	_, err := c.db.ExecContext(ctx, query)
+	return err
+}
+
+func (c *nativeClient) Columns(ctx context.Context, database, table string) (map[string]string, error) {
+	rows, err := c.db.QueryContext(ctx,
+		"SELECT name, type FROM system.columns WHERE database = ? AND table = ?", database, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]string)
+	for rows.Next() {
+		var name, chType string
+		if err := rows.Scan(&name, &chType); err != nil {
+			return nil, err
+		}
+		columns[name] = chType
+	}
+	return columns, rows.Err()
+}
+
+// Insert sends the rows as a single block, the driver buffers the rows of
+// a transaction and sends them on commit.
+func (c *nativeClient) Insert(ctx context.Context, database, table string, columns []column, rows [][]interface{}) error {
+	names := make([]string, 0, len(columns))
+	for _, col := range columns {
+		names = append(names, quoteIdentifier(col.name))
+	}
+	query := fmt.Sprintf("INSERT INTO %s.%s (%s) VALUES (%s)",
+		quoteIdentifier(database), quoteIdentifier(table), strings.Join(names, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (c *nativeClient) Close() error {
+	if c.tlsConfig != "" {
+		clickhouse.DeregisterTLSConfig(c.tlsConfig)
+	}
+	if c.db == nil {
+		return nil
+	}
+	return c.db.Close()
+}
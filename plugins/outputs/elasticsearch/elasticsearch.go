@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"text/template"
@@ -34,11 +35,21 @@ type Elasticsearch struct {
 	ManageTemplate      bool
 	TemplateName        string
 	OverwriteTemplate   bool
-	ForceDocumentID     bool `toml:"force_document_id"`
+	ForceDocumentID     bool   `toml:"force_document_id"`
+	UseDataStream       bool   `toml:"use_data_stream"`
+	Pipeline            string `toml:"pipeline"`
+	MaxRetries          int    `toml:"max_retries"`
+	TemplateType        string `toml:"template_type"`
+	ILMPolicyName       string `toml:"ilm_policy_name"`
+	ILMPolicy           string `toml:"ilm_policy"`
+	ManageILMPolicy     bool   `toml:"manage_ilm_policy"`
+	OverwriteILMPolicy  bool   `toml:"overwrite_ilm_policy"`
 	MajorReleaseNumber  int
 	tls.ClientConfig
 
 	Client *elastic.Client
+
+	minorReleaseNumber int
 }
 
 var sampleConfig = `
@@ -92,11 +103,83 @@ var sampleConfig = `
   template_name = "telegraf"
   ## Set to true if you want telegraf to overwrite an existing template
   overwrite_template = false
+  ## Template type, either "legacy" for the _template API or "composable"
+  ## for the _index_template API of Elasticsearch 7.8 and later.
+  # template_type = "legacy"
   ## If set to true a unique ID hash will be sent as sha256(concat(timestamp,measurement,series-hash)) string
   ## it will enable data resend and update metric points avoiding duplicated metrics with diferent id's
   force_document_id = false
+
+  ## Write into the data stream named by index_name instead of an index,
+  ## requires Elasticsearch 7.9 or later.  Data streams need a composable
+  ## template declaring the data stream, which is created by manage_template
+  ## with template_type = "composable".
+  # use_data_stream = false
+
+  ## Ingest pipeline the documents are processed by.
+  # pipeline = ""
+
+  ## Number of times documents rejected with a temporary error, such as a
+  ## full write queue, are resent within a write.  Only the rejected
+  ## documents are resent, documents rejected permanently are dropped.  If
+  ## documents are still rejected after the last retry the write fails and
+  ## the whole batch is sent again later, enable force_document_id to not
+  ## duplicate the documents already indexed.
+  # max_retries = 3
+
+  ## Index lifecycle management, requires Elasticsearch 6.6 or later.
+  ## Indexes created by the managed template are assigned the policy
+  ## named ilm_policy_name.
+  # ilm_policy_name = ""
+  ## Set to true to create the policy if it does not exist yet.
+  # manage_ilm_policy = false
+  ## Set to true to update an existing policy.
+  # overwrite_ilm_policy = false
+  ## Policy body in JSON, defaults to deleting indexes after 90 days.  With
+  ## use_data_stream the default policy also rolls the backing index over
+  ## after 30 days or 50GB.
+  # ilm_policy = '''{"policy": {"phases": {...}}}'''
 `
 
+// defaultILMPolicy is the lifecycle policy of data streams, rollover is only
+// possible for data streams as no rollover alias is configured.
+const defaultILMPolicy = `
+{
+	"policy": {
+		"phases": {
+			"hot": {
+				"actions": {
+					"rollover": {
+						"max_age": "30d",
+						"max_size": "50gb"
+					}
+				}
+			},
+			"delete": {
+				"min_age": "90d",
+				"actions": {
+					"delete": {}
+				}
+			}
+		}
+	}
+}`
+
+// defaultDailyILMPolicy is the lifecycle policy of time based indexes.
+const defaultDailyILMPolicy = `
+{
+	"policy": {
+		"phases": {
+			"delete": {
+				"min_age": "90d",
+				"actions": {
+					"delete": {}
+				}
+			}
+		}
+	}
+}`
+
 const telegrafTemplate = `
 {
 	{{ if (lt .Version 6) }}
@@ -104,8 +187,18 @@ const telegrafTemplate = `
 	{{ else }}
 	"index_patterns" : [ "{{.TemplatePattern}}" ],
 	{{ end }}
+	{{ if .Composable }}
+	{{ if .DataStream }}
+	"data_stream": {},
+	{{ end }}
+	"priority": 200,
+	"template": {
+	{{ end }}
 	"settings": {
 		"index": {
+			{{ if .ILMPolicyName }}
+			"lifecycle.name": "{{.ILMPolicyName}}",
+			{{ end }}
 			"refresh_interval": "10s",
 			"mapping.total_fields.limit": 5000,
 			"auto_expand_replicas" : "0-1",
@@ -165,11 +258,22 @@ const telegrafTemplate = `
 		}
 		{{ end }}
 	}
+	{{ if .Composable }}
+	}
+	{{ end }}
 }`
 
+const (
+	templateTypeLegacy     = "legacy"
+	templateTypeComposable = "composable"
+)
+
 type templatePart struct {
 	TemplatePattern string
 	Version         int
+	Composable      bool
+	DataStream      bool
+	ILMPolicyName   string
 }
 
 func (a *Elasticsearch) Connect() error {
@@ -230,15 +334,32 @@ func (a *Elasticsearch) Connect() error {
 	}
 
 	// quit if ES version is not supported
-	majorReleaseNumber, err := strconv.Atoi(strings.Split(esVersion, ".")[0])
+	versionParts := strings.Split(esVersion, ".")
+	majorReleaseNumber, err := strconv.Atoi(versionParts[0])
 	if err != nil || majorReleaseNumber < 5 {
 		return fmt.Errorf("Elasticsearch version not supported: %s", esVersion)
 	}
+	minorReleaseNumber := 0
+	if len(versionParts) > 1 {
+		minorReleaseNumber, _ = strconv.Atoi(versionParts[1])
+	}
 
 	log.Println("I! Elasticsearch version: " + esVersion)
 
 	a.Client = client
 	a.MajorReleaseNumber = majorReleaseNumber
+	a.minorReleaseNumber = minorReleaseNumber
+
+	if err := a.checkFeatures(); err != nil {
+		return err
+	}
+
+	if a.ManageILMPolicy {
+		err := a.manageILMPolicy(ctx)
+		if err != nil {
+			return err
+		}
+	}
 
 	if a.ManageTemplate {
 		err := a.manageTemplate(ctx)
@@ -265,12 +386,47 @@ func GetPointID(m telegraf.Metric) string {
 	return fmt.Sprintf("%x", sha256.Sum256(buffer.Bytes()))
 }
 
+// checkFeatures verifies the server supports the configured features.
+func (a *Elasticsearch) checkFeatures() error {
+	switch a.TemplateType {
+	case "", templateTypeLegacy:
+		if a.UseDataStream && a.ManageTemplate {
+			return fmt.Errorf("Elasticsearch data streams require template_type %q", templateTypeComposable)
+		}
+	case templateTypeComposable:
+		if !a.versionAtLeast(7, 8) {
+			return fmt.Errorf("Elasticsearch composable templates require version 7.8 or later")
+		}
+	default:
+		return fmt.Errorf("Elasticsearch template_type %q not supported", a.TemplateType)
+	}
+
+	if a.UseDataStream && !a.versionAtLeast(7, 9) {
+		return fmt.Errorf("Elasticsearch data streams require version 7.9 or later")
+	}
+
+	if (a.ManageILMPolicy || a.ILMPolicyName != "") && !a.versionAtLeast(6, 6) {
+		return fmt.Errorf("Elasticsearch index lifecycle management requires version 6.6 or later")
+	}
+	if a.ManageILMPolicy && a.ILMPolicyName == "" {
+		return fmt.Errorf("Elasticsearch ilm_policy_name configuration not defined")
+	}
+	return nil
+}
+
+func (a *Elasticsearch) versionAtLeast(major, minor int) bool {
+	if a.MajorReleaseNumber != major {
+		return a.MajorReleaseNumber > major
+	}
+	return a.minorReleaseNumber >= minor
+}
+
 func (a *Elasticsearch) Write(metrics []telegraf.Metric) error {
 	if len(metrics) == 0 {
 		return nil
 	}
 
-	bulkRequest := a.Client.Bulk()
+	requests := make([]*elastic.BulkIndexRequest, 0, len(metrics))
 
 	for _, metric := range metrics {
 		var name = metric.Name()
@@ -293,33 +449,122 @@ func (a *Elasticsearch) Write(metrics []telegraf.Metric) error {
 			br.Id(id)
 		}
 
-		if a.MajorReleaseNumber <= 6 {
+		if a.UseDataStream {
+			// data streams only accept appending documents
+			br.OpType("create")
+		} else if a.MajorReleaseNumber <= 6 {
 			br.Type("metrics")
 		}
 
-		bulkRequest.Add(br)
+		requests = append(requests, br)
 
 	}
 
+	for retry := 0; ; retry++ {
+		failed, err := a.bulk(requests)
+		if err != nil {
+			return err
+		}
+
+		if len(failed) == 0 {
+			return nil
+		}
+
+		// The error keeps the batch in the buffer, the documents already
+		// indexed are sent again with it.
+		if retry >= a.MaxRetries {
+			return fmt.Errorf("Elasticsearch rejected %d metrics after %d retries", len(failed), retry)
+		}
+
+		log.Printf("D! Elasticsearch resending %d rejected metrics", len(failed))
+		requests = failed
+		time.Sleep(time.Duration(retry+1) * 100 * time.Millisecond)
+	}
+}
+
+// bulk sends the requests and returns the requests rejected with a
+// temporary error.  Requests rejected permanently are dropped.
+func (a *Elasticsearch) bulk(requests []*elastic.BulkIndexRequest) ([]*elastic.BulkIndexRequest, error) {
+	bulkRequest := a.Client.Bulk()
+	if a.Pipeline != "" {
+		bulkRequest.Pipeline(a.Pipeline)
+	}
+	for _, br := range requests {
+		bulkRequest.Add(br)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), a.Timeout.Duration)
 	defer cancel()
 
 	res, err := bulkRequest.Do(ctx)
 
 	if err != nil {
-		return fmt.Errorf("Error sending bulk request to Elasticsearch: %s", err)
+		return nil, fmt.Errorf("Error sending bulk request to Elasticsearch: %s", err)
+	}
+
+	if !res.Errors {
+		return nil, nil
+	}
+
+	if len(res.Items) != len(requests) {
+		return nil, fmt.Errorf("Elasticsearch bulk response has %d items for %d requests", len(res.Items), len(requests))
 	}
 
-	if res.Errors {
-		for id, err := range res.Failed() {
-			log.Printf("E! Elasticsearch indexing failure, id: %d, error: %s, caused by: %s, %s", id, err.Error.Reason, err.Error.CausedBy["reason"], err.Error.CausedBy["type"])
-			break
+	var failed []*elastic.BulkIndexRequest
+	for i, item := range res.Items {
+		for _, result := range item {
+			switch {
+			case result.Status >= 200 && result.Status < 300:
+			case result.Status == http.StatusConflict && a.UseDataStream && a.ForceDocumentID:
+				// the document was written by an earlier attempt
+			case result.Status == http.StatusTooManyRequests || result.Status >= 500:
+				failed = append(failed, requests[i])
+			default:
+				reason := ""
+				if result.Error != nil {
+					reason = fmt.Sprintf("%s, caused by: %v, %v", result.Error.Reason, result.Error.CausedBy["reason"], result.Error.CausedBy["type"])
+				}
+				log.Printf("E! Elasticsearch dropping metric rejected with status %d, index: %s, error: %s", result.Status, result.Index, reason)
+			}
+		}
+	}
+	return failed, nil
+}
+
+func (a *Elasticsearch) manageILMPolicy(ctx context.Context) error {
+	path := "/_ilm/policy/" + url.PathEscape(a.ILMPolicyName)
+
+	if !a.OverwriteILMPolicy {
+		res, err := a.Client.PerformRequest(ctx, "GET", path, nil, nil, http.StatusNotFound)
+		if err != nil {
+			return fmt.Errorf("Elasticsearch ILM policy check failed, policy name: %s, error: %s", a.ILMPolicyName, err)
 		}
-		return fmt.Errorf("W! Elasticsearch failed to index %d metrics", len(res.Failed()))
+		if res.StatusCode == http.StatusOK {
+			log.Println("D! Found existing Elasticsearch ILM policy. Skipping policy management")
+			return nil
+		}
+	}
+
+	policy := a.ILMPolicy
+	if policy == "" {
+		policy = a.defaultILMPolicy()
 	}
 
+	_, err := a.Client.PerformRequest(ctx, "PUT", path, nil, policy)
+	if err != nil {
+		return fmt.Errorf("Elasticsearch failed to create ILM policy %s : %s", a.ILMPolicyName, err)
+	}
+
+	log.Printf("D! Elasticsearch ILM policy %s created or updated\n", a.ILMPolicyName)
 	return nil
+}
 
+// defaultILMPolicy returns the policy created if none is configured.
+func (a *Elasticsearch) defaultILMPolicy() string {
+	if a.UseDataStream {
+		return defaultILMPolicy
+	}
+	return defaultDailyILMPolicy
 }
 
 func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
@@ -327,37 +572,23 @@ func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
 		return fmt.Errorf("Elasticsearch template_name configuration not defined")
 	}
 
+	if a.TemplateType == templateTypeComposable {
+		return a.manageComposableTemplate(ctx)
+	}
+
 	templateExists, errExists := a.Client.IndexTemplateExists(a.TemplateName).Do(ctx)
 
 	if errExists != nil {
 		return fmt.Errorf("Elasticsearch template check failed, template name: %s, error: %s", a.TemplateName, errExists)
 	}
 
-	templatePattern := a.IndexName
-
-	if strings.Contains(templatePattern, "%") {
-		templatePattern = templatePattern[0:strings.Index(templatePattern, "%")]
-	}
-
-	if strings.Contains(templatePattern, "{{") {
-		templatePattern = templatePattern[0:strings.Index(templatePattern, "{{")]
-	}
-
-	if templatePattern == "" {
-		return fmt.Errorf("Template cannot be created for dynamic index names without an index prefix")
+	templatePattern, err := a.templatePattern()
+	if err != nil {
+		return err
 	}
 
 	if (a.OverwriteTemplate) || (!templateExists) || (templatePattern != "") {
-		tp := templatePart{
-			TemplatePattern: templatePattern + "*",
-			Version:         a.MajorReleaseNumber,
-		}
-
-		t := template.Must(template.New("template").Parse(telegrafTemplate))
-		var tmpl bytes.Buffer
-
-		t.Execute(&tmpl, tp)
-		_, errCreateTemplate := a.Client.IndexPutTemplate(a.TemplateName).BodyString(tmpl.String()).Do(ctx)
+		_, errCreateTemplate := a.Client.IndexPutTemplate(a.TemplateName).BodyString(a.templateBody(templatePattern)).Do(ctx)
 
 		if errCreateTemplate != nil {
 			return fmt.Errorf("Elasticsearch failed to create index template %s : %s", a.TemplateName, errCreateTemplate)
@@ -373,6 +604,71 @@ func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
 	return nil
 }
 
+// manageComposableTemplate creates the template with the _index_template
+// API, unlike legacy templates an existing template is kept unless
+// overwrite_template is set.
+func (a *Elasticsearch) manageComposableTemplate(ctx context.Context) error {
+	path := "/_index_template/" + url.PathEscape(a.TemplateName)
+
+	if !a.OverwriteTemplate {
+		res, err := a.Client.PerformRequest(ctx, "HEAD", path, nil, nil, http.StatusNotFound)
+		if err != nil {
+			return fmt.Errorf("Elasticsearch template check failed, template name: %s, error: %s", a.TemplateName, err)
+		}
+		if res.StatusCode == http.StatusOK {
+			log.Println("D! Found existing Elasticsearch template. Skipping template management")
+			return nil
+		}
+	}
+
+	templatePattern, err := a.templatePattern()
+	if err != nil {
+		return err
+	}
+
+	_, err = a.Client.PerformRequest(ctx, "PUT", path, nil, a.templateBody(templatePattern))
+	if err != nil {
+		return fmt.Errorf("Elasticsearch failed to create index template %s : %s", a.TemplateName, err)
+	}
+
+	log.Printf("D! Elasticsearch template %s created or updated\n", a.TemplateName)
+	return nil
+}
+
+// templatePattern returns the static prefix of the index name.
+func (a *Elasticsearch) templatePattern() (string, error) {
+	templatePattern := a.IndexName
+
+	if strings.Contains(templatePattern, "%") {
+		templatePattern = templatePattern[0:strings.Index(templatePattern, "%")]
+	}
+
+	if strings.Contains(templatePattern, "{{") {
+		templatePattern = templatePattern[0:strings.Index(templatePattern, "{{")]
+	}
+
+	if templatePattern == "" {
+		return "", fmt.Errorf("Template cannot be created for dynamic index names without an index prefix")
+	}
+	return templatePattern, nil
+}
+
+func (a *Elasticsearch) templateBody(templatePattern string) string {
+	tp := templatePart{
+		TemplatePattern: templatePattern + "*",
+		Version:         a.MajorReleaseNumber,
+		Composable:      a.TemplateType == templateTypeComposable,
+		DataStream:      a.UseDataStream,
+		ILMPolicyName:   a.ILMPolicyName,
+	}
+
+	t := template.Must(template.New("template").Parse(telegrafTemplate))
+	var tmpl bytes.Buffer
+
+	t.Execute(&tmpl, tp)
+	return tmpl.String()
+}
+
 func (a *Elasticsearch) GetTagKeys(indexName string) (string, []string) {
 
 	tagKeys := []string{}
@@ -453,6 +749,8 @@ func init() {
 		return &Elasticsearch{
 			Timeout:             internal.Duration{Duration: time.Second * 5},
 			HealthCheckInterval: internal.Duration{Duration: time.Second * 10},
+			MaxRetries:          3,
+			TemplateType:        templateTypeLegacy,
 		}
 	})
 }
@@ -1,13 +1,19 @@
 package elasticsearch
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/require"
@@ -330,3 +336,237 @@ func TestRequestHeaderWhenGzipIsDisabled(t *testing.T) {
 	err = e.Write(testutil.MockMetrics())
 	require.NoError(t, err)
 }
+
+func TestTemplateBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  int
+		template string
+		stream   bool
+		policy   string
+	}{
+		{"legacy 5", 5, templateTypeLegacy, false, ""},
+		{"legacy 6 with policy", 6, templateTypeLegacy, false, "telegraf"},
+		{"legacy 7", 7, templateTypeLegacy, false, ""},
+		{"composable", 7, templateTypeComposable, false, "telegraf"},
+		{"composable data stream", 7, templateTypeComposable, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Elasticsearch{
+				MajorReleaseNumber: tt.version,
+				TemplateType:       tt.template,
+				UseDataStream:      tt.stream,
+				ILMPolicyName:      tt.policy,
+			}
+
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(e.templateBody("telegraf-")), &body))
+
+			settings := body["settings"]
+			if tt.template == templateTypeComposable {
+				require.Equal(t, float64(200), body["priority"])
+				settings = body["template"].(map[string]interface{})["settings"]
+				_, ok := body["data_stream"]
+				require.Equal(t, tt.stream, ok)
+			}
+			index := settings.(map[string]interface{})["index"].(map[string]interface{})
+			if tt.policy != "" {
+				require.Equal(t, tt.policy, index["lifecycle.name"])
+			} else {
+				require.NotContains(t, index, "lifecycle.name")
+			}
+		})
+	}
+}
+
+func TestCheckFeatures(t *testing.T) {
+	e := &Elasticsearch{MajorReleaseNumber: 7, minorReleaseNumber: 8, TemplateType: templateTypeComposable}
+	require.NoError(t, e.checkFeatures())
+
+	e.UseDataStream = true
+	require.Error(t, e.checkFeatures())
+	e.minorReleaseNumber = 9
+	require.NoError(t, e.checkFeatures())
+
+	e.ManageTemplate = true
+	e.TemplateType = templateTypeLegacy
+	require.Error(t, e.checkFeatures())
+
+	e = &Elasticsearch{MajorReleaseNumber: 6, minorReleaseNumber: 5, ManageILMPolicy: true, ILMPolicyName: "telegraf"}
+	require.Error(t, e.checkFeatures())
+	e.minorReleaseNumber = 6
+	require.NoError(t, e.checkFeatures())
+	e.ILMPolicyName = ""
+	require.Error(t, e.checkFeatures())
+}
+
+func bulkTestMetrics() []telegraf.Metric {
+	var metrics []telegraf.Metric
+	for i := 0; i < 3; i++ {
+		metrics = append(metrics, testutil.MustMetric(
+			"cpu",
+			map[string]string{"id": strconv.Itoa(i)},
+			map[string]interface{}{"value": i},
+			time.Unix(1600000000, 0),
+		))
+	}
+	return metrics
+}
+
+// bulkDocuments returns the actions and the ids of the documents of a bulk
+// request.
+func bulkDocuments(t *testing.T, r *http.Request) ([]map[string]interface{}, []string) {
+	var actions []map[string]interface{}
+	var ids []string
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &action))
+		actions = append(actions, action)
+
+		require.True(t, scanner.Scan())
+		var doc map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &doc))
+		ids = append(ids, doc["tag"].(map[string]interface{})["id"].(string))
+	}
+	return actions, ids
+}
+
+func TestWriteRetriesRejectedDocuments(t *testing.T) {
+	var requests [][]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" {
+			_, err := w.Write([]byte(`{"version": {"number": "7.10.1"}}`))
+			require.NoError(t, err)
+			return
+		}
+
+		_, ids := bulkDocuments(t, r)
+		requests = append(requests, ids)
+
+		// The first document is rejected permanently, the second one is
+		// rejected once because the queue is full.
+		var items []string
+		for _, id := range ids {
+			status := 201
+			switch {
+			case id == "0":
+				status = 400
+			case id == "1" && len(requests) == 1:
+				status = 429
+			}
+			items = append(items, fmt.Sprintf(`{"index": {"_index": "test", "status": %d}}`, status))
+		}
+		_, err := fmt.Fprintf(w, `{"errors": true, "items": [%s]}`, strings.Join(items, ","))
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	e := &Elasticsearch{
+		URLs:       []string{ts.URL},
+		IndexName:  "test",
+		Timeout:    internal.Duration{Duration: time.Second * 5},
+		MaxRetries: 3,
+	}
+	require.NoError(t, e.Connect())
+
+	require.NoError(t, e.Write(bulkTestMetrics()))
+	require.Equal(t, [][]string{{"0", "1", "2"}, {"1"}}, requests)
+
+	// Documents still rejected after all retries fail the write, to keep
+	// the batch for the next write.
+	e.MaxRetries = 0
+	requests = nil
+	require.Error(t, e.Write(bulkTestMetrics()[1:3]))
+	require.Equal(t, [][]string{{"1", "2"}}, requests)
+}
+
+func TestWriteDataStream(t *testing.T) {
+	var actions []map[string]interface{}
+	var pipeline string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" {
+			_, err := w.Write([]byte(`{"version": {"number": "7.10.1"}}`))
+			require.NoError(t, err)
+			return
+		}
+
+		actions, _ = bulkDocuments(t, r)
+		pipeline = r.URL.Query().Get("pipeline")
+		// A document written by an earlier attempt conflicts.
+		_, err := w.Write([]byte(`{"errors": true, "items": [{"create": {"status": 409}}, {"create": {"status": 201}}, {"create": {"status": 201}}]}`))
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	e := &Elasticsearch{
+		URLs:            []string{ts.URL},
+		IndexName:       "metrics-telegraf-default",
+		Timeout:         internal.Duration{Duration: time.Second * 5},
+		UseDataStream:   true,
+		ForceDocumentID: true,
+		Pipeline:        "enrich",
+	}
+	require.NoError(t, e.Connect())
+	require.NoError(t, e.Write(bulkTestMetrics()))
+
+	require.Equal(t, "enrich", pipeline)
+	require.Len(t, actions, 3)
+	for _, action := range actions {
+		create, ok := action["create"].(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, "metrics-telegraf-default", create["_index"])
+		require.NotContains(t, create, "_type")
+	}
+}
+
+func TestManageILMPolicyAndComposableTemplate(t *testing.T) {
+	var created []string
+	var template map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/_ilm/policy/telegraf" && r.Method == "GET":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/_index_template/telegraf" && r.Method == "HEAD":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == "PUT":
+			created = append(created, r.URL.Path)
+			if r.URL.Path == "/_index_template/telegraf" {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&template))
+			}
+			_, err := w.Write([]byte(`{"acknowledged": true}`))
+			require.NoError(t, err)
+		default:
+			_, err := w.Write([]byte(`{"version": {"number": "7.10.1"}}`))
+			require.NoError(t, err)
+		}
+	}))
+	defer ts.Close()
+
+	e := &Elasticsearch{
+		URLs:            []string{ts.URL},
+		IndexName:       "metrics-telegraf-default",
+		Timeout:         internal.Duration{Duration: time.Second * 5},
+		UseDataStream:   true,
+		ManageTemplate:  true,
+		TemplateName:    "telegraf",
+		TemplateType:    templateTypeComposable,
+		ManageILMPolicy: true,
+		ILMPolicyName:   "telegraf",
+	}
+	require.NoError(t, e.Connect())
+
+	require.Equal(t, []string{"/_ilm/policy/telegraf", "/_index_template/telegraf"}, created)
+	require.Equal(t, []interface{}{"metrics-telegraf-default*"}, template["index_patterns"])
+	require.Contains(t, template, "data_stream")
+}
+
+func TestDefaultILMPolicy(t *testing.T) {
+	// Rollover requires a rollover alias, only data streams roll over.
+	e := &Elasticsearch{}
+	require.NotContains(t, e.defaultILMPolicy(), "rollover")
+	e.UseDataStream = true
+	require.Contains(t, e.defaultILMPolicy(), "rollover")
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
@@ -18,11 +20,23 @@ type File struct {
 	RotationMaxSize     internal.Size     `toml:"rotation_max_size"`
 	RotationMaxArchives int               `toml:"rotation_max_archives"`
 	UseBatchFormat      bool              `toml:"use_batch_format"`
+	SplitByMeasurement  bool              `toml:"split_by_measurement"`
 	Log                 telegraf.Logger   `toml:"-"`
 
-	writer     io.Writer
 	closers    []io.Closer
 	serializer serializers.Serializer
+
+	destinations []*destination
+	// destinations per measurement when splitting by measurement
+	measurementDestinations map[string][]*destination
+}
+
+// destination is written to with its own serializer if the serializer keeps
+// state of the data written, like the csv header.  Otherwise all files share
+// a single destination.
+type destination struct {
+	writer     io.Writer
+	serializer serializers.Serializer
 }
 
 var sampleConfig = `
@@ -34,6 +48,11 @@ var sampleConfig = `
   ## may more efficiently encode metric groups.
   # use_batch_format = false
 
+  ## Write each measurement to its own file.  The measurement name is
+  ## inserted before the file extension, "/tmp/metrics.out" becomes
+  ## "/tmp/metrics.cpu.out" for the cpu measurement.
+  # split_by_measurement = false
+
   ## The file will be rotated after the time interval specified.  When set
   ## to 0 no time based rotation is performed.
   # rotation_interval = "0d"
@@ -64,24 +83,79 @@ func (f *File) Connect() error {
 		f.Files = []string{"stdout"}
 	}
 
+	f.measurementDestinations = make(map[string][]*destination)
 	for _, file := range f.Files {
 		if file == "stdout" {
 			writers = append(writers, os.Stdout)
-		} else {
-			of, err := rotate.NewFileWriter(
-				file, f.RotationInterval.Duration, f.RotationMaxSize.Size, f.RotationMaxArchives)
+		} else if !f.SplitByMeasurement {
+			of, err := f.openFile(file)
 			if err != nil {
 				return err
 			}
-
 			writers = append(writers, of)
-			f.closers = append(f.closers, of)
 		}
 	}
-	f.writer = io.MultiWriter(writers...)
+	f.destinations = f.newDestinations(writers)
 	return nil
 }
 
+func (f *File) newDestinations(writers []io.Writer) []*destination {
+	if len(writers) == 0 {
+		return nil
+	}
+
+	stateful, ok := f.serializer.(serializers.StatefulSerializer)
+	if !ok {
+		return []*destination{{writer: io.MultiWriter(writers...), serializer: f.serializer}}
+	}
+
+	destinations := make([]*destination, 0, len(writers))
+	for _, w := range writers {
+		destinations = append(destinations, &destination{writer: w, serializer: stateful.Clone()})
+	}
+	return destinations
+}
+
+func (f *File) openFile(file string) (io.Writer, error) {
+	of, err := rotate.NewFileWriter(
+		file, f.RotationInterval.Duration, f.RotationMaxSize.Size, f.RotationMaxArchives)
+	if err != nil {
+		return nil, err
+	}
+	f.closers = append(f.closers, of)
+	return of, nil
+}
+
+// measurementFiles returns the destinations for the files of a
+// measurement, opening them on first use.
+func (f *File) measurementFiles(name string) ([]*destination, error) {
+	if d, ok := f.measurementDestinations[name]; ok {
+		return d, nil
+	}
+
+	var writers []io.Writer
+	for _, file := range f.Files {
+		if file == "stdout" {
+			continue
+		}
+		of, err := f.openFile(measurementFile(file, name))
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, of)
+	}
+
+	d := f.newDestinations(writers)
+	f.measurementDestinations[name] = d
+	return d, nil
+}
+
+// measurementFile inserts the measurement name before the file extension.
+func measurementFile(file, name string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + name + ext
+}
+
 func (f *File) Close() error {
 	var err error
 	for _, c := range f.closers {
@@ -102,26 +176,72 @@ func (f *File) Description() string {
 }
 
 func (f *File) Write(metrics []telegraf.Metric) error {
+	if !f.SplitByMeasurement {
+		return f.writeAll(f.destinations, metrics)
+	}
+
+	var names []string
+	byName := make(map[string][]telegraf.Metric)
+	for _, metric := range metrics {
+		if _, ok := byName[metric.Name()]; !ok {
+			names = append(names, metric.Name())
+		}
+		byName[metric.Name()] = append(byName[metric.Name()], metric)
+	}
+
+	var writeErr error
+	for _, name := range names {
+		files, err := f.measurementFiles(name)
+		if err != nil {
+			return err
+		}
+		if err := f.writeAll(files, byName[name]); err != nil {
+			writeErr = err
+		}
+		if err := f.writeAll(f.destinations, byName[name]); err != nil {
+			writeErr = err
+		}
+	}
+	return writeErr
+}
+
+func (f *File) writeAll(destinations []*destination, metrics []telegraf.Metric) error {
+	var writeErr error
+	for _, d := range destinations {
+		if err := f.write(d, metrics); err != nil {
+			writeErr = err
+		}
+	}
+	return writeErr
+}
+
+func (f *File) write(d *destination, metrics []telegraf.Metric) error {
 	var writeErr error
 
+	// Start over, for example writing the header again, in new and rotated
+	// files.
+	if s, ok := d.serializer.(serializers.StatefulSerializer); ok && isEmpty(d.writer) {
+		s.Reset()
+	}
+
 	if f.UseBatchFormat {
-		octets, err := f.serializer.SerializeBatch(metrics)
+		octets, err := d.serializer.SerializeBatch(metrics)
 		if err != nil {
 			f.Log.Errorf("Could not serialize metric: %v", err)
 		}
 
-		_, err = f.writer.Write(octets)
+		_, err = d.writer.Write(octets)
 		if err != nil {
 			f.Log.Errorf("Error writing to file: %v", err)
 		}
 	} else {
 		for _, metric := range metrics {
-			b, err := f.serializer.Serialize(metric)
+			b, err := d.serializer.Serialize(metric)
 			if err != nil {
 				f.Log.Debugf("Could not serialize metric: %v", err)
 			}
 
-			_, err = f.writer.Write(b)
+			_, err = d.writer.Write(b)
 			if err != nil {
 				writeErr = fmt.Errorf("failed to write message: %v", err)
 			}
@@ -131,6 +251,21 @@ func (f *File) Write(metrics []telegraf.Metric) error {
 	return writeErr
 }
 
+// isEmpty returns true if the writer is a file without content.
+func isEmpty(w io.Writer) bool {
+	switch w := w.(type) {
+	case *rotate.FileWriter:
+		return w.Size() == 0
+	case *os.File:
+		if w == os.Stdout {
+			return false
+		}
+		info, err := w.Stat()
+		return err == nil && info.Size() == 0
+	}
+	return false
+}
+
 func init() {
 	outputs.Add("file", func() telegraf.Output {
 		return &File{}
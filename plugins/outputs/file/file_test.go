@@ -5,10 +5,14 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/testutil"
@@ -180,6 +184,89 @@ func TestFileStdout(t *testing.T) {
 	assert.Equal(t, expNewFile, out)
 }
 
+func TestFileSplitByMeasurement(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := serializers.NewInfluxSerializer()
+	f := File{
+		Files:              []string{filepath.Join(dir, "metrics.out")},
+		SplitByMeasurement: true,
+		serializer:         s,
+	}
+
+	err = f.Connect()
+	assert.NoError(t, err)
+
+	metrics := append(testutil.MockMetrics(), testutil.TestMetric(2, "test2"))
+	err = f.Write(metrics)
+	assert.NoError(t, err)
+
+	err = f.Close()
+	assert.NoError(t, err)
+
+	validateFile(filepath.Join(dir, "metrics.test1.out"), expNewFile, t)
+	validateFile(filepath.Join(dir, "metrics.test2.out"), "test2,tag1=value1 value=2i 1257894000000000000\n", t)
+}
+
+func TestFileCSVHeaderPerFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := serializers.NewCSVSerializer(&serializers.Config{CSVHeader: "once", CSVLayout: "union"})
+	require.NoError(t, err)
+	f := File{
+		Files:              []string{filepath.Join(dir, "metrics.csv")},
+		SplitByMeasurement: true,
+		serializer:         s,
+	}
+	require.NoError(t, f.Connect())
+
+	other := testutil.MustMetric("test2",
+		map[string]string{"tag2": "value2"},
+		map[string]interface{}{"other": 2},
+		time.Unix(1257894000, 0))
+	require.NoError(t, f.Write([]telegraf.Metric{testutil.TestMetric(1), other}))
+	require.NoError(t, f.Write([]telegraf.Metric{testutil.TestMetric(3)}))
+	require.NoError(t, f.Close())
+
+	validateFile(filepath.Join(dir, "metrics.test1.csv"),
+		"timestamp,measurement,tag1,value\n1257894000,test1,value1,1\n1257894000,test1,value1,3\n", t)
+	validateFile(filepath.Join(dir, "metrics.test2.csv"),
+		"timestamp,measurement,tag2,other\n1257894000,test2,value2,2\n", t)
+}
+
+func TestFileCSVHeaderAfterRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := serializers.NewCSVSerializer(&serializers.Config{CSVHeader: "once"})
+	require.NoError(t, err)
+	fn := filepath.Join(dir, "metrics.csv")
+	f := File{
+		Files:               []string{fn},
+		RotationMaxSize:     internal.Size{Size: 80},
+		RotationMaxArchives: -1,
+		serializer:          s,
+	}
+	require.NoError(t, f.Connect())
+
+	// The first write exceeds the maximum size and rotates the file.
+	require.NoError(t, f.Write([]telegraf.Metric{testutil.TestMetric(1), testutil.TestMetric(2)}))
+	archives, err := filepath.Glob(filepath.Join(dir, "metrics.*-*.csv"))
+	require.NoError(t, err)
+	require.Len(t, archives, 1)
+	validateFile(archives[0],
+		"timestamp,measurement,tag1,value\n1257894000,test1,value1,1\n1257894000,test1,value1,2\n", t)
+
+	require.NoError(t, f.Write([]telegraf.Metric{testutil.TestMetric(3)}))
+	validateFile(fn, "timestamp,measurement,tag1,value\n1257894000,test1,value1,3\n", t)
+	require.NoError(t, f.Close())
+}
+
 func createFile() *os.File {
 	f, err := ioutil.TempFile("", "")
 	if err != nil {
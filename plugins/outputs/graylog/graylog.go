@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/zlib"
 	"crypto/rand"
+	"crypto/tls"
 	"encoding/binary"
 	ejson "encoding/json"
 	"fmt"
@@ -11,8 +12,12 @@ import (
 	"math"
 	"net"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	tlsint "github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/outputs"
 )
 
@@ -21,6 +26,10 @@ const (
 	defaultConnection      = "wan"
 	defaultMaxChunkSizeWan = 1420
 	defaultMaxChunkSizeLan = 8154
+
+	modeBroadcast   = "broadcast"
+	modeLoadbalance = "loadbalance"
+	modeFailover    = "failover"
 )
 
 type GelfConfig struct {
@@ -144,20 +153,131 @@ func (g *Gelf) send(b []byte) (n int, err error) {
 	if err != nil {
 		return
 	}
+	defer conn.Close()
 
 	n, err = conn.Write(b)
 	return
 }
 
+func (g *Gelf) Close() error {
+	return nil
+}
+
+// GelfTCP writes uncompressed messages terminated by a null byte to a
+// stream connection.  The connection is established on the first write and
+// after a failed write.
+type GelfTCP struct {
+	address   string
+	timeout   time.Duration
+	tlsConfig *tls.Config
+	conn      net.Conn
+}
+
+func NewGelfTCPWriter(address string, timeout time.Duration, tlsConfig *tls.Config) *GelfTCP {
+	return &GelfTCP{address: address, timeout: timeout, tlsConfig: tlsConfig}
+}
+
+func (g *GelfTCP) Write(message []byte) (int, error) {
+	frame := make([]byte, 0, len(message)+1)
+	frame = append(append(frame, message...), 0)
+
+	reused := g.conn != nil
+	err := g.send(frame)
+	if err != nil && reused {
+		// The server might have closed the idle connection, retry once
+		// with a new one.
+		err = g.send(frame)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(message), nil
+}
+
+func (g *GelfTCP) send(frame []byte) error {
+	if g.conn == nil {
+		if err := g.connect(); err != nil {
+			return err
+		}
+	}
+
+	if g.timeout > 0 {
+		g.conn.SetWriteDeadline(time.Now().Add(g.timeout))
+	}
+	if _, err := g.conn.Write(frame); err != nil {
+		g.Close()
+		return err
+	}
+	return nil
+}
+
+func (g *GelfTCP) connect() error {
+	dialer := &net.Dialer{Timeout: g.timeout}
+	var err error
+	if g.tlsConfig != nil {
+		g.conn, err = tls.DialWithDialer(dialer, "tcp", g.address, g.tlsConfig)
+	} else {
+		g.conn, err = dialer.Dial("tcp", g.address)
+	}
+	if err != nil {
+		g.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (g *GelfTCP) Close() error {
+	if g.conn == nil {
+		return nil
+	}
+	err := g.conn.Close()
+	g.conn = nil
+	return err
+}
+
 type Graylog struct {
-	Servers           []string `toml:"servers"`
-	ShortMessageField string   `toml:"short_message_field"`
-	writer            io.Writer
+	Servers           []string          `toml:"servers"`
+	ShortMessageField string            `toml:"short_message_field"`
+	Mode              string            `toml:"mode"`
+	Timeout           internal.Duration `toml:"timeout"`
+	EnableTLS         bool              `toml:"enable_tls"`
+	tlsint.ClientConfig
+
+	Log telegraf.Logger `toml:"-"`
+
+	writers []io.WriteCloser
+	// index of the server used next in the loadbalance and failover modes
+	next int
 }
 
 var sampleConfig = `
-  ## UDP endpoint for your graylog instance.
-  servers = ["127.0.0.1:12201"]
+  ## Endpoints for your graylog instances, prefixed by the protocol.  UDP
+  ## messages are compressed and chunked, TCP messages are terminated by a
+  ## null byte.  Addresses without a protocol use UDP.
+  servers = ["udp://127.0.0.1:12201"]
+
+  ## How messages are distributed across the servers:
+  ##   broadcast   - send every message to all servers
+  ##   loadbalance - send each message to the next server in turn
+  ##   failover    - send to the first server until it fails, then move on
+  ## In the loadbalance and failover modes a message is sent to the other
+  ## servers if a server fails.
+  # mode = "broadcast"
+
+  ## Timeout for establishing TCP connections and writing to them.
+  # timeout = "5s"
+
+  ## Enable TLS for TCP servers.  Without any of the options below the
+  ## server certificate is verified against the system CAs.  TLS is also
+  ## enabled when any of the options below is set.
+  # enable_tls = false
+
+  ## Optional TLS Config for TCP servers
+  # tls_ca = "/etc/telegraf/ca.pem"
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+  ## Use TLS but skip chain & host verification
+  # insecure_skip_verify = false
 
   ## The field to use as the GELF short_message, if unset the static string
   ## "telegraf" will be used.
@@ -165,23 +285,58 @@ var sampleConfig = `
   # short_message_field = ""
 `
 
-func (g *Graylog) Connect() error {
-	writers := []io.Writer{}
+func (g *Graylog) Init() error {
+	switch g.Mode {
+	case "", modeBroadcast, modeLoadbalance, modeFailover:
+	default:
+		return fmt.Errorf("invalid mode %q", g.Mode)
+	}
+	return nil
+}
 
+func (g *Graylog) Connect() error {
 	if len(g.Servers) == 0 {
 		g.Servers = append(g.Servers, "localhost:12201")
 	}
 
+	tlsConfig, err := g.ClientConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	writers := make([]io.WriteCloser, 0, len(g.Servers))
 	for _, server := range g.Servers {
-		w := NewGelfWriter(GelfConfig{GraylogEndpoint: server})
-		writers = append(writers, w)
+		scheme, address := "udp", server
+		if parts := strings.SplitN(server, "://", 2); len(parts) == 2 {
+			scheme, address = parts[0], parts[1]
+		}
+
+		switch scheme {
+		case "udp":
+			writers = append(writers, NewGelfWriter(GelfConfig{GraylogEndpoint: address}))
+		case "tcp":
+			serverTLSConfig := tlsConfig
+			if serverTLSConfig == nil && g.EnableTLS {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return fmt.Errorf("invalid address %q: %v", address, err)
+				}
+				serverTLSConfig = &tls.Config{ServerName: host}
+			}
+			writers = append(writers, NewGelfTCPWriter(address, g.Timeout.Duration, serverTLSConfig))
+		default:
+			return fmt.Errorf("unsupported protocol %q in server %q", scheme, server)
+		}
 	}
 
-	g.writer = io.MultiWriter(writers...)
+	g.writers = writers
 	return nil
 }
 
 func (g *Graylog) Close() error {
+	for _, w := range g.writers {
+		w.Close()
+	}
 	return nil
 }
 
@@ -201,8 +356,7 @@ func (g *Graylog) Write(metrics []telegraf.Metric) error {
 		}
 
 		for _, value := range values {
-			_, err := g.writer.Write([]byte(value))
-			if err != nil {
+			if err := g.send([]byte(value)); err != nil {
 				return fmt.Errorf("error writing message: %q, %v", value, err)
 			}
 		}
@@ -210,6 +364,34 @@ func (g *Graylog) Write(metrics []telegraf.Metric) error {
 	return nil
 }
 
+// send writes the message to the servers according to the mode.
+func (g *Graylog) send(message []byte) error {
+	if g.Mode == modeLoadbalance || g.Mode == modeFailover {
+		var err error
+		for i := 0; i < len(g.writers); i++ {
+			index := (g.next + i) % len(g.writers)
+			if _, err = g.writers[index].Write(message); err != nil {
+				g.Log.Debugf("Writing to %q failed: %v", g.Servers[index], err)
+				continue
+			}
+
+			g.next = index
+			if g.Mode == modeLoadbalance {
+				g.next = (index + 1) % len(g.writers)
+			}
+			return nil
+		}
+		return err
+	}
+
+	for _, w := range g.writers {
+		if _, err := w.Write(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (g *Graylog) serialize(metric telegraf.Metric) ([]string, error) {
 	out := []string{}
 
@@ -254,6 +436,9 @@ func (g *Graylog) serialize(metric telegraf.Metric) ([]string, error) {
 
 func init() {
 	outputs.Add("graylog", func() telegraf.Output {
-		return &Graylog{}
+		return &Graylog{
+			Mode:    modeBroadcast,
+			Timeout: internal.Duration{Duration: 5 * time.Second},
+		}
 	})
 }
@@ -1,16 +1,21 @@
 package graylog
 
 import (
+	"bufio"
 	"bytes"
 	"compress/zlib"
+	"crypto/tls"
 	"encoding/json"
 	"io"
 	"net"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestWrite(t *testing.T) {
@@ -56,3 +61,176 @@ func UDPServer(t *testing.T, wg *sync.WaitGroup, wg2 *sync.WaitGroup) {
 	json.Unmarshal(bufW.Bytes(), &obj)
 	assert.Equal(t, obj["_value"], float64(1))
 }
+
+// tcpServer accepts connections and collects the null byte terminated
+// messages.
+type tcpServer struct {
+	listener net.Listener
+	messages chan GelfObject
+}
+
+func newTCPServer(t *testing.T, tlsConfig *tls.Config) *tcpServer {
+	var listener net.Listener
+	var err error
+	if tlsConfig != nil {
+		listener, err = tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	require.NoError(t, err)
+
+	s := &tcpServer{listener: listener, messages: make(chan GelfObject, 100)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *tcpServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		message, err := r.ReadBytes(0)
+		if err != nil {
+			return
+		}
+		var obj GelfObject
+		if json.Unmarshal(message[:len(message)-1], &obj) == nil {
+			s.messages <- obj
+		}
+	}
+}
+
+func (s *tcpServer) address() string {
+	return "tcp://" + s.listener.Addr().String()
+}
+
+func (s *tcpServer) receive(t *testing.T) GelfObject {
+	select {
+	case obj := <-s.messages:
+		return obj
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+func newTCPGraylog(mode string, servers ...string) *Graylog {
+	return &Graylog{
+		Servers: servers,
+		Mode:    mode,
+		Timeout: internal.Duration{Duration: time.Second},
+		Log:     testutil.Logger{},
+	}
+}
+
+func TestWriteTCP(t *testing.T) {
+	server := newTCPServer(t, nil)
+	defer server.listener.Close()
+
+	g := newTCPGraylog(modeBroadcast, server.address())
+	require.NoError(t, g.Init())
+	require.NoError(t, g.Connect())
+	defer g.Close()
+
+	require.NoError(t, g.Write(testutil.MockMetrics()))
+	require.Equal(t, float64(1), server.receive(t)["_value"])
+}
+
+func TestWriteTCPReconnect(t *testing.T) {
+	server := newTCPServer(t, nil)
+	defer server.listener.Close()
+
+	g := newTCPGraylog(modeBroadcast, server.address())
+	require.NoError(t, g.Connect())
+	defer g.Close()
+
+	require.NoError(t, g.Write(testutil.MockMetrics()))
+	server.receive(t)
+
+	// A broken connection is replaced on the next write.
+	g.writers[0].(*GelfTCP).conn.Close()
+	require.NoError(t, g.Write(testutil.MockMetrics()))
+	server.receive(t)
+}
+
+func TestWriteTLS(t *testing.T) {
+	pki := testutil.NewPKI("../../../testutil/pki")
+	cert, err := tls.LoadX509KeyPair(pki.ServerCertPath(), pki.ServerKeyPath())
+	require.NoError(t, err)
+	server := newTCPServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	defer server.listener.Close()
+
+	g := newTCPGraylog(modeBroadcast, server.address())
+	g.InsecureSkipVerify = true
+	require.NoError(t, g.Connect())
+	defer g.Close()
+
+	require.NoError(t, g.Write(testutil.MockMetrics()))
+	require.Equal(t, float64(1), server.receive(t)["_value"])
+}
+
+func TestEnableTLS(t *testing.T) {
+	pki := testutil.NewPKI("../../../testutil/pki")
+	cert, err := tls.LoadX509KeyPair(pki.ServerCertPath(), pki.ServerKeyPath())
+	require.NoError(t, err)
+	server := newTCPServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	defer server.listener.Close()
+
+	g := newTCPGraylog(modeBroadcast, server.address())
+	g.EnableTLS = true
+	require.NoError(t, g.Connect())
+	defer g.Close()
+
+	tlsConfig := g.writers[0].(*GelfTCP).tlsConfig
+	require.NotNil(t, tlsConfig)
+	require.Equal(t, "127.0.0.1", tlsConfig.ServerName)
+	require.False(t, tlsConfig.InsecureSkipVerify)
+
+	// The test certificate is not signed by one of the system CAs.
+	require.Error(t, g.Write(testutil.MockMetrics()))
+}
+
+func TestWriteModes(t *testing.T) {
+	first := newTCPServer(t, nil)
+	defer first.listener.Close()
+	second := newTCPServer(t, nil)
+	defer second.listener.Close()
+
+	g := newTCPGraylog(modeLoadbalance, first.address(), second.address())
+	require.NoError(t, g.Connect())
+	require.NoError(t, g.Write(testutil.MockMetrics()))
+	require.NoError(t, g.Write(testutil.MockMetrics()))
+	first.receive(t)
+	second.receive(t)
+	g.Close()
+
+	g = newTCPGraylog(modeFailover, first.address(), second.address())
+	require.NoError(t, g.Connect())
+	defer g.Close()
+	require.NoError(t, g.Write(testutil.MockMetrics()))
+	require.NoError(t, g.Write(testutil.MockMetrics()))
+	first.receive(t)
+	first.receive(t)
+
+	// Once the first server is gone the second one takes over.
+	first.listener.Close()
+	g.writers[0].Close()
+	require.NoError(t, g.Write(testutil.MockMetrics()))
+	second.receive(t)
+	require.Equal(t, 1, g.next)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	g := newTCPGraylog("random", "tcp://127.0.0.1:12201")
+	require.Error(t, g.Init())
+
+	g = newTCPGraylog(modeBroadcast, "http://127.0.0.1:12201")
+	require.Error(t, g.Connect())
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -56,8 +55,9 @@ var sampleConfig = `
   ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
   # data_format = "influx"
 
-  ## HTTP Content-Encoding for write request body, can be set to "gzip" to
-  ## compress body or "identity" to apply no encoding.
+  ## HTTP Content-Encoding for write request body, can be set to "gzip",
+  ## "zstd", "snappy", "x-snappy-framed" or "lz4" to compress body or
+  ## "identity" to apply no encoding.
   # content_encoding = "identity"
 
   ## Additional HTTP headers
@@ -94,6 +94,7 @@ type HTTP struct {
 
 	client     *http.Client
 	serializer serializers.Serializer
+	encoder    internal.ContentEncoder
 }
 
 func (h *HTTP) SetSerializer(serializer serializers.Serializer) {
@@ -142,6 +143,12 @@ func (h *HTTP) Connect() error {
 		h.Timeout.Duration = defaultClientTimeout
 	}
 
+	encoder, err := internal.NewContentEncoder(h.ContentEncoding)
+	if err != nil {
+		return err
+	}
+	h.encoder = encoder
+
 	ctx := context.Background()
 	client, err := h.createClient(ctx)
 	if err != nil {
@@ -175,19 +182,12 @@ func (h *HTTP) Write(metrics []telegraf.Metric) error {
 }
 
 func (h *HTTP) write(reqBody []byte) error {
-	var reqBodyBuffer io.Reader = bytes.NewBuffer(reqBody)
-
-	var err error
-	if h.ContentEncoding == "gzip" {
-		rc, err := internal.CompressWithGzip(reqBodyBuffer)
-		if err != nil {
-			return err
-		}
-		defer rc.Close()
-		reqBodyBuffer = rc
+	reqBody, err := h.encoder.Encode(reqBody)
+	if err != nil {
+		return err
 	}
 
-	req, err := http.NewRequest(h.Method, h.URL, reqBodyBuffer)
+	req, err := http.NewRequest(h.Method, h.URL, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return err
 	}
@@ -198,8 +198,8 @@ func (h *HTTP) write(reqBody []byte) error {
 
 	req.Header.Set("User-Agent", internal.ProductToken())
 	req.Header.Set("Content-Type", defaultContentType)
-	if h.ContentEncoding == "gzip" {
-		req.Header.Set("Content-Encoding", "gzip")
+	if h.ContentEncoding != "" && h.ContentEncoding != "identity" {
+		req.Header.Set("Content-Encoding", h.ContentEncoding)
 	}
 	for k, v := range h.Headers {
 		if strings.ToLower(k) == "host" {
@@ -258,6 +258,14 @@ func TestContentEncodingGzip(t *testing.T) {
 			},
 			expected: "gzip",
 		},
+		{
+			name: "zstd content_encoding",
+			plugin: &HTTP{
+				URL:             u.String(),
+				ContentEncoding: "zstd",
+			},
+			expected: "zstd",
+		},
 	}
 
 	for _, tt := range tests {
@@ -274,6 +282,12 @@ func TestContentEncodingGzip(t *testing.T) {
 
 				payload, err := ioutil.ReadAll(body)
 				require.NoError(t, err)
+				if r.Header.Get("Content-Encoding") == "zstd" {
+					dec, err := internal.NewContentDecoder("zstd")
+					require.NoError(t, err)
+					payload, err = dec.Decode(payload)
+					require.NoError(t, err)
+				}
 				require.Contains(t, string(payload), "cpu value=42")
 
 				w.WriteHeader(http.StatusNoContent)
@@ -1,13 +1,14 @@
 package mqtt
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
-	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/common/tls"
@@ -18,11 +19,20 @@ import (
 var sampleConfig = `
   servers = ["localhost:1883"] # required.
 
+  ## MQTT protocol version, either "3.1.1" or "5".
+  # protocol = "3.1.1"
+
   ## MQTT outputs send metrics to this topic format
   ##    "<topic_prefix>/<hostname>/<pluginname>/"
   ##   ex: prefix/web01.example.com/mem
   topic_prefix = "telegraf"
 
+  ## Topic template replacing the topic_prefix format.  The template can use
+  ## the measurement name {{ .Name }}, the host tag {{ .Hostname }} and any
+  ## tag or field with {{ .Tag "key" }} and {{ .Field "key" }}.  Empty topic
+  ## levels, for example caused by missing tags, are removed.
+  # topic = 'telegraf/{{ .Hostname }}/{{ .Name }}/{{ .Tag "cpu" }}'
+
   ## QoS policy for messages
   ##   0 = at most once
   ##   1 = at least once
@@ -59,29 +69,79 @@ var sampleConfig = `
   ## more about them here:
   ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
   data_format = "influx"
+
+  ## MQTT v5 only: expiry interval of the messages, messages not delivered
+  ## within the interval are discarded by the broker.
+  # message_expiry = "0s"
+
+  ## MQTT v5 only: content type of the messages.
+  # content_type = ""
+
+  ## MQTT v5 only: user properties added to every message.
+  # [outputs.mqtt.user_properties]
+  #   source = "telegraf"
 `
 
+const (
+	protocolV311 = "3.1.1"
+	protocolV5   = "5"
+)
+
 type MQTT struct {
 	Servers     []string `toml:"servers"`
+	Protocol    string   `toml:"protocol"`
 	Username    string
 	Password    string
 	Database    string
 	Timeout     internal.Duration
 	TopicPrefix string
+	Topic       string `toml:"topic"`
 	QoS         int    `toml:"qos"`
 	ClientID    string `toml:"client_id"`
 	tls.ClientConfig
-	BatchMessage bool `toml:"batch"`
-	Retain       bool `toml:"retain"`
+	BatchMessage   bool              `toml:"batch"`
+	Retain         bool              `toml:"retain"`
+	MessageExpiry  internal.Duration `toml:"message_expiry"`
+	ContentType    string            `toml:"content_type"`
+	UserProperties map[string]string `toml:"user_properties"`
 
-	client paho.Client
-	opts   *paho.ClientOptions
+	client client
 
 	serializer serializers.Serializer
+	topic      *template.Template
 
 	sync.Mutex
 }
 
+// client publishes messages using one of the protocol versions.
+type client interface {
+	Connect() error
+	Publish(topic string, body []byte) error
+	Close() error
+}
+
+// topicData is passed to the topic template.
+type topicData struct {
+	telegraf.Metric
+}
+
+func (d topicData) Hostname() string {
+	return d.Tag("host")
+}
+
+func (d topicData) Tag(key string) string {
+	v, _ := d.GetTag(key)
+	return v
+}
+
+func (d topicData) Field(key string) string {
+	v, ok := d.GetField(key)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
 func (m *MQTT) Connect() error {
 	var err error
 	m.Lock()
@@ -90,17 +150,62 @@ func (m *MQTT) Connect() error {
 		return fmt.Errorf("MQTT Output, invalid QoS value: %d", m.QoS)
 	}
 
-	m.opts, err = m.createOpts()
+	if m.Topic != "" {
+		m.topic, err = template.New("topic").Parse(m.Topic)
+		if err != nil {
+			return fmt.Errorf("MQTT Output, invalid topic template: %v", err)
+		}
+	}
+
+	if len(m.Servers) == 0 {
+		return fmt.Errorf("could not get host informations")
+	}
+
+	if m.Timeout.Duration < time.Second {
+		m.Timeout.Duration = 5 * time.Second
+	}
+
+	if m.ClientID == "" {
+		m.ClientID = "Telegraf-Output-" + internal.RandomString(5)
+	}
+
+	tlsCfg, err := m.ClientConfig.TLSConfig()
 	if err != nil {
 		return err
 	}
 
-	m.client = paho.NewClient(m.opts)
-	if token := m.client.Connect(); token.Wait() && token.Error() != nil {
-		return token.Error()
+	switch m.Protocol {
+	case "", protocolV311:
+		m.client, err = newV311Client(m, tlsCfg)
+	case protocolV5:
+		m.client, err = newV5Client(m, tlsCfg)
+	default:
+		return fmt.Errorf("MQTT Output, unsupported protocol %q", m.Protocol)
+	}
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return m.client.Connect()
+}
+
+// brokers returns the broker URLs, servers without a scheme use TCP or SSL
+// depending on the TLS configuration.
+func (m *MQTT) brokers(tls bool) []string {
+	scheme := "tcp"
+	if tls {
+		scheme = "ssl"
+	}
+
+	brokers := make([]string, 0, len(m.Servers))
+	for _, host := range m.Servers {
+		if strings.Contains(host, "://") {
+			brokers = append(brokers, host)
+		} else {
+			brokers = append(brokers, fmt.Sprintf("%s://%s", scheme, host))
+		}
+	}
+	return brokers
 }
 
 func (m *MQTT) SetSerializer(serializer serializers.Serializer) {
@@ -108,10 +213,10 @@ func (m *MQTT) SetSerializer(serializer serializers.Serializer) {
 }
 
 func (m *MQTT) Close() error {
-	if m.client.IsConnected() {
-		m.client.Disconnect(20)
+	if m.client == nil {
+		return nil
 	}
-	return nil
+	return m.client.Close()
 }
 
 func (m *MQTT) SampleConfig() string {
@@ -136,16 +241,26 @@ func (m *MQTT) Write(metrics []telegraf.Metric) error {
 	metricsmap := make(map[string][]telegraf.Metric)
 
 	for _, metric := range metrics {
-		var t []string
-		if m.TopicPrefix != "" {
-			t = append(t, m.TopicPrefix)
-		}
-		if hostname != "" {
-			t = append(t, hostname)
-		}
+		var topic string
+		if m.topic != nil {
+			var err error
+			topic, err = m.renderTopic(metric)
+			if err != nil {
+				log.Printf("D! [outputs.mqtt] Could not generate topic: %v", err)
+				continue
+			}
+		} else {
+			var t []string
+			if m.TopicPrefix != "" {
+				t = append(t, m.TopicPrefix)
+			}
+			if hostname != "" {
+				t = append(t, hostname)
+			}
 
-		t = append(t, metric.Name())
-		topic := strings.Join(t, "/")
+			t = append(t, metric.Name())
+			topic = strings.Join(t, "/")
+		}
 
 		if m.BatchMessage {
 			metricsmap[topic] = append(metricsmap[topic], metric)
@@ -178,60 +293,32 @@ func (m *MQTT) Write(metrics []telegraf.Metric) error {
 	return nil
 }
 
-func (m *MQTT) publish(topic string, body []byte) error {
-	token := m.client.Publish(topic, byte(m.QoS), m.Retain, body)
-	token.WaitTimeout(m.Timeout.Duration)
-	if token.Error() != nil {
-		return token.Error()
+// renderTopic executes the topic template for the metric, empty topic
+// levels are removed.
+func (m *MQTT) renderTopic(metric telegraf.Metric) (string, error) {
+	var buf bytes.Buffer
+	if err := m.topic.Execute(&buf, topicData{metric}); err != nil {
+		return "", err
 	}
-	return nil
-}
 
-func (m *MQTT) createOpts() (*paho.ClientOptions, error) {
-	opts := paho.NewClientOptions()
-	opts.KeepAlive = 0
-
-	if m.Timeout.Duration < time.Second {
-		m.Timeout.Duration = 5 * time.Second
-	}
-	opts.WriteTimeout = m.Timeout.Duration
-
-	if m.ClientID != "" {
-		opts.SetClientID(m.ClientID)
-	} else {
-		opts.SetClientID("Telegraf-Output-" + internal.RandomString(5))
-	}
-
-	tlsCfg, err := m.ClientConfig.TLSConfig()
-	if err != nil {
-		return nil, err
-	}
-
-	scheme := "tcp"
-	if tlsCfg != nil {
-		scheme = "ssl"
-		opts.SetTLSConfig(tlsCfg)
-	}
-
-	user := m.Username
-	if user != "" {
-		opts.SetUsername(user)
+	levels := strings.Split(buf.String(), "/")
+	topic := levels[:0]
+	for _, level := range levels {
+		if level != "" {
+			topic = append(topic, level)
+		}
 	}
-	password := m.Password
-	if password != "" {
-		opts.SetPassword(password)
+	if len(topic) == 0 {
+		return "", fmt.Errorf("empty topic for metric %q", metric.Name())
 	}
-
-	if len(m.Servers) == 0 {
-		return opts, fmt.Errorf("could not get host informations")
+	if strings.ContainsAny(buf.String(), "+#") {
+		return "", fmt.Errorf("topic %q contains wildcards", buf.String())
 	}
-	for _, host := range m.Servers {
-		server := fmt.Sprintf("%s://%s", scheme, host)
+	return strings.Join(topic, "/"), nil
+}
 
-		opts.AddBroker(server)
-	}
-	opts.SetAutoReconnect(true)
-	return opts, nil
+func (m *MQTT) publish(topic string, body []byte) error {
+	return m.client.Publish(topic, body)
 }
 
 func init() {
@@ -1,8 +1,15 @@
 package mqtt
 
 import (
+	"net"
+	"sort"
 	"testing"
+	"text/template"
+	"time"
 
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/testutil"
 
@@ -29,3 +36,179 @@ func TestConnectAndWriteIntegration(t *testing.T) {
 	err = m.Write(testutil.MockMetrics())
 	require.NoError(t, err)
 }
+
+// fakeClient records the published messages.
+type fakeClient struct {
+	topics []string
+	bodies []string
+}
+
+func (c *fakeClient) Connect() error {
+	return nil
+}
+
+func (c *fakeClient) Publish(topic string, body []byte) error {
+	c.topics = append(c.topics, topic)
+	c.bodies = append(c.bodies, string(body))
+	return nil
+}
+
+func (c *fakeClient) Close() error {
+	return nil
+}
+
+func TestTopicTemplate(t *testing.T) {
+	metric := testutil.MustMetric(
+		"cpu",
+		map[string]string{"host": "web01", "cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 42.5, "core": int64(3)},
+		time.Unix(0, 0),
+	)
+
+	tests := []struct {
+		name     string
+		topic    string
+		expected string
+		err      bool
+	}{
+		{"name and host", "telegraf/{{ .Hostname }}/{{ .Name }}", "telegraf/web01/cpu", false},
+		{"tags and fields", `sensors/{{ .Tag "cpu" }}/{{ .Field "core" }}`, "sensors/cpu0/3", false},
+		{"missing tag", `telegraf/{{ .Tag "region" }}/{{ .Name }}`, "telegraf/cpu", false},
+		{"empty", `{{ .Tag "region" }}`, "", true},
+		{"wildcard", "telegraf/+/{{ .Name }}", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MQTT{Topic: tt.topic}
+			var err error
+			m.topic, err = template.New("topic").Parse(m.Topic)
+			require.NoError(t, err)
+
+			topic, err := m.renderTopic(metric)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, topic)
+		})
+	}
+}
+
+func TestWriteTopicTemplateBatch(t *testing.T) {
+	s, err := serializers.NewInfluxSerializer()
+	require.NoError(t, err)
+
+	client := &fakeClient{}
+	m := &MQTT{
+		BatchMessage: true,
+		client:       client,
+		serializer:   s,
+	}
+	m.topic, err = template.New("topic").Parse(`telegraf/{{ .Tag "cpu" }}`)
+	require.NoError(t, err)
+
+	metrics := []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{"cpu": "cpu0"}, map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"cpu": "cpu1"}, map[string]interface{}{"value": 2}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"cpu": "cpu0"}, map[string]interface{}{"value": 3}, time.Unix(0, 0)),
+	}
+	require.NoError(t, m.Write(metrics))
+
+	sort.Strings(client.topics)
+	require.Equal(t, []string{"telegraf/cpu0", "telegraf/cpu1"}, client.topics)
+}
+
+// fakeBroker is a MQTT v5 broker accepting a single connection and
+// acknowledging QoS 1 messages.  Messages to the "denied" topic are
+// rejected.
+type fakeBroker struct {
+	listener net.Listener
+	messages chan *packets.Publish
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	b := &fakeBroker{listener: listener, messages: make(chan *packets.Publish, 10)}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b.serve(conn)
+	}()
+	return b
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	for {
+		p, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		switch p.Type {
+		case packets.CONNECT:
+			packets.NewControlPacket(packets.CONNACK).WriteTo(conn)
+		case packets.PINGREQ:
+			packets.NewControlPacket(packets.PINGRESP).WriteTo(conn)
+		case packets.PUBLISH:
+			publish := p.Content.(*packets.Publish)
+			if publish.QoS == 1 {
+				ack := packets.NewControlPacket(packets.PUBACK)
+				ack.Content.(*packets.Puback).PacketID = publish.PacketID
+				if publish.Topic == "denied" {
+					ack.Content.(*packets.Puback).ReasonCode = packets.PubackNotAuthorized
+				}
+				ack.WriteTo(conn)
+			}
+			b.messages <- publish
+		case packets.DISCONNECT:
+			return
+		}
+	}
+}
+
+func TestWriteV5(t *testing.T) {
+	broker := newFakeBroker(t)
+	defer broker.listener.Close()
+
+	s, err := serializers.NewInfluxSerializer()
+	require.NoError(t, err)
+	m := &MQTT{
+		Servers:        []string{broker.listener.Addr().String()},
+		Protocol:       "5",
+		QoS:            1,
+		Topic:          "telegraf/{{ .Name }}",
+		MessageExpiry:  internal.Duration{Duration: time.Minute},
+		ContentType:    "text/plain",
+		UserProperties: map[string]string{"source": "telegraf"},
+		serializer:     s,
+	}
+	require.NoError(t, m.Connect())
+	defer m.Close()
+
+	require.NoError(t, m.Write(testutil.MockMetrics()))
+
+	var publish *packets.Publish
+	select {
+	case publish = <-broker.messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	require.Equal(t, "telegraf/test1", publish.Topic)
+	require.Equal(t, uint32(60), *publish.Properties.MessageExpiry)
+	require.Equal(t, "text/plain", publish.Properties.ContentType)
+	require.Equal(t, []packets.User{{Key: "source", Value: "telegraf"}}, publish.Properties.User)
+
+	// Reason codes of rejected messages are reported.
+	m.topic, err = template.New("topic").Parse("denied")
+	require.NoError(t, err)
+	err = m.Write(testutil.MockMetrics())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not authorized")
+}
@@ -0,0 +1,73 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// v311Client publishes using MQTT 3.1.1.
+type v311Client struct {
+	client  paho.Client
+	opts    *paho.ClientOptions
+	qos     byte
+	retain  bool
+	timeout time.Duration
+}
+
+func newV311Client(m *MQTT, tlsCfg *tls.Config) (*v311Client, error) {
+	opts := paho.NewClientOptions()
+	opts.KeepAlive = 0
+	opts.WriteTimeout = m.Timeout.Duration
+	opts.SetClientID(m.ClientID)
+
+	if tlsCfg != nil {
+		opts.SetTLSConfig(tlsCfg)
+	}
+
+	user := m.Username
+	if user != "" {
+		opts.SetUsername(user)
+	}
+	password := m.Password
+	if password != "" {
+		opts.SetPassword(password)
+	}
+
+	for _, server := range m.brokers(tlsCfg != nil) {
+		opts.AddBroker(server)
+	}
+	opts.SetAutoReconnect(true)
+
+	return &v311Client{
+		opts:    opts,
+		qos:     byte(m.QoS),
+		retain:  m.Retain,
+		timeout: m.Timeout.Duration,
+	}, nil
+}
+
+func (c *v311Client) Connect() error {
+	c.client = paho.NewClient(c.opts)
+	if token := c.client.Connect(); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+func (c *v311Client) Publish(topic string, body []byte) error {
+	token := c.client.Publish(topic, c.qos, c.retain, body)
+	token.WaitTimeout(c.timeout)
+	if token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+func (c *v311Client) Close() error {
+	if c.client != nil && c.client.IsConnected() {
+		c.client.Disconnect(20)
+	}
+	return nil
+}
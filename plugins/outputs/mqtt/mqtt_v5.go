@@ -0,0 +1,143 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+// v5Client publishes using MQTT 5, the connection is re-established in the
+// background when it is lost.
+type v5Client struct {
+	config     autopaho.ClientConfig
+	manager    *autopaho.ConnectionManager
+	cancel     context.CancelFunc
+	qos        byte
+	retain     bool
+	timeout    time.Duration
+	properties *paho.PublishProperties
+}
+
+func newV5Client(m *MQTT, tlsCfg *tls.Config) (*v5Client, error) {
+	var brokers []*url.URL
+	for _, server := range m.brokers(tlsCfg != nil) {
+		u, err := url.Parse(server)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server %q: %v", server, err)
+		}
+		brokers = append(brokers, u)
+	}
+
+	config := autopaho.ClientConfig{
+		BrokerUrls:     brokers,
+		TlsCfg:         tlsCfg,
+		KeepAlive:      60,
+		ConnectTimeout: m.Timeout.Duration,
+		OnConnectError: func(err error) {
+			log.Printf("D! [outputs.mqtt] Connecting failed: %v", err)
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID:      m.ClientID,
+			PacketTimeout: m.Timeout.Duration,
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				log.Printf("W! [outputs.mqtt] Server disconnected: %s", reason(d.ReasonCode, d.Properties))
+			},
+		},
+	}
+	config.SetUsernamePassword(m.Username, []byte(m.Password))
+
+	properties := &paho.PublishProperties{ContentType: m.ContentType}
+	if m.MessageExpiry.Duration > 0 {
+		expiry := uint32(m.MessageExpiry.Duration / time.Second)
+		properties.MessageExpiry = &expiry
+	}
+	keys := make([]string, 0, len(m.UserProperties))
+	for key := range m.UserProperties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		properties.User.Add(key, m.UserProperties[key])
+	}
+
+	return &v5Client{
+		config:     config,
+		qos:        byte(m.QoS),
+		retain:     m.Retain,
+		timeout:    m.Timeout.Duration,
+		properties: properties,
+	}, nil
+}
+
+func (c *v5Client) Connect() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	manager, err := autopaho.NewConnection(ctx, c.config)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	awaitCtx, awaitCancel := context.WithTimeout(ctx, c.timeout)
+	defer awaitCancel()
+	if err := manager.AwaitConnection(awaitCtx); err != nil {
+		cancel()
+		return fmt.Errorf("connecting to %v failed: %v", c.config.BrokerUrls, err)
+	}
+
+	c.manager = manager
+	c.cancel = cancel
+	return nil
+}
+
+func (c *v5Client) Publish(topic string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	resp, err := c.manager.Publish(ctx, &paho.Publish{
+		QoS:        c.qos,
+		Retain:     c.retain,
+		Topic:      topic,
+		Properties: c.properties,
+		Payload:    body,
+	})
+	if err != nil {
+		return err
+	}
+
+	// QoS 0 messages are not acknowledged, errors of QoS 2 messages are
+	// only reported by the reason code.
+	if resp != nil && resp.ReasonCode >= 0x80 {
+		if resp.Properties != nil && resp.Properties.ReasonString != "" {
+			return fmt.Errorf("publishing rejected with reason code 0x%02x %s", resp.ReasonCode, resp.Properties.ReasonString)
+		}
+		return fmt.Errorf("publishing rejected with reason code 0x%02x", resp.ReasonCode)
+	}
+	return nil
+}
+
+func (c *v5Client) Close() error {
+	if c.manager == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	err := c.manager.Disconnect(ctx)
+	c.cancel()
+	c.manager = nil
+	return err
+}
+
+// reason formats the reason code and string of a disconnect.
+func reason(code byte, properties *paho.DisconnectProperties) string {
+	if properties != nil && properties.ReasonString != "" {
+		return fmt.Sprintf("reason code 0x%02x %s", code, properties.ReasonString)
+	}
+	return fmt.Sprintf("reason code 0x%02x", code)
+}
@@ -0,0 +1,523 @@
+package parquet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+const (
+	timeColumn     = "time"
+	dateFormat     = "2006-01-02"
+	inProgressExt  = ".tmp"
+	fileExt        = ".parquet"
+	filePermission = os.FileMode(0644)
+)
+
+var sampleConfig = `
+  ## Directory to write the files to, one set of files is written for every
+  ## measurement.
+  directory = "/var/lib/telegraf/parquet"
+
+  ## Compression codec, one of "snappy", "zstd", "gzip" or "none".
+  # compression = "snappy"
+
+  ## The file will be rotated after the time interval specified.  Files can
+  ## only be read once they are rotated, as the footer is written last.  When
+  ## set to 0 no time based rotation is performed and the files are only
+  ## completed when Telegraf stops.
+  # rotation_interval = "1h"
+
+  ## The file will be rotated when it becomes larger than the specified
+  ## size.  When set to 0 no size based rotation is performed.
+  # rotation_max_size = "0MB"
+
+  ## Maximum number of files to keep per measurement, any older files are
+  ## deleted.  If set to -1, no files are removed.
+  # rotation_max_archives = -1
+`
+
+type Parquet struct {
+	Directory           string            `toml:"directory"`
+	Compression         string            `toml:"compression"`
+	RotationInterval    internal.Duration `toml:"rotation_interval"`
+	RotationMaxSize     internal.Size     `toml:"rotation_max_size"`
+	RotationMaxArchives int               `toml:"rotation_max_archives"`
+	Log                 telegraf.Logger   `toml:"-"`
+
+	codec   parquet.CompressionCodec
+	writers map[string]*measurementWriter
+}
+
+type columnKind int
+
+const (
+	kindTime columnKind = iota
+	kindTag
+	kindField
+)
+
+// column is a column of the file schema, typ is the type in the
+// parquet-go metadata notation.
+type column struct {
+	name string
+	kind columnKind
+	typ  string
+}
+
+// measurementWriter writes the metrics of a single measurement to the
+// current file.  The file carries a temporary extension until it is closed,
+// as the parquet footer is only written at that point.
+type measurementWriter struct {
+	path    string
+	file    *localFile
+	writer  *writer.CSVWriter
+	columns []column
+	index   map[string]int
+	opened  time.Time
+}
+
+func (p *Parquet) Description() string {
+	return "Write metrics to Apache Parquet files"
+}
+
+func (p *Parquet) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *Parquet) Init() error {
+	if p.Directory == "" {
+		return fmt.Errorf("directory is required")
+	}
+
+	switch strings.ToLower(p.Compression) {
+	case "", "snappy":
+		p.codec = parquet.CompressionCodec_SNAPPY
+	case "zstd":
+		p.codec = parquet.CompressionCodec_ZSTD
+	case "gzip":
+		p.codec = parquet.CompressionCodec_GZIP
+	case "none":
+		p.codec = parquet.CompressionCodec_UNCOMPRESSED
+	default:
+		return fmt.Errorf("unknown compression %q", p.Compression)
+	}
+	return nil
+}
+
+func (p *Parquet) Connect() error {
+	p.writers = make(map[string]*measurementWriter)
+	if err := os.MkdirAll(p.Directory, 0755); err != nil {
+		return err
+	}
+
+	// Files of a previous run which was not stopped cleanly lack the footer.
+	leftovers, err := filepath.Glob(filepath.Join(p.Directory, "*"+fileExt+inProgressExt))
+	if err != nil {
+		return err
+	}
+	for _, leftover := range leftovers {
+		p.Log.Warnf("Incomplete file %q of a previous run, it has no footer and cannot be read", leftover)
+	}
+	return nil
+}
+
+func (p *Parquet) Close() error {
+	var err error
+	for name := range p.writers {
+		if errClose := p.closeWriter(name); errClose != nil {
+			err = errClose
+		}
+	}
+	return err
+}
+
+func (p *Parquet) Write(metrics []telegraf.Metric) error {
+	var names []string
+	byName := make(map[string][]telegraf.Metric)
+	for _, m := range metrics {
+		if _, ok := byName[m.Name()]; !ok {
+			names = append(names, m.Name())
+		}
+		byName[m.Name()] = append(byName[m.Name()], m)
+	}
+
+	for _, name := range names {
+		if err := p.write(name, byName[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Parquet) write(name string, metrics []telegraf.Metric) error {
+	w := p.writers[name]
+
+	// Parquet files have a fixed schema, when new tags or fields appear the
+	// current file is closed and a new one with the extended schema is
+	// started.
+	var current []column
+	if w != nil {
+		current = w.columns
+	}
+	columns, changed := extendSchema(current, metrics)
+	if changed {
+		if w != nil {
+			if err := p.closeWriter(name); err != nil {
+				return err
+			}
+		}
+		var err error
+		w, err = p.openWriter(name, columns)
+		if err != nil {
+			return err
+		}
+		p.writers[name] = w
+	}
+
+	for _, m := range metrics {
+		if err := w.writer.Write(p.row(w, m)); err != nil {
+			return fmt.Errorf("writing metric to %q failed: %v", w.path, err)
+		}
+	}
+
+	// Flush a row group so the written metrics are persisted in the file.
+	if err := w.writer.Flush(true); err != nil {
+		return fmt.Errorf("flushing %q failed: %v", w.path, err)
+	}
+
+	if p.needsRotation(w) {
+		return p.closeWriter(name)
+	}
+	return nil
+}
+
+func (p *Parquet) row(w *measurementWriter, m telegraf.Metric) []interface{} {
+	row := make([]interface{}, len(w.columns))
+	row[0] = m.Time().UnixNano() / int64(time.Microsecond)
+	for _, tag := range m.TagList() {
+		if i, ok := w.index[columnName(tag.Key)]; ok && w.columns[i].kind == kindTag {
+			row[i] = tag.Value
+		}
+	}
+	for _, field := range m.FieldList() {
+		i, ok := w.index[columnName(field.Key)]
+		if !ok || w.columns[i].kind != kindField {
+			continue
+		}
+		v, ok := convert(field.Value, w.columns[i].typ)
+		if !ok {
+			p.Log.Debugf("Dropping field %q of %q: cannot convert %T to %s", field.Key, m.Name(), field.Value, w.columns[i].typ)
+			continue
+		}
+		row[i] = v
+	}
+	return row
+}
+
+func (p *Parquet) needsRotation(w *measurementWriter) bool {
+	if p.RotationInterval.Duration > 0 && time.Since(w.opened) >= p.RotationInterval.Duration {
+		return true
+	}
+	return p.RotationMaxSize.Size > 0 && w.file.size >= p.RotationMaxSize.Size
+}
+
+func (p *Parquet) openWriter(name string, columns []column) (*measurementWriter, error) {
+	now := time.Now()
+	path := filepath.Join(p.Directory, fmt.Sprintf("%s.%s-%d%s", fileName(name), now.Format(dateFormat), now.UnixNano(), fileExt))
+
+	f, err := os.OpenFile(path+inProgressExt, os.O_RDWR|os.O_CREATE|os.O_TRUNC, filePermission)
+	if err != nil {
+		return nil, err
+	}
+	lf := &localFile{File: f}
+
+	md := make([]string, 0, len(columns))
+	index := make(map[string]int, len(columns))
+	for i, c := range columns {
+		md = append(md, "name="+c.name+", type="+c.typ)
+		index[c.name] = i
+	}
+
+	pw, err := writer.NewCSVWriter(md, lf, 1)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	pw.CompressionType = p.codec
+
+	return &measurementWriter{
+		path:    path,
+		file:    lf,
+		writer:  pw,
+		columns: columns,
+		index:   index,
+		opened:  now,
+	}, nil
+}
+
+// closeWriter writes the footer of the current file of the measurement and
+// gives it its final name.
+func (p *Parquet) closeWriter(name string) error {
+	w := p.writers[name]
+	delete(p.writers, name)
+
+	if err := w.writer.WriteStop(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("finishing %q failed: %v", w.path, err)
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(w.path+inProgressExt, w.path); err != nil {
+		return err
+	}
+	return p.purgeArchives(name)
+}
+
+func (p *Parquet) purgeArchives(name string) error {
+	if p.RotationMaxArchives < 0 {
+		return nil
+	}
+
+	infos, err := ioutil.ReadDir(p.Directory)
+	if err != nil {
+		return err
+	}
+
+	type archive struct {
+		path    string
+		created int64
+	}
+	var archives []archive
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if created, ok := archiveTime(info.Name(), name); ok {
+			archives = append(archives, archive{path: filepath.Join(p.Directory, info.Name()), created: created})
+		}
+	}
+	if len(archives) <= p.RotationMaxArchives {
+		return nil
+	}
+
+	sort.Slice(archives, func(i, j int) bool { return archives[i].created < archives[j].created })
+	for _, a := range archives[:len(archives)-p.RotationMaxArchives] {
+		if err := os.Remove(a.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveTime returns the creation time in nanoseconds of a file named
+// "<measurement>.<date>-<time>.parquet" of the given measurement.  Files of
+// other measurements sharing the prefix, like "cpu.total" for "cpu", do not
+// match.
+func archiveTime(filename string, measurement string) (int64, bool) {
+	prefix := fileName(measurement) + "."
+	if !strings.HasPrefix(filename, prefix) || !strings.HasSuffix(filename, fileExt) {
+		return 0, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(filename, prefix), fileExt)
+
+	if len(stamp) < len(dateFormat)+2 || stamp[len(dateFormat)] != '-' {
+		return 0, false
+	}
+	if _, err := time.Parse(dateFormat, stamp[:len(dateFormat)]); err != nil {
+		return 0, false
+	}
+	created, err := strconv.ParseInt(stamp[len(dateFormat)+1:], 10, 64)
+	if err != nil || created < 0 {
+		return 0, false
+	}
+	return created, true
+}
+
+// extendSchema adds the tags and fields of the metrics missing in the given
+// columns.  The first column always holds the metric time.
+func extendSchema(current []column, metrics []telegraf.Metric) ([]column, bool) {
+	columns := current
+	if len(columns) == 0 {
+		columns = []column{{name: timeColumn, kind: kindTime, typ: "TIMESTAMP_MICROS"}}
+	}
+
+	// Column names are compared the way parquet-go derives its internal
+	// names, so that e.g. "Host" and "host" do not end up in one column.
+	seen := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		seen[common.StringToVariableName(c.name)] = true
+	}
+
+	changed := len(current) == 0
+	add := func(key string, kind columnKind, typ string) {
+		name := columnName(key)
+		if seen[common.StringToVariableName(name)] {
+			return
+		}
+		seen[common.StringToVariableName(name)] = true
+		columns = append(columns, column{name: name, kind: kind, typ: typ})
+		changed = true
+	}
+
+	for _, m := range metrics {
+		for _, tag := range m.TagList() {
+			add(tag.Key, kindTag, "UTF8, encoding=PLAIN_DICTIONARY")
+		}
+		for _, field := range m.FieldList() {
+			if typ, ok := parquetType(field.Value); ok {
+				add(field.Key, kindField, typ)
+			}
+		}
+	}
+	return columns, changed
+}
+
+func parquetType(v interface{}) (string, bool) {
+	switch v.(type) {
+	case float64:
+		return "DOUBLE", true
+	case int64:
+		return "INT64", true
+	case uint64:
+		return "UINT_64", true
+	case bool:
+		return "BOOLEAN", true
+	case string:
+		return "UTF8", true
+	}
+	return "", false
+}
+
+// convert returns the value in the representation expected by parquet-go
+// for the column type.
+func convert(v interface{}, typ string) (interface{}, bool) {
+	switch typ {
+	case "DOUBLE":
+		switch v := v.(type) {
+		case float64:
+			return v, true
+		case int64:
+			return float64(v), true
+		case uint64:
+			return float64(v), true
+		}
+	case "INT64":
+		switch v := v.(type) {
+		case int64:
+			return v, true
+		case uint64:
+			if v <= uint64(1<<63-1) {
+				return int64(v), true
+			}
+		}
+	case "UINT_64":
+		switch v := v.(type) {
+		case uint64:
+			return int64(v), true
+		case int64:
+			if v >= 0 {
+				return v, true
+			}
+		}
+	case "BOOLEAN":
+		if v, ok := v.(bool); ok {
+			return v, true
+		}
+	case "UTF8":
+		switch v := v.(type) {
+		case string:
+			return v, true
+		case bool:
+			return strconv.FormatBool(v), true
+		case int64:
+			return strconv.FormatInt(v, 10), true
+		case uint64:
+			return strconv.FormatUint(v, 10), true
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		}
+	}
+	return nil, false
+}
+
+// columnName replaces the characters with a special meaning in the parquet-go
+// schema notation.
+func columnName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '=', ' ', '\t':
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+func fileName(measurement string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\':
+			return '_'
+		}
+		return r
+	}, measurement)
+}
+
+// localFile is a parquet-go source writing to a local file and keeping
+// track of its size.
+type localFile struct {
+	*os.File
+	size int64
+}
+
+func (f *localFile) Write(b []byte) (int, error) {
+	n, err := f.File.Write(b)
+	f.size += int64(n)
+	return n, err
+}
+
+// Open opens the named file for reading, an empty name reopens the file
+// itself.
+func (f *localFile) Open(name string) (source.ParquetFile, error) {
+	if name == "" {
+		name = f.Name()
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &localFile{File: file}, nil
+}
+
+func (f *localFile) Create(name string) (source.ParquetFile, error) {
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, filePermission)
+	if err != nil {
+		return nil, err
+	}
+	return &localFile{File: file}, nil
+}
+
+func init() {
+	outputs.Add("parquet", func() telegraf.Output {
+		return &Parquet{
+			RotationInterval:    internal.Duration{Duration: time.Hour},
+			RotationMaxArchives: -1,
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package parquet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go/reader"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func newParquet(t *testing.T) (*Parquet, string) {
+	dir, err := ioutil.TempDir("", "parquet")
+	require.NoError(t, err)
+
+	p := &Parquet{
+		Directory:           dir,
+		RotationMaxArchives: -1,
+		Log:                 testutil.Logger{},
+	}
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Connect())
+	return p, dir
+}
+
+func readColumn(t *testing.T, filename string, name string) []interface{} {
+	t.Helper()
+	file, err := os.Open(filename)
+	require.NoError(t, err)
+	f := &localFile{File: file}
+	defer f.Close()
+
+	pr, err := reader.NewParquetColumnReader(f, 1)
+	require.NoError(t, err)
+	values, _, _, err := pr.ReadColumnByPath("parquet_go_root."+name, pr.GetNumRows())
+	require.NoError(t, err)
+	return values
+}
+
+func files(t *testing.T, dir string, pattern string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, pattern))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestWrite(t *testing.T) {
+	p, dir := newParquet(t)
+	defer os.RemoveAll(dir)
+
+	metrics := []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": 42.5},
+			time.Unix(1600000000, 0),
+		),
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "b"},
+			map[string]interface{}{"usage": int64(7)},
+			time.Unix(1600000001, 0),
+		),
+		testutil.MustMetric(
+			"mem",
+			map[string]string{},
+			map[string]interface{}{"used": uint64(1024), "ok": true},
+			time.Unix(1600000000, 0),
+		),
+	}
+	require.NoError(t, p.Write(metrics))
+	require.Len(t, files(t, dir, "*.parquet.tmp"), 2)
+	require.NoError(t, p.Close())
+
+	require.Empty(t, files(t, dir, "*.tmp"))
+	cpu := files(t, dir, "cpu.*.parquet")
+	require.Len(t, cpu, 1)
+	require.Equal(t, []interface{}{"a", "b"}, readColumn(t, cpu[0], "host"))
+	require.Equal(t, []interface{}{42.5, 7.0}, readColumn(t, cpu[0], "usage"))
+	require.Equal(t, []interface{}{int64(1600000000000000), int64(1600000001000000)}, readColumn(t, cpu[0], "time"))
+
+	mem := files(t, dir, "mem.*.parquet")
+	require.Len(t, mem, 1)
+	require.Equal(t, []interface{}{true}, readColumn(t, mem[0], "ok"))
+}
+
+func TestSchemaEvolution(t *testing.T) {
+	p, dir := newParquet(t)
+	defer os.RemoveAll(dir)
+
+	m1 := testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"a": 1.0}, time.Unix(0, 0))
+	m2 := testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"a": 2.0}, time.Unix(1, 0))
+	m3 := testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"a": 3.0, "b": "x"}, time.Unix(2, 0))
+
+	require.NoError(t, p.Write([]telegraf.Metric{m1}))
+	require.NoError(t, p.Write([]telegraf.Metric{m2}))
+	require.Empty(t, files(t, dir, "cpu.*.parquet"))
+
+	// A new field closes the current file and starts one with the new schema
+	require.NoError(t, p.Write([]telegraf.Metric{m3}))
+	closed := files(t, dir, "cpu.*.parquet")
+	require.Len(t, closed, 1)
+	require.Equal(t, []interface{}{1.0, 2.0}, readColumn(t, closed[0], "a"))
+
+	require.NoError(t, p.Close())
+	all := files(t, dir, "cpu.*.parquet")
+	require.Len(t, all, 2)
+	require.Equal(t, []interface{}{"x"}, readColumn(t, all[1], "b"))
+}
+
+func TestRotation(t *testing.T) {
+	p, dir := newParquet(t)
+	defer os.RemoveAll(dir)
+	p.RotationMaxSize = internal.Size{Size: 1}
+	p.RotationMaxArchives = 2
+
+	for i := 0; i < 4; i++ {
+		m := testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"a": float64(i)}, time.Unix(int64(i), 0))
+		require.NoError(t, p.Write([]telegraf.Metric{m}))
+	}
+	require.NoError(t, p.Close())
+
+	remaining := files(t, dir, "cpu.*.parquet")
+	require.Len(t, remaining, 2)
+	require.Equal(t, []interface{}{3.0}, readColumn(t, remaining[1], "a"))
+}
+
+func TestRotationSharedPrefix(t *testing.T) {
+	p, dir := newParquet(t)
+	defer os.RemoveAll(dir)
+	p.RotationMaxSize = internal.Size{Size: 1}
+	p.RotationMaxArchives = 1
+
+	for i := 0; i < 3; i++ {
+		for _, name := range []string{"cpu", "cpu.total", "cpu*"} {
+			m := testutil.MustMetric(name, map[string]string{}, map[string]interface{}{"a": float64(i)}, time.Unix(int64(i), 0))
+			require.NoError(t, p.Write([]telegraf.Metric{m}))
+		}
+	}
+	require.NoError(t, p.Close())
+
+	for _, pattern := range []string{"cpu.2*.parquet", "cpu.total.*.parquet", "cpu\\*.*.parquet"} {
+		remaining := files(t, dir, pattern)
+		require.Len(t, remaining, 1, pattern)
+		require.Equal(t, []interface{}{2.0}, readColumn(t, remaining[0], "a"))
+	}
+}
+
+func TestArchiveTime(t *testing.T) {
+	created, ok := archiveTime("cpu.2020-09-13-1600000000000000000.parquet", "cpu")
+	require.True(t, ok)
+	require.Equal(t, int64(1600000000000000000), created)
+
+	_, ok = archiveTime("cpu.total.2020-09-13-1600000000000000000.parquet", "cpu")
+	require.False(t, ok)
+	_, ok = archiveTime("cpu.2020-09-13-1600000000000000000.parquet.tmp", "cpu")
+	require.False(t, ok)
+}
+
+// warnLogger records the warnings logged.
+type warnLogger struct {
+	testutil.Logger
+	warnings []string
+}
+
+func (l *warnLogger) Warnf(format string, args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+
+func TestConnectReportsIncompleteFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	leftover := filepath.Join(dir, "cpu.2020-09-13-1600000000000000000.parquet.tmp")
+	require.NoError(t, ioutil.WriteFile(leftover, []byte("PAR1"), 0644))
+
+	log := &warnLogger{}
+	p := &Parquet{Directory: dir, RotationMaxArchives: -1, Log: log}
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Connect())
+	require.Len(t, log.warnings, 1)
+	require.Contains(t, log.warnings[0], leftover)
+	require.FileExists(t, leftover)
+}
+
+func TestInvalidCompression(t *testing.T) {
+	p := &Parquet{Directory: "/tmp", Compression: "lzo"}
+	require.Error(t, p.Init())
+}
@@ -0,0 +1,150 @@
+package postgresql
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func newTestPostgresql() *Postgresql {
+	return &Postgresql{
+		Schema:                  "public",
+		TagTableSuffix:          "_tag",
+		HypertableChunkInterval: "7 days",
+		Log:                     testutil.Logger{},
+		tables:                  make(map[string]map[string]string),
+		tagIDs:                  make(map[string]map[int64]bool),
+	}
+}
+
+// testMetrics returns metrics with fields in a fixed order, the order of the
+// columns depends on it.
+func testMetrics() []telegraf.Metric {
+	first := testutil.MustMetric(
+		"cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"usage": 42.5},
+		time.Unix(1600000000, 0),
+	)
+	first.AddField("count", uint64(3))
+	second := testutil.MustMetric(
+		"cpu",
+		map[string]string{"host": "b", "region": "eu"},
+		map[string]interface{}{"usage": 1.5},
+		time.Unix(1600000001, 0),
+	)
+	second.AddField("host", "clash")
+	return []telegraf.Metric{first, second}
+}
+
+func TestSchemaStatements(t *testing.T) {
+	p := newTestPostgresql()
+	p.CreateHypertables = true
+	tables := p.group(testMetrics())
+	require.Len(t, tables, 1)
+
+	columns, _ := p.wanted(tables[0])
+	spec := tableSpec{name: "cpu", columns: columns, hypertable: true}
+	require.Equal(t, []string{
+		`CREATE TABLE IF NOT EXISTS "public"."cpu" ("time" timestamp with time zone, "host" text, "region" text, "usage" double precision, "count" numeric)`,
+		`SELECT create_hypertable('"public"."cpu"', 'time', chunk_time_interval => INTERVAL '7 days', if_not_exists => TRUE)`,
+	}, p.schemaStatements(spec, map[string]string{}))
+
+	known := map[string]string{"time": typeTimestamp, "host": typeText, "usage": typeDouble}
+	require.Equal(t, []string{
+		`ALTER TABLE "public"."cpu" ADD COLUMN IF NOT EXISTS "region" text`,
+		`ALTER TABLE "public"."cpu" ADD COLUMN IF NOT EXISTS "count" numeric`,
+	}, p.schemaStatements(spec, known))
+}
+
+func TestTagsAsForeignKeys(t *testing.T) {
+	p := newTestPostgresql()
+	p.TagsAsForeignKeys = true
+	tables := p.group(testMetrics())
+	require.Len(t, tables, 1)
+	require.Equal(t, "cpu_tag", tables[0].tagTable)
+
+	columns, tagColumns := p.wanted(tables[0])
+	require.Equal(t, []column{
+		{name: "time", dataType: typeTimestamp, kind: kindTime},
+		{name: "tag_id", dataType: typeBigint, kind: kindTagID},
+		{name: "usage", dataType: typeDouble, kind: kindField},
+		{name: "count", dataType: typeNumeric, kind: kindField},
+		{name: "host", dataType: typeText, kind: kindField},
+	}, columns)
+	require.Equal(t, []column{
+		{name: "tag_id", dataType: typeBigint, kind: kindTagID},
+		{name: "host", dataType: typeText, kind: kindTag},
+		{name: "region", dataType: typeText, kind: kindTag},
+	}, tagColumns)
+
+	p.tables["cpu"] = map[string]string{"time": typeTimestamp, "tag_id": typeBigint, "usage": typeDouble, "count": typeNumeric, "host": typeText}
+	names, rows := p.rows(tables[0])
+	require.Equal(t, []string{"time", "tag_id", "usage", "count", "host"}, names)
+	metrics := testMetrics()
+	require.Equal(t, [][]interface{}{
+		{time.Unix(1600000000, 0), tagID(metrics[0]), 42.5, uint64(3), nil},
+		{time.Unix(1600000001, 0), tagID(metrics[1]), 1.5, nil, "clash"},
+	}, rows)
+}
+
+func TestTagID(t *testing.T) {
+	a := testutil.MustMetric("cpu", map[string]string{"a": "b", "c": "d"}, map[string]interface{}{"v": 1}, time.Unix(0, 0))
+	b := testutil.MustMetric("mem", map[string]string{"c": "d", "a": "b"}, map[string]interface{}{"v": 2}, time.Unix(1, 0))
+	c := testutil.MustMetric("cpu", map[string]string{"a": "bc", "": "d"}, map[string]interface{}{"v": 1}, time.Unix(0, 0))
+	require.Equal(t, tagID(a), tagID(b))
+	require.NotEqual(t, tagID(a), tagID(c))
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		dataType string
+		expected interface{}
+		ok       bool
+	}{
+		{int64(1), typeBigint, int64(1), true},
+		{uint64(1 << 63), typeBigint, nil, false},
+		{true, typeBigint, int64(1), true},
+		{int64(2), typeDouble, 2.0, true},
+		{"x", typeDouble, nil, false},
+		{1.5, typeText, "1.5", true},
+		{false, typeText, "false", true},
+		{uint64(1 << 63), typeNumeric, uint64(1 << 63), true},
+		{"true", typeBoolean, nil, false},
+		{"x", "jsonb", "x", true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %s", tt.value, tt.dataType), func(t *testing.T) {
+			actual, ok := convert(tt.value, tt.dataType)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestWriteIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	p := newTestPostgresql()
+	p.Address = fmt.Sprintf("host=%s user=postgres sslmode=disable", testutil.GetLocalHost())
+	p.TagsAsForeignKeys = true
+	p.Schema = "public"
+	p.TagTableSuffix = fmt.Sprintf("_tag_%d", time.Now().UnixNano())
+	require.NoError(t, p.Connect())
+	defer p.Close()
+
+	require.NoError(t, p.Write(testMetrics()))
+
+	var count int
+	err := p.service.DB.QueryRow(`SELECT count(*) FROM "public"."cpu` + p.TagTableSuffix + `"`).Scan(&count)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
@@ -0,0 +1,453 @@
+package redis
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/serializers"
+)
+
+const (
+	modeStream     = "stream"
+	modeTimeSeries = "timeseries"
+	modePublish    = "publish"
+)
+
+// Default keys of the modes, time series need one key per field and series.
+var defaultKeys = map[string]string{
+	modeStream:     "telegraf:{{ .Name }}",
+	modeTimeSeries: "telegraf:{{ .Name }}:{{ .FieldName }}{{ range .TagList }}:{{ .Key }}={{ .Value }}{{ end }}",
+	modePublish:    "telegraf:{{ .Name }}",
+}
+
+var sampleConfig = `
+  ## URL of the Redis server, either "tcp://host:port" or
+  ## "unix:///path/to/redis.sock".
+  server = "tcp://localhost:6379"
+
+  ## Password of the server, overrides the password of the URL.
+  # password = ""
+
+  ## Database selected after connecting.
+  # database = 0
+
+  ## Command used to write the metrics:
+  ##   stream     - add the serialized metrics to streams using XADD
+  ##   timeseries - add the fields to RedisTimeSeries keys using TS.MADD
+  ##   publish    - publish the serialized metrics to channels using PUBLISH
+  # mode = "stream"
+
+  ## Template of the key of the streams and time series or of the channel.
+  ## The template can use the measurement name {{ .Name }}, the host tag
+  ## {{ .Hostname }} and any tag with {{ .Tag "key" }}.  In timeseries mode
+  ## {{ .FieldName }} is the name of the field stored in the key.  The default
+  ## depends on the mode, time series keys include all tags by default:
+  ##   stream     - "telegraf:{{ .Name }}"
+  ##   timeseries - "telegraf:{{ .Name }}:{{ .FieldName }}{{ range .TagList }}:{{ .Key }}={{ .Value }}{{ end }}"
+  ##   publish    - "telegraf:{{ .Name }}"
+  # key = 'telegraf:{{ .Name }}'
+
+  ## Maximum length of the streams, older entries are trimmed.  When
+  ## approximate_max_len is true the streams are trimmed more efficiently by
+  ## keeping at least max_len entries.  0 disables trimming.
+  # max_len = 0
+  # approximate_max_len = false
+
+  ## Timeout for connecting, reading and writing.
+  # timeout = "5s"
+
+  ## Optional TLS Config
+  # tls_ca = "/etc/telegraf/ca.pem"
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+  ## Use TLS but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## Data format of the stream entries and published messages, not used in
+  ## timeseries mode.
+  ## Each data format has its own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
+  # data_format = "influx"
+`
+
+type Redis struct {
+	Server            string            `toml:"server"`
+	Password          string            `toml:"password"`
+	Database          int               `toml:"database"`
+	Mode              string            `toml:"mode"`
+	Key               string            `toml:"key"`
+	MaxLen            int64             `toml:"max_len"`
+	ApproximateMaxLen bool              `toml:"approximate_max_len"`
+	Timeout           internal.Duration `toml:"timeout"`
+	Log               telegraf.Logger   `toml:"-"`
+	tls.ClientConfig
+
+	key        *template.Template
+	serializer serializers.Serializer
+	client     *redis.Client
+
+	// series contains the time series keys known to exist.
+	series map[string]bool
+}
+
+// keyData is passed to the key template.
+type keyData struct {
+	telegraf.Metric
+	FieldName string
+}
+
+func (d keyData) Hostname() string {
+	return d.Tag("host")
+}
+
+func (d keyData) Tag(key string) string {
+	v, _ := d.GetTag(key)
+	return v
+}
+
+func (r *Redis) SampleConfig() string {
+	return sampleConfig
+}
+
+func (r *Redis) Description() string {
+	return "Send metrics to Redis streams, time series or channels"
+}
+
+func (r *Redis) SetSerializer(serializer serializers.Serializer) {
+	r.serializer = serializer
+}
+
+func (r *Redis) Init() error {
+	switch r.Mode {
+	case "":
+		r.Mode = modeStream
+	case modeStream, modeTimeSeries, modePublish:
+	default:
+		return fmt.Errorf("invalid mode %q", r.Mode)
+	}
+
+	if r.Key == "" {
+		r.Key = defaultKeys[r.Mode]
+	}
+	var err error
+	r.key, err = template.New("key").Parse(r.Key)
+	if err != nil {
+		return fmt.Errorf("invalid key template: %v", err)
+	}
+
+	if r.MaxLen < 0 {
+		return fmt.Errorf("max_len must not be negative")
+	}
+	return nil
+}
+
+func (r *Redis) Connect() error {
+	server := r.Server
+	if !strings.HasPrefix(server, "tcp://") && !strings.HasPrefix(server, "unix://") {
+		server = "tcp://" + server
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return fmt.Errorf("unable to parse address %q: %v", r.Server, err)
+	}
+
+	password := r.Password
+	if password == "" && u.User != nil {
+		password, _ = u.User.Password()
+	}
+
+	address := u.Host
+	if u.Scheme == "unix" {
+		address = u.Path
+	}
+
+	tlsConfig, err := r.ClientConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:         address,
+		Network:      u.Scheme,
+		Password:     password,
+		DB:           r.Database,
+		DialTimeout:  r.Timeout.Duration,
+		ReadTimeout:  r.Timeout.Duration,
+		WriteTimeout: r.Timeout.Duration,
+		PoolSize:     1,
+		TLSConfig:    tlsConfig,
+	})
+	if err := client.Ping().Err(); err != nil {
+		client.Close()
+		return fmt.Errorf("connecting to %q failed: %v", r.Server, err)
+	}
+
+	r.client = client
+	r.series = make(map[string]bool)
+	return nil
+}
+
+func (r *Redis) Close() error {
+	if r.client == nil {
+		return nil
+	}
+	err := r.client.Close()
+	r.client = nil
+	return err
+}
+
+func (r *Redis) Write(metrics []telegraf.Metric) error {
+	switch r.Mode {
+	case modeTimeSeries:
+		return r.writeTimeSeries(metrics)
+	default:
+		return r.writeSerialized(metrics)
+	}
+}
+
+// writeSerialized adds the serialized metrics to streams or publishes them
+// to channels.
+func (r *Redis) writeSerialized(metrics []telegraf.Metric) error {
+	pipe := r.client.Pipeline()
+	defer pipe.Close()
+
+	for _, metric := range metrics {
+		key, err := r.renderKey(keyData{Metric: metric})
+		if err != nil {
+			r.Log.Errorf("Could not generate key: %v", err)
+			continue
+		}
+
+		octets, err := r.serializer.Serialize(metric)
+		if err != nil {
+			r.Log.Errorf("Could not serialize metric: %v", err)
+			continue
+		}
+
+		if r.Mode == modePublish {
+			pipe.Publish(key, octets)
+			continue
+		}
+
+		args := &redis.XAddArgs{
+			Stream: key,
+			Values: map[string]interface{}{"metric": octets},
+		}
+		if r.ApproximateMaxLen {
+			args.MaxLenApprox = r.MaxLen
+		} else {
+			args.MaxLen = r.MaxLen
+		}
+		pipe.XAdd(args)
+	}
+
+	_, err := pipe.Exec()
+	return err
+}
+
+// writeTimeSeries adds the numeric fields to RedisTimeSeries keys.  Missing
+// keys are created first, using the tags as labels.
+func (r *Redis) writeTimeSeries(metrics []telegraf.Metric) error {
+	var samples []interface{}
+	args := make(map[string][]interface{})
+	for _, metric := range metrics {
+		timestamp := metric.Time().UnixNano() / int64(time.Millisecond)
+		for _, field := range metric.FieldList() {
+			value, ok := sampleValue(field.Value)
+			if !ok {
+				r.Log.Debugf("Skipping field %q of %q with unsupported type %T", field.Key, metric.Name(), field.Value)
+				continue
+			}
+
+			key, err := r.renderKey(keyData{Metric: metric, FieldName: field.Key})
+			if err != nil {
+				r.Log.Errorf("Could not generate key: %v", err)
+				continue
+			}
+			samples = append(samples, key, timestamp, value)
+
+			if args[key] == nil {
+				args[key] = createArgs(key, metric, field.Key)
+			}
+		}
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+
+	creates := make(map[string][]interface{})
+	for key, a := range args {
+		if !r.series[key] {
+			creates[key] = a
+		}
+	}
+	if err := r.createSeries(creates); err != nil {
+		return err
+	}
+
+	rejected, err := r.addSamples(samples)
+	if err != nil {
+		return err
+	}
+
+	// Samples are rejected individually.  Keys deleted since they were
+	// created are created again and their samples are added once more,
+	// samples older than the retention of their key are dropped.
+	var retry []interface{}
+	recreates := make(map[string][]interface{})
+	var failed []error
+	for _, rs := range rejected {
+		key := samples[3*rs.index].(string)
+		switch msg := rs.err.Error(); {
+		case strings.Contains(msg, "does not exist"):
+			delete(r.series, key)
+			recreates[key] = args[key]
+			retry = append(retry, samples[3*rs.index:3*rs.index+3]...)
+		case strings.Contains(msg, "older than retention"):
+			r.Log.Warnf("Dropping sample of %q older than the retention of the key", key)
+		default:
+			failed = append(failed, fmt.Errorf("%q: %v", key, rs.err))
+		}
+	}
+	if len(retry) > 0 {
+		if err := r.createSeries(recreates); err != nil {
+			return err
+		}
+		rejected, err := r.addSamples(retry)
+		if err != nil {
+			return err
+		}
+		for _, rs := range rejected {
+			failed = append(failed, fmt.Errorf("%q: %v", retry[3*rs.index], rs.err))
+		}
+	}
+
+	// The batch is written again, the keys are created with the duplicate
+	// policy LAST to overwrite the samples added already.
+	if len(failed) > 0 {
+		return fmt.Errorf("adding %d samples failed, first error: %v", len(failed), failed[0])
+	}
+	return nil
+}
+
+// rejectedSample is a sample rejected by TS.MADD, index is the position of
+// the sample in the command.
+type rejectedSample struct {
+	index int
+	err   error
+}
+
+// addSamples adds the key, timestamp and value triples using TS.MADD and
+// returns the samples rejected.
+func (r *Redis) addSamples(samples []interface{}) ([]rejectedSample, error) {
+	cmd := redis.NewSliceCmd(append([]interface{}{"TS.MADD"}, samples...)...)
+	if err := r.client.Process(cmd); err != nil {
+		return nil, fmt.Errorf("adding samples failed: %v", err)
+	}
+
+	var rejected []rejectedSample
+	results, _ := cmd.Result()
+	for i, result := range results {
+		if err, ok := result.(error); ok {
+			rejected = append(rejected, rejectedSample{index: i, err: err})
+		}
+	}
+	return rejected, nil
+}
+
+// createSeries creates the time series keys.  Keys created by other clients
+// in the meantime are fine.
+func (r *Redis) createSeries(creates map[string][]interface{}) error {
+	if len(creates) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(creates))
+	for key := range creates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pipe := r.client.Pipeline()
+	defer pipe.Close()
+	cmds := make([]*redis.Cmd, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, pipe.Do(creates[key]...))
+	}
+	pipe.Exec()
+
+	for i, cmd := range cmds {
+		if err := cmd.Err(); err != nil && !strings.Contains(err.Error(), "key already exists") {
+			return fmt.Errorf("creating time series %q failed: %v", keys[i], err)
+		}
+		r.series[keys[i]] = true
+	}
+	return nil
+}
+
+// createArgs returns the command creating the time series key of the field,
+// the labels are the tags, the measurement and the field name.  Samples of
+// an existing timestamp replace the previous value, so that writing a batch
+// again does not fail.
+func createArgs(key string, metric telegraf.Metric, field string) []interface{} {
+	args := []interface{}{"TS.CREATE", key, "DUPLICATE_POLICY", "LAST", "LABELS", "measurement", metric.Name(), "field", field}
+	for _, tag := range metric.TagList() {
+		if tag.Key == "measurement" || tag.Key == "field" {
+			continue
+		}
+		args = append(args, tag.Key, tag.Value)
+	}
+	return args
+}
+
+// sampleValue converts the field value to a float, time series can only
+// store numbers.
+func sampleValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+func (r *Redis) renderKey(data keyData) (string, error) {
+	var buf bytes.Buffer
+	if err := r.key.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	if buf.Len() == 0 {
+		return "", fmt.Errorf("empty key for metric %q", data.Name())
+	}
+	return buf.String(), nil
+}
+
+func init() {
+	outputs.Add("redis", func() telegraf.Output {
+		return &Redis{
+			Server:  "tcp://localhost:6379",
+			Mode:    modeStream,
+			Timeout: internal.Duration{Duration: 5 * time.Second},
+		}
+	})
+}
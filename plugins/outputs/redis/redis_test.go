@@ -0,0 +1,287 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+// fakeServer speaks enough of the Redis protocol to record the commands
+// sent by the plugin.
+type fakeServer struct {
+	sync.Mutex
+	listener net.Listener
+	commands [][]string
+	series   map[string]bool
+	// reject holds the errors of samples rejected by key
+	reject map[string]string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeServer{listener: listener, series: make(map[string]bool), reject: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		command, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, s.reply(command)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) reply(command []string) string {
+	s.Lock()
+	defer s.Unlock()
+
+	switch strings.ToUpper(command[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "PUBLISH":
+		s.commands = append(s.commands, command)
+		return ":1\r\n"
+	case "XADD":
+		s.commands = append(s.commands, command)
+		return "$3\r\n1-0\r\n"
+	case "TS.CREATE":
+		s.commands = append(s.commands, command)
+		if s.series[command[1]] {
+			return "-ERR TSDB: key already exists\r\n"
+		}
+		s.series[command[1]] = true
+		return "+OK\r\n"
+	case "TS.MADD":
+		s.commands = append(s.commands, command)
+		reply := fmt.Sprintf("*%d\r\n", (len(command)-1)/3)
+		for i := 1; i < len(command); i += 3 {
+			if !s.series[command[i]] {
+				reply += "-ERR TSDB: the key does not exist\r\n"
+				continue
+			}
+			if msg, ok := s.reject[command[i]]; ok {
+				reply += "-" + msg + "\r\n"
+				continue
+			}
+			reply += fmt.Sprintf(":%s\r\n", command[i+1])
+		}
+		return reply
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", command[0])
+	}
+}
+
+func (s *fakeServer) received() [][]string {
+	s.Lock()
+	defer s.Unlock()
+	commands := s.commands
+	s.commands = nil
+	return commands
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	command := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		command = append(command, string(buf[:size]))
+	}
+	return command, nil
+}
+
+func newTestRedis(server *fakeServer, mode string) *Redis {
+	r := &Redis{
+		Server: server.listener.Addr().String(),
+		Mode:   mode,
+		Log:    testutil.Logger{},
+	}
+	r.SetSerializer(influx.NewSerializer())
+	return r
+}
+
+func testMetrics() []telegraf.Metric {
+	return []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "a", "cpu": "cpu0"},
+			map[string]interface{}{"usage": 42.5, "state": "ok"},
+			time.Unix(1600000000, 0),
+		),
+		testutil.MustMetric(
+			"mem",
+			map[string]string{"host": "b"},
+			map[string]interface{}{"free": int64(1024)},
+			time.Unix(1600000001, 0),
+		),
+	}
+}
+
+func TestInit(t *testing.T) {
+	r := &Redis{}
+	require.NoError(t, r.Init())
+	require.Equal(t, modeStream, r.Mode)
+	require.Equal(t, defaultKeys[modeStream], r.Key)
+
+	r = &Redis{Mode: "list"}
+	require.Error(t, r.Init())
+
+	r = &Redis{Key: "{{ .Name"}
+	require.Error(t, r.Init())
+}
+
+func TestRenderKey(t *testing.T) {
+	metric := testMetrics()[0]
+
+	r := &Redis{Mode: modeTimeSeries}
+	require.NoError(t, r.Init())
+	key, err := r.renderKey(keyData{Metric: metric, FieldName: "usage"})
+	require.NoError(t, err)
+	require.Equal(t, "telegraf:cpu:usage:cpu=cpu0:host=a", key)
+
+	r = &Redis{Key: `{{ .Hostname }}{{ .Tag "missing" }}`}
+	require.NoError(t, r.Init())
+	key, err = r.renderKey(keyData{Metric: metric})
+	require.NoError(t, err)
+	require.Equal(t, "a", key)
+
+	r = &Redis{Key: `{{ .Tag "missing" }}`}
+	require.NoError(t, r.Init())
+	_, err = r.renderKey(keyData{Metric: metric})
+	require.Error(t, err)
+}
+
+func TestWriteStream(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.listener.Close()
+
+	r := newTestRedis(server, modeStream)
+	r.Key = `metrics:{{ .Hostname }}`
+	r.MaxLen = 1000
+	r.ApproximateMaxLen = true
+	require.NoError(t, r.Init())
+	require.NoError(t, r.Connect())
+	defer r.Close()
+
+	require.NoError(t, r.Write(testMetrics()))
+	require.Equal(t, [][]string{
+		{"xadd", "metrics:a", "maxlen", "~", "1000", "*", "metric", "cpu,cpu=cpu0,host=a usage=42.5,state=\"ok\" 1600000000000000000\n"},
+		{"xadd", "metrics:b", "maxlen", "~", "1000", "*", "metric", "mem,host=b free=1024i 1600000001000000000\n"},
+	}, server.received())
+}
+
+func TestWritePublish(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.listener.Close()
+
+	r := newTestRedis(server, modePublish)
+	require.NoError(t, r.Init())
+	require.NoError(t, r.Connect())
+	defer r.Close()
+
+	require.NoError(t, r.Write(testMetrics()))
+	require.Equal(t, [][]string{
+		{"publish", "telegraf:cpu", "cpu,cpu=cpu0,host=a usage=42.5,state=\"ok\" 1600000000000000000\n"},
+		{"publish", "telegraf:mem", "mem,host=b free=1024i 1600000001000000000\n"},
+	}, server.received())
+}
+
+func TestWriteTimeSeries(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.listener.Close()
+	server.series["telegraf:mem:free:host=b"] = true
+
+	r := newTestRedis(server, modeTimeSeries)
+	require.NoError(t, r.Init())
+	require.NoError(t, r.Connect())
+	defer r.Close()
+
+	// The string field is skipped, keys created by others are accepted.
+	require.NoError(t, r.Write(testMetrics()))
+	require.Equal(t, [][]string{
+		{"TS.CREATE", "telegraf:cpu:usage:cpu=cpu0:host=a", "DUPLICATE_POLICY", "LAST", "LABELS", "measurement", "cpu", "field", "usage", "cpu", "cpu0", "host", "a"},
+		{"TS.CREATE", "telegraf:mem:free:host=b", "DUPLICATE_POLICY", "LAST", "LABELS", "measurement", "mem", "field", "free", "host", "b"},
+		{"TS.MADD", "telegraf:cpu:usage:cpu=cpu0:host=a", "1600000000000", "42.5", "telegraf:mem:free:host=b", "1600000001000", "1024"},
+	}, server.received())
+
+	// Known keys are not created again, deleted keys are created again and
+	// their samples added once more.
+	delete(server.series, "telegraf:mem:free:host=b")
+	require.NoError(t, r.Write(testMetrics()))
+	require.Equal(t, [][]string{
+		{"TS.MADD", "telegraf:cpu:usage:cpu=cpu0:host=a", "1600000000000", "42.5", "telegraf:mem:free:host=b", "1600000001000", "1024"},
+		{"TS.CREATE", "telegraf:mem:free:host=b", "DUPLICATE_POLICY", "LAST", "LABELS", "measurement", "mem", "field", "free", "host", "b"},
+		{"TS.MADD", "telegraf:mem:free:host=b", "1600000001000", "1024"},
+	}, server.received())
+
+	// Samples older than the retention are dropped, other rejections fail
+	// the write.
+	server.reject["telegraf:mem:free:host=b"] = "ERR TSDB: Timestamp is older than retention"
+	require.NoError(t, r.Write(testMetrics()))
+	server.reject["telegraf:mem:free:host=b"] = "ERR TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode"
+	require.Error(t, r.Write(testMetrics()))
+}
+
+func TestWriteIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	r := &Redis{
+		Server: fmt.Sprintf("tcp://%s:6379", testutil.GetLocalHost()),
+		Log:    testutil.Logger{},
+	}
+	r.SetSerializer(influx.NewSerializer())
+	require.NoError(t, r.Init())
+	require.NoError(t, r.Connect())
+	defer r.Close()
+
+	require.NoError(t, r.Write(testMetrics()))
+}
@@ -0,0 +1,548 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	gosql "database/sql"
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs"
+
+	_ "github.com/go-sql-driver/mysql" // register the MySQL driver
+	_ "github.com/jackc/pgx/stdlib"    // register the PostgreSQL driver
+)
+
+var sampleConfig = `
+  ## Database driver
+  ## Valid options: mysql (MySQL), pgx (PostgreSQL), sqlite (SQLite)
+  ## SQLite is only available on Linux for 386, amd64, arm and arm64.
+  driver = "sqlite"
+
+  ## Data source name
+  ## The format of the data source name is different for each database driver.
+  ## See the plugin readme for details.
+  data_source_name = "/var/lib/telegraf/metrics.db"
+
+  ## Timeout for the whole write including schema changes.
+  # timeout = "5s"
+
+  ## Name of the timestamp column
+  # timestamp_column = "timestamp"
+
+  ## Templates for the table and column names.  The table name template is
+  ## executed for every metric, "{{.Name}}" is the measurement name and
+  ## '{{.Tag "key"}}' the value of a tag.  In the column name template
+  ## "{{.Name}}" is the tag or field key and "{{.Kind}}" is "tag" or "field".
+  ## The functions lower, upper and replace are available.
+  # table_name_template = "{{.Name}}"
+  # column_name_template = "{{.Name}}"
+
+  ## Statements used to create tables and add columns.  {TABLE} is replaced
+  ## by the quoted table name, {COLUMNS} by the list of quoted column names
+  ## with types and {COLUMN} by a single quoted column name with type.
+  # table_create_template = "CREATE TABLE {TABLE}({COLUMNS})"
+  # table_add_column_template = "ALTER TABLE {TABLE} ADD COLUMN {COLUMN}"
+
+  ## Maximum number of open and idle connections and the maximum time a
+  ## connection may be reused.  0 means unlimited.
+  # connection_max_open = 0
+  # connection_max_idle = 2
+  # connection_max_lifetime = "0s"
+
+  ## SQL types used for the columns, the defaults depend on the driver.
+  # [outputs.sql.convert]
+  #   integer   = "BIGINT"
+  #   unsigned  = "BIGINT UNSIGNED"
+  #   real      = "DOUBLE"
+  #   text      = "TEXT"
+  #   bool      = "BOOLEAN"
+  #   timestamp = "DATETIME(6)"
+`
+
+// ConvertStruct holds the SQL types used for the columns.
+type ConvertStruct struct {
+	Integer   string `toml:"integer"`
+	Unsigned  string `toml:"unsigned"`
+	Real      string `toml:"real"`
+	Text      string `toml:"text"`
+	Bool      string `toml:"bool"`
+	Timestamp string `toml:"timestamp"`
+}
+
+type dialect struct {
+	quote    string
+	numbered bool
+	types    ConvertStruct
+
+	// query returning the column names of the table given as parameter
+	columnsQuery string
+}
+
+var dialects = map[string]dialect{
+	"mysql": {
+		quote:        "`",
+		columnsQuery: "SELECT column_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ?",
+		types: ConvertStruct{
+			Integer:   "BIGINT",
+			Unsigned:  "BIGINT UNSIGNED",
+			Real:      "DOUBLE",
+			Text:      "TEXT",
+			Bool:      "BOOLEAN",
+			Timestamp: "DATETIME(6)",
+		},
+	},
+	"pgx": {
+		quote:        `"`,
+		numbered:     true,
+		columnsQuery: "SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1",
+		types: ConvertStruct{
+			Integer:   "BIGINT",
+			Unsigned:  "NUMERIC(20)",
+			Real:      "DOUBLE PRECISION",
+			Text:      "TEXT",
+			Bool:      "BOOLEAN",
+			Timestamp: "TIMESTAMP",
+		},
+	},
+	"sqlite": {
+		quote:        `"`,
+		columnsQuery: "SELECT name FROM pragma_table_info(?)",
+		types: ConvertStruct{
+			Integer:   "INTEGER",
+			Unsigned:  "INTEGER",
+			Real:      "REAL",
+			Text:      "TEXT",
+			Bool:      "BOOLEAN",
+			Timestamp: "TIMESTAMP",
+		},
+	},
+}
+
+var nameFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	// the string is the last argument to allow using replace in pipelines
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+}
+
+type SQL struct {
+	Driver                 string            `toml:"driver"`
+	DataSourceName         string            `toml:"data_source_name"`
+	Timeout                internal.Duration `toml:"timeout"`
+	TimestampColumn        string            `toml:"timestamp_column"`
+	TableNameTemplate      string            `toml:"table_name_template"`
+	ColumnNameTemplate     string            `toml:"column_name_template"`
+	TableCreateTemplate    string            `toml:"table_create_template"`
+	TableAddColumnTemplate string            `toml:"table_add_column_template"`
+	ConnectionMaxOpen      int               `toml:"connection_max_open"`
+	ConnectionMaxIdle      int               `toml:"connection_max_idle"`
+	ConnectionMaxLifetime  internal.Duration `toml:"connection_max_lifetime"`
+	Convert                ConvertStruct     `toml:"convert"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	db         *gosql.DB
+	dialect    dialect
+	tableName  *template.Template
+	columnName *template.Template
+
+	// columns known to exist in every table
+	tables map[string]map[string]bool
+	// columns of which a colliding tag or field was dropped, to warn only
+	// once per column
+	collisions map[string]bool
+}
+
+// column is a single column of a table with its SQL type.
+type column struct {
+	name    string
+	sqlType string
+}
+
+// table collects the rows written to a table in a single write.
+type table struct {
+	name    string
+	columns []column
+	seen    map[string]bool
+	rows    []map[string]interface{}
+}
+
+// tableNameData is passed to the table name template.
+type tableNameData struct {
+	telegraf.Metric
+}
+
+// Tag returns the value of the tag or an empty string if it is missing.
+func (d tableNameData) Tag(key string) string {
+	v, _ := d.GetTag(key)
+	return v
+}
+
+// columnNameData is passed to the column name template.
+type columnNameData struct {
+	Name string
+	Kind string
+}
+
+func (*SQL) Description() string {
+	return "Save metrics to an SQL database"
+}
+
+func (*SQL) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *SQL) Init() error {
+	d, ok := dialects[s.Driver]
+	if !ok {
+		return fmt.Errorf("unsupported driver %q", s.Driver)
+	}
+	if !driverRegistered(s.Driver) {
+		return fmt.Errorf("driver %q is not available on %s/%s", s.Driver, runtime.GOOS, runtime.GOARCH)
+	}
+	s.dialect = d
+	mergeTypes(&s.dialect.types, s.Convert)
+
+	var err error
+	s.tableName, err = template.New("table_name_template").Funcs(nameFuncs).Parse(s.TableNameTemplate)
+	if err != nil {
+		return fmt.Errorf("parsing table name template failed: %v", err)
+	}
+	s.columnName, err = template.New("column_name_template").Funcs(nameFuncs).Parse(s.ColumnNameTemplate)
+	if err != nil {
+		return fmt.Errorf("parsing column name template failed: %v", err)
+	}
+
+	s.tables = make(map[string]map[string]bool)
+	s.collisions = make(map[string]bool)
+	return nil
+}
+
+// driverRegistered returns true if the driver is compiled in, some drivers
+// are not available on every platform.
+func driverRegistered(name string) bool {
+	for _, d := range gosql.Drivers() {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeTypes overrides the types of the dialect with the configured ones.
+func mergeTypes(types *ConvertStruct, convert ConvertStruct) {
+	override := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	override(&types.Integer, convert.Integer)
+	override(&types.Unsigned, convert.Unsigned)
+	override(&types.Real, convert.Real)
+	override(&types.Text, convert.Text)
+	override(&types.Bool, convert.Bool)
+	override(&types.Timestamp, convert.Timestamp)
+}
+
+func (s *SQL) Connect() error {
+	db, err := gosql.Open(s.Driver, s.DataSourceName)
+	if err != nil {
+		return err
+	}
+
+	db.SetMaxOpenConns(s.ConnectionMaxOpen)
+	db.SetMaxIdleConns(s.ConnectionMaxIdle)
+	db.SetConnMaxLifetime(s.ConnectionMaxLifetime.Duration)
+
+	s.db = db
+	return nil
+}
+
+func (s *SQL) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *SQL) Write(metrics []telegraf.Metric) error {
+	tables, err := s.group(metrics)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout.Duration)
+	defer cancel()
+
+	err = s.write(ctx, tables)
+	if err != nil {
+		// The schema might have been changed by someone else, look it up
+		// again on the next write.
+		s.tables = make(map[string]map[string]bool)
+	}
+	return err
+}
+
+func (s *SQL) write(ctx context.Context, tables []*table) error {
+	// Schema changes are done outside of the transaction as some databases
+	// implicitly commit on DDL statements and others abort the transaction
+	// on failing statements.
+	for _, t := range tables {
+		if err := s.ensureTable(ctx, t); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tables {
+		if err := s.insert(ctx, tx, t); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// group sorts the metrics into the tables they are written to, keeping the
+// order in which the tables first occur.
+func (s *SQL) group(metrics []telegraf.Metric) ([]*table, error) {
+	var tables []*table
+	byName := make(map[string]*table)
+	for _, m := range metrics {
+		name, err := s.executeName(s.tableName, tableNameData{m})
+		if err != nil {
+			return nil, fmt.Errorf("executing table name template failed: %v", err)
+		}
+
+		t, ok := byName[name]
+		if !ok {
+			t = &table{name: name, seen: make(map[string]bool)}
+			t.add(s.TimestampColumn, s.dialect.types.Timestamp)
+			byName[name] = t
+			tables = append(tables, t)
+		}
+
+		row := map[string]interface{}{s.TimestampColumn: m.Time().UTC()}
+		for _, tag := range m.TagList() {
+			name, err := s.executeName(s.columnName, columnNameData{Name: tag.Key, Kind: "tag"})
+			if err != nil {
+				return nil, fmt.Errorf("executing column name template failed: %v", err)
+			}
+			if _, ok := row[name]; ok {
+				s.warnCollision(t.name, name, "tag", tag.Key)
+				continue
+			}
+			t.add(name, s.dialect.types.Text)
+			row[name] = tag.Value
+		}
+		for _, field := range m.FieldList() {
+			name, err := s.executeName(s.columnName, columnNameData{Name: field.Key, Kind: "field"})
+			if err != nil {
+				return nil, fmt.Errorf("executing column name template failed: %v", err)
+			}
+			if _, ok := row[name]; ok {
+				s.warnCollision(t.name, name, "field", field.Key)
+				continue
+			}
+			sqlType, value := s.convert(field.Value)
+			if sqlType == "" {
+				s.Log.Debugf("Skipping field %q of unsupported type %T", field.Key, field.Value)
+				continue
+			}
+			t.add(name, sqlType)
+			row[name] = value
+		}
+		t.rows = append(t.rows, row)
+	}
+	return tables, nil
+}
+
+func (t *table) add(name, sqlType string) {
+	if !t.seen[name] {
+		t.seen[name] = true
+		t.columns = append(t.columns, column{name: name, sqlType: sqlType})
+	}
+}
+
+func (s *SQL) executeName(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// warnCollision logs a tag or field dropped because its column is already
+// used by the timestamp or another tag or field of the metric.
+func (s *SQL) warnCollision(table, column, kind, key string) {
+	id := table + "\x00" + column
+	if s.collisions[id] {
+		return
+	}
+	s.collisions[id] = true
+	s.Log.Warnf("Dropping %s %q, column %q of table %q is already used; use column_name_template to keep them apart",
+		kind, key, column, table)
+}
+
+// convert returns the SQL type and the value passed to the driver for a
+// field value.
+func (s *SQL) convert(v interface{}) (string, interface{}) {
+	switch v := v.(type) {
+	case int64:
+		return s.dialect.types.Integer, v
+	case uint64:
+		// The database/sql package does not accept unsigned integers with
+		// the high bit set, pass them as decimal string instead.
+		if v > math.MaxInt64 {
+			return s.dialect.types.Unsigned, strconv.FormatUint(v, 10)
+		}
+		return s.dialect.types.Unsigned, int64(v)
+	case float64:
+		return s.dialect.types.Real, v
+	case string:
+		return s.dialect.types.Text, v
+	case bool:
+		return s.dialect.types.Bool, v
+	default:
+		return "", nil
+	}
+}
+
+// ensureTable creates the table or adds the missing columns.
+func (s *SQL) ensureTable(ctx context.Context, t *table) error {
+	known, ok := s.tables[t.name]
+	if !ok {
+		var err error
+		known, err = s.describe(ctx, t.name)
+		if err != nil {
+			return fmt.Errorf("looking up columns of table %q failed: %v", t.name, err)
+		}
+		if len(known) == 0 {
+			if _, err := s.db.ExecContext(ctx, s.createStatement(t)); err != nil {
+				return fmt.Errorf("creating table %q failed: %v", t.name, err)
+			}
+			for _, c := range t.columns {
+				known[c.name] = true
+			}
+		}
+		s.tables[t.name] = known
+	}
+
+	for _, c := range t.columns {
+		if known[c.name] {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, s.addColumnStatement(t.name, c)); err != nil {
+			return fmt.Errorf("adding column %q to table %q failed: %v", c.name, t.name, err)
+		}
+		known[c.name] = true
+	}
+	return nil
+}
+
+// describe returns the columns of a table, the result is empty if the table
+// does not exist.
+func (s *SQL) describe(ctx context.Context, name string) (map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.columnsQuery, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	known := make(map[string]bool)
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		known[column] = true
+	}
+	// Some drivers report empty results as error.
+	if err := rows.Err(); err != nil && err != gosql.ErrNoRows {
+		return nil, err
+	}
+	return known, nil
+}
+
+func (s *SQL) createStatement(t *table) string {
+	columns := make([]string, 0, len(t.columns))
+	for _, c := range t.columns {
+		columns = append(columns, s.quote(c.name)+" "+c.sqlType)
+	}
+	query := strings.Replace(s.TableCreateTemplate, "{TABLE}", s.quote(t.name), -1)
+	return strings.Replace(query, "{COLUMNS}", strings.Join(columns, ","), -1)
+}
+
+func (s *SQL) addColumnStatement(name string, c column) string {
+	query := strings.Replace(s.TableAddColumnTemplate, "{TABLE}", s.quote(name), -1)
+	return strings.Replace(query, "{COLUMN}", s.quote(c.name)+" "+c.sqlType, -1)
+}
+
+func (s *SQL) insertStatement(t *table) string {
+	columns := make([]string, 0, len(t.columns))
+	placeholders := make([]string, 0, len(t.columns))
+	for i, c := range t.columns {
+		columns = append(columns, s.quote(c.name))
+		if s.dialect.numbered {
+			placeholders = append(placeholders, "$"+strconv.Itoa(i+1))
+		} else {
+			placeholders = append(placeholders, "?")
+		}
+	}
+	return "INSERT INTO " + s.quote(t.name) +
+		"(" + strings.Join(columns, ",") + ") VALUES(" + strings.Join(placeholders, ",") + ")"
+}
+
+func (s *SQL) insert(ctx context.Context, tx *gosql.Tx, t *table) error {
+	stmt, err := tx.PrepareContext(ctx, s.insertStatement(t))
+	if err != nil {
+		return fmt.Errorf("preparing insert into table %q failed: %v", t.name, err)
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, len(t.columns))
+	for _, row := range t.rows {
+		for i, c := range t.columns {
+			args[i] = row[c.name]
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("inserting into table %q failed: %v", t.name, err)
+		}
+	}
+	return nil
+}
+
+// quote quotes an identifier, quote characters inside of the identifier are
+// doubled.
+func (s *SQL) quote(name string) string {
+	q := s.dialect.quote
+	return q + strings.Replace(name, q, q+q, -1) + q
+}
+
+func init() {
+	outputs.Add("sql", func() telegraf.Output {
+		return &SQL{
+			Timeout:                internal.Duration{Duration: 5 * time.Second},
+			TimestampColumn:        "timestamp",
+			TableNameTemplate:      "{{.Name}}",
+			ColumnNameTemplate:     "{{.Name}}",
+			TableCreateTemplate:    "CREATE TABLE {TABLE}({COLUMNS})",
+			TableAddColumnTemplate: "ALTER TABLE {TABLE} ADD COLUMN {COLUMN}",
+			ConnectionMaxIdle:      2,
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package sql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func newTestSQL(t *testing.T, driver string) *SQL {
+	t.Helper()
+	s := &SQL{
+		Driver:                 driver,
+		TimestampColumn:        "timestamp",
+		TableNameTemplate:      "{{.Name}}",
+		ColumnNameTemplate:     "{{.Name}}",
+		TableCreateTemplate:    "CREATE TABLE {TABLE}({COLUMNS})",
+		TableAddColumnTemplate: "ALTER TABLE {TABLE} ADD COLUMN {COLUMN}",
+		Log:                    testutil.Logger{},
+	}
+	require.NoError(t, s.Init())
+	return s
+}
+
+func TestStatements(t *testing.T) {
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"usage": 42.0, "count": uint64(3)},
+		time.Unix(1600000000, 0),
+	)
+
+	tests := []struct {
+		driver string
+		create string
+		add    string
+		insert string
+	}{
+		{
+			driver: "mysql",
+			create: "CREATE TABLE `cpu`(`timestamp` DATETIME(6),`host` TEXT,`usage` DOUBLE,`count` BIGINT UNSIGNED)",
+			add:    "ALTER TABLE `cpu` ADD COLUMN `usage` DOUBLE",
+			insert: "INSERT INTO `cpu`(`timestamp`,`host`,`usage`,`count`) VALUES(?,?,?,?)",
+		},
+		{
+			driver: "pgx",
+			create: `CREATE TABLE "cpu"("timestamp" TIMESTAMP,"host" TEXT,"usage" DOUBLE PRECISION,"count" NUMERIC(20))`,
+			add:    `ALTER TABLE "cpu" ADD COLUMN "usage" DOUBLE PRECISION`,
+			insert: `INSERT INTO "cpu"("timestamp","host","usage","count") VALUES($1,$2,$3,$4)`,
+		},
+		{
+			driver: "sqlite",
+			create: `CREATE TABLE "cpu"("timestamp" TIMESTAMP,"host" TEXT,"usage" REAL,"count" INTEGER)`,
+			add:    `ALTER TABLE "cpu" ADD COLUMN "usage" REAL`,
+			insert: `INSERT INTO "cpu"("timestamp","host","usage","count") VALUES(?,?,?,?)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			if !driverRegistered(tt.driver) {
+				t.Skipf("driver %q not available on this platform", tt.driver)
+			}
+			s := newTestSQL(t, tt.driver)
+			tables, err := s.group([]telegraf.Metric{m})
+			require.NoError(t, err)
+			require.Len(t, tables, 1)
+
+			require.Equal(t, tt.create, s.createStatement(tables[0]))
+			require.Equal(t, tt.add, s.addColumnStatement("cpu", tables[0].columns[2]))
+			require.Equal(t, tt.insert, s.insertStatement(tables[0]))
+		})
+	}
+}
+
+func TestNameTemplatesAndConvert(t *testing.T) {
+	s := newTestSQL(t, "pgx")
+	s.TableNameTemplate = `{{.Name}}_{{.Tag "region"}}`
+	s.ColumnNameTemplate = `{{.Kind}}_{{.Name | replace "-" "_"}}`
+	s.Convert = ConvertStruct{Real: "REAL", Timestamp: "TIMESTAMPTZ"}
+	require.NoError(t, s.Init())
+
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{"region": "eu", "host-name": `we"ird`},
+		map[string]interface{}{"usage-idle": 42.0, "big": uint64(1 << 63)},
+		time.Unix(1600000000, 0),
+	)
+	tables, err := s.group([]telegraf.Metric{m})
+	require.NoError(t, err)
+	require.Len(t, tables, 1)
+	require.Equal(t, "cpu_eu", tables[0].name)
+	require.Equal(t,
+		`CREATE TABLE "cpu_eu"("timestamp" TIMESTAMPTZ,"tag_host_name" TEXT,"tag_region" TEXT,"field_usage_idle" REAL,"field_big" NUMERIC(20))`,
+		s.createStatement(tables[0]))
+	require.Equal(t, map[string]interface{}{
+		"timestamp":        time.Unix(1600000000, 0).UTC(),
+		"tag_host_name":    `we"ird`,
+		"tag_region":       "eu",
+		"field_usage_idle": 42.0,
+		"field_big":        "9223372036854775808",
+	}, tables[0].rows[0])
+}
+
+func TestColumnCollision(t *testing.T) {
+	s := newTestSQL(t, "pgx")
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{"usage": "high"},
+		map[string]interface{}{"usage": 42.0, "timestamp": int64(1)},
+		time.Unix(1600000000, 0),
+	)
+	tables, err := s.group([]telegraf.Metric{m, m})
+	require.NoError(t, err)
+	require.Len(t, tables, 1)
+	require.Equal(t, map[string]interface{}{
+		"timestamp": time.Unix(1600000000, 0).UTC(),
+		"usage":     "high",
+	}, tables[0].rows[0])
+	require.Len(t, s.collisions, 2)
+}
+
+func TestQuote(t *testing.T) {
+	require.Equal(t, `"a""b"`, newTestSQL(t, "pgx").quote(`a"b`))
+	require.Equal(t, "`a``b`", newTestSQL(t, "mysql").quote("a`b"))
+}
+
+func TestInitInvalidDriver(t *testing.T) {
+	s := &SQL{Driver: "oracle"}
+	require.Error(t, s.Init())
+}
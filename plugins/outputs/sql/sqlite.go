@@ -0,0 +1,10 @@
+// +build linux
+// +build 386 amd64 arm arm64
+
+package sql
+
+// The SQLite driver is only available on the platforms supported by
+// modernc.org/sqlite.
+import (
+	_ "modernc.org/sqlite" // register the SQLite driver
+)
@@ -0,0 +1,97 @@
+// +build linux
+// +build 386 amd64 arm arm64
+
+package sql
+
+import (
+	gosql "database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestSqlite(t *testing.T) {
+	dbfile := filepath.Join(t.TempDir(), "metrics.db")
+
+	s := newTestSQL(t, "sqlite")
+	s.DataSourceName = dbfile
+	s.Timeout.Duration = 5 * time.Second
+	require.NoError(t, s.Connect())
+	defer s.Close()
+
+	// The first write creates the tables, the second adds a column.
+	require.NoError(t, s.Write([]telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": 42.5},
+			time.Unix(1600000000, 0),
+		),
+		testutil.MustMetric(
+			"mem",
+			map[string]string{},
+			map[string]interface{}{"used": int64(1024), "ok": true},
+			time.Unix(1600000000, 0),
+		),
+	}))
+	require.NoError(t, s.Write([]telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "b", "region": "eu"},
+			map[string]interface{}{"usage": 1.5},
+			time.Unix(1600000001, 0),
+		),
+	}))
+
+	// A new instance must pick up the existing schema.
+	s2 := newTestSQL(t, "sqlite")
+	s2.DataSourceName = dbfile
+	s2.Timeout.Duration = 5 * time.Second
+	require.NoError(t, s2.Connect())
+	defer s2.Close()
+	require.NoError(t, s2.Write([]telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "c"},
+			map[string]interface{}{"usage": 3.0},
+			time.Unix(1600000002, 0),
+		),
+	}))
+
+	db, err := gosql.Open("sqlite", dbfile)
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT "host", "region", "usage" FROM "cpu" ORDER BY "host"`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	type row struct {
+		host   string
+		region gosql.NullString
+		usage  float64
+	}
+	var actual []row
+	for rows.Next() {
+		var r row
+		require.NoError(t, rows.Scan(&r.host, &r.region, &r.usage))
+		actual = append(actual, r)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []row{
+		{host: "a", usage: 42.5},
+		{host: "b", region: gosql.NullString{String: "eu", Valid: true}, usage: 1.5},
+		{host: "c", usage: 3.0},
+	}, actual)
+
+	var used int64
+	var ok bool
+	require.NoError(t, db.QueryRow(`SELECT "used", "ok" FROM "mem"`).Scan(&used, &ok))
+	require.Equal(t, int64(1024), used)
+	require.True(t, ok)
+}
@@ -0,0 +1,375 @@
+package websocket
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/serializers"
+)
+
+const (
+	defaultConnectTimeout      = 30 * time.Second
+	defaultWriteTimeout        = 30 * time.Second
+	defaultAckTimeout          = 10 * time.Second
+	defaultMaxReconnectBackoff = time.Minute
+	minReconnectBackoff        = time.Second
+)
+
+var sampleConfig = `
+  ## URL is the address to send metrics to. Make sure ws or wss scheme is used.
+  url = "ws://127.0.0.1:8080/telegraf"
+
+  ## Timeouts (make sure read_timeout is larger than server ping interval or set to zero).
+  # connect_timeout = "30s"
+  # write_timeout = "30s"
+  # read_timeout = "0s"
+
+  ## Interval of the pings sent to the server, the connection is considered
+  ## lost if no pong arrives within read_timeout.  0 disables the pings.
+  # ping_interval = "0s"
+
+  ## Maximum delay between reconnection attempts.  After a failed attempt
+  ## the delay starts at one second and is doubled up to this value.
+  # max_reconnect_backoff = "1m"
+
+  ## Optionally turn on using text data frames (binary by default).
+  # use_text_frames = false
+
+  ## If true, wait for a message from the server after each write.  The write
+  ## fails if no message arrives within ack_timeout or if ack_message is set
+  ## and the message differs from it.
+  # wait_for_ack = false
+  # ack_timeout = "10s"
+  # ack_message = ""
+
+  ## Optional TLS Config
+  # tls_ca = "/etc/telegraf/ca.pem"
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+  ## Use TLS but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## Data format to output.
+  ## Each data format has it's own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
+  # data_format = "influx"
+
+  ## Additional HTTP Upgrade headers
+  # [outputs.websocket.headers]
+  #   Authorization = "Bearer <TOKEN>"
+`
+
+// WebSocket can output to WebSocket endpoint.
+type WebSocket struct {
+	URL                 string            `toml:"url"`
+	ConnectTimeout      internal.Duration `toml:"connect_timeout"`
+	WriteTimeout        internal.Duration `toml:"write_timeout"`
+	ReadTimeout         internal.Duration `toml:"read_timeout"`
+	PingInterval        internal.Duration `toml:"ping_interval"`
+	MaxReconnectBackoff internal.Duration `toml:"max_reconnect_backoff"`
+	Headers             map[string]string `toml:"headers"`
+	UseTextFrames       bool              `toml:"use_text_frames"`
+	WaitForAck          bool              `toml:"wait_for_ack"`
+	AckTimeout          internal.Duration `toml:"ack_timeout"`
+	AckMessage          string            `toml:"ack_message"`
+	Log                 telegraf.Logger   `toml:"-"`
+	tls.ClientConfig
+
+	serializer serializers.Serializer
+	conn       *connection
+
+	backoff   time.Duration
+	nextRetry time.Time
+}
+
+// connection is a single established connection.  The reader processes the
+// control frames and forwards the messages of the server as acks.
+type connection struct {
+	conn *ws.Conn
+	acks chan []byte
+	done chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	// err is the reason the connection was lost, valid once done is closed.
+	err error
+}
+
+// SetSerializer implements serializers.SerializerOutput.
+func (w *WebSocket) SetSerializer(serializer serializers.Serializer) {
+	w.serializer = serializer
+}
+
+// Description of plugin.
+func (w *WebSocket) Description() string {
+	return "Generic WebSocket output writer."
+}
+
+// SampleConfig returns plugin config sample.
+func (w *WebSocket) SampleConfig() string {
+	return sampleConfig
+}
+
+// Init the output plugin.
+func (w *WebSocket) Init() error {
+	if parsedURL, err := url.Parse(w.URL); err != nil || (parsedURL.Scheme != "ws" && parsedURL.Scheme != "wss") {
+		return fmt.Errorf("invalid websocket URL %q", w.URL)
+	}
+	if w.MaxReconnectBackoff.Duration < minReconnectBackoff {
+		w.MaxReconnectBackoff.Duration = minReconnectBackoff
+	}
+	return nil
+}
+
+// Connect to the output endpoint.
+func (w *WebSocket) Connect() error {
+	tlsCfg, err := w.ClientConfig.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("error creating TLS config: %v", err)
+	}
+
+	dialer := &ws.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: w.ConnectTimeout.Duration,
+		TLSClientConfig:  tlsCfg,
+	}
+
+	headers := http.Header{}
+	for k, v := range w.Headers {
+		headers.Set(k, v)
+	}
+
+	conn, resp, err := dialer.Dial(w.URL, headers)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("error dial: %v (status %s)", err, resp.Status)
+		}
+		return fmt.Errorf("error dial: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return fmt.Errorf("wrong status code while connecting to server: %d", resp.StatusCode)
+	}
+
+	w.conn = w.start(conn)
+	return nil
+}
+
+// start runs the reader and, if enabled, the pings of the connection.
+func (w *WebSocket) start(conn *ws.Conn) *connection {
+	c := &connection{
+		conn: conn,
+		acks: make(chan []byte, 1),
+		done: make(chan struct{}),
+		stop: make(chan struct{}),
+	}
+
+	if w.ReadTimeout.Duration > 0 {
+		conn.SetReadDeadline(time.Now().Add(w.ReadTimeout.Duration))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(w.ReadTimeout.Duration))
+		})
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer close(c.done)
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				c.err = err
+				return
+			}
+			if w.ReadTimeout.Duration > 0 {
+				conn.SetReadDeadline(time.Now().Add(w.ReadTimeout.Duration))
+			}
+			if !w.WaitForAck {
+				continue
+			}
+			select {
+			case c.acks <- msg:
+			default:
+				w.Log.Debugf("Dropping unexpected message from server: %q", msg)
+			}
+		}
+	}()
+
+	if w.PingInterval.Duration > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			ticker := time.NewTicker(w.PingInterval.Duration)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-c.stop:
+					return
+				case <-c.done:
+					return
+				case <-ticker.C:
+					if err := conn.WriteControl(ws.PingMessage, nil, w.writeDeadline()); err != nil {
+						w.Log.Debugf("Sending ping failed: %v", err)
+					}
+				}
+			}
+		}()
+	}
+
+	return c
+}
+
+// reconnect establishes a new connection unless the backoff of the last
+// failed attempt did not pass yet.
+func (w *WebSocket) reconnect() error {
+	if now := time.Now(); now.Before(w.nextRetry) {
+		return fmt.Errorf("not connected, next attempt in %s", w.nextRetry.Sub(now).Round(time.Second))
+	}
+
+	if err := w.Connect(); err != nil {
+		w.backoff *= 2
+		if w.backoff < minReconnectBackoff {
+			w.backoff = minReconnectBackoff
+		}
+		if w.backoff > w.MaxReconnectBackoff.Duration {
+			w.backoff = w.MaxReconnectBackoff.Duration
+		}
+		w.nextRetry = time.Now().Add(w.backoff)
+		return err
+	}
+
+	w.backoff = 0
+	w.nextRetry = time.Time{}
+	return nil
+}
+
+// Write writes the given metrics to the destination. Not thread-safe.
+func (w *WebSocket) Write(metrics []telegraf.Metric) error {
+	if w.conn != nil {
+		select {
+		case <-w.conn.done:
+			w.Log.Infof("Connection lost: %v", w.conn.err)
+			w.close()
+		default:
+		}
+	}
+	if w.conn == nil {
+		if err := w.reconnect(); err != nil {
+			return err
+		}
+	}
+
+	messageData, err := w.serializer.SerializeBatch(metrics)
+	if err != nil {
+		return err
+	}
+
+	// Discard acks not belonging to this write, for example sent late.
+	w.conn.drainAcks()
+
+	if err := w.conn.conn.SetWriteDeadline(w.writeDeadline()); err != nil {
+		w.close()
+		return fmt.Errorf("error setting write deadline: %v", err)
+	}
+	messageType := ws.BinaryMessage
+	if w.UseTextFrames {
+		messageType = ws.TextMessage
+	}
+	if err := w.conn.conn.WriteMessage(messageType, messageData); err != nil {
+		w.close()
+		return fmt.Errorf("error writing to connection: %v", err)
+	}
+
+	if w.WaitForAck {
+		if err := w.waitAck(); err != nil {
+			w.close()
+			return err
+		}
+	}
+	return nil
+}
+
+// waitAck waits for the next message of the server acknowledging the write.
+func (w *WebSocket) waitAck() error {
+	timer := time.NewTimer(w.AckTimeout.Duration)
+	defer timer.Stop()
+
+	select {
+	case msg := <-w.conn.acks:
+		if w.AckMessage != "" && !bytes.Equal(msg, []byte(w.AckMessage)) {
+			return fmt.Errorf("write not acknowledged by server: %q", msg)
+		}
+		return nil
+	case <-w.conn.done:
+		return fmt.Errorf("connection lost while waiting for ack: %v", w.conn.err)
+	case <-timer.C:
+		return fmt.Errorf("no ack received within %s", w.AckTimeout.Duration)
+	}
+}
+
+// writeDeadline returns the deadline of writes, zero if there is no timeout.
+func (w *WebSocket) writeDeadline() time.Time {
+	if w.WriteTimeout.Duration <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(w.WriteTimeout.Duration)
+}
+
+func (c *connection) drainAcks() {
+	for {
+		select {
+		case <-c.acks:
+		default:
+			return
+		}
+	}
+}
+
+// close closes the connection and waits for its goroutines to finish.
+func (w *WebSocket) close() error {
+	if w.conn == nil {
+		return nil
+	}
+	c := w.conn
+	w.conn = nil
+
+	close(c.stop)
+	c.conn.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseNormalClosure, ""), w.writeDeadline())
+	err := c.conn.Close()
+	c.wg.Wait()
+	return err
+}
+
+// Close closes the connection. Noop if already closed.
+func (w *WebSocket) Close() error {
+	err := w.close()
+	if err != nil {
+		return fmt.Errorf("error closing websocket connection: %v", err)
+	}
+	return nil
+}
+
+func newWebSocket() *WebSocket {
+	return &WebSocket{
+		ConnectTimeout:      internal.Duration{Duration: defaultConnectTimeout},
+		WriteTimeout:        internal.Duration{Duration: defaultWriteTimeout},
+		AckTimeout:          internal.Duration{Duration: defaultAckTimeout},
+		MaxReconnectBackoff: internal.Duration{Duration: defaultMaxReconnectBackoff},
+	}
+}
+
+func init() {
+	outputs.Add("websocket", func() telegraf.Output {
+		return newWebSocket()
+	})
+}
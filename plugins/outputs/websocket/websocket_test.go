@@ -0,0 +1,257 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+// testServer records the messages it receives and optionally replies to
+// each of them.
+type testServer struct {
+	*httptest.Server
+	t *testing.T
+
+	sync.Mutex
+	header   http.Header
+	messages []string
+	types    []int
+	reply    string
+	conns    []*ws.Conn
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{t: t}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *testServer) handle(w http.ResponseWriter, r *http.Request) {
+	upgrader := ws.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.Lock()
+	s.header = r.Header
+	s.conns = append(s.conns, conn)
+	s.Unlock()
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		s.Lock()
+		s.messages = append(s.messages, string(data))
+		s.types = append(s.types, messageType)
+		reply := s.reply
+		s.Unlock()
+		if reply != "" {
+			if err := conn.WriteMessage(ws.TextMessage, []byte(reply)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// dropConnections closes the connections to the clients.
+func (s *testServer) dropConnections() {
+	s.Lock()
+	defer s.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testServer) received() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func (s *testServer) wsURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func newTestWebSocket(url string) *WebSocket {
+	w := newWebSocket()
+	w.URL = url
+	w.Log = testutil.Logger{}
+	w.SetSerializer(influx.NewSerializer())
+	return w
+}
+
+func TestInit(t *testing.T) {
+	w := newTestWebSocket("wss://example.com/metrics")
+	require.NoError(t, w.Init())
+
+	w = newTestWebSocket("http://example.com/metrics")
+	require.Error(t, w.Init())
+
+	w = newTestWebSocket("ws://example.com/metrics")
+	w.MaxReconnectBackoff = internal.Duration{}
+	require.NoError(t, w.Init())
+	require.Equal(t, minReconnectBackoff, w.MaxReconnectBackoff.Duration)
+}
+
+func TestWrite(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	w := newTestWebSocket(s.wsURL())
+	w.UseTextFrames = true
+	w.Headers = map[string]string{"Authorization": "Bearer token"}
+	require.NoError(t, w.Init())
+	require.NoError(t, w.Connect())
+	defer w.Close()
+
+	require.NoError(t, w.Write(testutil.MockMetrics()))
+	require.Eventually(t, func() bool {
+		return len(s.received()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	s.Lock()
+	require.Equal(t, "Bearer token", s.header.Get("Authorization"))
+	require.Equal(t, []int{ws.TextMessage}, s.types)
+	s.Unlock()
+	require.Equal(t, []string{"test1,tag1=value1 value=1 1257894000000000000\n"}, s.received())
+}
+
+func TestWriteAck(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	s.reply = "ok"
+
+	w := newTestWebSocket(s.wsURL())
+	w.WaitForAck = true
+	w.AckMessage = "ok"
+	w.AckTimeout = internal.Duration{Duration: time.Second}
+	require.NoError(t, w.Init())
+	require.NoError(t, w.Connect())
+	defer w.Close()
+
+	require.NoError(t, w.Write(testutil.MockMetrics()))
+	require.Len(t, s.received(), 1)
+
+	// A different reply fails the write and drops the connection.
+	s.Lock()
+	s.reply = "error: storage full"
+	s.Unlock()
+	err := w.Write(testutil.MockMetrics())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "storage full")
+	require.Nil(t, w.conn)
+
+	// Without a reply the write times out.
+	s.Lock()
+	s.reply = ""
+	s.Unlock()
+	w.AckTimeout = internal.Duration{Duration: 50 * time.Millisecond}
+	err = w.Write(testutil.MockMetrics())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no ack received")
+}
+
+func TestReconnect(t *testing.T) {
+	s := newTestServer(t)
+
+	w := newTestWebSocket(s.wsURL())
+	require.NoError(t, w.Init())
+	require.NoError(t, w.Connect())
+	defer w.Close()
+
+	// A lost connection is detected and re-established on the next write.
+	s.dropConnections()
+	require.Eventually(t, func() bool {
+		select {
+		case <-w.conn.done:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, w.Write(testutil.MockMetrics()))
+	require.Eventually(t, func() bool {
+		return len(s.received()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// Failed attempts delay the next attempt.
+	s.Close()
+	s.dropConnections()
+	require.Eventually(t, func() bool {
+		select {
+		case <-w.conn.done:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+	err := w.Write(testutil.MockMetrics())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error dial")
+	require.Equal(t, minReconnectBackoff, w.backoff)
+
+	err = w.Write(testutil.MockMetrics())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "next attempt")
+
+	w.nextRetry = time.Time{}
+	require.Error(t, w.Write(testutil.MockMetrics()))
+	require.Equal(t, 2*minReconnectBackoff, w.backoff)
+}
+
+func TestPing(t *testing.T) {
+	pings := make(chan struct{}, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := ws.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetPingHandler(func(data string) error {
+			pings <- struct{}{}
+			return conn.WriteControl(ws.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+
+	w := newTestWebSocket("ws" + strings.TrimPrefix(ts.URL, "http"))
+	w.PingInterval = internal.Duration{Duration: 10 * time.Millisecond}
+	w.ReadTimeout = internal.Duration{Duration: time.Second}
+	require.NoError(t, w.Init())
+	require.NoError(t, w.Connect())
+	defer w.Close()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-pings:
+		case <-time.After(time.Second):
+			t.Fatal("no ping received")
+		}
+	}
+
+	// The pongs keep the connection alive beyond the read timeout.
+	select {
+	case <-w.conn.done:
+		t.Fatalf("connection lost: %v", w.conn.err)
+	default:
+	}
+}
@@ -0,0 +1,159 @@
+package zabbix
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// rule identifies a discovery rule of a host.
+type rule struct {
+	host string
+	key  string
+}
+
+// entity is a discovered tag combination, the macros are the tag values.
+type entity struct {
+	macros   map[string]string
+	lastSeen time.Time
+}
+
+// lld tracks the tag combinations of the discovery rules.  The discovery data
+// is sent when new combinations appear and repeated at the send interval,
+// combinations not seen within the clear interval are dropped.
+type lld struct {
+	sendInterval  time.Duration
+	clearInterval time.Duration
+
+	rules    map[rule]map[string]*entity
+	changed  bool
+	lastSent time.Time
+}
+
+func newLLD(sendInterval, clearInterval time.Duration) *lld {
+	return &lld{
+		sendInterval:  sendInterval,
+		clearInterval: clearInterval,
+		rules:         make(map[rule]map[string]*entity),
+	}
+}
+
+// add records the tag combination, key is the key of the discovery rule and
+// empty for metrics without tags.
+func (l *lld) add(host, key string, tags []*telegraf.Tag) {
+	if key == "" {
+		return
+	}
+
+	now := time.Now()
+	r := rule{host: host, key: key}
+	entities, ok := l.rules[r]
+	if !ok {
+		entities = make(map[string]*entity)
+		l.rules[r] = entities
+	}
+
+	values := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		values = append(values, tag.Value)
+	}
+	id := strings.Join(values, "\x00")
+	if e, ok := entities[id]; ok {
+		e.lastSeen = now
+		return
+	}
+
+	macros := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		macros[macro(tag.Key)] = tag.Value
+	}
+	entities[id] = &entity{macros: macros, lastSeen: now}
+	l.changed = true
+}
+
+// values returns the discovery data to send, nil if nothing changed and the
+// send interval did not pass yet.
+func (l *lld) values(now time.Time) []value {
+	l.expire(now)
+	if !l.changed && now.Sub(l.lastSent) < l.sendInterval {
+		return nil
+	}
+
+	keys := make(map[string]bool, len(l.rules))
+	byKey := make(map[string]rule, len(l.rules))
+	for r := range l.rules {
+		k := r.host + "\x00" + r.key
+		keys[k] = true
+		byKey[k] = r
+	}
+
+	values := make([]value, 0, len(l.rules))
+	for _, k := range sortedKeys(keys) {
+		r := byKey[k]
+		entities := l.rules[r]
+
+		ids := make(map[string]bool, len(entities))
+		for id := range entities {
+			ids[id] = true
+		}
+		data := make([]map[string]string, 0, len(entities))
+		for _, id := range sortedKeys(ids) {
+			data = append(data, entities[id].macros)
+		}
+		octets, _ := json.Marshal(data)
+
+		values = append(values, value{
+			Host:  r.host,
+			Key:   r.key,
+			Value: string(octets),
+			Clock: now.Unix(),
+			NS:    int64(now.Nanosecond()),
+		})
+	}
+	return values
+}
+
+// sent marks the discovery data as sent, rules without combinations were
+// reported empty and are dropped.
+func (l *lld) sent(now time.Time) {
+	l.changed = false
+	l.lastSent = now
+	for r, entities := range l.rules {
+		if len(entities) == 0 {
+			delete(l.rules, r)
+		}
+	}
+}
+
+// expire drops the combinations not seen within the clear interval.
+func (l *lld) expire(now time.Time) {
+	if l.clearInterval <= 0 {
+		return
+	}
+	for _, entities := range l.rules {
+		for id, e := range entities {
+			if now.Sub(e.lastSeen) >= l.clearInterval {
+				delete(entities, id)
+				l.changed = true
+			}
+		}
+	}
+}
+
+// macro returns the name of the LLD macro of the tag key, macros only allow
+// upper case letters, digits, underscores and dots.
+func macro(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	return "{#" + name + "}"
+}
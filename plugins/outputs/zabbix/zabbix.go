@@ -0,0 +1,339 @@
+package zabbix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+// header of the messages of the Zabbix protocol, followed by the protocol
+// flags and the 8 byte length of the data.
+const header = "ZBXD\x01"
+
+// maxResponseSize limits the size of the responses of the server.
+const maxResponseSize = 1 << 20
+
+var sampleConfig = `
+  ## Address of the Zabbix server or proxy receiving the values.
+  address = "localhost:10051"
+
+  ## Timeout for connecting to and communicating with the server.
+  # timeout = "5s"
+
+  ## Prefix added to all item keys.
+  # key_prefix = "telegraf."
+
+  ## Tag whose value is used as the Zabbix host, the hostname of the machine
+  ## running Telegraf is used if the tag is missing.
+  # host_tag = "host"
+
+  ## If true, the measurement name is not part of the item keys.
+  # skip_measurement_prefix = false
+
+  ## If true, send the values as an active agent using the "agent data"
+  ## request, otherwise as a sender using the "sender data" request.
+  # agent_active = false
+
+  ## Low-level discovery data is sent when new tag combinations appear and
+  ## repeated at this interval, so Zabbix keeps the discovered items.
+  # lld_send_interval = "10m"
+
+  ## Interval to forget the discovered tag combinations, combinations not seen
+  ## again are no longer sent and the items are eventually removed by Zabbix.
+  # lld_clear_interval = "1h"
+`
+
+type Zabbix struct {
+	Address               string            `toml:"address"`
+	Timeout               internal.Duration `toml:"timeout"`
+	KeyPrefix             string            `toml:"key_prefix"`
+	HostTag               string            `toml:"host_tag"`
+	SkipMeasurementPrefix bool              `toml:"skip_measurement_prefix"`
+	AgentActive           bool              `toml:"agent_active"`
+	LLDSendInterval       internal.Duration `toml:"lld_send_interval"`
+	LLDClearInterval      internal.Duration `toml:"lld_clear_interval"`
+	Log                   telegraf.Logger   `toml:"-"`
+
+	hostname string
+	lld      *lld
+}
+
+// value is a single item value of a request.
+type value struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+	NS    int64  `json:"ns"`
+}
+
+type request struct {
+	Request string  `json:"request"`
+	Data    []value `json:"data"`
+	Clock   int64   `json:"clock"`
+	NS      int64   `json:"ns"`
+}
+
+type response struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+func (z *Zabbix) Description() string {
+	return "Send metrics to Zabbix using the sender protocol"
+}
+
+func (z *Zabbix) SampleConfig() string {
+	return sampleConfig
+}
+
+func (z *Zabbix) Init() error {
+	if z.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	if _, _, err := net.SplitHostPort(z.Address); err != nil {
+		z.Address = net.JoinHostPort(z.Address, "10051")
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("getting hostname failed: %v", err)
+	}
+	z.hostname = hostname
+
+	z.lld = newLLD(z.LLDSendInterval.Duration, z.LLDClearInterval.Duration)
+	return nil
+}
+
+func (z *Zabbix) Connect() error {
+	return nil
+}
+
+func (z *Zabbix) Close() error {
+	return nil
+}
+
+func (z *Zabbix) Write(metrics []telegraf.Metric) error {
+	now := time.Now()
+
+	var values []value
+	for _, metric := range metrics {
+		host, tags := z.splitTags(metric)
+		z.lld.add(host, z.discoveryKey(metric.Name(), tags), tags)
+
+		for _, field := range metric.FieldList() {
+			v, ok := formatValue(field.Value)
+			if !ok {
+				z.Log.Debugf("Skipping field %q of %q with unsupported type %T", field.Key, metric.Name(), field.Value)
+				continue
+			}
+			values = append(values, value{
+				Host:  host,
+				Key:   z.itemKey(metric.Name(), field.Key, tags),
+				Value: v,
+				Clock: metric.Time().Unix(),
+				NS:    int64(metric.Time().Nanosecond()),
+			})
+		}
+	}
+
+	// The discovery data is sent first, so Zabbix can create the items before
+	// their values arrive.
+	if discovery := z.lld.values(now); len(discovery) > 0 {
+		if err := z.send(discovery, now); err != nil {
+			return fmt.Errorf("sending discovery data failed: %v", err)
+		}
+		z.lld.sent(now)
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	return z.send(values, now)
+}
+
+// splitTags returns the Zabbix host of the metric and the remaining tags,
+// sorted by key.
+func (z *Zabbix) splitTags(metric telegraf.Metric) (string, []*telegraf.Tag) {
+	host := z.hostname
+	tags := make([]*telegraf.Tag, 0, len(metric.TagList()))
+	for _, tag := range metric.TagList() {
+		if tag.Key == z.HostTag {
+			host = tag.Value
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return host, tags
+}
+
+// itemKey returns the key of the item of the field, the tag values are the
+// parameters of the key:  prefix.measurement.field[value1,value2]
+func (z *Zabbix) itemKey(measurement, field string, tags []*telegraf.Tag) string {
+	key := z.KeyPrefix
+	if !z.SkipMeasurementPrefix {
+		key += measurement + "."
+	}
+	key += field
+
+	if len(tags) == 0 {
+		return key
+	}
+	params := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		params = append(params, quoteParameter(tag.Value))
+	}
+	return key + "[" + strings.Join(params, ",") + "]"
+}
+
+// discoveryKey returns the key of the discovery rule of the measurement and
+// tag keys:  prefix.measurement.tag1.tag2
+func (z *Zabbix) discoveryKey(measurement string, tags []*telegraf.Tag) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(tags)+1)
+	parts = append(parts, measurement)
+	for _, tag := range tags {
+		parts = append(parts, tag.Key)
+	}
+	return z.KeyPrefix + strings.Join(parts, ".")
+}
+
+// quoteParameter quotes item key parameters containing special characters.
+func quoteParameter(s string) string {
+	if s == "" || !strings.ContainsAny(s, ",]\"[ ") {
+		return s
+	}
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
+
+func formatValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case string:
+		return v, true
+	case bool:
+		if v {
+			return "1", true
+		}
+		return "0", true
+	default:
+		return "", false
+	}
+}
+
+// send sends the values in a single request and checks the response.
+func (z *Zabbix) send(values []value, now time.Time) error {
+	req := request{
+		Request: "sender data",
+		Data:    values,
+		Clock:   now.Unix(),
+		NS:      int64(now.Nanosecond()),
+	}
+	if z.AgentActive {
+		req.Request = "agent data"
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", z.Address, z.Timeout.Duration)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if z.Timeout.Duration > 0 {
+		conn.SetDeadline(time.Now().Add(z.Timeout.Duration))
+	}
+
+	if _, err := conn.Write(encode(data)); err != nil {
+		return err
+	}
+
+	body, err := decode(conn)
+	if err != nil {
+		return fmt.Errorf("reading response failed: %v", err)
+	}
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("decoding response failed: %v", err)
+	}
+	if resp.Response != "success" {
+		return fmt.Errorf("server responded with %q: %s", resp.Response, resp.Info)
+	}
+
+	// Values of unknown items or hosts are counted as failed, but retrying
+	// would not help.
+	if !strings.Contains(resp.Info, "failed: 0;") {
+		z.Log.Debugf("Some values were not processed: %s", resp.Info)
+	}
+	return nil
+}
+
+// encode prepends the header and length to the data.
+func encode(data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	binary.Write(&buf, binary.LittleEndian, uint64(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// decode reads a message and returns its data.
+func decode(r io.Reader) ([]byte, error) {
+	head := make([]byte, len(header)+8)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	if string(head[:4]) != header[:4] {
+		return nil, fmt.Errorf("invalid header %q", head[:len(header)])
+	}
+	size := binary.LittleEndian.Uint64(head[len(header):])
+	if size > maxResponseSize {
+		return nil, fmt.Errorf("response of %d bytes too large", size)
+	}
+	return ioutil.ReadAll(io.LimitReader(r, int64(size)))
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	outputs.Add("zabbix", func() telegraf.Output {
+		return &Zabbix{
+			Address:          "localhost:10051",
+			Timeout:          internal.Duration{Duration: 5 * time.Second},
+			KeyPrefix:        "telegraf.",
+			HostTag:          "host",
+			LLDSendInterval:  internal.Duration{Duration: 10 * time.Minute},
+			LLDClearInterval: internal.Duration{Duration: time.Hour},
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package zabbix
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+// fakeServer answers the requests like a Zabbix server and records them.
+type fakeServer struct {
+	sync.Mutex
+	listener net.Listener
+	requests []request
+	response response
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeServer{
+		listener: listener,
+		response: response{Response: "success", Info: "processed: 1; failed: 0; total: 1; seconds spent: 0.000055"},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	data, err := decode(conn)
+	if err != nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return
+	}
+	s.requests = append(s.requests, req)
+	octets, _ := json.Marshal(s.response)
+	conn.Write(encode(octets))
+}
+
+func (s *fakeServer) received() []request {
+	s.Lock()
+	defer s.Unlock()
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
+
+func newTestZabbix(address string) *Zabbix {
+	return &Zabbix{
+		Address:          address,
+		Timeout:          internal.Duration{Duration: 5 * time.Second},
+		KeyPrefix:        "telegraf.",
+		HostTag:          "host",
+		LLDSendInterval:  internal.Duration{Duration: 10 * time.Minute},
+		LLDClearInterval: internal.Duration{Duration: time.Hour},
+		Log:              testutil.Logger{},
+	}
+}
+
+func testMetrics() []telegraf.Metric {
+	return []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "web01", "cpu": "cpu0"},
+			map[string]interface{}{"usage_idle": 98.5, "online": true},
+			time.Unix(1600000000, 500),
+		),
+		testutil.MustMetric(
+			"system",
+			map[string]string{"host": "web01"},
+			map[string]interface{}{"uptime": uint64(3600), "os": "linux"},
+			time.Unix(1600000000, 0),
+		),
+	}
+}
+
+func TestItemKey(t *testing.T) {
+	z := newTestZabbix("localhost")
+	require.NoError(t, z.Init())
+	require.Equal(t, "localhost:10051", z.Address)
+
+	tags := []*telegraf.Tag{{Key: "cpu", Value: "cpu0"}, {Key: "path", Value: "/mnt/a b"}}
+	require.Equal(t, `telegraf.disk.used[cpu0,"/mnt/a b"]`, z.itemKey("disk", "used", tags))
+	require.Equal(t, "telegraf.disk.cpu.path", z.discoveryKey("disk", tags))
+	require.Equal(t, "telegraf.disk.used", z.itemKey("disk", "used", nil))
+	require.Equal(t, "", z.discoveryKey("disk", nil))
+
+	z.SkipMeasurementPrefix = true
+	z.KeyPrefix = ""
+	require.Equal(t, "used[cpu0]", z.itemKey("disk", "used", tags[:1]))
+}
+
+func TestMacro(t *testing.T) {
+	require.Equal(t, "{#CPU}", macro("cpu"))
+	require.Equal(t, "{#DEVICE_NAME.X}", macro("device-name.x"))
+}
+
+func TestWrite(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.listener.Close()
+
+	z := newTestZabbix(server.listener.Addr().String())
+	require.NoError(t, z.Init())
+	require.NoError(t, z.Connect())
+	defer z.Close()
+
+	require.NoError(t, z.Write(testMetrics()))
+	requests := server.received()
+	require.Len(t, requests, 2)
+
+	// The discovery data is sent before the values.
+	require.Equal(t, "sender data", requests[0].Request)
+	require.Len(t, requests[0].Data, 1)
+	require.Equal(t, "web01", requests[0].Data[0].Host)
+	require.Equal(t, "telegraf.cpu.cpu", requests[0].Data[0].Key)
+	require.JSONEq(t, `[{"{#CPU}": "cpu0"}]`, requests[0].Data[0].Value)
+
+	require.Equal(t, []value{
+		{Host: "web01", Key: "telegraf.cpu.usage_idle[cpu0]", Value: "98.5", Clock: 1600000000, NS: 500},
+		{Host: "web01", Key: "telegraf.cpu.online[cpu0]", Value: "1", Clock: 1600000000, NS: 500},
+		{Host: "web01", Key: "telegraf.system.uptime", Value: "3600", Clock: 1600000000},
+		{Host: "web01", Key: "telegraf.system.os", Value: "linux", Clock: 1600000000},
+	}, requests[1].Data)
+
+	// Known combinations are not sent again until the send interval passed.
+	require.NoError(t, z.Write(testMetrics()))
+	require.Len(t, server.received(), 1)
+
+	z.lld.lastSent = time.Now().Add(-time.Hour)
+	require.NoError(t, z.Write(testMetrics()[1:]))
+	require.Len(t, server.received(), 2)
+
+	// New combinations are sent along with the known ones.
+	metric := testMetrics()[0]
+	metric.AddTag("cpu", "cpu1")
+	require.NoError(t, z.Write([]telegraf.Metric{metric}))
+	requests = server.received()
+	require.Len(t, requests, 2)
+	require.JSONEq(t, `[{"{#CPU}": "cpu0"}, {"{#CPU}": "cpu1"}]`, requests[0].Data[0].Value)
+}
+
+func TestWriteAgentActive(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.listener.Close()
+
+	z := newTestZabbix(server.listener.Addr().String())
+	z.AgentActive = true
+	require.NoError(t, z.Init())
+
+	require.NoError(t, z.Write(testMetrics()[1:]))
+	requests := server.received()
+	require.Len(t, requests, 1)
+	require.Equal(t, "agent data", requests[0].Request)
+}
+
+func TestWriteFailed(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.listener.Close()
+	server.response = response{Response: "failed", Info: "host is not allowed"}
+
+	z := newTestZabbix(server.listener.Addr().String())
+	require.NoError(t, z.Init())
+
+	err := z.Write(testMetrics()[1:])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "host is not allowed")
+}
+
+func TestLLDExpire(t *testing.T) {
+	l := newLLD(time.Hour, time.Minute)
+	l.add("web01", "telegraf.cpu.cpu", []*telegraf.Tag{{Key: "cpu", Value: "cpu0"}})
+	l.add("web01", "telegraf.cpu.cpu", []*telegraf.Tag{{Key: "cpu", Value: "cpu1"}})
+
+	now := time.Now()
+	require.Len(t, l.values(now), 1)
+	l.sent(now)
+	require.Nil(t, l.values(now))
+
+	// Combinations not seen again are removed, the empty rule is sent once.
+	l.rules[rule{host: "web01", key: "telegraf.cpu.cpu"}]["cpu1"].lastSeen = now.Add(-2 * time.Minute)
+	values := l.values(now)
+	require.Len(t, values, 1)
+	require.JSONEq(t, `[{"{#CPU}": "cpu0"}]`, values[0].Value)
+	l.sent(now)
+
+	values = l.values(now.Add(2 * time.Minute))
+	require.Len(t, values, 1)
+	require.JSONEq(t, `[]`, values[0].Value)
+	l.sent(now)
+	require.Empty(t, l.rules)
+}
+
+func TestDecode(t *testing.T) {
+	data, err := decode(bytes.NewReader(encode([]byte(`{"response":"success"}`))))
+	require.NoError(t, err)
+	require.Equal(t, `{"response":"success"}`, string(data))
+
+	_, err = decode(bytes.NewReader([]byte("HTTP/1.1 400 Bad Request\r\n")))
+	require.Error(t, err)
+}
@@ -2,8 +2,12 @@ package parsers
 
 import (
 	"fmt"
+	"time"
+
+	"github.com/influxdata/go-syslog/v2/nontransparent"
 
 	"github.com/influxdata/telegraf"
+	framing "github.com/influxdata/telegraf/internal/syslog"
 	"github.com/influxdata/telegraf/plugins/parsers/collectd"
 	"github.com/influxdata/telegraf/plugins/parsers/csv"
 	"github.com/influxdata/telegraf/plugins/parsers/dropwizard"
@@ -15,6 +19,7 @@ import (
 	"github.com/influxdata/telegraf/plugins/parsers/logfmt"
 	"github.com/influxdata/telegraf/plugins/parsers/nagios"
 	"github.com/influxdata/telegraf/plugins/parsers/prometheus"
+	"github.com/influxdata/telegraf/plugins/parsers/syslog"
 	"github.com/influxdata/telegraf/plugins/parsers/value"
 	"github.com/influxdata/telegraf/plugins/parsers/wavefront"
 	"github.com/influxdata/telegraf/plugins/parsers/xml"
@@ -154,6 +159,14 @@ type Config struct {
 
 	// XML configuration
 	XMLConfig []XMLConfig `toml:"xml"`
+
+	// Syslog configuration
+	SyslogFormat     string `toml:"syslog_format"`
+	SyslogFraming    string `toml:"syslog_framing"`
+	SyslogTrailer    string `toml:"syslog_trailer"`
+	SyslogBestEffort bool   `toml:"syslog_best_effort"`
+	SyslogSeparator  string `toml:"syslog_sdparam_separator"`
+	SyslogTimezone   string `toml:"syslog_timezone"`
 }
 
 type XMLConfig struct {
@@ -247,6 +260,16 @@ func NewParser(config *Config) (Parser, error) {
 		parser, err = NewPrometheusParser(config.DefaultTags)
 	case "xml":
 		parser, err = NewXMLParser(config.MetricName, config.DefaultTags, config.XMLConfig)
+	case "syslog":
+		parser, err = NewSyslogParser(
+			config.SyslogFormat,
+			config.SyslogFraming,
+			config.SyslogTrailer,
+			config.SyslogBestEffort,
+			config.SyslogSeparator,
+			config.SyslogTimezone,
+			config.DefaultTags,
+		)
 	default:
 		err = fmt.Errorf("Invalid data format: %s", config.DataFormat)
 	}
@@ -386,3 +409,42 @@ func NewXMLParser(metricName string, defaultTags map[string]string, xmlConfigs [
 		DefaultTags: defaultTags,
 	}, nil
 }
+
+func NewSyslogParser(
+	format string,
+	framingName string,
+	trailerName string,
+	bestEffort bool,
+	separator string,
+	timezone string,
+	defaultTags map[string]string,
+) (Parser, error) {
+	config := &syslog.Config{
+		Format:      format,
+		Framing:     framing.NonTransparent,
+		Trailer:     nontransparent.LF,
+		BestEffort:  bestEffort,
+		Separator:   separator,
+		DefaultTags: defaultTags,
+	}
+
+	if framingName != "" {
+		if err := config.Framing.UnmarshalText([]byte(framingName)); err != nil {
+			return nil, fmt.Errorf("invalid syslog framing %q", framingName)
+		}
+	}
+	if trailerName != "" {
+		if err := config.Trailer.UnmarshalText([]byte(trailerName)); err != nil {
+			return nil, fmt.Errorf("invalid syslog trailer %q", trailerName)
+		}
+	}
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, err
+		}
+		config.Location = loc
+	}
+
+	return syslog.NewParser(config)
+}
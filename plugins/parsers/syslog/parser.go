@@ -0,0 +1,274 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/influxdata/go-syslog/v2"
+	"github.com/influxdata/go-syslog/v2/nontransparent"
+	"github.com/influxdata/go-syslog/v2/rfc5424"
+
+	"github.com/influxdata/telegraf"
+	framing "github.com/influxdata/telegraf/internal/syslog"
+	"github.com/influxdata/telegraf/metric"
+)
+
+const measurement = "syslog"
+
+var (
+	ErrNoMetric = fmt.Errorf("no metric in line")
+)
+
+// Config contains the options of the syslog parser.
+type Config struct {
+	// Format is either "rfc5424" (default) or "rfc3164".
+	Format string
+	// Framing is the technique used to delimit messages within a buffer.
+	Framing framing.Framing
+	// Trailer delimits messages when using non-transparent framing.
+	Trailer nontransparent.TrailerType
+	// BestEffort allows partially valid messages to be returned.
+	BestEffort bool
+	// Separator is prepended to structured data parameter names.
+	Separator string
+	// Location is used for RFC3164 timestamps which carry no timezone.
+	Location *time.Location
+
+	DefaultTags map[string]string
+}
+
+// Parser decodes syslog messages into metrics using the same tags and fields
+// as the syslog input plugin.
+type Parser struct {
+	format      string
+	framing     framing.Framing
+	trailer     byte
+	bestEffort  bool
+	separator   string
+	location    *time.Location
+	defaultTags map[string]string
+
+	Now func() time.Time
+}
+
+// NewParser creates a parser from the given configuration.
+func NewParser(c *Config) (*Parser, error) {
+	p := &Parser{
+		format:      strings.ToLower(c.Format),
+		framing:     c.Framing,
+		bestEffort:  c.BestEffort,
+		separator:   c.Separator,
+		location:    c.Location,
+		defaultTags: c.DefaultTags,
+		Now:         time.Now,
+	}
+
+	switch p.format {
+	case "":
+		p.format = "rfc5424"
+	case "rfc5424", "rfc3164":
+	default:
+		return nil, fmt.Errorf("unknown syslog format %q", c.Format)
+	}
+
+	switch p.framing {
+	case framing.OctetCounting, framing.NonTransparent:
+	default:
+		return nil, fmt.Errorf("unknown syslog framing %q", c.Framing)
+	}
+
+	switch c.Trailer {
+	case nontransparent.LF:
+		p.trailer = '\n'
+	case nontransparent.NUL:
+		p.trailer = 0
+	default:
+		return nil, fmt.Errorf("unknown syslog trailer %q", c.Trailer)
+	}
+
+	if p.separator == "" {
+		p.separator = "_"
+	}
+	if p.location == nil {
+		p.location = time.Local
+	}
+
+	return p, nil
+}
+
+// Parse converts a buffer holding one or more framed syslog messages to
+// metrics.
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	frames, err := p.split(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]telegraf.Metric, 0, len(frames))
+	for _, frame := range frames {
+		msg, err := p.parseMessage(frame)
+		if err != nil && (msg == nil || !p.bestEffort) {
+			return nil, err
+		}
+
+		m, err := metric.New(measurement, Tags(msg), Fields(msg, p.separator), p.Now())
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	p.applyDefaultTags(metrics)
+	return metrics, nil
+}
+
+// ParseLine converts a single syslog message to a metric.
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, ErrNoMetric
+	}
+	return metrics[0], nil
+}
+
+// SetDefaultTags adds tags to the metrics outputs of Parse and ParseLine.
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.defaultTags = tags
+}
+
+func (p *Parser) applyDefaultTags(metrics []telegraf.Metric) {
+	if len(p.defaultTags) == 0 {
+		return
+	}
+
+	for _, m := range metrics {
+		for k, v := range p.defaultTags {
+			if !m.HasTag(k) {
+				m.AddTag(k, v)
+			}
+		}
+	}
+}
+
+func (p *Parser) parseMessage(frame []byte) (syslog.Message, error) {
+	if p.format == "rfc3164" {
+		return parseRFC3164(frame, p.Now(), p.location, p.bestEffort)
+	}
+
+	var machine syslog.Machine
+	if p.bestEffort {
+		machine = rfc5424.NewParser(rfc5424.WithBestEffort())
+	} else {
+		machine = rfc5424.NewParser()
+	}
+	return machine.Parse(frame)
+}
+
+// split cuts the buffer into single messages according to the framing.
+func (p *Parser) split(buf []byte) ([][]byte, error) {
+	var frames [][]byte
+	if p.framing == framing.NonTransparent {
+		for _, frame := range bytes.Split(buf, []byte{p.trailer}) {
+			frame = bytes.TrimRight(frame, "\r\n\x00")
+			if len(bytes.TrimSpace(frame)) == 0 {
+				continue
+			}
+			frames = append(frames, frame)
+		}
+		return frames, nil
+	}
+
+	for {
+		buf = bytes.TrimLeft(buf, " \r\n\x00")
+		if len(buf) == 0 {
+			return frames, nil
+		}
+
+		i := bytes.IndexByte(buf, ' ')
+		if i < 1 {
+			return nil, fmt.Errorf("missing message length in octet-counting frame")
+		}
+		length, err := strconv.Atoi(string(buf[:i]))
+		if err != nil || length < 1 {
+			return nil, fmt.Errorf("invalid message length %q in octet-counting frame", buf[:i])
+		}
+		buf = buf[i+1:]
+		if len(buf) < length {
+			return nil, fmt.Errorf("message length %d exceeds remaining %d bytes", length, len(buf))
+		}
+		frames = append(frames, buf[:length])
+		buf = buf[length:]
+	}
+}
+
+// Tags returns the tags for a syslog message.
+func Tags(msg syslog.Message) map[string]string {
+	ts := map[string]string{}
+
+	// Not checking assuming a minimally valid message
+	ts["severity"] = *msg.SeverityShortLevel()
+	ts["facility"] = *msg.FacilityLevel()
+
+	if msg.Hostname() != nil {
+		ts["hostname"] = *msg.Hostname()
+	}
+
+	if msg.Appname() != nil {
+		ts["appname"] = *msg.Appname()
+	}
+
+	return ts
+}
+
+// Fields returns the fields for a syslog message, structured data parameter
+// names are joined to their element ids using separator.
+func Fields(msg syslog.Message, separator string) map[string]interface{} {
+	// Not checking assuming a minimally valid message
+	flds := map[string]interface{}{}
+	if msg.Version() != 0 {
+		flds["version"] = msg.Version()
+	}
+	flds["severity_code"] = int(*msg.Severity())
+	flds["facility_code"] = int(*msg.Facility())
+
+	if msg.Timestamp() != nil {
+		flds["timestamp"] = (*msg.Timestamp()).UnixNano()
+	}
+
+	if msg.ProcID() != nil {
+		flds["procid"] = *msg.ProcID()
+	}
+
+	if msg.MsgID() != nil {
+		flds["msgid"] = *msg.MsgID()
+	}
+
+	if msg.Message() != nil {
+		flds["message"] = strings.TrimRightFunc(*msg.Message(), func(r rune) bool {
+			return unicode.IsSpace(r)
+		})
+	}
+
+	if msg.StructuredData() != nil {
+		for sdid, sdparams := range *msg.StructuredData() {
+			if len(sdparams) == 0 {
+				// When SD-ID does not have params we indicate its presence with a bool
+				flds[sdid] = true
+				continue
+			}
+			for name, value := range sdparams {
+				// Using whitespace as separator since it is not allowed by the grammar within SDID
+				flds[sdid+separator+name] = value
+			}
+		}
+	}
+
+	return flds
+}
@@ -0,0 +1,197 @@
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/go-syslog/v2/nontransparent"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	framing "github.com/influxdata/telegraf/internal/syslog"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func newTestParser(t *testing.T, c *Config) *Parser {
+	t.Helper()
+	p, err := NewParser(c)
+	require.NoError(t, err)
+	p.Now = func() time.Time { return time.Unix(1600000000, 0) }
+	return p
+}
+
+func TestParseRFC5424(t *testing.T) {
+	p := newTestParser(t, &Config{
+		Framing: framing.NonTransparent,
+		Trailer: nontransparent.LF,
+	})
+
+	buf := []byte(`<29>1 2016-02-21T04:32:57+00:00 web1 someservice 2341 2 [origin][meta sequence="14125553" service="someservice"] "GET /v1/ok HTTP/1.1" 200 145 "-" "hacheck 0.9.0" 24306 127.0.0.1:40124 575` + "\n")
+	metrics, err := p.Parse(buf)
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"syslog",
+			map[string]string{
+				"severity": "notice",
+				"facility": "daemon",
+				"hostname": "web1",
+				"appname":  "someservice",
+			},
+			map[string]interface{}{
+				"version":       uint16(1),
+				"timestamp":     time.Unix(1456029177, 0).UnixNano(),
+				"procid":        "2341",
+				"msgid":         "2",
+				"message":       `"GET /v1/ok HTTP/1.1" 200 145 "-" "hacheck 0.9.0" 24306 127.0.0.1:40124 575`,
+				"origin":        true,
+				"meta_sequence": "14125553",
+				"meta_service":  "someservice",
+				"severity_code": 5,
+				"facility_code": 3,
+			},
+			time.Unix(1600000000, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseRFC3164(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		bestEffort bool
+		expected   telegraf.Metric
+		wantErr    bool
+	}{
+		{
+			name: "complete message",
+			line: "<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8",
+			expected: testutil.MustMetric(
+				"syslog",
+				map[string]string{
+					"severity": "crit",
+					"facility": "auth",
+					"hostname": "mymachine",
+					"appname":  "su",
+				},
+				map[string]interface{}{
+					"timestamp":     time.Date(2020, 10, 11, 22, 14, 15, 0, time.UTC).UnixNano(),
+					"procid":        "123",
+					"message":       "'su root' failed for lonvick on /dev/pts/8",
+					"severity_code": 2,
+					"facility_code": 4,
+				},
+				time.Unix(1600000000, 0),
+			),
+		},
+		{
+			name: "timestamp from the previous year",
+			line: "<13>Dec 31 23:59:59 host cron: job done",
+			expected: testutil.MustMetric(
+				"syslog",
+				map[string]string{
+					"severity": "notice",
+					"facility": "user",
+					"hostname": "host",
+					"appname":  "cron",
+				},
+				map[string]interface{}{
+					"timestamp":     time.Date(2019, 12, 31, 23, 59, 59, 0, time.UTC).UnixNano(),
+					"message":       "job done",
+					"severity_code": 5,
+					"facility_code": 1,
+				},
+				time.Unix(1600000000, 0),
+			),
+		},
+		{
+			name: "rfc3339 timestamp",
+			line: "<14>2020-09-13T12:26:40.5Z host app: hello",
+			expected: testutil.MustMetric(
+				"syslog",
+				map[string]string{
+					"severity": "info",
+					"facility": "user",
+					"hostname": "host",
+					"appname":  "app",
+				},
+				map[string]interface{}{
+					"timestamp":     time.Date(2020, 9, 13, 12, 26, 40, 500000000, time.UTC).UnixNano(),
+					"message":       "hello",
+					"severity_code": 6,
+					"facility_code": 1,
+				},
+				time.Unix(1600000000, 0),
+			),
+		},
+		{
+			name:    "missing priority",
+			line:    "Oct 11 22:14:15 mymachine su: hello",
+			wantErr: true,
+		},
+		{
+			name:       "missing priority in best effort mode",
+			line:       "Oct 11 22:14:15 mymachine su: hello",
+			bestEffort: true,
+			expected: testutil.MustMetric(
+				"syslog",
+				map[string]string{
+					"severity": "notice",
+					"facility": "user",
+					"hostname": "mymachine",
+					"appname":  "su",
+				},
+				map[string]interface{}{
+					"timestamp":     time.Date(2020, 10, 11, 22, 14, 15, 0, time.UTC).UnixNano(),
+					"message":       "hello",
+					"severity_code": 5,
+					"facility_code": 1,
+				},
+				time.Unix(1600000000, 0),
+			),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser(t, &Config{
+				Format:     "rfc3164",
+				Framing:    framing.NonTransparent,
+				Trailer:    nontransparent.LF,
+				BestEffort: tt.bestEffort,
+				Location:   time.UTC,
+			})
+			m, err := p.ParseLine(tt.line)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			testutil.RequireMetricEqual(t, tt.expected, m)
+		})
+	}
+}
+
+func TestParseOctetCounting(t *testing.T) {
+	p := newTestParser(t, &Config{
+		Framing: framing.OctetCounting,
+		Trailer: nontransparent.LF,
+	})
+	p.SetDefaultTags(map[string]string{"source": "kafka"})
+
+	metrics, err := p.Parse([]byte("16 <1>1 - - - - - -17 <2>1 - - - - - - \n"))
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	require.Equal(t, "alert", metrics[0].Tags()["severity"])
+	require.Equal(t, "crit", metrics[1].Tags()["severity"])
+	require.Equal(t, "kafka", metrics[1].Tags()["source"])
+
+	_, err = p.Parse([]byte("99 <1>1 - - - - - -"))
+	require.Error(t, err)
+}
+
+func TestNewParserInvalidFormat(t *testing.T) {
+	_, err := NewParser(&Config{Format: "rfc1234", Trailer: nontransparent.LF})
+	require.Error(t, err)
+}
@@ -0,0 +1,142 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/go-syslog/v2"
+	"github.com/influxdata/go-syslog/v2/rfc5424"
+)
+
+// defaultPriority is assumed for messages without a PRI part as per
+// RFC3164#section-4.3.3 (user-level, notice).
+const defaultPriority = 13
+
+// rfc3164Message is a BSD syslog message.  The embedded RFC5424 message only
+// holds the priority so it can provide the facility and severity names.
+type rfc3164Message struct {
+	*rfc5424.SyslogMessage
+
+	timestamp *time.Time
+	hostname  *string
+	appname   *string
+	procid    *string
+	message   *string
+}
+
+func (m *rfc3164Message) Valid() bool                                   { return m.Priority() != nil }
+func (m *rfc3164Message) Version() uint16                               { return 0 }
+func (m *rfc3164Message) Timestamp() *time.Time                         { return m.timestamp }
+func (m *rfc3164Message) Hostname() *string                             { return m.hostname }
+func (m *rfc3164Message) Appname() *string                              { return m.appname }
+func (m *rfc3164Message) ProcID() *string                               { return m.procid }
+func (m *rfc3164Message) MsgID() *string                                { return nil }
+func (m *rfc3164Message) Message() *string                              { return m.message }
+func (m *rfc3164Message) StructuredData() *map[string]map[string]string { return nil }
+
+// parseRFC3164 parses a message of the form
+//
+//	<PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+//
+// RFC3339 timestamps, as sent by many modern daemons, are accepted as well.
+// In best effort mode a missing PRI, timestamp or hostname is not an error.
+func parseRFC3164(buf []byte, now time.Time, loc *time.Location, bestEffort bool) (syslog.Message, error) {
+	msg := &rfc3164Message{SyslogMessage: &rfc5424.SyslogMessage{}}
+
+	rest, pri, err := parsePriority(buf)
+	if err != nil {
+		if !bestEffort {
+			return nil, err
+		}
+		pri = defaultPriority
+	}
+	msg.SetPriority(pri)
+
+	ts, remainder, err := parseTimestamp(rest, now, loc)
+	if err != nil {
+		if !bestEffort {
+			return nil, err
+		}
+		msg.message = stringPtr(string(rest))
+		return msg, err
+	}
+	msg.timestamp = &ts
+	rest = remainder
+
+	// The hostname is only recognized when followed by another word, so
+	// that a bare message is not mistaken for one.
+	if i := bytes.IndexByte(rest, ' '); i > 0 && !isTag(rest[:i]) {
+		msg.hostname = stringPtr(string(rest[:i]))
+		rest = rest[i+1:]
+	} else if !bestEffort {
+		return nil, fmt.Errorf("expecting a hostname")
+	}
+
+	if i := bytes.IndexByte(rest, ' '); i > 0 && isTag(rest[:i]) {
+		tag := rest[:i-1]
+		if j := bytes.IndexByte(tag, '['); j > 0 && tag[len(tag)-1] == ']' {
+			msg.procid = stringPtr(string(tag[j+1 : len(tag)-1]))
+			tag = tag[:j]
+		}
+		msg.appname = stringPtr(string(tag))
+		rest = rest[i+1:]
+	}
+
+	if len(rest) > 0 {
+		msg.message = stringPtr(string(rest))
+	}
+
+	return msg, nil
+}
+
+func parsePriority(buf []byte) ([]byte, uint8, error) {
+	if len(buf) < 3 || buf[0] != '<' {
+		return buf, 0, fmt.Errorf("expecting a priority value within angle brackets")
+	}
+	end := bytes.IndexByte(buf, '>')
+	if end < 2 || end > 4 {
+		return buf, 0, fmt.Errorf("expecting a priority value within angle brackets")
+	}
+	pri, err := strconv.ParseUint(string(buf[1:end]), 10, 8)
+	if err != nil || pri > 191 {
+		return buf, 0, fmt.Errorf("expecting a priority value in the range 0-191")
+	}
+	return buf[end+1:], uint8(pri), nil
+}
+
+// parseTimestamp accepts either the BSD "Mmm dd hh:mm:ss" timestamp or an
+// RFC3339 one. BSD timestamps do not include a year; the one closest to now
+// is chosen.
+func parseTimestamp(buf []byte, now time.Time, loc *time.Location) (time.Time, []byte, error) {
+	const stamp = "Jan _2 15:04:05"
+	if len(buf) > len(stamp) && buf[len(stamp)] == ' ' {
+		if ts, err := time.ParseInLocation(stamp, string(buf[:len(stamp)]), loc); err == nil {
+			now = now.In(loc)
+			ts = ts.AddDate(now.Year(), 0, 0)
+			if ts.After(now.AddDate(0, 1, 0)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			return ts, buf[len(stamp)+1:], nil
+		}
+	}
+
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, string(buf[:i])); err == nil {
+			return ts, buf[i+1:], nil
+		}
+	}
+
+	return time.Time{}, buf, fmt.Errorf("expecting a timestamp")
+}
+
+// isTag reports whether the word is a TAG, that is it is terminated by a
+// colon.
+func isTag(word []byte) bool {
+	return len(word) > 1 && word[len(word)-1] == ':'
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
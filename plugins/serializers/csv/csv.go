@@ -0,0 +1,334 @@
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/influxdata/telegraf"
+)
+
+type HeaderMode string
+
+const (
+	// HeaderNone never writes a header row.
+	HeaderNone = HeaderMode("none")
+	// HeaderOnce writes the header row before the first row written to a
+	// destination, the columns of the header are kept for all further rows.
+	HeaderOnce = HeaderMode("once")
+	// HeaderBatch writes the header row at the start of every serialized
+	// batch.
+	HeaderBatch = HeaderMode("batch")
+)
+
+type Layout string
+
+const (
+	// LayoutUnion writes all metrics using the union of their columns, cells
+	// of missing tags and fields are left empty.
+	LayoutUnion = Layout("union")
+	// LayoutMeasurement tracks the columns separately for every measurement
+	// so each measurement forms its own table.
+	LayoutMeasurement = Layout("measurement")
+)
+
+const (
+	timestampColumn   = "timestamp"
+	measurementColumn = "measurement"
+)
+
+type columnKind int
+
+const (
+	kindTimestamp columnKind = iota
+	kindMeasurement
+	kindTag
+	kindField
+)
+
+type column struct {
+	kind columnKind
+	key  string
+}
+
+type Config struct {
+	Header          string
+	Columns         []string
+	TimestampFormat string
+	Separator       string
+	Layout          string
+}
+
+type Serializer struct {
+	header          HeaderMode
+	timestampFormat string
+	separator       rune
+	layout          Layout
+	order           map[string]int
+
+	// columns written in the last header, keyed by measurement when using
+	// the measurement layout.
+	current map[string][]column
+}
+
+// Clone returns a serializer with the same configuration, to serialize
+// metrics for another destination.
+func (s *Serializer) Clone() *Serializer {
+	return &Serializer{
+		header:          s.header,
+		timestampFormat: s.timestampFormat,
+		separator:       s.separator,
+		layout:          s.layout,
+		order:           s.order,
+		current:         make(map[string][]column),
+	}
+}
+
+// Reset forgets the columns written, the header is written again before the
+// next row.  Call it when the destination starts a new file.
+func (s *Serializer) Reset() {
+	s.current = make(map[string][]column)
+}
+
+func NewSerializer(config *Config) (*Serializer, error) {
+	s := &Serializer{
+		header:          HeaderMode(config.Header),
+		timestampFormat: config.TimestampFormat,
+		separator:       ',',
+		layout:          Layout(config.Layout),
+		order:           make(map[string]int, len(config.Columns)),
+		current:         make(map[string][]column),
+	}
+
+	switch s.header {
+	case "":
+		s.header = HeaderOnce
+	case HeaderNone, HeaderOnce, HeaderBatch:
+	default:
+		return nil, fmt.Errorf("unknown csv header mode: %s", config.Header)
+	}
+
+	switch s.layout {
+	case "":
+		s.layout = LayoutUnion
+	case LayoutUnion, LayoutMeasurement:
+	default:
+		return nil, fmt.Errorf("unknown csv layout: %s", config.Layout)
+	}
+
+	if s.timestampFormat == "" {
+		s.timestampFormat = "unix"
+	}
+
+	if config.Separator != "" {
+		r, size := utf8.DecodeRuneInString(config.Separator)
+		if size != len(config.Separator) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+			return nil, fmt.Errorf("invalid csv separator: %q", config.Separator)
+		}
+		s.separator = r
+	}
+
+	for i, name := range config.Columns {
+		s.order[name] = i
+	}
+
+	return s, nil
+}
+
+func (s *Serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	return s.SerializeBatch([]telegraf.Metric{metric})
+}
+
+func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = s.separator
+
+	keys, groups := s.group(metrics)
+	for _, key := range keys {
+		group := groups[key]
+		columns := s.columns(key, group)
+
+		if s.header == HeaderBatch || (s.header == HeaderOnce && !equalColumns(columns, s.current[key])) {
+			if err := w.Write(headerRow(columns)); err != nil {
+				return nil, err
+			}
+		}
+		s.current[key] = columns
+
+		for _, m := range group {
+			if err := w.Write(s.row(columns, m)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// group splits the metrics into the tables they are written to, keeping the
+// order in which the tables first occur.
+func (s *Serializer) group(metrics []telegraf.Metric) ([]string, map[string][]telegraf.Metric) {
+	if s.layout == LayoutUnion {
+		return []string{""}, map[string][]telegraf.Metric{"": metrics}
+	}
+
+	var keys []string
+	groups := make(map[string][]telegraf.Metric)
+	for _, m := range metrics {
+		if _, ok := groups[m.Name()]; !ok {
+			keys = append(keys, m.Name())
+		}
+		groups[m.Name()] = append(groups[m.Name()], m)
+	}
+	return keys, groups
+}
+
+// columns returns the union of the previously written columns and the
+// columns of the given metrics.  Once a header is written with
+// csv_header = "once" its columns are kept, tags and fields of other
+// columns are not written.
+func (s *Serializer) columns(key string, metrics []telegraf.Metric) []column {
+	if current, ok := s.current[key]; ok && s.header == HeaderOnce {
+		return current
+	}
+
+	seen := make(map[column]bool)
+	columns := []column{{kind: kindTimestamp}, {kind: kindMeasurement}}
+	add := func(c column) {
+		if !seen[c] {
+			seen[c] = true
+			columns = append(columns, c)
+		}
+	}
+
+	for _, c := range s.current[key] {
+		if c.kind == kindTag || c.kind == kindField {
+			add(c)
+		}
+	}
+	for _, m := range metrics {
+		for _, tag := range m.TagList() {
+			add(column{kind: kindTag, key: tag.Key})
+		}
+		for _, field := range m.FieldList() {
+			add(column{kind: kindField, key: field.Key})
+		}
+	}
+
+	sort.SliceStable(columns, func(i, j int) bool {
+		return s.less(columns[i], columns[j])
+	})
+	return columns
+}
+
+// less orders columns by their position in the configured column list, the
+// remaining columns follow with tags before fields, sorted by name.
+func (s *Serializer) less(a, b column) bool {
+	ia, oka := s.order[a.name()]
+	ib, okb := s.order[b.name()]
+	switch {
+	case oka && okb:
+		if ia != ib {
+			return ia < ib
+		}
+		return a.kind < b.kind
+	case oka != okb:
+		return oka
+	}
+
+	if a.kind != b.kind {
+		return a.kind < b.kind
+	}
+	return a.key < b.key
+}
+
+func (s *Serializer) row(columns []column, m telegraf.Metric) []string {
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		switch c.kind {
+		case kindTimestamp:
+			row[i] = s.formatTimestamp(m.Time())
+		case kindMeasurement:
+			row[i] = m.Name()
+		case kindTag:
+			row[i], _ = m.GetTag(c.key)
+		case kindField:
+			if v, ok := m.GetField(c.key); ok {
+				row[i] = formatValue(v)
+			}
+		}
+	}
+	return row
+}
+
+func (s *Serializer) formatTimestamp(t time.Time) string {
+	switch s.timestampFormat {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unix_ms":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	case "unix_us":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Microsecond), 10)
+	case "unix_ns":
+		return strconv.FormatInt(t.UnixNano(), 10)
+	default:
+		return t.UTC().Format(s.timestampFormat)
+	}
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (c column) name() string {
+	switch c.kind {
+	case kindTimestamp:
+		return timestampColumn
+	case kindMeasurement:
+		return measurementColumn
+	default:
+		return c.key
+	}
+}
+
+func headerRow(columns []column) []string {
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		row[i] = c.name()
+	}
+	return row
+}
+
+func equalColumns(a, b []column) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package csv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func testMetrics() []telegraf.Metric {
+	return []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": 42.5, "count": int64(3)},
+			time.Unix(1600000000, 0),
+		),
+		testutil.MustMetric(
+			"mem",
+			map[string]string{"host": "a", "region": "eu"},
+			map[string]interface{}{"used": uint64(1024), "comment": "a, b"},
+			time.Unix(1600000001, 0),
+		),
+	}
+}
+
+func TestSerializeBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected string
+	}{
+		{
+			name:   "union with default options",
+			config: Config{},
+			expected: "timestamp,measurement,host,region,comment,count,usage,used\n" +
+				"1600000000,cpu,a,,,3,42.5,\n" +
+				"1600000001,mem,a,eu,\"a, b\",,,1024\n",
+		},
+		{
+			name:   "measurement layout",
+			config: Config{Layout: "measurement"},
+			expected: "timestamp,measurement,host,count,usage\n" +
+				"1600000000,cpu,a,3,42.5\n" +
+				"timestamp,measurement,host,region,comment,used\n" +
+				"1600000001,mem,a,eu,\"a, b\",1024\n",
+		},
+		{
+			name: "column order, separator and timestamp format",
+			config: Config{
+				Header:          "none",
+				Columns:         []string{"usage", "timestamp", "host"},
+				Separator:       ";",
+				TimestampFormat: time.RFC3339,
+			},
+			expected: "42.5;2020-09-13T12:26:40Z;a;cpu;;;3;\n" +
+				";2020-09-13T12:26:41Z;a;mem;eu;a, b;;1024\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSerializer(&tt.config)
+			require.NoError(t, err)
+			actual, err := s.SerializeBatch(testMetrics())
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(actual))
+		})
+	}
+}
+
+func TestHeaderModes(t *testing.T) {
+	m := testMetrics()[0]
+
+	s, err := NewSerializer(&Config{Header: "once"})
+	require.NoError(t, err)
+	first, err := s.Serialize(m)
+	require.NoError(t, err)
+	second, err := s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, "timestamp,measurement,host,count,usage\n1600000000,cpu,a,3,42.5\n", string(first))
+	require.Equal(t, "1600000000,cpu,a,3,42.5\n", string(second))
+
+	// The columns of the header are kept, other tags and fields are not
+	// written.
+	third, err := s.Serialize(testMetrics()[1])
+	require.NoError(t, err)
+	require.Equal(t, "1600000001,mem,a,,\n", string(third))
+
+	// A clone and a reset serializer write the header again.
+	clone := s.Clone()
+	actual, err := clone.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, string(first), string(actual))
+	s.Reset()
+	actual, err = s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, string(first), string(actual))
+
+	s, err = NewSerializer(&Config{Header: "batch"})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		actual, err := s.SerializeBatch([]telegraf.Metric{m})
+		require.NoError(t, err)
+		require.Equal(t, "timestamp,measurement,host,count,usage\n1600000000,cpu,a,3,42.5\n", string(actual))
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	_, err := NewSerializer(&Config{Header: "always"})
+	require.Error(t, err)
+	_, err = NewSerializer(&Config{Layout: "narrow"})
+	require.Error(t, err)
+	_, err = NewSerializer(&Config{Separator: "ab"})
+	require.Error(t, err)
+}
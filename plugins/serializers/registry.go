@@ -6,6 +6,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/plugins/serializers/carbon2"
+	"github.com/influxdata/telegraf/plugins/serializers/csv"
 	"github.com/influxdata/telegraf/plugins/serializers/graphite"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/plugins/serializers/json"
@@ -14,6 +15,7 @@ import (
 	"github.com/influxdata/telegraf/plugins/serializers/prometheus"
 	"github.com/influxdata/telegraf/plugins/serializers/prometheusremotewrite"
 	"github.com/influxdata/telegraf/plugins/serializers/splunkmetric"
+	"github.com/influxdata/telegraf/plugins/serializers/template"
 	"github.com/influxdata/telegraf/plugins/serializers/wavefront"
 )
 
@@ -82,6 +84,9 @@ type Config struct {
 	// Templates same Template, but multiple
 	Templates []string `toml:"templates"`
 
+	// Template used to render a whole batch, only supports Template
+	TemplateBatch string `toml:"template_batch"`
+
 	// Timestamp units to use for JSON formatted output
 	TimestampUnits time.Duration `toml:"timestamp_units"`
 
@@ -108,6 +113,36 @@ type Config struct {
 	// Output string fields as metric labels; when false string fields are
 	// discarded.
 	PrometheusStringAsLabel bool `toml:"prometheus_string_as_label"`
+
+	// When to write the CSV header row; one of "none", "once" or "batch".
+	CSVHeader string `toml:"csv_header"`
+
+	// Preferred ordering of the CSV columns.
+	CSVColumns []string `toml:"csv_columns"`
+
+	// Timestamp format of the CSV timestamp column.
+	CSVTimestampFormat string `toml:"csv_timestamp_format"`
+
+	// Separator between CSV cells.
+	CSVSeparator string `toml:"csv_separator"`
+
+	// Handling of metrics with differing tags and fields; one of "union" or
+	// "measurement".
+	CSVLayout string `toml:"csv_layout"`
+}
+
+// StatefulSerializer is implemented by serializers whose output depends on
+// the data previously written to the destination, like the header of the
+// csv format.  Outputs writing to several destinations use a clone for each
+// of them and reset it when the destination starts a new file.
+type StatefulSerializer interface {
+	Serializer
+
+	// Clone returns a serializer with the same configuration and no state.
+	Clone() Serializer
+
+	// Reset clears the state as the destination is empty.
+	Reset()
 }
 
 // NewSerializer a Serializer interface based on the given config.
@@ -135,6 +170,10 @@ func NewSerializer(config *Config) (Serializer, error) {
 		serializer, err = NewPrometheusRemoteWriteSerializer(config)
 	case "msgpack":
 		serializer, err = NewMsgpackSerializer()
+	case "csv":
+		serializer, err = NewCSVSerializer(config)
+	case "template":
+		serializer, err = NewTemplateSerializer(config.Template, config.TemplateBatch)
 	default:
 		err = fmt.Errorf("Invalid data format: %s", config.DataFormat)
 	}
@@ -250,3 +289,30 @@ func NewGraphiteSerializer(prefix, template string, tagSupport bool, separator s
 func NewMsgpackSerializer() (Serializer, error) {
 	return msgpack.NewSerializer(), nil
 }
+
+func NewCSVSerializer(config *Config) (Serializer, error) {
+	s, err := csv.NewSerializer(&csv.Config{
+		Header:          config.CSVHeader,
+		Columns:         config.CSVColumns,
+		TimestampFormat: config.CSVTimestampFormat,
+		Separator:       config.CSVSeparator,
+		Layout:          config.CSVLayout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &csvSerializer{s}, nil
+}
+
+// csvSerializer implements StatefulSerializer for the csv serializer.
+type csvSerializer struct {
+	*csv.Serializer
+}
+
+func (s *csvSerializer) Clone() Serializer {
+	return &csvSerializer{s.Serializer.Clone()}
+}
+
+func NewTemplateSerializer(text string, batchText string) (Serializer, error) {
+	return template.NewSerializer(text, batchText)
+}
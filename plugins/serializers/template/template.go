@@ -0,0 +1,168 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Metric is the value passed to the templates for every metric.  Besides the
+// methods of telegraf.Metric it provides accessors returning empty values for
+// missing tags and fields, which keeps templates short.
+type Metric struct {
+	telegraf.Metric
+}
+
+// Tag returns the value of the tag or an empty string if it is missing.
+func (m Metric) Tag(key string) string {
+	v, _ := m.GetTag(key)
+	return v
+}
+
+// Field returns the value of the field or nil if it is missing.
+func (m Metric) Field(key string) interface{} {
+	v, _ := m.GetField(key)
+	return v
+}
+
+// Serializer renders metrics through Go text/templates.
+type Serializer struct {
+	metricTemplate *template.Template
+	batchTemplate  *template.Template
+}
+
+// NewSerializer parses the templates; batchText may be empty in which case
+// batches are rendered by executing the metric template for every metric.
+func NewSerializer(text string, batchText string) (*Serializer, error) {
+	if text == "" && batchText == "" {
+		return nil, fmt.Errorf("a template is required")
+	}
+
+	s := &Serializer{}
+	var err error
+	if text != "" {
+		s.metricTemplate, err = template.New("template").Funcs(funcMap).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template failed: %v", err)
+		}
+	}
+	if batchText != "" {
+		s.batchTemplate, err = template.New("template_batch").Funcs(funcMap).Parse(batchText)
+		if err != nil {
+			return nil, fmt.Errorf("parsing batch template failed: %v", err)
+		}
+	}
+	return s, nil
+}
+
+func (s *Serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	if s.metricTemplate == nil {
+		return s.SerializeBatch([]telegraf.Metric{metric})
+	}
+
+	var buf bytes.Buffer
+	if err := s.metricTemplate.Execute(&buf, Metric{metric}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	var buf bytes.Buffer
+	if s.batchTemplate == nil {
+		for _, metric := range metrics {
+			if err := s.metricTemplate.Execute(&buf, Metric{metric}); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	}
+
+	batch := make([]Metric, 0, len(metrics))
+	for _, metric := range metrics {
+		batch = append(batch, Metric{metric})
+	}
+	if err := s.batchTemplate.Execute(&buf, batch); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var funcMap = template.FuncMap{
+	"formatTime": formatTime,
+	"unix":       func(t time.Time) int64 { return t.Unix() },
+	"unixMilli":  func(t time.Time) int64 { return t.UnixNano() / int64(time.Millisecond) },
+	"unixNano":   func(t time.Time) int64 { return t.UnixNano() },
+	"sortedKeys": sortedKeys,
+	"join":       strings.Join,
+	"replace":    strings.ReplaceAll,
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"quote":      strconv.Quote,
+	"escape":     escape,
+	"json":       toJSON,
+}
+
+// formatTime formats the time in UTC using the given layout; the special
+// layouts "unix", "unix_ms", "unix_us" and "unix_ns" produce epoch times.
+func formatTime(layout string, t time.Time) string {
+	switch layout {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unix_ms":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	case "unix_us":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Microsecond), 10)
+	case "unix_ns":
+		return strconv.FormatInt(t.UnixNano(), 10)
+	default:
+		return t.UTC().Format(layout)
+	}
+}
+
+// sortedKeys returns the keys of a tag or field map in sorted order.
+func sortedKeys(m interface{}) ([]string, error) {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]interface{}:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	default:
+		return nil, fmt.Errorf("sortedKeys: unsupported type %T", m)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// escape prefixes every occurrence of the given characters, as well as the
+// backslash itself, with a backslash.
+func escape(chars string, s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\\' || strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
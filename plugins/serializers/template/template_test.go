@@ -0,0 +1,92 @@
+package template
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func testMetrics() []telegraf.Metric {
+	return []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "web 1", "cpu": "cpu0"},
+			map[string]interface{}{"usage_idle": 91.5},
+			time.Unix(1600000000, 0),
+		),
+		testutil.MustMetric(
+			"mem",
+			map[string]string{"host": "web 1"},
+			map[string]interface{}{"used": int64(42)},
+			time.Unix(1600000010, 0),
+		),
+	}
+}
+
+func TestSerialize(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "tags fields and time",
+			template: `{{ .Name }} {{ .Tag "host" | escape " " }} {{ .Field "usage_idle" }} {{ .Time | formatTime "2006-01-02T15:04:05Z07:00" }}` + "\n",
+			expected: "cpu web\\ 1 91.5 2020-09-13T12:26:40Z\n",
+		},
+		{
+			name:     "missing tag and field",
+			template: `{{ .Tag "region" }}|{{ with .Field "used" }}{{ . }}{{ else }}none{{ end }}` + "\n",
+			expected: "|none\n",
+		},
+		{
+			name:     "iterate sorted tags",
+			template: `{{ $m := . }}{{ range sortedKeys .Tags }}{{ . }}={{ $m.Tag . }};{{ end }}{{ unix .Time }}` + "\n",
+			expected: "cpu=cpu0;host=web 1;1600000000\n",
+		},
+		{
+			name:     "json helper",
+			template: `{{ json .Fields }}` + "\n",
+			expected: "{\"usage_idle\":91.5}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSerializer(tt.template, "")
+			require.NoError(t, err)
+			actual, err := s.Serialize(testMetrics()[0])
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(actual))
+		})
+	}
+}
+
+func TestSerializeBatch(t *testing.T) {
+	s, err := NewSerializer(`{{ .Name }}`+"\n", "")
+	require.NoError(t, err)
+	actual, err := s.SerializeBatch(testMetrics())
+	require.NoError(t, err)
+	require.Equal(t, "cpu\nmem\n", string(actual))
+
+	s, err = NewSerializer("", `BEGIN {{ len . }}{{ range . }}|{{ .Name }}@{{ unixMilli .Time }}{{ end }}`)
+	require.NoError(t, err)
+	actual, err = s.SerializeBatch(testMetrics())
+	require.NoError(t, err)
+	require.Equal(t, "BEGIN 2|cpu@1600000000000|mem@1600000010000", string(actual))
+
+	// Without a metric template single metrics are rendered as a batch.
+	actual, err = s.Serialize(testMetrics()[1])
+	require.NoError(t, err)
+	require.Equal(t, "BEGIN 1|mem@1600000010000", string(actual))
+}
+
+func TestInvalidTemplate(t *testing.T) {
+	_, err := NewSerializer("", "")
+	require.Error(t, err)
+	_, err = NewSerializer("{{ .Name ", "")
+	require.Error(t, err)
+}
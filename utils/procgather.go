@@ -9,35 +9,78 @@ arstercz<arstercz@gmail.com>
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
 	"time"
 	"runtime"
 	"sort"
 	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"github.com/shirou/gopsutil/process"
 )
 
+// options selects the stats gathered for a process.
+type options struct {
+	cpu, mem, io, limit, comm, all, simple bool
+	prefix                                 string
+	sleep                                  time.Duration
+}
+
 func main() {
 	// options
-	var cpu, mem, io, limit, comm, all, simple bool
+	var opts options
 	var pid int
-	var prefix string
-	var sleep time.Duration
-
-	flag.BoolVar(&cpu, "cpu", false, "whether gather process cpu usage")
-	flag.BoolVar(&mem, "mem", false, "whether gather process mem usage")
-	flag.BoolVar(&io, "io", false, "whether gather process io usage")
-	flag.BoolVar(&limit, "limit", false, "whether gather process limit usage")
-	flag.BoolVar(&comm, "comm", false, "whether gather process common usage")
-	flag.BoolVar(&all, "all", true, "whether gather process all usage")
-	flag.BoolVar(&simple, "simple", false, "whether gather simple result or not")
+	var serve bool
+	var listen string
+	var socketMode string
+	var socketGroup string
+
+	flag.BoolVar(&opts.cpu, "cpu", false, "whether gather process cpu usage")
+	flag.BoolVar(&opts.mem, "mem", false, "whether gather process mem usage")
+	flag.BoolVar(&opts.io, "io", false, "whether gather process io usage")
+	flag.BoolVar(&opts.limit, "limit", false, "whether gather process limit usage")
+	flag.BoolVar(&opts.comm, "comm", false, "whether gather process common usage")
+	flag.BoolVar(&opts.all, "all", true, "whether gather process all usage")
+	flag.BoolVar(&opts.simple, "simple", false, "whether gather simple result or not")
 	flag.IntVar(&pid, "pid", 0, "gather process info with process ID")
-	flag.StringVar(&prefix, "prefix", "", "add prefix string to the result fileds")
-	flag.DurationVar(&sleep, "sleep", 100 * time.Millisecond, "sleep time(Millisecond) between cpu usage")
+	flag.StringVar(&opts.prefix, "prefix", "", "add prefix string to the result fileds")
+	flag.DurationVar(&opts.sleep, "sleep", 100 * time.Millisecond, "sleep time(Millisecond) between cpu usage")
+	flag.BoolVar(&serve, "serve", false, "serve requests for many pids on stdin and stdout until stdin is closed")
+	flag.StringVar(&listen, "listen", "", "serve requests on the unix socket instead of stdin and stdout")
+	flag.StringVar(&socketMode, "socket-mode", "0600", "permissions of the unix socket in octal, use 0660 to allow the socket-group")
+	flag.StringVar(&socketGroup, "socket-group", "", "group name or id owning the unix socket")
 
 	flag.Parse()
 
+	if serve || listen != "" {
+		var err error
+		if listen != "" {
+			var mode uint64
+			mode, err = strconv.ParseUint(socketMode, 8, 32)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid socket mode %q\n", socketMode)
+				os.Exit(1)
+			}
+			err = serveSocket(listen, os.FileMode(mode), socketGroup)
+		} else {
+			err = serveRequests(os.Stdin, os.Stdout, newServer())
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "serve error: %v\n", err)
+			os.Exit(5)
+		}
+		return
+	}
+
 	if pid <= 0 {
 		fmt.Println("Invailid pid!")
 		os.Exit(1)
@@ -54,8 +97,8 @@ func main() {
 		os.Exit(3)
 	}
 
-	if cpu || mem || io || limit || comm {
-		all = false
+	if opts.cpu || opts.mem || opts.io || opts.limit || opts.comm {
+		opts.all = false
 	}
 
 	proc, err := process.NewProcess(int32(pid))
@@ -64,6 +107,14 @@ func main() {
 		os.Exit(4)
 	}
 
+	dumpFields(gather(proc, opts))
+}
+
+// gather returns the stats of the process.
+func gather(proc *process.Process, opts options) map[string]interface{} {
+	all, cpu, mem, io, limit, comm, simple := opts.all, opts.cpu, opts.mem, opts.io, opts.limit, opts.comm, opts.simple
+	sleep := opts.sleep
+	prefix := opts.prefix
 	if prefix != "" {
 		prefix = prefix + "_"
 	}
@@ -207,7 +258,7 @@ func main() {
 		}
 	}
 
-	dumpFields(fields)
+	return fields
 }
 
 func isSolaris() bool {
@@ -233,3 +284,190 @@ func dumpFields(fields map[string]interface{}) {
 		fmt.Println("  [note] empty result.")
 	}
 }
+
+// request asks for the stats of many processes, one JSON document per line.
+type request struct {
+	ID     uint64  `json:"id"`
+	PIDs   []int32 `json:"pids"`
+	Prefix string  `json:"prefix,omitempty"`
+}
+
+// response contains the fields of each process as JSON numbers, the types
+// tell if a field is an "int", "uint" or "float", as integral floats are
+// encoded without a fraction.
+type response struct {
+	ID     uint64                           `json:"id"`
+	Stats  map[int32]map[string]interface{} `json:"stats"`
+	Types  map[string]string                `json:"types,omitempty"`
+	Errors map[int32]string                 `json:"errors,omitempty"`
+	Error  string                           `json:"error,omitempty"`
+}
+
+// server keeps the processes between requests, so the cpu usage is
+// calculated since the previous request without sleeping.  Every client has
+// its own server, as the processes not requested are forgotten.
+type server struct {
+	mu    sync.Mutex
+	procs map[int32]*process.Process
+}
+
+func newServer() *server {
+	return &server{procs: make(map[int32]*process.Process)}
+}
+
+func (s *server) handle(req *request) *response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := &response{
+		ID:    req.ID,
+		Stats: make(map[int32]map[string]interface{}, len(req.PIDs)),
+		Types: make(map[string]string),
+	}
+	opts := options{all: true, prefix: req.Prefix}
+	prefix := ""
+	if req.Prefix != "" {
+		prefix = req.Prefix + "_"
+	}
+
+	procs := make(map[int32]*process.Process, len(req.PIDs))
+	for _, pid := range req.PIDs {
+		proc, ok := s.procs[pid]
+		if !ok {
+			var err error
+			proc, err = process.NewProcess(pid)
+			if err != nil {
+				if resp.Errors == nil {
+					resp.Errors = make(map[int32]string)
+				}
+				resp.Errors[pid] = err.Error()
+				continue
+			}
+		}
+		procs[pid] = proc
+
+		fields := gather(proc, opts)
+		// The cpu usage is calculated since the previous request, there is
+		// none for a new process.
+		if !ok {
+			delete(fields, prefix+"cpu_usage")
+		}
+		for k, v := range fields {
+			typ := valueType(v)
+			if typ == "" {
+				delete(fields, k)
+				continue
+			}
+			resp.Types[k] = typ
+		}
+		resp.Stats[pid] = fields
+	}
+
+	// Processes no longer requested are forgotten.
+	s.procs = procs
+	return resp
+}
+
+// valueType returns the type of a field in the response, values which can
+// not be encoded as JSON numbers are left out.
+func valueType(v interface{}) string {
+	switch v := v.(type) {
+	case int32, int64:
+		return "int"
+	case uint32, uint64:
+		return "uint"
+	case float32:
+		return floatType(float64(v))
+	case float64:
+		return floatType(v)
+	}
+	return ""
+}
+
+func floatType(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return ""
+	}
+	return "float"
+}
+
+// serveRequests answers the requests read from r until r is closed.
+func serveRequests(r io.Reader, w io.Writer, s *server) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(w)
+	for scanner.Scan() {
+		var req request
+		var resp *response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp = &response{ID: req.ID, Error: fmt.Sprintf("invalid request: %v", err)}
+		} else {
+			resp = s.handle(&req)
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// serveSocket serves the clients connecting to the unix socket.  The socket
+// is created in a private directory and moved into place after setting its
+// mode and group, so no other user can connect in between.
+func serveSocket(path string, mode os.FileMode, group string) error {
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".procgather")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "socket")
+	listener, err := net.Listen("unix", tmp)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	if group != "" {
+		gid, err := lookupGroup(group)
+		if err != nil {
+			return err
+		}
+		if err := os.Chown(tmp, -1, gid); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(tmp, mode); err != nil {
+		return err
+	}
+	os.Remove(path)
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := serveRequests(conn, conn, newServer()); err != nil {
+				fmt.Fprintf(os.Stderr, "client error: %v\n", err)
+			}
+		}()
+	}
+}
+
+// lookupGroup returns the id of a group given by name or id.
+func lookupGroup(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}